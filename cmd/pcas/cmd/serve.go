@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net"
@@ -12,11 +13,15 @@ import (
 	"google.golang.org/grpc"
 
 	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	"github.com/soaringjerry/pcas/internal/budget"
 	"github.com/soaringjerry/pcas/internal/bus"
 	"github.com/soaringjerry/pcas/internal/policy"
 	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/providers/middleware"
 	"github.com/soaringjerry/pcas/internal/providers/mock"
+	"github.com/soaringjerry/pcas/internal/providers/ollama"
 	"github.com/soaringjerry/pcas/internal/providers/openai"
+	"github.com/soaringjerry/pcas/internal/storage"
 	"github.com/soaringjerry/pcas/internal/storage/sqlite"
 )
 
@@ -65,11 +70,54 @@ func runServer() error {
 			}
 			providerMap[providerConfig.Name] = openai.NewProvider(apiKey)
 			log.Printf("Initialized provider: %s (type: %s)", providerConfig.Name, providerConfig.Type)
+		case "ollama":
+			host, _ := providerConfig.Config["host"].(string)
+			if host == "" {
+				host = os.Getenv("OLLAMA_HOST")
+			}
+			if host == "" {
+				host = "http://localhost:11434"
+			}
+			ollamaProvider := ollama.NewProvider(nil, host)
+			if model, ok := providerConfig.Config["model"].(string); ok && model != "" {
+				ollamaProvider.SetDefaultModel(model)
+			}
+			providerMap[providerConfig.Name] = ollamaProvider
+			log.Printf("Initialized provider: %s (type: %s, host: %s)", providerConfig.Name, providerConfig.Type, host)
 		default:
 			log.Printf("Unknown provider type: %s", providerConfig.Type)
 		}
 	}
 
+	// Wrap every provider in a circuit breaker and let the policy engine skip unhealthy ones
+	healthRegistry := middleware.NewHealthRegistry()
+	for _, providerConfig := range policyConfig.Providers {
+		provider, ok := providerMap[providerConfig.Name]
+		if !ok {
+			continue
+		}
+		breakerConfig := middleware.DefaultConfig()
+		if cbConfig := providerConfig.CircuitBreaker; cbConfig != nil {
+			if cbConfig.Disabled {
+				continue
+			}
+			if cbConfig.FailureThreshold > 0 {
+				breakerConfig.FailureThreshold = cbConfig.FailureThreshold
+			}
+			if cbConfig.OpenTimeout > 0 {
+				breakerConfig.OpenTimeout = cbConfig.OpenTimeout
+			}
+			if cbConfig.HalfOpenMaxRequests > 0 {
+				breakerConfig.HalfOpenMaxRequests = cbConfig.HalfOpenMaxRequests
+			}
+			if cbConfig.ErrorRateThreshold > 0 {
+				breakerConfig.ErrorRateThreshold = cbConfig.ErrorRateThreshold
+			}
+		}
+		providerMap[providerConfig.Name] = middleware.WrapWithCircuitBreaker(providerConfig.Name, provider, healthRegistry, breakerConfig)
+	}
+	policyEngine.SetHealthChecker(healthRegistry)
+
 	// Ensure data directory exists
 	if err := os.MkdirAll("data", 0755); err != nil {
 		return fmt.Errorf("failed to create data directory: %w", err)
@@ -116,6 +164,34 @@ func runServer() error {
 		busServer.SetEmbeddingProvider(embeddingProvider)
 	}
 
+	// Enable token accounting and budget enforcement
+	if usageStore, ok := localStorage.(storage.UsageStore); ok {
+		busServer.SetBudgetManager(budget.NewManager(policyConfig.Budgets, policyConfig.Providers, usageStore))
+		log.Printf("Token accounting enabled with %d budget(s)", len(policyConfig.Budgets))
+	}
+
+	// Background jobs run until shutdown
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
+	// Enable near-duplicate detection and memory consolidation
+	if policyConfig.Memory != nil && policyConfig.Memory.Deduplication != nil {
+		busServer.SetDeduplication(policyConfig.Memory.Deduplication)
+		busServer.StartConsolidation(backgroundCtx)
+		log.Printf("Memory deduplication enabled")
+	}
+
+	// Compact idle sessions into summary facts
+	if policyConfig.Memory != nil && policyConfig.Memory.Summarization != nil {
+		busServer.SetSummarization(policyConfig.Memory.Summarization)
+		busServer.StartSummarization(backgroundCtx)
+	}
+
+	// Remember published event IDs for as long as configured
+	if policyConfig.Publish != nil {
+		busServer.SetPublishing(policyConfig.Publish)
+	}
+
 	busv1.RegisterEventBusServiceServer(grpcServer, busServer)
 
 	log.Printf("PCAS server starting on %s...", listenAddr)
@@ -133,7 +209,20 @@ func runServer() error {
 		log.Println("Stopping gRPC server...")
 		grpcServer.GracefulStop()
 
+		// Stop the periodic jobs and wait for a running pass to return, since
+		// it may still store events and start their vectorization
+		log.Println("Stopping background jobs...")
+		stopBackground()
+		busServer.WaitForBackground()
+
+		// Cancel the providers still working on accepted events, so that a
+		// hung provider cannot hold up the shutdown
+		log.Println("Cancelling event processing...")
+		busServer.StopProcessing()
+
 		// NEW: Wait for all background tasks to complete
+		log.Println("Waiting for queued events to be processed...")
+		busServer.WaitForPublishing()
 		log.Println("Waiting for background vectorization to complete...")
 		busServer.WaitForVectorization()
 		log.Println("All background tasks finished.")
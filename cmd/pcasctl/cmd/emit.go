@@ -29,13 +29,20 @@ var (
 	traceID     string // Optional trace ID for correlation
 	userID      string // Optional user ID
 	sessionID   string // Optional session ID
+	ragDebug    bool   // Ask the server for a RAG trace of the event
+	emitAsync   bool          // Return once the event is queued
+	emitTimeout time.Duration // How long to wait for the responses
+	eventID     string        // Event ID, to retry an earlier emit
 )
 
 var emitCmd = &cobra.Command{
 	Use:   "emit",
 	Short: "Emit an event to the PCAS bus",
 	Long: `Emit an event to the PCAS bus for processing by the decision-making 
-engine. Events can trigger actions, update context, or initiate workflows.`,
+engine. Events can trigger actions, update context, or initiate workflows.
+
+By default emit waits for the responses to the event. With --async it returns
+as soon as the server has queued the event.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return emitEvent()
 	},
@@ -56,32 +63,13 @@ func emitEvent() error {
 	// Create the client
 	client := busv1.NewEventBusServiceClient(conn)
 	
-	// Generate a unique client ID
-	clientID := fmt.Sprintf("pcasctl-%s", uuid.New().String()[:8])
-	
-	// Start subscription in background goroutine
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	
-	// Channel to signal when subscription is ready
-	subReady := make(chan bool)
-	
-	// Start subscriber goroutine
-	go func() {
-		subscribeToEvents(ctx, client, clientID, subReady)
-	}()
-	
-	// Wait for subscription to be ready
-	select {
-	case <-subReady:
-		log.Println("Subscription established, emitting event...")
-	case <-time.After(2 * time.Second):
-		log.Println("Warning: Subscription setup timed out, continuing anyway...")
+	// Create event with user-provided values. Reusing the ID of an earlier
+	// emit retries it; the server does not process the same event twice.
+	if eventID == "" {
+		eventID = uuid.New().String()
 	}
-	
-	// Create event with user-provided values
 	event := &eventsv1.Event{
-		Id:          uuid.New().String(),
+		Id:          eventID,
 		Source:      eventSource,
 		Specversion: "1.0",
 		Type:        eventType,
@@ -110,6 +98,11 @@ func emitEvent() error {
 		event.SessionId = sessionID
 	}
 	
+	// Opt into a pcas.rag.trace.v1 event explaining the retrieval
+	if ragDebug {
+		event.Attributes = map[string]string{"rag_debug": "true"}
+	}
+	
 	// Parse and add data if provided
 	if eventData != "" {
 		var jsonData map[string]interface{}
@@ -132,73 +125,65 @@ func emitEvent() error {
 		event.Data = anyData
 	}
 	
-	// Send the event with extended timeout for RAG-enhanced processing
-	pubCtx, pubCancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer pubCancel()
+	// Without waiting, the event is processed in the background
+	if emitAsync {
+		pubCtx, pubCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer pubCancel()
+		
+		resp, err := client.Publish(pubCtx, event)
+		if err != nil {
+			return fmt.Errorf("failed to publish event: %v", err)
+		}
+		log.Printf("Event published: id=%s accepted=%v duplicate=%v", resp.EventId, resp.Accepted, resp.Duplicate)
+		if ragDebug {
+			log.Printf("Inspect the retrieval with: pcasctl rag explain %s", event.Id)
+		}
+		return nil
+	}
+	
+	// Wait for the responses correlated with the event
+	waitCtx, waitCancel := context.WithTimeout(context.Background(), emitTimeout)
+	defer waitCancel()
 	
-	resp, err := client.Publish(pubCtx, event)
+	log.Println("Emitting event and waiting for responses...")
+	resp, err := client.PublishAndWait(waitCtx, &busv1.PublishAndWaitRequest{Event: event})
 	if err != nil {
 		return fmt.Errorf("failed to publish event: %v", err)
 	}
 	
-	log.Printf("Event published successfully: %+v", resp)
-	
-	// Wait a bit to receive responses
-	log.Println("Waiting for responses...")
-	time.Sleep(3 * time.Second)
+	log.Printf("Event published: id=%s accepted=%v duplicate=%v", resp.EventId, resp.Accepted, resp.Duplicate)
+	if ragDebug {
+		log.Printf("Inspect the retrieval with: pcasctl rag explain %s", event.Id)
+	}
+	if !resp.Accepted {
+		log.Println("No rule routes this event type; it was stored without a response.")
+	}
+	for _, response := range resp.Responses {
+		printEvent(response)
+	}
 	
 	return nil
 }
 
-// subscribeToEvents handles the subscription stream
-func subscribeToEvents(ctx context.Context, client busv1.EventBusServiceClient, clientID string, ready chan<- bool) {
-	// Create subscription request
-	req := &busv1.SubscribeRequest{
-		ClientId: clientID,
-	}
-	
-	// Start subscription stream
-	stream, err := client.Subscribe(ctx, req)
-	if err != nil {
-		log.Printf("Failed to subscribe: %v", err)
-		close(ready)
-		return
-	}
-	
-	// Signal that subscription is ready
-	close(ready)
-	
-	// Receive events
-	for {
-		event, err := stream.Recv()
-		if err != nil {
-			if ctx.Err() != nil {
-				// Context was cancelled, normal shutdown
-				return
-			}
-			log.Printf("Stream error: %v", err)
-			return
-		}
-		
-		// Print received event
-		log.Printf("\n=== Received Event ===")
-		log.Printf("ID: %s", event.Id)
-		log.Printf("Type: %s", event.Type)
-		log.Printf("Source: %s", event.Source)
-		log.Printf("Subject: %s", event.Subject)
-		
-		// Extract and print data if present
-		if event.Data != nil {
-			value := &structpb.Value{}
-			if event.Data.MessageIs(value) {
-				if err := event.Data.UnmarshalTo(value); err == nil {
-					jsonBytes, _ := json.MarshalIndent(value.AsInterface(), "", "  ")
-					log.Printf("Data: %s", string(jsonBytes))
-				}
+// printEvent logs an event received from the server
+func printEvent(event *eventsv1.Event) {
+	log.Printf("\n=== Received Event ===")
+	log.Printf("ID: %s", event.Id)
+	log.Printf("Type: %s", event.Type)
+	log.Printf("Source: %s", event.Source)
+	log.Printf("Subject: %s", event.Subject)
+	
+	// Extract and print data if present
+	if event.Data != nil {
+		value := &structpb.Value{}
+		if event.Data.MessageIs(value) {
+			if err := event.Data.UnmarshalTo(value); err == nil {
+				jsonBytes, _ := json.MarshalIndent(value.AsInterface(), "", "  ")
+				log.Printf("Data: %s", string(jsonBytes))
 			}
 		}
-		log.Printf("====================\n")
 	}
+	log.Printf("====================\n")
 }
 
 func init() {
@@ -214,6 +199,10 @@ func init() {
 	emitCmd.Flags().StringVar(&traceID, "trace-id", "", "Trace ID for correlation (optional, auto-generated if not provided)")
 	emitCmd.Flags().StringVar(&userID, "user-id", "", "User ID for event context (optional)")
 	emitCmd.Flags().StringVar(&sessionID, "session-id", "", "Session ID for event grouping (optional)")
+	emitCmd.Flags().BoolVar(&ragDebug, "rag-debug", false, "Record a RAG trace for the event (see pcasctl rag explain)")
+	emitCmd.Flags().BoolVar(&emitAsync, "async", false, "Return once the event is queued instead of waiting for the responses")
+	emitCmd.Flags().StringVar(&eventID, "id", "", "Event ID (optional, auto-generated if not provided); reuse one to retry an emit")
+	emitCmd.Flags().DurationVar(&emitTimeout, "timeout", 60*time.Second, "How long to wait for the responses")
 	
 	// Mark type as required
 	emitCmd.MarkFlagRequired("type")
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	_ "modernc.org/sqlite"
+)
+
+// ragTraceEventType is the type of the events that explain a retrieval
+const ragTraceEventType = "pcas.rag.trace.v1"
+
+var (
+	ragDBPath   string
+	ragShowJSON bool
+)
+
+var ragCmd = &cobra.Command{
+	Use:   "rag",
+	Short: "Inspect retrieval-augmented generation",
+}
+
+var ragExplainCmd = &cobra.Command{
+	Use:   "explain [event-id]",
+	Short: "Explain how the context of an event's prompt was retrieved",
+	Long: `Explain shows the RAG trace of an event: the generated and rewritten
+queries, the search filter, every candidate with its raw score and why it was
+kept or dropped, and the final prompt sent to the provider.
+
+Traces are only recorded for events published with the rag_debug attribute
+set to "true", for example with pcasctl emit --rag-debug.
+
+Examples:
+  pcasctl rag explain 3f2c9a1e-8b7d-4c55-9e0f-2a1b3c4d5e6f
+  pcasctl rag explain 3f2c9a1e-8b7d-4c55-9e0f-2a1b3c4d5e6f --json`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return explainRAG(args[0])
+	},
+}
+
+func explainRAG(eventID string) error {
+	db, err := sql.Open("sqlite", ragDBPath)
+	if err != nil {
+		return fmt.Errorf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// The trace is correlated with the event it explains; the latest one wins
+	query := `SELECT content FROM nodes
+			  WHERE type = 'event'
+			    AND json_extract(content, '$.type') = ?
+			    AND json_extract(content, '$.correlation_id') = ?
+			  ORDER BY created_at DESC, rowid DESC LIMIT 1`
+	var content string
+	err = db.QueryRow(query, ragTraceEventType, eventID).Scan(&content)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no RAG trace found for event %s (was it published with rag_debug=true?)", eventID)
+		}
+		return fmt.Errorf("failed to query RAG trace: %v", err)
+	}
+
+	var traceEvent struct {
+		ID   string                 `json:"id"`
+		Time string                 `json:"time"`
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal([]byte(content), &traceEvent); err != nil {
+		return fmt.Errorf("failed to parse RAG trace: %v", err)
+	}
+
+	if ragShowJSON {
+		dataJSON, err := json.MarshalIndent(traceEvent.Data, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to format RAG trace: %v", err)
+		}
+		fmt.Println(string(dataJSON))
+		return nil
+	}
+
+	printRAGTrace(eventID, traceEvent.ID, traceEvent.Time, traceEvent.Data)
+	return nil
+}
+
+// printRAGTrace renders a trace for humans
+func printRAGTrace(eventID, traceID, traceTime string, data map[string]interface{}) {
+	fmt.Printf("RAG trace %s for event %s (%s)\n\n", traceID, eventID, traceTime)
+
+	applied, _ := data["rag_applied"].(bool)
+	fmt.Printf("Applied: %v", applied)
+	if reason, ok := data["reason"].(string); ok {
+		fmt.Printf(" (%s)", reason)
+	}
+	fmt.Println()
+
+	fmt.Printf("Query: %v\n", data["query"])
+	if queries, ok := data["queries"].([]interface{}); ok && len(queries) > 1 {
+		fmt.Println("Rewritten queries:")
+		for _, q := range queries {
+			fmt.Printf("  - %v\n", q)
+		}
+	}
+	if filter, ok := data["filter"].(map[string]interface{}); ok {
+		filterJSON, _ := json.Marshal(filter)
+		fmt.Printf("Filter: %s\n", filterJSON)
+	}
+	fmt.Printf("Top K: %v, score threshold: %v\n\n", data["top_k"], data["score_threshold"])
+
+	candidates, _ := data["candidates"].([]interface{})
+	fmt.Printf("Candidates (%d):\n", len(candidates))
+	for i, item := range candidates {
+		candidate, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		fmt.Printf("%3d. %-40v score %.3f", i+1, candidate["event_id"], candidate["score"])
+		if ranked, ok := candidate["ranked_score"].(float64); ok {
+			fmt.Printf("  ranked %.3f", ranked)
+		}
+		if chunk, ok := candidate["chunk_index"].(float64); ok {
+			fmt.Printf("  chunk %d", int(chunk))
+		}
+		fmt.Printf("  %v\n", candidate["decision"])
+	}
+
+	if contextData, ok := data["context"].(map[string]interface{}); ok {
+		fmt.Printf("\nContext: %v of %v tokens", contextData["context_tokens"], contextData["max_tokens"])
+		if truncated, _ := contextData["truncated"].(bool); truncated {
+			fmt.Printf(", truncated at rank %v", contextData["truncated_at_rank"])
+		}
+		fmt.Println()
+		if sources, ok := contextData["sources"].([]interface{}); ok {
+			for _, item := range sources {
+				if source, ok := item.(map[string]interface{}); ok {
+					fmt.Printf("  #%v %-12v %v (%v tokens)\n", source["rank"], source["section"], source["event_id"], source["tokens"])
+				}
+			}
+		}
+	}
+
+	fmt.Println("\nFinal prompt:")
+	if messages, ok := data["messages"].([]interface{}); ok {
+		for _, item := range messages {
+			if message, ok := item.(map[string]interface{}); ok {
+				fmt.Printf("--- %v ---\n%v\n", strings.ToUpper(fmt.Sprint(message["role"])), message["content"])
+			}
+		}
+	} else if prompt, ok := data["prompt"].(string); ok {
+		fmt.Println(prompt)
+	} else {
+		fmt.Println("(none)")
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(ragCmd)
+	ragCmd.AddCommand(ragExplainCmd)
+
+	ragExplainCmd.Flags().StringVar(&ragDBPath, "db", "pcas.db", "Path to the PCAS database")
+	ragExplainCmd.Flags().BoolVar(&ragShowJSON, "json", false, "Print the raw trace as JSON")
+}
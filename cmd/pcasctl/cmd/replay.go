@@ -2,22 +2,19 @@ package cmd
 
 import (
 	"context"
-	"database/sql"
-	"encoding/json"
 	"fmt"
 	"log"
 	"time"
 
-	_ "modernc.org/sqlite"
+	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/protobuf/types/known/anypb"
-	"google.golang.org/protobuf/types/known/structpb"
-	"google.golang.org/protobuf/types/known/timestamppb"
+	"google.golang.org/protobuf/proto"
 	
 	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
 )
 
 var (
@@ -31,7 +28,8 @@ var replayCmd = &cobra.Command{
 	Short: "Replay an event from the database",
 	Long: `Replay retrieves a historical event from the database and re-publishes it 
 to the event bus. This is useful for testing, debugging, and understanding 
-the causality chain of events.`,
+the causality chain of events. The replayed event gets a new ID, so the server
+processes it again, and carries the original ID as its correlation ID.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		eventID := args[0]
@@ -40,62 +38,23 @@ the causality chain of events.`,
 }
 
 func replayEvent(eventID string) error {
-	// Connect to SQLite database
-	db, err := sql.Open("sqlite", replayDBPath)
+	// Read the event through the storage layer, which keeps it in the nodes table
+	store, err := sqlite.NewProvider(replayDBPath)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %v", err)
 	}
-	defer db.Close()
-
-	// Query the event from database
-	var id, eventType, source, subject, specversion string
-	var traceID, correlationID sql.NullString
-	var eventTime time.Time
-	var dataJSON sql.NullString
-
-	query := `SELECT id, type, source, subject, specversion, time, data, trace_id, correlation_id 
-			  FROM events WHERE id = ?`
-	err = db.QueryRow(query, eventID).Scan(
-		&id, &eventType, &source, &subject, &specversion,
-		&eventTime, &dataJSON, &traceID, &correlationID,
-	)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("event not found: %s", eventID)
-		}
-		return fmt.Errorf("failed to query event: %v", err)
-	}
-
-	// Reconstruct the event
-	event := &eventsv1.Event{
-		Id:          id,
-		Type:        eventType,
-		Source:      source,
-		Subject:     subject,
-		Specversion: specversion,
-		Time:        timestamppb.New(eventTime),
-	}
-
-	// Set trace ID if present
-	if traceID.Valid {
-		event.TraceId = traceID.String
-	}
+	defer store.Close()
 
-	// Set correlation ID if present
-	if correlationID.Valid {
-		event.CorrelationId = correlationID.String
+	original, err := store.GetEventByID(context.Background(), eventID)
+	if err != nil {
+		return fmt.Errorf("failed to read event: %v", err)
 	}
 
-	// Reconstruct data if present
-	if dataJSON.Valid && dataJSON.String != "" {
-		var data interface{}
-		if err := json.Unmarshal([]byte(dataJSON.String), &data); err == nil {
-			value, err := structpb.NewValue(data)
-			if err == nil {
-				event.Data, _ = anypb.New(value)
-			}
-		}
-	}
+	// Republish the event under a new ID, since the server ignores an ID it
+	// has already seen, and correlate it with the original
+	event := proto.Clone(original).(*eventsv1.Event)
+	event.Id = uuid.New().String()
+	event.CorrelationId = original.Id
 
 	// Connect to PCAS server
 	if replayServerAddr == "" {
@@ -120,8 +79,13 @@ func replayEvent(eventID string) error {
 		return fmt.Errorf("failed to publish replayed event: %v", err)
 	}
 
+	if resp.Duplicate {
+		return fmt.Errorf("server treated replayed event %s as a duplicate and did not process it", event.Id)
+	}
+
 	log.Printf("Event replayed successfully:")
 	log.Printf("  ID: %s", event.Id)
+	log.Printf("  Original ID: %s", original.Id)
 	log.Printf("  Type: %s", event.Type)
 	log.Printf("  Source: %s", event.Source)
 	if event.TraceId != "" {
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+// recordingBus keeps the events published to it and reports them as duplicates
+// if told to
+type recordingBus struct {
+	busv1.UnimplementedEventBusServiceServer
+	mu        sync.Mutex
+	events    []*eventsv1.Event
+	duplicate bool
+}
+
+func (b *recordingBus) Publish(ctx context.Context, event *eventsv1.Event) (*busv1.PublishResponse, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.events = append(b.events, event)
+	return &busv1.PublishResponse{EventId: event.Id, Accepted: true, Duplicate: b.duplicate}, nil
+}
+
+func TestReplayEvent(t *testing.T) {
+	// A database written by the server
+	dbPath := filepath.Join(t.TempDir(), "pcas.db")
+	store, err := sqlite.NewProvider(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	original := &eventsv1.Event{
+		Id:          "original",
+		Type:        "pcas.user.prompt.v1",
+		Source:      "test",
+		Specversion: "1.0",
+		Subject:     "What is on my calendar?",
+		TraceId:     "trace-1",
+		UserId:      "user-1",
+		SessionId:   "session-1",
+	}
+	if err := store.StoreEvent(context.Background(), original, nil); err != nil {
+		t.Fatalf("Failed to store event: %v", err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatalf("Failed to close storage: %v", err)
+	}
+
+	bus := &recordingBus{}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	busv1.RegisterEventBusServiceServer(server, bus)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	replayDBPath = dbPath
+	replayServerAddr = listener.Addr().String()
+	defer func() { replayDBPath, replayServerAddr = "pcas.db", "" }()
+
+	// The event is republished under a new ID, correlated with the original
+	if err := replayEvent("original"); err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	if len(bus.events) != 1 {
+		t.Fatalf("Expected one published event, got %d", len(bus.events))
+	}
+	replayed := bus.events[0]
+	if replayed.Id == "" || replayed.Id == "original" || replayed.CorrelationId != "original" {
+		t.Errorf("Expected a new ID correlated with the original, got ID %q and correlation %q", replayed.Id, replayed.CorrelationId)
+	}
+	if replayed.Type != original.Type || replayed.Subject != original.Subject || replayed.TraceId != original.TraceId ||
+		replayed.UserId != original.UserId || replayed.SessionId != original.SessionId {
+		t.Errorf("Expected the original event's content, got %v", replayed)
+	}
+
+	// A replay the server ignores as a duplicate is reported
+	bus.mu.Lock()
+	bus.duplicate = true
+	bus.mu.Unlock()
+	if err := replayEvent("original"); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("Expected the duplicate to be reported, got %v", err)
+	}
+
+	if err := replayEvent("missing"); err == nil {
+		t.Error("Expected an error for a missing event")
+	}
+}
@@ -14,8 +14,12 @@ import (
 )
 
 var (
-	topK int
-	searchUserID string
+	topK             int
+	searchUserID     string
+	recencyWeight    float32
+	halfLifeHours    float32
+	importanceWeight float32
+	mmrLambda        float32
 )
 
 // searchCmd represents the search command
@@ -27,7 +31,9 @@ var searchCmd = &cobra.Command{
 Examples:
   pcasctl search "user login errors"
   pcasctl search "discussions about architecture" --top-k 10
-  pcasctl search "recent deployments"`,
+  pcasctl search "recent deployments"
+  pcasctl search "trip plans" --recency-weight 0.3 --half-life-hours 72
+  pcasctl search "project notes" --importance-weight 0.2 --mmr-lambda 0.7`,
 	Args: cobra.ExactArgs(1),
 	RunE: runSearch,
 }
@@ -38,6 +44,10 @@ func init() {
 	searchCmd.Flags().StringVar(&serverPort, "port", "50051", "PCAS server port")
 	searchCmd.Flags().StringVar(&serverAddr, "server", "", "PCAS server address (overrides --port)")
 	searchCmd.Flags().StringVar(&searchUserID, "user-id", "", "User ID to filter results by (optional)")
+	searchCmd.Flags().Float32Var(&recencyWeight, "recency-weight", 0, "Weight of the time decay when ranking results (0 to 1)")
+	searchCmd.Flags().Float32Var(&halfLifeHours, "half-life-hours", 0, "Age in hours at which the recency signal halves (default 168)")
+	searchCmd.Flags().Float32Var(&importanceWeight, "importance-weight", 0, "Weight of the importance attribute and user feedback (0 to 1)")
+	searchCmd.Flags().Float32Var(&mmrLambda, "mmr-lambda", 0, "Relevance vs. diversity trade-off for MMR re-ranking (0 disables)")
 }
 
 func runSearch(cmd *cobra.Command, args []string) error {
@@ -62,9 +72,13 @@ func runSearch(cmd *cobra.Command, args []string) error {
 
 	// Create search request
 	req := &busv1.SearchRequest{
-		QueryText: queryText,
-		TopK:      int32(topK),
-		UserId:    searchUserID,
+		QueryText:            queryText,
+		TopK:                 int32(topK),
+		UserId:               searchUserID,
+		RecencyWeight:        recencyWeight,
+		RecencyHalfLifeHours: halfLifeHours,
+		ImportanceWeight:     importanceWeight,
+		MmrLambda:            mmrLambda,
 	}
 
 	// Perform search
@@ -85,7 +99,7 @@ func runSearch(cmd *cobra.Command, args []string) error {
 		fmt.Printf("%d. Event ID: %s\n", i+1, event.Id)
 		// Display similarity score if available
 		if i < len(resp.Scores) {
-			fmt.Printf("   Similarity Score: %.3f\n", resp.Scores[i])
+			fmt.Printf("   Score: %.3f\n", resp.Scores[i])
 		}
 		fmt.Printf("   Type: %s\n", event.Type)
 		fmt.Printf("   Source: %s\n", event.Source)
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// streamsCmd represents the streams command
+var streamsCmd = &cobra.Command{
+	Use:   "streams",
+	Short: "Show the live interaction streams of the server",
+	Long: `Show the InteractStream sessions the PCAS server holds, with their
+usage and the errors streams have failed with since the server started.
+
+Examples:
+  pcasctl streams
+  pcasctl streams --server pcas.example.com:50051`,
+	Args: cobra.NoArgs,
+	RunE: runStreams,
+}
+
+func init() {
+	rootCmd.AddCommand(streamsCmd)
+	streamsCmd.Flags().StringVar(&serverPort, "port", "50051", "PCAS server port")
+	streamsCmd.Flags().StringVar(&serverAddr, "server", "", "PCAS server address (overrides --port)")
+}
+
+func runStreams(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Determine server address
+	if serverAddr == "" {
+		serverAddr = fmt.Sprintf("localhost:%s", serverPort)
+	}
+
+	// Connect to gRPC server
+	conn, err := grpc.Dial(serverAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+	defer conn.Close()
+
+	client := busv1.NewEventBusServiceClient(conn)
+	resp, err := client.GetStreamStats(ctx, &busv1.StreamStatsRequest{})
+	if err != nil {
+		return fmt.Errorf("failed to get stream stats: %w", err)
+	}
+
+	fmt.Printf("Active streams: %d (%d detached)\n", resp.ActiveStreams, resp.DetachedStreams)
+	fmt.Printf("Started streams: %d\n", resp.StartedStreams)
+
+	if len(resp.Errors) > 0 {
+		reasons := make([]string, 0, len(resp.Errors))
+		for reason := range resp.Errors {
+			reasons = append(reasons, reason)
+		}
+		sort.Strings(reasons)
+		fmt.Println("\nFailed streams:")
+		for _, reason := range reasons {
+			fmt.Printf("   %s: %d\n", reason, resp.Errors[reason])
+		}
+	}
+
+	for i, stream := range resp.Streams {
+		if i == 0 {
+			fmt.Println()
+		}
+		state := "attached"
+		if !stream.Attached {
+			state = "detached"
+		}
+		fmt.Printf("%d. Stream ID: %s (%s)\n", i+1, stream.StreamId, state)
+		fmt.Printf("   Event Type: %s\n", stream.EventType)
+		fmt.Printf("   Provider: %s\n", stream.Provider)
+		if stream.StartedAt != nil {
+			startedAt := stream.StartedAt.AsTime()
+			fmt.Printf("   Started: %s (%s ago)\n", startedAt.Format(time.RFC3339), time.Since(startedAt).Round(time.Second))
+		}
+		fmt.Printf("   Input: %d chunks, %d bytes\n", stream.InputChunks, stream.InputBytes)
+		fmt.Printf("   Output: %d chunks, %d bytes\n", stream.OutputChunks, stream.OutputBytes)
+	}
+
+	return nil
+}
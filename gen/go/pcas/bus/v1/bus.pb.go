@@ -10,6 +10,7 @@ import (
 	v1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
 	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
 	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
 	reflect "reflect"
 	sync "sync"
 	unsafe "unsafe"
@@ -22,9 +23,162 @@ const (
 	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
 )
 
+// SegmentationMode selects where PCAS cuts the text input of a stream.
+type SegmentationMode int32
+
+const (
+	// Every StreamData message is forwarded as is. This is the default.
+	SegmentationMode_SEGMENTATION_MODE_NONE SegmentationMode = 0
+	// Cut after the end of each sentence. Latin sentences end with . ! ? or …
+	// followed by whitespace; CJK sentences end with 。！？ directly.
+	SegmentationMode_SEGMENTATION_MODE_SENTENCE SegmentationMode = 1
+	// Cut after sentence ends and after clause punctuation: , ; : followed by
+	// whitespace in Latin text, ，、；： in CJK text.
+	SegmentationMode_SEGMENTATION_MODE_PUNCTUATION SegmentationMode = 2
+	// Cut only when no input arrived for the idle timeout.
+	SegmentationMode_SEGMENTATION_MODE_IDLE_TIMEOUT SegmentationMode = 3
+)
+
+// Enum value maps for SegmentationMode.
+var (
+	SegmentationMode_name = map[int32]string{
+		0: "SEGMENTATION_MODE_NONE",
+		1: "SEGMENTATION_MODE_SENTENCE",
+		2: "SEGMENTATION_MODE_PUNCTUATION",
+		3: "SEGMENTATION_MODE_IDLE_TIMEOUT",
+	}
+	SegmentationMode_value = map[string]int32{
+		"SEGMENTATION_MODE_NONE":         0,
+		"SEGMENTATION_MODE_SENTENCE":     1,
+		"SEGMENTATION_MODE_PUNCTUATION":  2,
+		"SEGMENTATION_MODE_IDLE_TIMEOUT": 3,
+	}
+)
+
+func (x SegmentationMode) Enum() *SegmentationMode {
+	p := new(SegmentationMode)
+	*p = x
+	return p
+}
+
+func (x SegmentationMode) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SegmentationMode) Descriptor() protoreflect.EnumDescriptor {
+	return file_pcas_bus_v1_bus_proto_enumTypes[0].Descriptor()
+}
+
+func (SegmentationMode) Type() protoreflect.EnumType {
+	return &file_pcas_bus_v1_bus_proto_enumTypes[0]
+}
+
+func (x SegmentationMode) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use SegmentationMode.Descriptor instead.
+func (SegmentationMode) EnumDescriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{0}
+}
+
+// StreamErrorReason tells why a stream failed.
+type StreamErrorReason int32
+
+const (
+	// The reason is not known.
+	StreamErrorReason_STREAM_ERROR_REASON_UNSPECIFIED StreamErrorReason = 0
+	// The provider failed. The code tells how, e.g. RESOURCE_EXHAUSTED when it
+	// rate limited the stream.
+	StreamErrorReason_STREAM_ERROR_REASON_PROVIDER_ERROR StreamErrorReason = 1
+	// No input or output for the stream's idle timeout. Code DEADLINE_EXCEEDED.
+	StreamErrorReason_STREAM_ERROR_REASON_IDLE_TIMEOUT StreamErrorReason = 2
+	// The stream ran for its maximum duration. Code DEADLINE_EXCEEDED.
+	StreamErrorReason_STREAM_ERROR_REASON_MAX_DURATION StreamErrorReason = 3
+	// The client sent more input bytes than the stream allows. Code RESOURCE_EXHAUSTED.
+	StreamErrorReason_STREAM_ERROR_REASON_MAX_INPUT_BYTES StreamErrorReason = 4
+	// The client sent more input chunks than the stream allows. Code RESOURCE_EXHAUSTED.
+	StreamErrorReason_STREAM_ERROR_REASON_MAX_INPUT_CHUNKS StreamErrorReason = 5
+	// The client sent input faster than the provider consumes it. Code RESOURCE_EXHAUSTED.
+	StreamErrorReason_STREAM_ERROR_REASON_INPUT_OVERFLOW StreamErrorReason = 6
+	// The stream was not resumed within the grace period, or is not known. Code
+	// CANCELED or NOT_FOUND.
+	StreamErrorReason_STREAM_ERROR_REASON_NOT_RESUMED StreamErrorReason = 7
+	// No streaming provider is configured for the event type. Code NOT_FOUND or
+	// FAILED_PRECONDITION.
+	StreamErrorReason_STREAM_ERROR_REASON_NO_PROVIDER StreamErrorReason = 8
+	// The client sent a request that is not valid at this point, e.g. data for
+	// a channel that is not open. Code INVALID_ARGUMENT, NOT_FOUND or ALREADY_EXISTS.
+	StreamErrorReason_STREAM_ERROR_REASON_INVALID_REQUEST StreamErrorReason = 9
+)
+
+// Enum value maps for StreamErrorReason.
+var (
+	StreamErrorReason_name = map[int32]string{
+		0: "STREAM_ERROR_REASON_UNSPECIFIED",
+		1: "STREAM_ERROR_REASON_PROVIDER_ERROR",
+		2: "STREAM_ERROR_REASON_IDLE_TIMEOUT",
+		3: "STREAM_ERROR_REASON_MAX_DURATION",
+		4: "STREAM_ERROR_REASON_MAX_INPUT_BYTES",
+		5: "STREAM_ERROR_REASON_MAX_INPUT_CHUNKS",
+		6: "STREAM_ERROR_REASON_INPUT_OVERFLOW",
+		7: "STREAM_ERROR_REASON_NOT_RESUMED",
+		8: "STREAM_ERROR_REASON_NO_PROVIDER",
+		9: "STREAM_ERROR_REASON_INVALID_REQUEST",
+	}
+	StreamErrorReason_value = map[string]int32{
+		"STREAM_ERROR_REASON_UNSPECIFIED":      0,
+		"STREAM_ERROR_REASON_PROVIDER_ERROR":   1,
+		"STREAM_ERROR_REASON_IDLE_TIMEOUT":     2,
+		"STREAM_ERROR_REASON_MAX_DURATION":     3,
+		"STREAM_ERROR_REASON_MAX_INPUT_BYTES":  4,
+		"STREAM_ERROR_REASON_MAX_INPUT_CHUNKS": 5,
+		"STREAM_ERROR_REASON_INPUT_OVERFLOW":   6,
+		"STREAM_ERROR_REASON_NOT_RESUMED":      7,
+		"STREAM_ERROR_REASON_NO_PROVIDER":      8,
+		"STREAM_ERROR_REASON_INVALID_REQUEST":  9,
+	}
+)
+
+func (x StreamErrorReason) Enum() *StreamErrorReason {
+	p := new(StreamErrorReason)
+	*p = x
+	return p
+}
+
+func (x StreamErrorReason) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (StreamErrorReason) Descriptor() protoreflect.EnumDescriptor {
+	return file_pcas_bus_v1_bus_proto_enumTypes[1].Descriptor()
+}
+
+func (StreamErrorReason) Type() protoreflect.EnumType {
+	return &file_pcas_bus_v1_bus_proto_enumTypes[1]
+}
+
+func (x StreamErrorReason) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Use StreamErrorReason.Descriptor instead.
+func (StreamErrorReason) EnumDescriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{1}
+}
+
 // PublishResponse is the response from publishing an event
 type PublishResponse struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The ID of the published event, the correlation_id of its responses.
+	EventId string `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// True if a rule routes the event to a provider and it was queued for
+	// processing. False if the event was only stored.
+	Accepted bool `protobuf:"varint,2,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	// True if an event with the same ID was published within the idempotency
+	// window. The event was not stored or processed again; accepted is the
+	// outcome of the original.
+	Duplicate     bool `protobuf:"varint,3,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -59,6 +213,157 @@ func (*PublishResponse) Descriptor() ([]byte, []int) {
 	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{0}
 }
 
+func (x *PublishResponse) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *PublishResponse) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *PublishResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
+// PublishAndWaitRequest publishes an event and waits for its responses
+type PublishAndWaitRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	Event *v1.Event              `protobuf:"bytes,1,opt,name=event,proto3" json:"event,omitempty"`
+	// How long to wait for the responses. Defaults to the deadline of the call,
+	// or 30 seconds without one.
+	TimeoutMs     uint32 `protobuf:"varint,2,opt,name=timeout_ms,json=timeoutMs,proto3" json:"timeout_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishAndWaitRequest) Reset() {
+	*x = PublishAndWaitRequest{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishAndWaitRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishAndWaitRequest) ProtoMessage() {}
+
+func (x *PublishAndWaitRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishAndWaitRequest.ProtoReflect.Descriptor instead.
+func (*PublishAndWaitRequest) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *PublishAndWaitRequest) GetEvent() *v1.Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *PublishAndWaitRequest) GetTimeoutMs() uint32 {
+	if x != nil {
+		return x.TimeoutMs
+	}
+	return 0
+}
+
+// PublishAndWaitResponse carries the responses to a published event
+type PublishAndWaitResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The ID of the published event.
+	EventId string `protobuf:"bytes,1,opt,name=event_id,json=eventId,proto3" json:"event_id,omitempty"`
+	// True if a rule routed the event to a provider.
+	Accepted bool `protobuf:"varint,2,opt,name=accepted,proto3" json:"accepted,omitempty"`
+	// The pcas.response.v1 events whose correlation_id is the event ID: one, or
+	// one per provider for fan-out rules. Empty if the event was not accepted.
+	Responses []*v1.Event `protobuf:"bytes,3,rep,name=responses,proto3" json:"responses,omitempty"`
+	// True if an event with the same ID was published within the idempotency
+	// window. The responses are those to the original event.
+	Duplicate     bool `protobuf:"varint,4,opt,name=duplicate,proto3" json:"duplicate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PublishAndWaitResponse) Reset() {
+	*x = PublishAndWaitResponse{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PublishAndWaitResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PublishAndWaitResponse) ProtoMessage() {}
+
+func (x *PublishAndWaitResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PublishAndWaitResponse.ProtoReflect.Descriptor instead.
+func (*PublishAndWaitResponse) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PublishAndWaitResponse) GetEventId() string {
+	if x != nil {
+		return x.EventId
+	}
+	return ""
+}
+
+func (x *PublishAndWaitResponse) GetAccepted() bool {
+	if x != nil {
+		return x.Accepted
+	}
+	return false
+}
+
+func (x *PublishAndWaitResponse) GetResponses() []*v1.Event {
+	if x != nil {
+		return x.Responses
+	}
+	return nil
+}
+
+func (x *PublishAndWaitResponse) GetDuplicate() bool {
+	if x != nil {
+		return x.Duplicate
+	}
+	return false
+}
+
 // SubscribeRequest is the request for subscribing to the event stream
 type SubscribeRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -70,7 +375,7 @@ type SubscribeRequest struct {
 
 func (x *SubscribeRequest) Reset() {
 	*x = SubscribeRequest{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[1]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[3]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -82,7 +387,7 @@ func (x *SubscribeRequest) String() string {
 func (*SubscribeRequest) ProtoMessage() {}
 
 func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[1]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[3]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -95,7 +400,7 @@ func (x *SubscribeRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SubscribeRequest.ProtoReflect.Descriptor instead.
 func (*SubscribeRequest) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{1}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{3}
 }
 
 func (x *SubscribeRequest) GetClientId() string {
@@ -117,13 +422,23 @@ type SearchRequest struct {
 	// Attribute filters for metadata pre-filtering (AND logic)
 	// 用于元数据预过滤的属性过滤器（AND逻辑）
 	AttributeFilters map[string]string `protobuf:"bytes,4,rep,name=attribute_filters,json=attributeFilters,proto3" json:"attribute_filters,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
-	unknownFields    protoimpl.UnknownFields
-	sizeCache        protoimpl.SizeCache
+	// Weight of the time decay signal (0.0 to 1.0)
+	RecencyWeight float32 `protobuf:"fixed32,5,opt,name=recency_weight,json=recencyWeight,proto3" json:"recency_weight,omitempty"`
+	// Age in hours at which the recency signal has halved (default: 168, one week)
+	RecencyHalfLifeHours float32 `protobuf:"fixed32,6,opt,name=recency_half_life_hours,json=recencyHalfLifeHours,proto3" json:"recency_half_life_hours,omitempty"`
+	// Weight of the importance signal, taken from the "importance" attribute and
+	// pcas.user.feedback.v1 events (0.0 to 1.0)
+	ImportanceWeight float32 `protobuf:"fixed32,7,opt,name=importance_weight,json=importanceWeight,proto3" json:"importance_weight,omitempty"`
+	// MMR trade-off between relevance and diversity (0.0 to 1.0). Zero disables
+	// diversity re-ranking, 1.0 ranks by relevance only.
+	MmrLambda     float32 `protobuf:"fixed32,8,opt,name=mmr_lambda,json=mmrLambda,proto3" json:"mmr_lambda,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
 }
 
 func (x *SearchRequest) Reset() {
 	*x = SearchRequest{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[2]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[4]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -135,7 +450,7 @@ func (x *SearchRequest) String() string {
 func (*SearchRequest) ProtoMessage() {}
 
 func (x *SearchRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[2]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[4]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -148,7 +463,7 @@ func (x *SearchRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchRequest.ProtoReflect.Descriptor instead.
 func (*SearchRequest) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{2}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{4}
 }
 
 func (x *SearchRequest) GetQueryText() string {
@@ -179,12 +494,41 @@ func (x *SearchRequest) GetAttributeFilters() map[string]string {
 	return nil
 }
 
+func (x *SearchRequest) GetRecencyWeight() float32 {
+	if x != nil {
+		return x.RecencyWeight
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetRecencyHalfLifeHours() float32 {
+	if x != nil {
+		return x.RecencyHalfLifeHours
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetImportanceWeight() float32 {
+	if x != nil {
+		return x.ImportanceWeight
+	}
+	return 0
+}
+
+func (x *SearchRequest) GetMmrLambda() float32 {
+	if x != nil {
+		return x.MmrLambda
+	}
+	return 0
+}
+
 // SearchResponse is the response from semantic search
 type SearchResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// The matching events found
 	Events []*v1.Event `protobuf:"bytes,1,rep,name=events,proto3" json:"events,omitempty"`
-	// Similarity scores corresponding to each event (0.0 to 1.0)
+	// Scores corresponding to each event (0.0 to 1.0). This is the similarity, or the
+	// blended score when re-ranking weights are set.
 	Scores        []float32 `protobuf:"fixed32,2,rep,packed,name=scores,proto3" json:"scores,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -192,7 +536,7 @@ type SearchResponse struct {
 
 func (x *SearchResponse) Reset() {
 	*x = SearchResponse{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[3]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[5]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -204,7 +548,7 @@ func (x *SearchResponse) String() string {
 func (*SearchResponse) ProtoMessage() {}
 
 func (x *SearchResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[3]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[5]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -217,7 +561,7 @@ func (x *SearchResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use SearchResponse.ProtoReflect.Descriptor instead.
 func (*SearchResponse) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{3}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{5}
 }
 
 func (x *SearchResponse) GetEvents() []*v1.Event {
@@ -235,6 +579,13 @@ func (x *SearchResponse) GetScores() []float32 {
 }
 
 // InteractRequest represents a client request in the bidirectional stream.
+//
+// One stream can carry several logical channels, e.g. translation and
+// summarization of the same transcript. The first StreamConfig opens a channel,
+// and every later StreamConfig with a new channel_id opens another one with its
+// own routing, provider, flow control and end. All other messages name their
+// channel with channel_id; an empty channel_id is a channel of its own. The
+// stream ends once its last channel has ended.
 type InteractRequest struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// Types that are valid to be assigned to RequestType:
@@ -242,6 +593,9 @@ type InteractRequest struct {
 	//	*InteractRequest_Config
 	//	*InteractRequest_Data
 	//	*InteractRequest_ClientEnd
+	//	*InteractRequest_Ack
+	//	*InteractRequest_Cancel
+	//	*InteractRequest_Update
 	RequestType   isInteractRequest_RequestType `protobuf_oneof:"request_type"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -249,7 +603,7 @@ type InteractRequest struct {
 
 func (x *InteractRequest) Reset() {
 	*x = InteractRequest{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[4]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -261,7 +615,7 @@ func (x *InteractRequest) String() string {
 func (*InteractRequest) ProtoMessage() {}
 
 func (x *InteractRequest) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[4]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -274,7 +628,7 @@ func (x *InteractRequest) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use InteractRequest.ProtoReflect.Descriptor instead.
 func (*InteractRequest) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{4}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{6}
 }
 
 func (x *InteractRequest) GetRequestType() isInteractRequest_RequestType {
@@ -311,6 +665,33 @@ func (x *InteractRequest) GetClientEnd() *StreamEnd {
 	return nil
 }
 
+func (x *InteractRequest) GetAck() *StreamAck {
+	if x != nil {
+		if x, ok := x.RequestType.(*InteractRequest_Ack); ok {
+			return x.Ack
+		}
+	}
+	return nil
+}
+
+func (x *InteractRequest) GetCancel() *StreamCancel {
+	if x != nil {
+		if x, ok := x.RequestType.(*InteractRequest_Cancel); ok {
+			return x.Cancel
+		}
+	}
+	return nil
+}
+
+func (x *InteractRequest) GetUpdate() *StreamUpdate {
+	if x != nil {
+		if x, ok := x.RequestType.(*InteractRequest_Update); ok {
+			return x.Update
+		}
+	}
+	return nil
+}
+
 type isInteractRequest_RequestType interface {
 	isInteractRequest_RequestType()
 }
@@ -330,12 +711,33 @@ type InteractRequest_ClientEnd struct {
 	ClientEnd *StreamEnd `protobuf:"bytes,3,opt,name=client_end,json=clientEnd,proto3,oneof"`
 }
 
+type InteractRequest_Ack struct {
+	// Acknowledges output the client has received, so the server can release it.
+	Ack *StreamAck `protobuf:"bytes,4,opt,name=ack,proto3,oneof"`
+}
+
+type InteractRequest_Cancel struct {
+	// Aborts the current generation. The stream stays open for more input.
+	Cancel *StreamCancel `protobuf:"bytes,5,opt,name=cancel,proto3,oneof"`
+}
+
+type InteractRequest_Update struct {
+	// Changes attributes of the stream, e.g. the target language.
+	Update *StreamUpdate `protobuf:"bytes,6,opt,name=update,proto3,oneof"`
+}
+
 func (*InteractRequest_Config) isInteractRequest_RequestType() {}
 
 func (*InteractRequest_Data) isInteractRequest_RequestType() {}
 
 func (*InteractRequest_ClientEnd) isInteractRequest_RequestType() {}
 
+func (*InteractRequest_Ack) isInteractRequest_RequestType() {}
+
+func (*InteractRequest_Cancel) isInteractRequest_RequestType() {}
+
+func (*InteractRequest_Update) isInteractRequest_RequestType() {}
+
 // InteractResponse represents a server response in the bidirectional stream.
 type InteractResponse struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -352,7 +754,7 @@ type InteractResponse struct {
 
 func (x *InteractResponse) Reset() {
 	*x = InteractResponse{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[5]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -364,7 +766,7 @@ func (x *InteractResponse) String() string {
 func (*InteractResponse) ProtoMessage() {}
 
 func (x *InteractResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[5]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -377,7 +779,7 @@ func (x *InteractResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use InteractResponse.ProtoReflect.Descriptor instead.
 func (*InteractResponse) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{5}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *InteractResponse) GetResponseType() isInteractResponse_ResponseType {
@@ -464,14 +866,31 @@ type StreamConfig struct {
 	// This is the key for routing the entire stream to the correct provider.
 	EventType string `protobuf:"bytes,1,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
 	// Optional, additional attributes for the stream's context.
-	Attributes    map[string]string `protobuf:"bytes,2,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Attributes map[string]string `protobuf:"bytes,2,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Optional user the stream belongs to. The events recorded for the stream are
+	// stored in this user's memory.
+	UserId string `protobuf:"bytes,3,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	// Optional session the stream belongs to, linking its events to a conversation.
+	// Defaults to the stream ID.
+	SessionId string `protobuf:"bytes,4,opt,name=session_id,json=sessionId,proto3" json:"session_id,omitempty"`
+	// Collapse the stream into a single pcas.stream.transcript.v1 fact event when it
+	// closes, so that it is vectorized and can be found by search and RAG.
+	Transcript bool `protobuf:"varint,5,opt,name=transcript,proto3" json:"transcript,omitempty"`
+	// Reattach to a live stream instead of starting a new one, e.g. after the
+	// network dropped. When set, all other fields are ignored.
+	Resume *StreamResume `protobuf:"bytes,6,opt,name=resume,proto3" json:"resume,omitempty"`
+	// Optional server-side segmentation of text input. Without it every StreamData
+	// message is forwarded to the provider as is.
+	Segmentation *Segmentation `protobuf:"bytes,7,opt,name=segmentation,proto3" json:"segmentation,omitempty"`
+	// The channel opened by this config. Empty for streams with a single channel.
+	ChannelId     string `protobuf:"bytes,8,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *StreamConfig) Reset() {
 	*x = StreamConfig{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[6]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -483,7 +902,7 @@ func (x *StreamConfig) String() string {
 func (*StreamConfig) ProtoMessage() {}
 
 func (x *StreamConfig) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[6]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -496,7 +915,7 @@ func (x *StreamConfig) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamConfig.ProtoReflect.Descriptor instead.
 func (*StreamConfig) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{6}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *StreamConfig) GetEventType() string {
@@ -513,32 +932,259 @@ func (x *StreamConfig) GetAttributes() map[string]string {
 	return nil
 }
 
-// StreamData carries the actual payload in the stream.
-type StreamData struct {
-	state protoimpl.MessageState `protogen:"open.v1"`
-	// The raw data content.
-	Content       []byte `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+func (x *StreamConfig) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
 }
 
-func (x *StreamData) Reset() {
-	*x = StreamData{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[7]
-	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
-	ms.StoreMessageInfo(mi)
+func (x *StreamConfig) GetSessionId() string {
+	if x != nil {
+		return x.SessionId
+	}
+	return ""
 }
 
-func (x *StreamData) String() string {
-	return protoimpl.X.MessageStringOf(x)
+func (x *StreamConfig) GetTranscript() bool {
+	if x != nil {
+		return x.Transcript
+	}
+	return false
 }
 
-func (*StreamData) ProtoMessage() {}
-
-func (x *StreamData) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[7]
+func (x *StreamConfig) GetResume() *StreamResume {
 	if x != nil {
-		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		return x.Resume
+	}
+	return nil
+}
+
+func (x *StreamConfig) GetSegmentation() *Segmentation {
+	if x != nil {
+		return x.Segmentation
+	}
+	return nil
+}
+
+func (x *StreamConfig) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
+// Segmentation configures how PCAS re-cuts the text input of a stream.
+type Segmentation struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Where to cut the input.
+	Mode SegmentationMode `protobuf:"varint,1,opt,name=mode,proto3,enum=pcas.bus.v1.SegmentationMode" json:"mode,omitempty"`
+	// Time without input after which buffered text is forwarded, in milliseconds
+	// (default: 1000). In sentence and punctuation mode this flushes an unfinished
+	// sentence.
+	IdleTimeoutMs uint32 `protobuf:"varint,2,opt,name=idle_timeout_ms,json=idleTimeoutMs,proto3" json:"idle_timeout_ms,omitempty"`
+	// Maximum length of a segment in characters (default: 500). Longer text is
+	// cut at the last word boundary.
+	MaxChars      uint32 `protobuf:"varint,3,opt,name=max_chars,json=maxChars,proto3" json:"max_chars,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Segmentation) Reset() {
+	*x = Segmentation{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Segmentation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Segmentation) ProtoMessage() {}
+
+func (x *Segmentation) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Segmentation.ProtoReflect.Descriptor instead.
+func (*Segmentation) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Segmentation) GetMode() SegmentationMode {
+	if x != nil {
+		return x.Mode
+	}
+	return SegmentationMode_SEGMENTATION_MODE_NONE
+}
+
+func (x *Segmentation) GetIdleTimeoutMs() uint32 {
+	if x != nil {
+		return x.IdleTimeoutMs
+	}
+	return 0
+}
+
+func (x *Segmentation) GetMaxChars() uint32 {
+	if x != nil {
+		return x.MaxChars
+	}
+	return 0
+}
+
+// StreamResume identifies the stream to reattach to and the output the client
+// already received. The server replays the buffered output after last_sequence.
+type StreamResume struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The stream_id from the StreamReady of the original stream.
+	StreamId string `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	// Sequence number of the last output chunk the client received, 0 for none.
+	LastSequence  uint64 `protobuf:"varint,2,opt,name=last_sequence,json=lastSequence,proto3" json:"last_sequence,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamResume) Reset() {
+	*x = StreamResume{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamResume) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamResume) ProtoMessage() {}
+
+func (x *StreamResume) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamResume.ProtoReflect.Descriptor instead.
+func (*StreamResume) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *StreamResume) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *StreamResume) GetLastSequence() uint64 {
+	if x != nil {
+		return x.LastSequence
+	}
+	return 0
+}
+
+// StreamAck acknowledges all output up to and including a sequence number.
+// Acknowledged output is no longer buffered for a resume.
+type StreamAck struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Sequence number of the last output chunk the client received.
+	Sequence uint64 `protobuf:"varint,1,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// The channel whose output is acknowledged.
+	ChannelId     string `protobuf:"bytes,2,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamAck) Reset() {
+	*x = StreamAck{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamAck) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamAck) ProtoMessage() {}
+
+func (x *StreamAck) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamAck.ProtoReflect.Descriptor instead.
+func (*StreamAck) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *StreamAck) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *StreamAck) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
+// StreamData carries the actual payload in the stream.
+type StreamData struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The raw data content.
+	Content []byte `protobuf:"bytes,1,opt,name=content,proto3" json:"content,omitempty"`
+	// Position of the chunk in its direction of the stream, starting at 1. The
+	// server numbers its output. Clients may number their input, so that input
+	// resent after a resume is not processed twice.
+	Sequence uint64 `protobuf:"varint,2,opt,name=sequence,proto3" json:"sequence,omitempty"`
+	// The channel the chunk belongs to.
+	ChannelId     string `protobuf:"bytes,3,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamData) Reset() {
+	*x = StreamData{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamData) ProtoMessage() {}
+
+func (x *StreamData) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
 			ms.StoreMessageInfo(mi)
 		}
@@ -549,7 +1195,7 @@ func (x *StreamData) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamData.ProtoReflect.Descriptor instead.
 func (*StreamData) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{7}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *StreamData) GetContent() []byte {
@@ -559,19 +1205,40 @@ func (x *StreamData) GetContent() []byte {
 	return nil
 }
 
+func (x *StreamData) GetSequence() uint64 {
+	if x != nil {
+		return x.Sequence
+	}
+	return 0
+}
+
+func (x *StreamData) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
 // StreamReady indicates the server has successfully configured the stream
 // and is ready to process data.
 type StreamReady struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// A unique ID assigned by the server to this interaction stream.
-	StreamId      string `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	StreamId string `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	// True if the client reattached to a live stream.
+	Resumed bool `protobuf:"varint,2,opt,name=resumed,proto3" json:"resumed,omitempty"`
+	// Sequence number of the last input chunk the server received. After a resume
+	// the client resends its input from the next sequence number on.
+	LastInputSequence uint64 `protobuf:"varint,3,opt,name=last_input_sequence,json=lastInputSequence,proto3" json:"last_input_sequence,omitempty"`
+	// The channel that is ready.
+	ChannelId     string `protobuf:"bytes,4,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *StreamReady) Reset() {
 	*x = StreamReady{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[8]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -583,7 +1250,7 @@ func (x *StreamReady) String() string {
 func (*StreamReady) ProtoMessage() {}
 
 func (x *StreamReady) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[8]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -596,7 +1263,7 @@ func (x *StreamReady) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamReady.ProtoReflect.Descriptor instead.
 func (*StreamReady) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{8}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *StreamReady) GetStreamId() string {
@@ -606,20 +1273,54 @@ func (x *StreamReady) GetStreamId() string {
 	return ""
 }
 
+func (x *StreamReady) GetResumed() bool {
+	if x != nil {
+		return x.Resumed
+	}
+	return false
+}
+
+func (x *StreamReady) GetLastInputSequence() uint64 {
+	if x != nil {
+		return x.LastInputSequence
+	}
+	return 0
+}
+
+func (x *StreamReady) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
 // StreamError represents a terminal error that occurred during the stream.
 type StreamError struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
 	// A status code for the error.
 	Code int32 `protobuf:"varint,1,opt,name=code,proto3" json:"code,omitempty"`
 	// A human-readable error message.
-	Message       string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Message string `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	// The channel that failed. Other channels of the stream continue.
+	ChannelId string `protobuf:"bytes,3,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	// Why the stream failed, e.g. which limit it hit.
+	Reason StreamErrorReason `protobuf:"varint,4,opt,name=reason,proto3,enum=pcas.bus.v1.StreamErrorReason" json:"reason,omitempty"`
+	// True if the same request may succeed when it is retried later.
+	Retryable bool `protobuf:"varint,5,opt,name=retryable,proto3" json:"retryable,omitempty"`
+	// How long to wait before retrying, if the provider said. 0 if unknown.
+	RetryAfterMs uint32 `protobuf:"varint,6,opt,name=retry_after_ms,json=retryAfterMs,proto3" json:"retry_after_ms,omitempty"`
+	// The provider that failed, for provider errors.
+	Provider string `protobuf:"bytes,7,opt,name=provider,proto3" json:"provider,omitempty"`
+	// The machine-readable reason, the same as the ErrorInfo reason of the status
+	// the stream ends with, e.g. PROVIDER_RATE_LIMITED.
+	ErrorReason   string `protobuf:"bytes,8,opt,name=error_reason,json=errorReason,proto3" json:"error_reason,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *StreamError) Reset() {
 	*x = StreamError{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[9]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[14]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -631,7 +1332,7 @@ func (x *StreamError) String() string {
 func (*StreamError) ProtoMessage() {}
 
 func (x *StreamError) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[9]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[14]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -644,7 +1345,7 @@ func (x *StreamError) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamError.ProtoReflect.Descriptor instead.
 func (*StreamError) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{9}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{14}
 }
 
 func (x *StreamError) GetCode() int32 {
@@ -661,17 +1362,401 @@ func (x *StreamError) GetMessage() string {
 	return ""
 }
 
-// StreamEnd is an empty message that signals the graceful end of one
-// direction of the stream.
-type StreamEnd struct {
+func (x *StreamError) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
+func (x *StreamError) GetReason() StreamErrorReason {
+	if x != nil {
+		return x.Reason
+	}
+	return StreamErrorReason_STREAM_ERROR_REASON_UNSPECIFIED
+}
+
+func (x *StreamError) GetRetryable() bool {
+	if x != nil {
+		return x.Retryable
+	}
+	return false
+}
+
+func (x *StreamError) GetRetryAfterMs() uint32 {
+	if x != nil {
+		return x.RetryAfterMs
+	}
+	return 0
+}
+
+func (x *StreamError) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *StreamError) GetErrorReason() string {
+	if x != nil {
+		return x.ErrorReason
+	}
+	return ""
+}
+
+// StreamStatsRequest is the request for the stream statistics
+type StreamStatsRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
+func (x *StreamStatsRequest) Reset() {
+	*x = StreamStatsRequest{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamStatsRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamStatsRequest) ProtoMessage() {}
+
+func (x *StreamStatsRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamStatsRequest.ProtoReflect.Descriptor instead.
+func (*StreamStatsRequest) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{15}
+}
+
+// StreamStatsResponse reports the live InteractStreams of the server
+type StreamStatsResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Live streams, including those waiting to be resumed
+	ActiveStreams int32 `protobuf:"varint,1,opt,name=active_streams,json=activeStreams,proto3" json:"active_streams,omitempty"`
+	// Live streams whose client disconnected and that wait to be resumed
+	DetachedStreams int32 `protobuf:"varint,2,opt,name=detached_streams,json=detachedStreams,proto3" json:"detached_streams,omitempty"`
+	// Streams started since the server started
+	StartedStreams uint64 `protobuf:"varint,3,opt,name=started_streams,json=startedStreams,proto3" json:"started_streams,omitempty"`
+	// Failed streams since the server started, by StreamErrorReason name
+	Errors map[string]uint64 `protobuf:"bytes,4,rep,name=errors,proto3" json:"errors,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"varint,2,opt,name=value"`
+	// The live streams, oldest first
+	Streams       []*StreamStats `protobuf:"bytes,5,rep,name=streams,proto3" json:"streams,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamStatsResponse) Reset() {
+	*x = StreamStatsResponse{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamStatsResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamStatsResponse) ProtoMessage() {}
+
+func (x *StreamStatsResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamStatsResponse.ProtoReflect.Descriptor instead.
+func (*StreamStatsResponse) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *StreamStatsResponse) GetActiveStreams() int32 {
+	if x != nil {
+		return x.ActiveStreams
+	}
+	return 0
+}
+
+func (x *StreamStatsResponse) GetDetachedStreams() int32 {
+	if x != nil {
+		return x.DetachedStreams
+	}
+	return 0
+}
+
+func (x *StreamStatsResponse) GetStartedStreams() uint64 {
+	if x != nil {
+		return x.StartedStreams
+	}
+	return 0
+}
+
+func (x *StreamStatsResponse) GetErrors() map[string]uint64 {
+	if x != nil {
+		return x.Errors
+	}
+	return nil
+}
+
+func (x *StreamStatsResponse) GetStreams() []*StreamStats {
+	if x != nil {
+		return x.Streams
+	}
+	return nil
+}
+
+// StreamStats describes a live stream
+type StreamStats struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	StreamId  string                 `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	EventType string                 `protobuf:"bytes,2,opt,name=event_type,json=eventType,proto3" json:"event_type,omitempty"`
+	Provider  string                 `protobuf:"bytes,3,opt,name=provider,proto3" json:"provider,omitempty"`
+	StartedAt *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=started_at,json=startedAt,proto3" json:"started_at,omitempty"`
+	// False while the stream waits to be resumed
+	Attached      bool   `protobuf:"varint,5,opt,name=attached,proto3" json:"attached,omitempty"`
+	InputChunks   uint64 `protobuf:"varint,6,opt,name=input_chunks,json=inputChunks,proto3" json:"input_chunks,omitempty"`
+	InputBytes    uint64 `protobuf:"varint,7,opt,name=input_bytes,json=inputBytes,proto3" json:"input_bytes,omitempty"`
+	OutputChunks  uint64 `protobuf:"varint,8,opt,name=output_chunks,json=outputChunks,proto3" json:"output_chunks,omitempty"`
+	OutputBytes   uint64 `protobuf:"varint,9,opt,name=output_bytes,json=outputBytes,proto3" json:"output_bytes,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamStats) Reset() {
+	*x = StreamStats{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamStats) ProtoMessage() {}
+
+func (x *StreamStats) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamStats.ProtoReflect.Descriptor instead.
+func (*StreamStats) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *StreamStats) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *StreamStats) GetEventType() string {
+	if x != nil {
+		return x.EventType
+	}
+	return ""
+}
+
+func (x *StreamStats) GetProvider() string {
+	if x != nil {
+		return x.Provider
+	}
+	return ""
+}
+
+func (x *StreamStats) GetStartedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.StartedAt
+	}
+	return nil
+}
+
+func (x *StreamStats) GetAttached() bool {
+	if x != nil {
+		return x.Attached
+	}
+	return false
+}
+
+func (x *StreamStats) GetInputChunks() uint64 {
+	if x != nil {
+		return x.InputChunks
+	}
+	return 0
+}
+
+func (x *StreamStats) GetInputBytes() uint64 {
+	if x != nil {
+		return x.InputBytes
+	}
+	return 0
+}
+
+func (x *StreamStats) GetOutputChunks() uint64 {
+	if x != nil {
+		return x.OutputChunks
+	}
+	return 0
+}
+
+func (x *StreamStats) GetOutputBytes() uint64 {
+	if x != nil {
+		return x.OutputBytes
+	}
+	return 0
+}
+
+// StreamCancel asks the provider to abort the output it is generating. Output
+// generated before the provider saw the cancel may still arrive.
+type StreamCancel struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Optional reason, recorded with the stream's events.
+	Reason string `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	// The channel whose generation to abort.
+	ChannelId     string `protobuf:"bytes,2,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamCancel) Reset() {
+	*x = StreamCancel{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamCancel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamCancel) ProtoMessage() {}
+
+func (x *StreamCancel) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamCancel.ProtoReflect.Descriptor instead.
+func (*StreamCancel) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *StreamCancel) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *StreamCancel) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
+// StreamUpdate changes attributes of a running stream. Attributes that are not
+// listed keep their value.
+type StreamUpdate struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Attributes to set. An empty value removes the attribute.
+	Attributes map[string]string `protobuf:"bytes,1,rep,name=attributes,proto3" json:"attributes,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// The channel to reconfigure.
+	ChannelId     string `protobuf:"bytes,2,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamUpdate) Reset() {
+	*x = StreamUpdate{}
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamUpdate) ProtoMessage() {}
+
+func (x *StreamUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamUpdate.ProtoReflect.Descriptor instead.
+func (*StreamUpdate) Descriptor() ([]byte, []int) {
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *StreamUpdate) GetAttributes() map[string]string {
+	if x != nil {
+		return x.Attributes
+	}
+	return nil
+}
+
+func (x *StreamUpdate) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
+}
+
+// StreamEnd signals the graceful end of one direction of a channel.
+type StreamEnd struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The channel that ended.
+	ChannelId     string `protobuf:"bytes,1,opt,name=channel_id,json=channelId,proto3" json:"channel_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
 func (x *StreamEnd) Reset() {
 	*x = StreamEnd{}
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[10]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[20]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -683,7 +1768,7 @@ func (x *StreamEnd) String() string {
 func (*StreamEnd) ProtoMessage() {}
 
 func (x *StreamEnd) ProtoReflect() protoreflect.Message {
-	mi := &file_pcas_bus_v1_bus_proto_msgTypes[10]
+	mi := &file_pcas_bus_v1_bus_proto_msgTypes[20]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -696,34 +1781,61 @@ func (x *StreamEnd) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StreamEnd.ProtoReflect.Descriptor instead.
 func (*StreamEnd) Descriptor() ([]byte, []int) {
-	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{10}
+	return file_pcas_bus_v1_bus_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *StreamEnd) GetChannelId() string {
+	if x != nil {
+		return x.ChannelId
+	}
+	return ""
 }
 
 var File_pcas_bus_v1_bus_proto protoreflect.FileDescriptor
 
 const file_pcas_bus_v1_bus_proto_rawDesc = "" +
 	"\n" +
-	"\x15pcas/bus/v1/bus.proto\x12\vpcas.bus.v1\x1a\x1apcas/events/v1/event.proto\"\x11\n" +
-	"\x0fPublishResponse\"/\n" +
+	"\x15pcas/bus/v1/bus.proto\x12\vpcas.bus.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1apcas/events/v1/event.proto\"f\n" +
+	"\x0fPublishResponse\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x12\x1a\n" +
+	"\baccepted\x18\x02 \x01(\bR\baccepted\x12\x1c\n" +
+	"\tduplicate\x18\x03 \x01(\bR\tduplicate\"c\n" +
+	"\x15PublishAndWaitRequest\x12+\n" +
+	"\x05event\x18\x01 \x01(\v2\x15.pcas.events.v1.EventR\x05event\x12\x1d\n" +
+	"\n" +
+	"timeout_ms\x18\x02 \x01(\rR\ttimeoutMs\"\xa2\x01\n" +
+	"\x16PublishAndWaitResponse\x12\x19\n" +
+	"\bevent_id\x18\x01 \x01(\tR\aeventId\x12\x1a\n" +
+	"\baccepted\x18\x02 \x01(\bR\baccepted\x123\n" +
+	"\tresponses\x18\x03 \x03(\v2\x15.pcas.events.v1.EventR\tresponses\x12\x1c\n" +
+	"\tduplicate\x18\x04 \x01(\bR\tduplicate\"/\n" +
 	"\x10SubscribeRequest\x12\x1b\n" +
-	"\tclient_id\x18\x01 \x01(\tR\bclientId\"\x80\x02\n" +
+	"\tclient_id\x18\x01 \x01(\tR\bclientId\"\xaa\x03\n" +
 	"\rSearchRequest\x12\x1d\n" +
 	"\n" +
 	"query_text\x18\x01 \x01(\tR\tqueryText\x12\x13\n" +
 	"\x05top_k\x18\x02 \x01(\x05R\x04topK\x12\x17\n" +
 	"\auser_id\x18\x03 \x01(\tR\x06userId\x12]\n" +
-	"\x11attribute_filters\x18\x04 \x03(\v20.pcas.bus.v1.SearchRequest.AttributeFiltersEntryR\x10attributeFilters\x1aC\n" +
+	"\x11attribute_filters\x18\x04 \x03(\v20.pcas.bus.v1.SearchRequest.AttributeFiltersEntryR\x10attributeFilters\x12%\n" +
+	"\x0erecency_weight\x18\x05 \x01(\x02R\rrecencyWeight\x125\n" +
+	"\x17recency_half_life_hours\x18\x06 \x01(\x02R\x14recencyHalfLifeHours\x12+\n" +
+	"\x11importance_weight\x18\a \x01(\x02R\x10importanceWeight\x12\x1d\n" +
+	"\n" +
+	"mmr_lambda\x18\b \x01(\x02R\tmmrLambda\x1aC\n" +
 	"\x15AttributeFiltersEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
 	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"W\n" +
 	"\x0eSearchResponse\x12-\n" +
 	"\x06events\x18\x01 \x03(\v2\x15.pcas.events.v1.EventR\x06events\x12\x16\n" +
-	"\x06scores\x18\x02 \x03(\x02R\x06scores\"\xbe\x01\n" +
+	"\x06scores\x18\x02 \x03(\x02R\x06scores\"\xd4\x02\n" +
 	"\x0fInteractRequest\x123\n" +
 	"\x06config\x18\x01 \x01(\v2\x19.pcas.bus.v1.StreamConfigH\x00R\x06config\x12-\n" +
 	"\x04data\x18\x02 \x01(\v2\x17.pcas.bus.v1.StreamDataH\x00R\x04data\x127\n" +
 	"\n" +
-	"client_end\x18\x03 \x01(\v2\x16.pcas.bus.v1.StreamEndH\x00R\tclientEndB\x0e\n" +
+	"client_end\x18\x03 \x01(\v2\x16.pcas.bus.v1.StreamEndH\x00R\tclientEnd\x12*\n" +
+	"\x03ack\x18\x04 \x01(\v2\x16.pcas.bus.v1.StreamAckH\x00R\x03ack\x123\n" +
+	"\x06cancel\x18\x05 \x01(\v2\x19.pcas.bus.v1.StreamCancelH\x00R\x06cancel\x123\n" +
+	"\x06update\x18\x06 \x01(\v2\x19.pcas.bus.v1.StreamUpdateH\x00R\x06updateB\x0e\n" +
 	"\frequest_type\"\xef\x01\n" +
 	"\x10InteractResponse\x120\n" +
 	"\x05ready\x18\x01 \x01(\v2\x18.pcas.bus.v1.StreamReadyH\x00R\x05ready\x12-\n" +
@@ -731,30 +1843,121 @@ const file_pcas_bus_v1_bus_proto_rawDesc = "" +
 	"\x05error\x18\x03 \x01(\v2\x18.pcas.bus.v1.StreamErrorH\x00R\x05error\x127\n" +
 	"\n" +
 	"server_end\x18\x04 \x01(\v2\x16.pcas.bus.v1.StreamEndH\x00R\tserverEndB\x0f\n" +
-	"\rresponse_type\"\xb7\x01\n" +
+	"\rresponse_type\"\xa0\x03\n" +
 	"\fStreamConfig\x12\x1d\n" +
 	"\n" +
 	"event_type\x18\x01 \x01(\tR\teventType\x12I\n" +
 	"\n" +
 	"attributes\x18\x02 \x03(\v2).pcas.bus.v1.StreamConfig.AttributesEntryR\n" +
-	"attributes\x1a=\n" +
+	"attributes\x12\x17\n" +
+	"\auser_id\x18\x03 \x01(\tR\x06userId\x12\x1d\n" +
+	"\n" +
+	"session_id\x18\x04 \x01(\tR\tsessionId\x12\x1e\n" +
+	"\n" +
+	"transcript\x18\x05 \x01(\bR\n" +
+	"transcript\x121\n" +
+	"\x06resume\x18\x06 \x01(\v2\x19.pcas.bus.v1.StreamResumeR\x06resume\x12=\n" +
+	"\fsegmentation\x18\a \x01(\v2\x19.pcas.bus.v1.SegmentationR\fsegmentation\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\b \x01(\tR\tchannelId\x1a=\n" +
 	"\x0fAttributesEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
-	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"&\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\x86\x01\n" +
+	"\fSegmentation\x121\n" +
+	"\x04mode\x18\x01 \x01(\x0e2\x1d.pcas.bus.v1.SegmentationModeR\x04mode\x12&\n" +
+	"\x0fidle_timeout_ms\x18\x02 \x01(\rR\ridleTimeoutMs\x12\x1b\n" +
+	"\tmax_chars\x18\x03 \x01(\rR\bmaxChars\"P\n" +
+	"\fStreamResume\x12\x1b\n" +
+	"\tstream_id\x18\x01 \x01(\tR\bstreamId\x12#\n" +
+	"\rlast_sequence\x18\x02 \x01(\x04R\flastSequence\"F\n" +
+	"\tStreamAck\x12\x1a\n" +
+	"\bsequence\x18\x01 \x01(\x04R\bsequence\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x02 \x01(\tR\tchannelId\"a\n" +
 	"\n" +
 	"StreamData\x12\x18\n" +
-	"\acontent\x18\x01 \x01(\fR\acontent\"*\n" +
+	"\acontent\x18\x01 \x01(\fR\acontent\x12\x1a\n" +
+	"\bsequence\x18\x02 \x01(\x04R\bsequence\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x03 \x01(\tR\tchannelId\"\x93\x01\n" +
 	"\vStreamReady\x12\x1b\n" +
-	"\tstream_id\x18\x01 \x01(\tR\bstreamId\";\n" +
+	"\tstream_id\x18\x01 \x01(\tR\bstreamId\x12\x18\n" +
+	"\aresumed\x18\x02 \x01(\bR\aresumed\x12.\n" +
+	"\x13last_input_sequence\x18\x03 \x01(\x04R\x11lastInputSequence\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x04 \x01(\tR\tchannelId\"\x95\x02\n" +
 	"\vStreamError\x12\x12\n" +
 	"\x04code\x18\x01 \x01(\x05R\x04code\x12\x18\n" +
-	"\amessage\x18\x02 \x01(\tR\amessage\"\v\n" +
-	"\tStreamEnd2\xac\x02\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x03 \x01(\tR\tchannelId\x126\n" +
+	"\x06reason\x18\x04 \x01(\x0e2\x1e.pcas.bus.v1.StreamErrorReasonR\x06reason\x12\x1c\n" +
+	"\tretryable\x18\x05 \x01(\bR\tretryable\x12$\n" +
+	"\x0eretry_after_ms\x18\x06 \x01(\rR\fretryAfterMs\x12\x1a\n" +
+	"\bprovider\x18\a \x01(\tR\bprovider\x12!\n" +
+	"\ferror_reason\x18\b \x01(\tR\verrorReason\"\x14\n" +
+	"\x12StreamStatsRequest\"\xc5\x02\n" +
+	"\x13StreamStatsResponse\x12%\n" +
+	"\x0eactive_streams\x18\x01 \x01(\x05R\ractiveStreams\x12)\n" +
+	"\x10detached_streams\x18\x02 \x01(\x05R\x0fdetachedStreams\x12'\n" +
+	"\x0fstarted_streams\x18\x03 \x01(\x04R\x0estartedStreams\x12D\n" +
+	"\x06errors\x18\x04 \x03(\v2,.pcas.bus.v1.StreamStatsResponse.ErrorsEntryR\x06errors\x122\n" +
+	"\astreams\x18\x05 \x03(\v2\x18.pcas.bus.v1.StreamStatsR\astreams\x1a9\n" +
+	"\vErrorsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x04R\x05value:\x028\x01\"\xc8\x02\n" +
+	"\vStreamStats\x12\x1b\n" +
+	"\tstream_id\x18\x01 \x01(\tR\bstreamId\x12\x1d\n" +
+	"\n" +
+	"event_type\x18\x02 \x01(\tR\teventType\x12\x1a\n" +
+	"\bprovider\x18\x03 \x01(\tR\bprovider\x129\n" +
+	"\n" +
+	"started_at\x18\x04 \x01(\v2\x1a.google.protobuf.TimestampR\tstartedAt\x12\x1a\n" +
+	"\battached\x18\x05 \x01(\bR\battached\x12!\n" +
+	"\finput_chunks\x18\x06 \x01(\x04R\vinputChunks\x12\x1f\n" +
+	"\vinput_bytes\x18\a \x01(\x04R\n" +
+	"inputBytes\x12#\n" +
+	"\routput_chunks\x18\b \x01(\x04R\foutputChunks\x12!\n" +
+	"\foutput_bytes\x18\t \x01(\x04R\voutputBytes\"E\n" +
+	"\fStreamCancel\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x02 \x01(\tR\tchannelId\"\xb7\x01\n" +
+	"\fStreamUpdate\x12I\n" +
+	"\n" +
+	"attributes\x18\x01 \x03(\v2).pcas.bus.v1.StreamUpdate.AttributesEntryR\n" +
+	"attributes\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x02 \x01(\tR\tchannelId\x1a=\n" +
+	"\x0fAttributesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"*\n" +
+	"\tStreamEnd\x12\x1d\n" +
+	"\n" +
+	"channel_id\x18\x01 \x01(\tR\tchannelId*\x95\x01\n" +
+	"\x10SegmentationMode\x12\x1a\n" +
+	"\x16SEGMENTATION_MODE_NONE\x10\x00\x12\x1e\n" +
+	"\x1aSEGMENTATION_MODE_SENTENCE\x10\x01\x12!\n" +
+	"\x1dSEGMENTATION_MODE_PUNCTUATION\x10\x02\x12\"\n" +
+	"\x1eSEGMENTATION_MODE_IDLE_TIMEOUT\x10\x03*\x9a\x03\n" +
+	"\x11StreamErrorReason\x12#\n" +
+	"\x1fSTREAM_ERROR_REASON_UNSPECIFIED\x10\x00\x12&\n" +
+	"\"STREAM_ERROR_REASON_PROVIDER_ERROR\x10\x01\x12$\n" +
+	" STREAM_ERROR_REASON_IDLE_TIMEOUT\x10\x02\x12$\n" +
+	" STREAM_ERROR_REASON_MAX_DURATION\x10\x03\x12'\n" +
+	"#STREAM_ERROR_REASON_MAX_INPUT_BYTES\x10\x04\x12(\n" +
+	"$STREAM_ERROR_REASON_MAX_INPUT_CHUNKS\x10\x05\x12&\n" +
+	"\"STREAM_ERROR_REASON_INPUT_OVERFLOW\x10\x06\x12#\n" +
+	"\x1fSTREAM_ERROR_REASON_NOT_RESUMED\x10\a\x12#\n" +
+	"\x1fSTREAM_ERROR_REASON_NO_PROVIDER\x10\b\x12'\n" +
+	"#STREAM_ERROR_REASON_INVALID_REQUEST\x10\t2\xdc\x03\n" +
 	"\x0fEventBusService\x12>\n" +
-	"\aPublish\x12\x15.pcas.events.v1.Event\x1a\x1c.pcas.bus.v1.PublishResponse\x12C\n" +
+	"\aPublish\x12\x15.pcas.events.v1.Event\x1a\x1c.pcas.bus.v1.PublishResponse\x12Y\n" +
+	"\x0ePublishAndWait\x12\".pcas.bus.v1.PublishAndWaitRequest\x1a#.pcas.bus.v1.PublishAndWaitResponse\x12C\n" +
 	"\tSubscribe\x12\x1d.pcas.bus.v1.SubscribeRequest\x1a\x15.pcas.events.v1.Event0\x01\x12A\n" +
 	"\x06Search\x12\x1a.pcas.bus.v1.SearchRequest\x1a\x1b.pcas.bus.v1.SearchResponse\x12Q\n" +
-	"\x0eInteractStream\x12\x1c.pcas.bus.v1.InteractRequest\x1a\x1d.pcas.bus.v1.InteractResponse(\x010\x01B\xa0\x01\n" +
+	"\x0eInteractStream\x12\x1c.pcas.bus.v1.InteractRequest\x1a\x1d.pcas.bus.v1.InteractResponse(\x010\x01\x12S\n" +
+	"\x0eGetStreamStats\x12\x1f.pcas.bus.v1.StreamStatsRequest\x1a .pcas.bus.v1.StreamStatsResponseB\xa0\x01\n" +
 	"\x0fcom.pcas.bus.v1B\bBusProtoP\x01Z5github.com/soaringjerry/pcas/gen/go/pcas/bus/v1;busv1\xa2\x02\x03PBX\xaa\x02\vPcas.Bus.V1\xca\x02\vPcas\\Bus\\V1\xe2\x02\x17Pcas\\Bus\\V1\\GPBMetadata\xea\x02\rPcas::Bus::V1b\x06proto3"
 
 var (
@@ -769,47 +1972,80 @@ func file_pcas_bus_v1_bus_proto_rawDescGZIP() []byte {
 	return file_pcas_bus_v1_bus_proto_rawDescData
 }
 
-var file_pcas_bus_v1_bus_proto_msgTypes = make([]protoimpl.MessageInfo, 13)
+var file_pcas_bus_v1_bus_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
+var file_pcas_bus_v1_bus_proto_msgTypes = make([]protoimpl.MessageInfo, 25)
 var file_pcas_bus_v1_bus_proto_goTypes = []any{
-	(*PublishResponse)(nil),  // 0: pcas.bus.v1.PublishResponse
-	(*SubscribeRequest)(nil), // 1: pcas.bus.v1.SubscribeRequest
-	(*SearchRequest)(nil),    // 2: pcas.bus.v1.SearchRequest
-	(*SearchResponse)(nil),   // 3: pcas.bus.v1.SearchResponse
-	(*InteractRequest)(nil),  // 4: pcas.bus.v1.InteractRequest
-	(*InteractResponse)(nil), // 5: pcas.bus.v1.InteractResponse
-	(*StreamConfig)(nil),     // 6: pcas.bus.v1.StreamConfig
-	(*StreamData)(nil),       // 7: pcas.bus.v1.StreamData
-	(*StreamReady)(nil),      // 8: pcas.bus.v1.StreamReady
-	(*StreamError)(nil),      // 9: pcas.bus.v1.StreamError
-	(*StreamEnd)(nil),        // 10: pcas.bus.v1.StreamEnd
-	nil,                      // 11: pcas.bus.v1.SearchRequest.AttributeFiltersEntry
-	nil,                      // 12: pcas.bus.v1.StreamConfig.AttributesEntry
-	(*v1.Event)(nil),         // 13: pcas.events.v1.Event
+	(SegmentationMode)(0),          // 0: pcas.bus.v1.SegmentationMode
+	(StreamErrorReason)(0),         // 1: pcas.bus.v1.StreamErrorReason
+	(*PublishResponse)(nil),        // 2: pcas.bus.v1.PublishResponse
+	(*PublishAndWaitRequest)(nil),  // 3: pcas.bus.v1.PublishAndWaitRequest
+	(*PublishAndWaitResponse)(nil), // 4: pcas.bus.v1.PublishAndWaitResponse
+	(*SubscribeRequest)(nil),       // 5: pcas.bus.v1.SubscribeRequest
+	(*SearchRequest)(nil),          // 6: pcas.bus.v1.SearchRequest
+	(*SearchResponse)(nil),         // 7: pcas.bus.v1.SearchResponse
+	(*InteractRequest)(nil),        // 8: pcas.bus.v1.InteractRequest
+	(*InteractResponse)(nil),       // 9: pcas.bus.v1.InteractResponse
+	(*StreamConfig)(nil),           // 10: pcas.bus.v1.StreamConfig
+	(*Segmentation)(nil),           // 11: pcas.bus.v1.Segmentation
+	(*StreamResume)(nil),           // 12: pcas.bus.v1.StreamResume
+	(*StreamAck)(nil),              // 13: pcas.bus.v1.StreamAck
+	(*StreamData)(nil),             // 14: pcas.bus.v1.StreamData
+	(*StreamReady)(nil),            // 15: pcas.bus.v1.StreamReady
+	(*StreamError)(nil),            // 16: pcas.bus.v1.StreamError
+	(*StreamStatsRequest)(nil),     // 17: pcas.bus.v1.StreamStatsRequest
+	(*StreamStatsResponse)(nil),    // 18: pcas.bus.v1.StreamStatsResponse
+	(*StreamStats)(nil),            // 19: pcas.bus.v1.StreamStats
+	(*StreamCancel)(nil),           // 20: pcas.bus.v1.StreamCancel
+	(*StreamUpdate)(nil),           // 21: pcas.bus.v1.StreamUpdate
+	(*StreamEnd)(nil),              // 22: pcas.bus.v1.StreamEnd
+	nil,                            // 23: pcas.bus.v1.SearchRequest.AttributeFiltersEntry
+	nil,                            // 24: pcas.bus.v1.StreamConfig.AttributesEntry
+	nil,                            // 25: pcas.bus.v1.StreamStatsResponse.ErrorsEntry
+	nil,                            // 26: pcas.bus.v1.StreamUpdate.AttributesEntry
+	(*v1.Event)(nil),               // 27: pcas.events.v1.Event
+	(*timestamppb.Timestamp)(nil),  // 28: google.protobuf.Timestamp
 }
 var file_pcas_bus_v1_bus_proto_depIdxs = []int32{
-	11, // 0: pcas.bus.v1.SearchRequest.attribute_filters:type_name -> pcas.bus.v1.SearchRequest.AttributeFiltersEntry
-	13, // 1: pcas.bus.v1.SearchResponse.events:type_name -> pcas.events.v1.Event
-	6,  // 2: pcas.bus.v1.InteractRequest.config:type_name -> pcas.bus.v1.StreamConfig
-	7,  // 3: pcas.bus.v1.InteractRequest.data:type_name -> pcas.bus.v1.StreamData
-	10, // 4: pcas.bus.v1.InteractRequest.client_end:type_name -> pcas.bus.v1.StreamEnd
-	8,  // 5: pcas.bus.v1.InteractResponse.ready:type_name -> pcas.bus.v1.StreamReady
-	7,  // 6: pcas.bus.v1.InteractResponse.data:type_name -> pcas.bus.v1.StreamData
-	9,  // 7: pcas.bus.v1.InteractResponse.error:type_name -> pcas.bus.v1.StreamError
-	10, // 8: pcas.bus.v1.InteractResponse.server_end:type_name -> pcas.bus.v1.StreamEnd
-	12, // 9: pcas.bus.v1.StreamConfig.attributes:type_name -> pcas.bus.v1.StreamConfig.AttributesEntry
-	13, // 10: pcas.bus.v1.EventBusService.Publish:input_type -> pcas.events.v1.Event
-	1,  // 11: pcas.bus.v1.EventBusService.Subscribe:input_type -> pcas.bus.v1.SubscribeRequest
-	2,  // 12: pcas.bus.v1.EventBusService.Search:input_type -> pcas.bus.v1.SearchRequest
-	4,  // 13: pcas.bus.v1.EventBusService.InteractStream:input_type -> pcas.bus.v1.InteractRequest
-	0,  // 14: pcas.bus.v1.EventBusService.Publish:output_type -> pcas.bus.v1.PublishResponse
-	13, // 15: pcas.bus.v1.EventBusService.Subscribe:output_type -> pcas.events.v1.Event
-	3,  // 16: pcas.bus.v1.EventBusService.Search:output_type -> pcas.bus.v1.SearchResponse
-	5,  // 17: pcas.bus.v1.EventBusService.InteractStream:output_type -> pcas.bus.v1.InteractResponse
-	14, // [14:18] is the sub-list for method output_type
-	10, // [10:14] is the sub-list for method input_type
-	10, // [10:10] is the sub-list for extension type_name
-	10, // [10:10] is the sub-list for extension extendee
-	0,  // [0:10] is the sub-list for field type_name
+	27, // 0: pcas.bus.v1.PublishAndWaitRequest.event:type_name -> pcas.events.v1.Event
+	27, // 1: pcas.bus.v1.PublishAndWaitResponse.responses:type_name -> pcas.events.v1.Event
+	23, // 2: pcas.bus.v1.SearchRequest.attribute_filters:type_name -> pcas.bus.v1.SearchRequest.AttributeFiltersEntry
+	27, // 3: pcas.bus.v1.SearchResponse.events:type_name -> pcas.events.v1.Event
+	10, // 4: pcas.bus.v1.InteractRequest.config:type_name -> pcas.bus.v1.StreamConfig
+	14, // 5: pcas.bus.v1.InteractRequest.data:type_name -> pcas.bus.v1.StreamData
+	22, // 6: pcas.bus.v1.InteractRequest.client_end:type_name -> pcas.bus.v1.StreamEnd
+	13, // 7: pcas.bus.v1.InteractRequest.ack:type_name -> pcas.bus.v1.StreamAck
+	20, // 8: pcas.bus.v1.InteractRequest.cancel:type_name -> pcas.bus.v1.StreamCancel
+	21, // 9: pcas.bus.v1.InteractRequest.update:type_name -> pcas.bus.v1.StreamUpdate
+	15, // 10: pcas.bus.v1.InteractResponse.ready:type_name -> pcas.bus.v1.StreamReady
+	14, // 11: pcas.bus.v1.InteractResponse.data:type_name -> pcas.bus.v1.StreamData
+	16, // 12: pcas.bus.v1.InteractResponse.error:type_name -> pcas.bus.v1.StreamError
+	22, // 13: pcas.bus.v1.InteractResponse.server_end:type_name -> pcas.bus.v1.StreamEnd
+	24, // 14: pcas.bus.v1.StreamConfig.attributes:type_name -> pcas.bus.v1.StreamConfig.AttributesEntry
+	12, // 15: pcas.bus.v1.StreamConfig.resume:type_name -> pcas.bus.v1.StreamResume
+	11, // 16: pcas.bus.v1.StreamConfig.segmentation:type_name -> pcas.bus.v1.Segmentation
+	0,  // 17: pcas.bus.v1.Segmentation.mode:type_name -> pcas.bus.v1.SegmentationMode
+	1,  // 18: pcas.bus.v1.StreamError.reason:type_name -> pcas.bus.v1.StreamErrorReason
+	25, // 19: pcas.bus.v1.StreamStatsResponse.errors:type_name -> pcas.bus.v1.StreamStatsResponse.ErrorsEntry
+	19, // 20: pcas.bus.v1.StreamStatsResponse.streams:type_name -> pcas.bus.v1.StreamStats
+	28, // 21: pcas.bus.v1.StreamStats.started_at:type_name -> google.protobuf.Timestamp
+	26, // 22: pcas.bus.v1.StreamUpdate.attributes:type_name -> pcas.bus.v1.StreamUpdate.AttributesEntry
+	27, // 23: pcas.bus.v1.EventBusService.Publish:input_type -> pcas.events.v1.Event
+	3,  // 24: pcas.bus.v1.EventBusService.PublishAndWait:input_type -> pcas.bus.v1.PublishAndWaitRequest
+	5,  // 25: pcas.bus.v1.EventBusService.Subscribe:input_type -> pcas.bus.v1.SubscribeRequest
+	6,  // 26: pcas.bus.v1.EventBusService.Search:input_type -> pcas.bus.v1.SearchRequest
+	8,  // 27: pcas.bus.v1.EventBusService.InteractStream:input_type -> pcas.bus.v1.InteractRequest
+	17, // 28: pcas.bus.v1.EventBusService.GetStreamStats:input_type -> pcas.bus.v1.StreamStatsRequest
+	2,  // 29: pcas.bus.v1.EventBusService.Publish:output_type -> pcas.bus.v1.PublishResponse
+	4,  // 30: pcas.bus.v1.EventBusService.PublishAndWait:output_type -> pcas.bus.v1.PublishAndWaitResponse
+	27, // 31: pcas.bus.v1.EventBusService.Subscribe:output_type -> pcas.events.v1.Event
+	7,  // 32: pcas.bus.v1.EventBusService.Search:output_type -> pcas.bus.v1.SearchResponse
+	9,  // 33: pcas.bus.v1.EventBusService.InteractStream:output_type -> pcas.bus.v1.InteractResponse
+	18, // 34: pcas.bus.v1.EventBusService.GetStreamStats:output_type -> pcas.bus.v1.StreamStatsResponse
+	29, // [29:35] is the sub-list for method output_type
+	23, // [23:29] is the sub-list for method input_type
+	23, // [23:23] is the sub-list for extension type_name
+	23, // [23:23] is the sub-list for extension extendee
+	0,  // [0:23] is the sub-list for field type_name
 }
 
 func init() { file_pcas_bus_v1_bus_proto_init() }
@@ -817,12 +2053,15 @@ func file_pcas_bus_v1_bus_proto_init() {
 	if File_pcas_bus_v1_bus_proto != nil {
 		return
 	}
-	file_pcas_bus_v1_bus_proto_msgTypes[4].OneofWrappers = []any{
+	file_pcas_bus_v1_bus_proto_msgTypes[6].OneofWrappers = []any{
 		(*InteractRequest_Config)(nil),
 		(*InteractRequest_Data)(nil),
 		(*InteractRequest_ClientEnd)(nil),
+		(*InteractRequest_Ack)(nil),
+		(*InteractRequest_Cancel)(nil),
+		(*InteractRequest_Update)(nil),
 	}
-	file_pcas_bus_v1_bus_proto_msgTypes[5].OneofWrappers = []any{
+	file_pcas_bus_v1_bus_proto_msgTypes[7].OneofWrappers = []any{
 		(*InteractResponse_Ready)(nil),
 		(*InteractResponse_Data)(nil),
 		(*InteractResponse_Error)(nil),
@@ -833,13 +2072,14 @@ func file_pcas_bus_v1_bus_proto_init() {
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pcas_bus_v1_bus_proto_rawDesc), len(file_pcas_bus_v1_bus_proto_rawDesc)),
-			NumEnums:      0,
-			NumMessages:   13,
+			NumEnums:      2,
+			NumMessages:   25,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
 		GoTypes:           file_pcas_bus_v1_bus_proto_goTypes,
 		DependencyIndexes: file_pcas_bus_v1_bus_proto_depIdxs,
+		EnumInfos:         file_pcas_bus_v1_bus_proto_enumTypes,
 		MessageInfos:      file_pcas_bus_v1_bus_proto_msgTypes,
 	}.Build()
 	File_pcas_bus_v1_bus_proto = out.File
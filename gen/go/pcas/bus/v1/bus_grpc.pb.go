@@ -21,9 +21,11 @@ const _ = grpc.SupportPackageIsVersion9
 
 const (
 	EventBusService_Publish_FullMethodName        = "/pcas.bus.v1.EventBusService/Publish"
+	EventBusService_PublishAndWait_FullMethodName = "/pcas.bus.v1.EventBusService/PublishAndWait"
 	EventBusService_Subscribe_FullMethodName      = "/pcas.bus.v1.EventBusService/Subscribe"
 	EventBusService_Search_FullMethodName         = "/pcas.bus.v1.EventBusService/Search"
 	EventBusService_InteractStream_FullMethodName = "/pcas.bus.v1.EventBusService/InteractStream"
+	EventBusService_GetStreamStats_FullMethodName = "/pcas.bus.v1.EventBusService/GetStreamStats"
 )
 
 // EventBusServiceClient is the client API for EventBusService service.
@@ -32,8 +34,21 @@ const (
 //
 // EventBusService provides methods for publishing events to the PCAS event bus
 type EventBusServiceClient interface {
-	// Publish sends an event to the event bus
+	// Publish sends an event to the event bus. It returns once the event is
+	// stored and queued; the event is processed in the background and its
+	// pcas.response.v1 events carry the event ID as correlation_id.
+	// Publishing is idempotent on the event ID: a client may retry with the
+	// same ID and the event is processed only once. Published IDs are
+	// remembered in memory, so a retry after a server restart is processed
+	// again.
+	// The event must have an id, a source, a dot-separated lowercase type and
+	// specversion "1.0", and its data and attributes must be within the size
+	// limits; a missing time is filled in. Invalid events fail with
+	// INVALID_ARGUMENT and a BadRequest detail listing the invalid fields.
 	Publish(ctx context.Context, in *v1.Event, opts ...grpc.CallOption) (*PublishResponse, error)
+	// PublishAndWait sends an event like Publish and waits for the responses
+	// correlated with it, until the deadline of the call or timeout_ms.
+	PublishAndWait(ctx context.Context, in *PublishAndWaitRequest, opts ...grpc.CallOption) (*PublishAndWaitResponse, error)
 	// Subscribe allows clients to receive a stream of events
 	Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[v1.Event], error)
 	// Search performs semantic search on stored events
@@ -47,14 +62,19 @@ type EventBusServiceClient interface {
 	// semantic segmentation of continuous user input into meaningful units (e.g., complete
 	// sentences, questions, or logical chunks) before sending each StreamData message.
 	//
-	// PCAS BEHAVIOR: PCAS will NOT perform sentence segmentation or semantic slicing on
-	// streaming data. Each StreamData message received is treated as an independent,
-	// complete processing unit. The AI provider will process each chunk as a standalone
-	// input without waiting for or combining with subsequent chunks.
+	// PCAS BEHAVIOR: By default PCAS will NOT perform sentence segmentation or semantic
+	// slicing on streaming data. Each StreamData message received is treated as an
+	// independent, complete processing unit. The AI provider will process each chunk as
+	// a standalone input without waiting for or combining with subsequent chunks.
 	//
 	// This design ensures predictable latency and allows clients to implement custom
-	// segmentation strategies appropriate for their specific use cases.
+	// segmentation strategies appropriate for their specific use cases. Clients that do
+	// not need a custom strategy can opt into server-side segmentation with
+	// StreamConfig.segmentation, which buffers text input and re-cuts it into
+	// sentences or clauses before it reaches the provider.
 	InteractStream(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[InteractRequest, InteractResponse], error)
+	// GetStreamStats reports the live InteractStreams for monitoring
+	GetStreamStats(ctx context.Context, in *StreamStatsRequest, opts ...grpc.CallOption) (*StreamStatsResponse, error)
 }
 
 type eventBusServiceClient struct {
@@ -75,6 +95,16 @@ func (c *eventBusServiceClient) Publish(ctx context.Context, in *v1.Event, opts
 	return out, nil
 }
 
+func (c *eventBusServiceClient) PublishAndWait(ctx context.Context, in *PublishAndWaitRequest, opts ...grpc.CallOption) (*PublishAndWaitResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(PublishAndWaitResponse)
+	err := c.cc.Invoke(ctx, EventBusService_PublishAndWait_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *eventBusServiceClient) Subscribe(ctx context.Context, in *SubscribeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[v1.Event], error) {
 	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
 	stream, err := c.cc.NewStream(ctx, &EventBusService_ServiceDesc.Streams[0], EventBusService_Subscribe_FullMethodName, cOpts...)
@@ -117,14 +147,37 @@ func (c *eventBusServiceClient) InteractStream(ctx context.Context, opts ...grpc
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type EventBusService_InteractStreamClient = grpc.BidiStreamingClient[InteractRequest, InteractResponse]
 
+func (c *eventBusServiceClient) GetStreamStats(ctx context.Context, in *StreamStatsRequest, opts ...grpc.CallOption) (*StreamStatsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(StreamStatsResponse)
+	err := c.cc.Invoke(ctx, EventBusService_GetStreamStats_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // EventBusServiceServer is the server API for EventBusService service.
 // All implementations must embed UnimplementedEventBusServiceServer
 // for forward compatibility.
 //
 // EventBusService provides methods for publishing events to the PCAS event bus
 type EventBusServiceServer interface {
-	// Publish sends an event to the event bus
+	// Publish sends an event to the event bus. It returns once the event is
+	// stored and queued; the event is processed in the background and its
+	// pcas.response.v1 events carry the event ID as correlation_id.
+	// Publishing is idempotent on the event ID: a client may retry with the
+	// same ID and the event is processed only once. Published IDs are
+	// remembered in memory, so a retry after a server restart is processed
+	// again.
+	// The event must have an id, a source, a dot-separated lowercase type and
+	// specversion "1.0", and its data and attributes must be within the size
+	// limits; a missing time is filled in. Invalid events fail with
+	// INVALID_ARGUMENT and a BadRequest detail listing the invalid fields.
 	Publish(context.Context, *v1.Event) (*PublishResponse, error)
+	// PublishAndWait sends an event like Publish and waits for the responses
+	// correlated with it, until the deadline of the call or timeout_ms.
+	PublishAndWait(context.Context, *PublishAndWaitRequest) (*PublishAndWaitResponse, error)
 	// Subscribe allows clients to receive a stream of events
 	Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[v1.Event]) error
 	// Search performs semantic search on stored events
@@ -138,14 +191,19 @@ type EventBusServiceServer interface {
 	// semantic segmentation of continuous user input into meaningful units (e.g., complete
 	// sentences, questions, or logical chunks) before sending each StreamData message.
 	//
-	// PCAS BEHAVIOR: PCAS will NOT perform sentence segmentation or semantic slicing on
-	// streaming data. Each StreamData message received is treated as an independent,
-	// complete processing unit. The AI provider will process each chunk as a standalone
-	// input without waiting for or combining with subsequent chunks.
+	// PCAS BEHAVIOR: By default PCAS will NOT perform sentence segmentation or semantic
+	// slicing on streaming data. Each StreamData message received is treated as an
+	// independent, complete processing unit. The AI provider will process each chunk as
+	// a standalone input without waiting for or combining with subsequent chunks.
 	//
 	// This design ensures predictable latency and allows clients to implement custom
-	// segmentation strategies appropriate for their specific use cases.
+	// segmentation strategies appropriate for their specific use cases. Clients that do
+	// not need a custom strategy can opt into server-side segmentation with
+	// StreamConfig.segmentation, which buffers text input and re-cuts it into
+	// sentences or clauses before it reaches the provider.
 	InteractStream(grpc.BidiStreamingServer[InteractRequest, InteractResponse]) error
+	// GetStreamStats reports the live InteractStreams for monitoring
+	GetStreamStats(context.Context, *StreamStatsRequest) (*StreamStatsResponse, error)
 	mustEmbedUnimplementedEventBusServiceServer()
 }
 
@@ -159,6 +217,9 @@ type UnimplementedEventBusServiceServer struct{}
 func (UnimplementedEventBusServiceServer) Publish(context.Context, *v1.Event) (*PublishResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Publish not implemented")
 }
+func (UnimplementedEventBusServiceServer) PublishAndWait(context.Context, *PublishAndWaitRequest) (*PublishAndWaitResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishAndWait not implemented")
+}
 func (UnimplementedEventBusServiceServer) Subscribe(*SubscribeRequest, grpc.ServerStreamingServer[v1.Event]) error {
 	return status.Errorf(codes.Unimplemented, "method Subscribe not implemented")
 }
@@ -168,6 +229,9 @@ func (UnimplementedEventBusServiceServer) Search(context.Context, *SearchRequest
 func (UnimplementedEventBusServiceServer) InteractStream(grpc.BidiStreamingServer[InteractRequest, InteractResponse]) error {
 	return status.Errorf(codes.Unimplemented, "method InteractStream not implemented")
 }
+func (UnimplementedEventBusServiceServer) GetStreamStats(context.Context, *StreamStatsRequest) (*StreamStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStreamStats not implemented")
+}
 func (UnimplementedEventBusServiceServer) mustEmbedUnimplementedEventBusServiceServer() {}
 func (UnimplementedEventBusServiceServer) testEmbeddedByValue()                         {}
 
@@ -207,6 +271,24 @@ func _EventBusService_Publish_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _EventBusService_PublishAndWait_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublishAndWaitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventBusServiceServer).PublishAndWait(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventBusService_PublishAndWait_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventBusServiceServer).PublishAndWait(ctx, req.(*PublishAndWaitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _EventBusService_Subscribe_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(SubscribeRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -243,6 +325,24 @@ func _EventBusService_InteractStream_Handler(srv interface{}, stream grpc.Server
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type EventBusService_InteractStreamServer = grpc.BidiStreamingServer[InteractRequest, InteractResponse]
 
+func _EventBusService_GetStreamStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StreamStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(EventBusServiceServer).GetStreamStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: EventBusService_GetStreamStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(EventBusServiceServer).GetStreamStats(ctx, req.(*StreamStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // EventBusService_ServiceDesc is the grpc.ServiceDesc for EventBusService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -254,10 +354,18 @@ var EventBusService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "Publish",
 			Handler:    _EventBusService_Publish_Handler,
 		},
+		{
+			MethodName: "PublishAndWait",
+			Handler:    _EventBusService_PublishAndWait_Handler,
+		},
 		{
 			MethodName: "Search",
 			Handler:    _EventBusService_Search_Handler,
 		},
+		{
+			MethodName: "GetStreamStats",
+			Handler:    _EventBusService_GetStreamStats_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -0,0 +1,161 @@
+// Package budget implements token and cost accounting for compute providers
+// and enforces the per-user and per-dApp budgets configured in policy.yaml.
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+// ErrBudgetExceeded indicates the event was rejected because a budget is exhausted
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// Manager checks budgets and records provider usage
+type Manager struct {
+	budgets []policy.Budget
+	pricing map[string]policy.Pricing
+	store   storage.UsageStore
+	now     func() time.Time // Replaceable clock for tests
+}
+
+// Exceeded describes a budget that has been exhausted
+type Exceeded struct {
+	Budget      *policy.Budget
+	Key         string // The user_id or source the budget was accounted for
+	Used        storage.UsageTotals
+	PeriodStart time.Time
+}
+
+// NewManager creates a budget manager for the given budgets and provider pricing
+func NewManager(budgets []policy.Budget, providerConfigs []policy.ProviderConfig, store storage.UsageStore) *Manager {
+	pricing := make(map[string]policy.Pricing)
+	for _, providerConfig := range providerConfigs {
+		if providerConfig.Pricing != nil {
+			pricing[providerConfig.Name] = *providerConfig.Pricing
+		}
+	}
+	
+	return &Manager{
+		budgets: budgets,
+		pricing: pricing,
+		store:   store,
+		now:     time.Now,
+	}
+}
+
+// Check returns the first exhausted budget that applies to a call of the provider
+// on behalf of the user and source, or nil if the call is within budget
+func (m *Manager) Check(ctx context.Context, userID, source, provider string) (*Exceeded, error) {
+	return m.CheckEstimate(ctx, userID, source, provider, 0)
+}
+
+// CheckEstimate is like Check, but also treats a budget as exhausted if the
+// estimated prompt tokens of the pending call would take it over its limit
+func (m *Manager) CheckEstimate(ctx context.Context, userID, source, provider string, promptTokens int) (*Exceeded, error) {
+	estimatedCost := m.Cost(provider, providers.Usage{PromptTokens: promptTokens})
+	
+	for i := range m.budgets {
+		budget := &m.budgets[i]
+		
+		key, ok := applies(budget, userID, source, provider)
+		if !ok {
+			continue
+		}
+		
+		periodStart := m.periodStart(budget.Period)
+		query := storage.UsageQuery{
+			Providers: budget.Providers,
+			Since:     periodStart,
+		}
+		if budget.Scope == policy.BudgetScopeUser {
+			query.UserID = &key
+		} else {
+			query.Source = &key
+		}
+		
+		used, err := m.store.SumUsage(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check budget %s: %w", budget.Name, err)
+		}
+		
+		tokensExceeded := budget.MaxTokens > 0 &&
+			(used.TotalTokens() >= budget.MaxTokens || used.TotalTokens()+int64(promptTokens) > budget.MaxTokens)
+		costExceeded := budget.MaxCost > 0 &&
+			(used.Cost >= budget.MaxCost || used.Cost+estimatedCost > budget.MaxCost)
+		if tokensExceeded || costExceeded {
+			return &Exceeded{
+				Budget:      budget,
+				Key:         key,
+				Used:        used,
+				PeriodStart: periodStart,
+			}, nil
+		}
+	}
+	
+	return nil, nil
+}
+
+// Record persists the usage of a provider call together with its cost
+func (m *Manager) Record(ctx context.Context, eventID, userID, source, provider string, usage providers.Usage) error {
+	return m.store.RecordUsage(ctx, storage.UsageRecord{
+		EventID:          eventID,
+		UserID:           userID,
+		Source:           source,
+		Provider:         provider,
+		Model:            usage.Model,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		Cost:             m.Cost(provider, usage),
+		Time:             m.now(),
+	})
+}
+
+// Cost computes the cost of the usage from the provider's pricing
+func (m *Manager) Cost(provider string, usage providers.Usage) float64 {
+	pricing, ok := m.pricing[provider]
+	if !ok {
+		return 0
+	}
+	return float64(usage.PromptTokens)/1000*pricing.PromptPer1K +
+		float64(usage.CompletionTokens)/1000*pricing.CompletionPer1K
+}
+
+// applies reports whether the budget covers the call and returns the accounting key
+func applies(budget *policy.Budget, userID, source, provider string) (string, bool) {
+	if len(budget.Providers) > 0 {
+		covered := false
+		for _, name := range budget.Providers {
+			if name == provider {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return "", false
+		}
+	}
+	
+	key := userID
+	if budget.Scope == policy.BudgetScopeSource {
+		key = source
+	}
+	if budget.Match != "" && budget.Match != key {
+		return "", false
+	}
+	return key, true
+}
+
+// periodStart returns the start of the current budget period in local time
+func (m *Manager) periodStart(period string) time.Time {
+	now := m.now()
+	if period == policy.BudgetPeriodMonthly {
+		return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	}
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+}
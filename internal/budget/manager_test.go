@@ -0,0 +1,123 @@
+package budget
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+func newTestManager(t *testing.T, budgets []policy.Budget) *Manager {
+	t.Helper()
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	usageStore, ok := store.(storage.UsageStore)
+	if !ok {
+		t.Fatal("SQLite storage does not implement UsageStore")
+	}
+
+	providerConfigs := []policy.ProviderConfig{
+		{Name: "openai-gpt4", Pricing: &policy.Pricing{PromptPer1K: 1, CompletionPer1K: 2}},
+	}
+	return NewManager(budgets, providerConfigs, usageStore)
+}
+
+func TestManager_TokenBudgetPerUser(t *testing.T) {
+	manager := newTestManager(t, []policy.Budget{{
+		Name:        "daily-openai",
+		Scope:       policy.BudgetScopeUser,
+		Providers:   []string{"openai-gpt4"},
+		Period:      policy.BudgetPeriodDaily,
+		MaxTokens:   1000,
+		OnExceeded:  policy.BudgetActionDowngrade,
+		DowngradeTo: "ollama-llama3",
+	}})
+	ctx := context.Background()
+
+	if err := manager.Record(ctx, "e1", "alice", "dapp", "openai-gpt4", providers.Usage{PromptTokens: 600, CompletionTokens: 300}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	exceeded, err := manager.Check(ctx, "alice", "dapp", "openai-gpt4")
+	if err != nil || exceeded != nil {
+		t.Fatalf("Expected alice to be within budget, got %+v, %v", exceeded, err)
+	}
+
+	manager.Record(ctx, "e2", "alice", "dapp", "openai-gpt4", providers.Usage{PromptTokens: 100})
+	exceeded, err = manager.Check(ctx, "alice", "dapp", "openai-gpt4")
+	if err != nil || exceeded == nil {
+		t.Fatalf("Expected alice to exceed the budget, got %+v, %v", exceeded, err)
+	}
+	if exceeded.Key != "alice" || exceeded.Used.TotalTokens() != 1000 {
+		t.Errorf("Unexpected exceeded details: %+v", exceeded)
+	}
+
+	// Other users and uncovered providers are not affected
+	if exceeded, _ := manager.Check(ctx, "bob", "dapp", "openai-gpt4"); exceeded != nil {
+		t.Error("Expected bob to be within budget")
+	}
+	if exceeded, _ := manager.Check(ctx, "alice", "dapp", "ollama-llama3"); exceeded != nil {
+		t.Error("Expected uncovered provider to be within budget")
+	}
+
+	// Usage from the previous day does not count
+	manager.now = func() time.Time { return time.Now().Add(24 * time.Hour) }
+	if exceeded, _ := manager.Check(ctx, "alice", "dapp", "openai-gpt4"); exceeded != nil {
+		t.Error("Expected budget to reset the next day")
+	}
+}
+
+func TestManager_CostBudgetPerSource(t *testing.T) {
+	manager := newTestManager(t, []policy.Budget{{
+		Name:    "monthly-cost",
+		Scope:   policy.BudgetScopeSource,
+		Match:   "expensive-dapp",
+		Period:  policy.BudgetPeriodMonthly,
+		MaxCost: 3,
+	}})
+	ctx := context.Background()
+
+	// 1000 prompt tokens at 1/1K plus 1000 completion tokens at 2/1K
+	usage := providers.Usage{PromptTokens: 1000, CompletionTokens: 1000}
+	if cost := manager.Cost("openai-gpt4", usage); cost != 3 {
+		t.Fatalf("Expected cost 3, got %v", cost)
+	}
+
+	manager.Record(ctx, "e1", "alice", "expensive-dapp", "openai-gpt4", usage)
+	manager.Record(ctx, "e2", "alice", "cheap-dapp", "openai-gpt4", usage)
+
+	if exceeded, _ := manager.Check(ctx, "alice", "expensive-dapp", "openai-gpt4"); exceeded == nil {
+		t.Error("Expected expensive-dapp to exceed its cost budget")
+	}
+	if exceeded, _ := manager.Check(ctx, "alice", "cheap-dapp", "openai-gpt4"); exceeded != nil {
+		t.Error("Expected budget to only match expensive-dapp")
+	}
+}
+
+func TestManager_CheckEstimate(t *testing.T) {
+	manager := newTestManager(t, []policy.Budget{{
+		Name:      "daily-openai",
+		Scope:     policy.BudgetScopeUser,
+		Providers: []string{"openai-gpt4"},
+		Period:    policy.BudgetPeriodDaily,
+		MaxTokens: 1000,
+	}})
+	ctx := context.Background()
+
+	manager.Record(ctx, "e1", "alice", "dapp", "openai-gpt4", providers.Usage{PromptTokens: 700, CompletionTokens: 200})
+
+	// A prompt that still fits passes, one that would overrun the budget does not
+	if exceeded, err := manager.CheckEstimate(ctx, "alice", "dapp", "openai-gpt4", 100); err != nil || exceeded != nil {
+		t.Errorf("Expected 100 more tokens to fit, got %+v, %v", exceeded, err)
+	}
+	if exceeded, err := manager.CheckEstimate(ctx, "alice", "dapp", "openai-gpt4", 101); err != nil || exceeded == nil {
+		t.Errorf("Expected 101 more tokens to exceed the budget, got %+v, %v", exceeded, err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
 	"github.com/soaringjerry/pcas/internal/bus"
 	"github.com/soaringjerry/pcas/internal/policy"
@@ -132,15 +133,12 @@ func TestEchoEventRouting(t *testing.T) {
 	}
 	echoEvent.Data, _ = anypb.New(structData)
 
-	// 8. Publish the echo event
-	_, err = server.Publish(ctx, echoEvent)
+	// 8. Publish the echo event and wait for its processing
+	_, err = server.PublishAndWait(ctx, &busv1.PublishAndWaitRequest{Event: echoEvent})
 	if err != nil {
 		t.Fatalf("Failed to publish echo event: %v", err)
 	}
 
-	// 9. Wait a short time for processing
-	time.Sleep(100 * time.Millisecond)
-
 	// 10. Check that the response event was created and stored
 	// The response event should have type "pcas.response.v1"
 	// and should contain the MockProvider's response
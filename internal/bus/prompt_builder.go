@@ -0,0 +1,195 @@
+package bus
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	
+	"github.com/soaringjerry/pcas/internal/tokenizer"
+)
+
+// Context sections of the prompt, following the three-part layout of ADR 001:
+// system persona, context chunks, then the user prompt
+const (
+	contextSectionPreferences = "preferences"
+	contextSectionSession     = "session"
+	contextSectionMemory      = "memory"
+)
+
+const (
+	// Chunks shorter than this are only deduplicated on exact matches
+	minOverlapChars = 24
+
+	// Approximate tokens for the section headers and separators of the system message
+	promptOverheadTokens = 120
+)
+
+// contextChunk is a piece of retrieved context for the prompt
+type contextChunk struct {
+	eventID string
+	section string
+	text    string    // Rendered markdown as it appears in the prompt
+	content string    // Plain content used to detect overlapping chunks
+	score   float32   // Relevance score, zero for session events and preferences
+	time    time.Time // Event time, used to order session events
+	rank    int       // 1-based position in priority order, set by the builder
+	tokens  int       // Token count of the text, set by the builder
+}
+
+// builtPrompt is the result of fitting the context chunks into the token budget
+type builtPrompt struct {
+	system   string
+	included []contextChunk
+	dropped  []contextChunk // Chunks that did not fit into the budget
+	tokens   int
+}
+
+// promptBuilder assembles the RAG system message from session events, long-term
+// memories and user preferences. Chunks are added in priority order; overlapping
+// chunks are skipped and the rest is fitted greedily into the token budget.
+type promptBuilder struct {
+	maxTokens   int
+	countTokens func(string) int
+
+	chunks   []contextChunk
+	seenIDs  map[string]bool
+	contents []string
+}
+
+// newPromptBuilder creates a builder for the given context token budget. Tokens are
+// estimated until countTokens is set to the tokenizer of the target model.
+func newPromptBuilder(maxTokens int) *promptBuilder {
+	return &promptBuilder{
+		maxTokens:   maxTokens,
+		countTokens: tokenizer.Estimate().CountTokens,
+		seenIDs:     make(map[string]bool),
+	}
+}
+
+// add queues a chunk and reports whether it was accepted. Chunks for an event that
+// was already added, or whose content overlaps an earlier chunk, are rejected.
+func (b *promptBuilder) add(chunk contextChunk) bool {
+	if chunk.eventID != "" && b.seenIDs[chunk.eventID] {
+		return false
+	}
+
+	content := normalizeChunkContent(chunk.content)
+	if content == "" {
+		content = normalizeChunkContent(chunk.text)
+	}
+	for _, existing := range b.contents {
+		if chunksOverlap(existing, content) {
+			return false
+		}
+	}
+
+	if chunk.eventID != "" {
+		b.seenIDs[chunk.eventID] = true
+	}
+	chunk.rank = len(b.chunks) + 1
+	b.contents = append(b.contents, content)
+	b.chunks = append(b.chunks, chunk)
+	return true
+}
+
+// build fits the queued chunks into the budget in priority order and renders the
+// system message. It returns an empty system message if no chunk fits.
+func (b *promptBuilder) build() builtPrompt {
+	var result builtPrompt
+	used := promptOverheadTokens
+
+	for _, chunk := range b.chunks {
+		tokens := b.countTokens(chunk.text)
+		chunk.tokens = tokens
+		if used+tokens > b.maxTokens {
+			// A smaller chunk further down may still fit
+			result.dropped = append(result.dropped, chunk)
+			continue
+		}
+		used += tokens
+		result.included = append(result.included, chunk)
+	}
+
+	if len(result.included) == 0 {
+		return result
+	}
+
+	result.tokens = used
+	result.system = renderSystemMessage(result.included, len(result.dropped))
+	return result
+}
+
+// renderSystemMessage lays out the included chunks by section
+func renderSystemMessage(chunks []contextChunk, dropped int) string {
+	var preferences, session, memories []contextChunk
+	for _, chunk := range chunks {
+		switch chunk.section {
+		case contextSectionPreferences:
+			preferences = append(preferences, chunk)
+		case contextSectionSession:
+			session = append(session, chunk)
+		default:
+			memories = append(memories, chunk)
+		}
+	}
+
+	// The session reads as a conversation, oldest first
+	sort.SliceStable(session, func(i, j int) bool {
+		return session[i].time.Before(session[j].time)
+	})
+
+	var buf strings.Builder
+	buf.WriteString("You are a personal AI assistant. Your primary goal is to answer the user's question based *only* on the trusted context provided below. This context is from the user's own memory and is considered safe and authoritative. Do not use your general knowledge unless the context is insufficient.\n")
+
+	if len(preferences) > 0 {
+		buf.WriteString("\n---\nUSER PREFERENCES (follow these when answering):\n")
+		for _, chunk := range preferences {
+			buf.WriteString(chunk.text)
+			buf.WriteString("\n")
+		}
+	}
+
+	if len(session) > 0 || len(memories) > 0 {
+		buf.WriteString("\n---\nRELEVANT HISTORICAL CONTEXT:\n")
+	}
+	if len(session) > 0 {
+		buf.WriteString("\n## Current Session\n\n")
+		for _, chunk := range session {
+			buf.WriteString(chunk.text)
+			buf.WriteString("\n---\n\n")
+		}
+	}
+	if len(memories) > 0 {
+		buf.WriteString("\n## Relevant Historical Context\n\n")
+		for _, chunk := range memories {
+			buf.WriteString(chunk.text)
+			buf.WriteString("\n---\n\n")
+		}
+	}
+	if dropped > 0 {
+		buf.WriteString(fmt.Sprintf("\n*... and %d more relevant events (truncated due to token limit)*\n", dropped))
+	}
+	buf.WriteString("---\n")
+
+	return buf.String()
+}
+
+// normalizeChunkContent lowercases the content and collapses whitespace
+func normalizeChunkContent(content string) string {
+	return strings.ToLower(strings.Join(strings.Fields(content), " "))
+}
+
+// chunksOverlap reports whether two normalized contents repeat each other
+func chunksOverlap(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if a == b {
+		return true
+	}
+	if len(a) < minOverlapChars || len(b) < minOverlapChars {
+		return false
+	}
+	return strings.Contains(a, b) || strings.Contains(b, a)
+}
@@ -0,0 +1,86 @@
+package bus
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPromptBuilder_Deduplicates(t *testing.T) {
+	builder := newPromptBuilder(4000)
+
+	testCases := []struct {
+		name         string
+		chunk        contextChunk
+		expectAccept bool
+	}{
+		{
+			name:         "first chunk",
+			chunk:        contextChunk{eventID: "e1", section: contextSectionSession, text: "note 1", content: "Remember to renew the passport before July"},
+			expectAccept: true,
+		},
+		{
+			name:         "same event again",
+			chunk:        contextChunk{eventID: "e1", section: contextSectionMemory, text: "note 1", content: "something else entirely"},
+			expectAccept: false,
+		},
+		{
+			name:         "overlapping content",
+			chunk:        contextChunk{eventID: "e2", section: contextSectionMemory, text: "note 2", content: "remember to renew   the passport"},
+			expectAccept: false,
+		},
+		{
+			name:         "short content only matches exactly",
+			chunk:        contextChunk{eventID: "e3", section: contextSectionMemory, text: "note 3", content: "passport"},
+			expectAccept: true,
+		},
+		{
+			name:         "distinct content",
+			chunk:        contextChunk{eventID: "e4", section: contextSectionMemory, text: "note 4", content: "Book flights to Tokyo"},
+			expectAccept: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if accepted := builder.add(tc.chunk); accepted != tc.expectAccept {
+				t.Errorf("Expected accepted = %v, got %v", tc.expectAccept, accepted)
+			}
+		})
+	}
+}
+
+func TestPromptBuilder_BudgetAndLayout(t *testing.T) {
+	base := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	builder := newPromptBuilder(promptOverheadTokens + 30)
+	builder.countTokens = func(text string) int { return len(text) }
+
+	// Session chunks are added newest first but rendered oldest first
+	builder.add(contextChunk{eventID: "s2", section: contextSectionSession, text: "SESSION-2", time: base.Add(time.Minute)})
+	builder.add(contextChunk{eventID: "s1", section: contextSectionSession, text: "SESSION-1", time: base})
+	builder.add(contextChunk{eventID: "m1", section: contextSectionMemory, text: "MEMORY-TOO-LONG-FOR-THE-BUDGET"})
+	builder.add(contextChunk{eventID: "p1", section: contextSectionPreferences, text: "- tone: dry"})
+
+	prompt := builder.build()
+
+	if len(prompt.included) != 3 || len(prompt.dropped) != 1 || prompt.dropped[0].eventID != "m1" {
+		t.Fatalf("Expected the memory chunk to be dropped, got included=%v dropped=%v", prompt.included, prompt.dropped)
+	}
+
+	preferences := strings.Index(prompt.system, "- tone: dry")
+	first := strings.Index(prompt.system, "SESSION-1")
+	second := strings.Index(prompt.system, "SESSION-2")
+	if preferences < 0 || first < 0 || second < 0 {
+		t.Fatalf("Expected preferences and session events in system message:\n%s", prompt.system)
+	}
+	if !(preferences < first && first < second) {
+		t.Errorf("Expected persona before session events in time order:\n%s", prompt.system)
+	}
+	if !strings.Contains(prompt.system, "1 more relevant events") {
+		t.Errorf("Expected truncation note in system message:\n%s", prompt.system)
+	}
+
+	if empty := newPromptBuilder(10).build(); empty.system != "" {
+		t.Errorf("Expected empty system message without chunks, got %q", empty.system)
+	}
+}
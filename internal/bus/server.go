@@ -17,8 +17,10 @@ import (
 	
 	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/budget"
 	"github.com/soaringjerry/pcas/internal/policy"
 	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/ranking"
 	"github.com/soaringjerry/pcas/internal/storage"
 )
 
@@ -41,11 +43,47 @@ type Server struct {
 	
 	// Background task tracking
 	vectorizeWG  sync.WaitGroup
+	vectorizeTimeout time.Duration // Zero means defaultVectorizeTimeout
+	backgroundWG sync.WaitGroup // Periodic jobs: memory consolidation and session summarization
+	
+	// Token accounting and budget enforcement (optional)
+	budgetManager *budget.Manager
+	
+	// Near-duplicate detection and consolidation (optional)
+	deduplication *policy.Deduplication
+	
+	// Compaction of idle sessions into summary facts (optional)
+	summarization *policy.Summarization
+	
+	// Live InteractStream sessions by stream ID, kept for resumption
+	streamSessions    map[string]*interactSession
+	streamSessionsMu  sync.Mutex
+	streamResumeGrace time.Duration // Zero means defaultStreamResumeGrace
+	streamsStarted    uint64
+	streamErrors      map[busv1.StreamErrorReason]uint64 // Failed streams by reason
+	
+	// Accepted events waiting to be processed, started with the first event
+	publishQueue      chan *publishJob
+	publishOnce       sync.Once
+	publishWG         sync.WaitGroup
+	publishQueueSize  int                // Zero means defaultPublishQueueSize
+	publishWorkers    int                // Zero means defaultPublishWorkers
+	processingTimeout time.Duration      // Zero means defaultProcessingTimeout
+	processingCtx     context.Context    // Cancelled by StopProcessing
+	stopProcessing    context.CancelFunc
+	
+	// Published events by ID within the idempotency window, to detect retries
+	published         map[string]*publishRecord
+	publishedOrder    []*publishRecord // Oldest first
+	publishedMu       sync.Mutex
+	idempotencyWindow time.Duration // Zero means defaultIdempotencyWindow
+	maxEventDataBytes  int           // Zero means defaultMaxEventDataBytes
+	maxEventAttributes int           // Zero means defaultMaxEventAttributes
 }
 
 // NewServer creates a new bus server instance
 func NewServer(policyEngine *policy.Engine, providerMap map[string]providers.ComputeProvider, storage storage.Storage) *Server {
-	return &Server{
+	s := &Server{
 		policyEngine: policyEngine,
 		providers:    providerMap,
 		storage:      storage,
@@ -54,34 +92,29 @@ func NewServer(policyEngine *policy.Engine, providerMap map[string]providers.Com
 		rateLimiter:    rate.NewLimiter(rate.Every(time.Second), 10), // 10 requests per second
 		singleFlight:   &singleflight.Group{},
 	}
+	s.processingCtx, s.stopProcessing = context.WithCancel(context.Background())
+	return s
 }
 
-// Publish handles incoming events from clients
+// Publish validates and stores an incoming event and queues it for processing.
+// Events with an invalid envelope are rejected with INVALID_ARGUMENT. The
+// responses are broadcast to subscribers once a worker has processed it. A
+// retry of an event published within the idempotency window is reported as a
+// duplicate with the original outcome, and not stored or processed again.
 func (s *Server) Publish(ctx context.Context, event *eventsv1.Event) (*busv1.PublishResponse, error) {
-	// Store the incoming event immediately
-	if err := s.storage.StoreEvent(ctx, event, nil); err != nil {
-		log.Printf("Failed to store incoming event: %v", err)
-		// Continue processing even if storage fails
-	}
-	
-	// Start vectorization in background if providers are available
-	// Only vectorize fact events
-	if s.embeddingProvider != nil {
-		if IsFactEvent(event.Type) {
-			log.Printf("Will vectorize fact event: type=%s, id=%s", event.Type, event.Id)
-			s.vectorizeWG.Add(1)  // Increment counter before starting goroutine
-			go s.vectorizeEvent(event)
-		} else {
-			log.Printf("Skipping vectorization for non-fact event: type=%s, id=%s", event.Type, event.Id)
-		}
+	record, duplicate, err := s.acceptEvent(ctx, event)
+	if err != nil {
+		return nil, err
 	}
-	
-	log.Printf("Received event: ID=%s, Type=%s, Source=%s", event.Id, event.Type, event.Source)
-	
-	if event.Subject != "" {
-		log.Printf("  Subject: %s", event.Subject)
+	if err := record.waitQueued(ctx); err != nil {
+		return nil, err
 	}
-	
+	return &busv1.PublishResponse{EventId: event.Id, Accepted: record.accepted, Duplicate: duplicate}, nil
+}
+
+// processEvent runs the action of an accepted event and returns the response
+// events it emitted
+func (s *Server) processEvent(ctx context.Context, event *eventsv1.Event, action *policy.Action) ([]*eventsv1.Event, error) {
 	// Extract event data if present
 	var requestData map[string]interface{}
 	if event.Data != nil {
@@ -110,76 +143,118 @@ func (s *Server) Publish(ctx context.Context, event *eventsv1.Event) (*busv1.Pub
 		}
 	}
 	
-	// Use policy engine to select provider
-	providerName, promptTemplate := s.policyEngine.SelectProvider(event)
-	if providerName == "" {
-		log.Printf("No provider configured for event type: %s", event.Type)
-		return &busv1.PublishResponse{}, nil
+	// Apply RAG enhancement when the rule enables it, for any provider
+	var retrieval *ragRetrieval
+	if ragConfig := ragConfigFor(action); ragConfig != nil && s.embeddingProvider != nil && s.storage != nil {
+		// RAG adds its messages and flags to the request data, so events without
+		// data need a map that the providers receive as well
+		if requestData == nil {
+			requestData = make(map[string]interface{})
+		}
+		retrieval = s.applyRAGEnhancement(ctx, event, requestData, ragConfig, actionProviders(action))
 	}
 	
-	log.Printf("Selected provider: %s", providerName)
-	if promptTemplate != "" {
-		log.Printf("Using prompt template: %s", promptTemplate)
+	// Fan-out rules send the event to several providers in parallel
+	if action.FanOut != nil {
+		responses, err := s.executeFanOut(ctx, event, action.FanOut, action.Cache, requestData, retrieval)
+		if err != nil {
+			return nil, fmt.Errorf("fan-out execution failed: %w", err)
+		}
+		return responses, nil
 	}
 	
-	// Get the provider instance
-	provider, exists := s.providers[providerName]
-	if !exists {
-		return nil, fmt.Errorf("provider not found: %s", providerName)
-	}
+	providerName := action.Provider
 	
-	// Apply RAG enhancement only for LLM providers
-	if providerName == "openai-gpt4" && s.embeddingProvider != nil && s.storage != nil {
-		s.applyRAGEnhancement(ctx, event, requestData)
+	log.Printf("Selected provider: %s", providerName)
+	if len(action.Fallbacks) > 0 {
+		log.Printf("Fallback providers: %v", action.Fallbacks)
+	}
+	if action.PromptTemplate != "" {
+		log.Printf("Using prompt template: %s", action.PromptTemplate)
 	}
 	
-	// Execute the provider
-	result, err := provider.Execute(ctx, requestData)
+	// Execute the provider chain, failing over to the next provider on standard errors
+	// Providers with an open circuit are skipped
+	chain := s.policyEngine.HealthyProviders(action.ProviderChain())
+	execution, err := s.executeWithFailover(ctx, event, chain, requestData, action.Cache)
 	if err != nil {
 		return nil, fmt.Errorf("provider execution failed: %w", err)
 	}
 	
-	log.Printf("Provider response: %s", result)
+	log.Printf("Provider response: %s", execution.response)
 	
-	// Create a response event
-	responseEvent := &eventsv1.Event{
+	// Add the response data
+	responseData := map[string]interface{}{
+		"original_event_id": event.Id,
+		"provider":          execution.provider,
+		"response":          execution.response,
+		"cached":            execution.cached,
+	}
+	if execution.provider != providerName {
+		// Record the failover path so consumers know which provider actually answered
+		responseData["requested_provider"] = providerName
+		failedList := make([]interface{}, len(execution.failedProviders))
+		for i, name := range execution.failedProviders {
+			failedList[i] = name
+		}
+		responseData["failed_providers"] = failedList
+	}
+	
+	return []*eventsv1.Event{s.emitResponse(ctx, event, responseData, retrieval)}, nil
+}
+
+// emitResponse creates a pcas.response.v1 event for the original event, stores it
+// and broadcasts it to all subscribers
+func (s *Server) emitResponse(ctx context.Context, event *eventsv1.Event, responseData map[string]interface{}, retrieval *ragRetrieval) *eventsv1.Event {
+	// Cite the events that were injected into the prompt
+	if retrieval != nil {
+		responseData["rag"] = retrieval.responseData()
+	}
+	
+	responseEvent := newServerEvent("pcas.response.v1", fmt.Sprintf("response-to-%s", event.Id), event, responseData)
+	
+	// Don't vectorize response events - they don't contain user intent
+	// Only user-generated content should be in vector space
+	s.publishServerEvent(ctx, responseEvent)
+	s.recordProvenance(ctx, responseEvent, retrieval)
+	
+	return responseEvent
+}
+
+// newServerEvent creates an event emitted by the server in reaction to the original event
+func newServerEvent(eventType, subject string, origin *eventsv1.Event, data map[string]interface{}) *eventsv1.Event {
+	event := &eventsv1.Event{
 		Id:            uuid.New().String(),
-		Type:          "pcas.response.v1",
+		Type:          eventType,
 		Source:        "pcas-server",
 		Specversion:   "1.0",
 		Time:          timestamppb.New(time.Now()),
-		Subject:       fmt.Sprintf("response-to-%s", event.Id),
-		TraceId:       event.TraceId,        // Pass through the trace ID
-		CorrelationId: event.Id,              // Set correlation to the original event ID
-	}
-	
-	// Add the response data
-	responseData := map[string]interface{}{
-		"original_event_id": event.Id,
-		"provider":          providerName,
-		"response":          result,
+		Subject:       subject,
+		TraceId:       origin.TraceId,        // Pass through the trace ID
+		CorrelationId: origin.Id,             // Set correlation to the original event ID
+		UserId:        origin.UserId,         // Keep responses in the user's memory
+		SessionId:     origin.SessionId,      // Keep responses in the conversation's session
 	}
 	
-	structData, err := structpb.NewValue(responseData)
+	structData, err := structpb.NewValue(data)
 	if err != nil {
-		log.Printf("Failed to create response data: %v", err)
+		log.Printf("Failed to create %s data: %v", eventType, err)
 	} else {
-		responseEvent.Data, _ = anypb.New(structData)
+		event.Data, _ = anypb.New(structData)
 	}
 	
-	// Store the response event before broadcasting
-	if err := s.storage.StoreEvent(ctx, responseEvent, nil); err != nil {
-		log.Printf("Failed to store response event: %v", err)
+	return event
+}
+
+// publishServerEvent stores a server-generated event and broadcasts it to all subscribers
+func (s *Server) publishServerEvent(ctx context.Context, event *eventsv1.Event) {
+	// Store the event before broadcasting
+	if err := s.storage.StoreEvent(ctx, event, nil); err != nil {
+		log.Printf("Failed to store %s event: %v", event.Type, err)
 		// Continue processing even if storage fails
 	}
 	
-	// Don't vectorize response events - they don't contain user intent
-	// Only user-generated content should be in vector space
-	
-	// Broadcast the response event to all subscribers
-	s.broadcastEvent(responseEvent)
-	
-	return &busv1.PublishResponse{}, nil
+	s.broadcastEvent(event)
 }
 
 // Search performs semantic search across stored events
@@ -193,6 +268,11 @@ func (s *Server) Search(ctx context.Context, req *busv1.SearchRequest) (*busv1.S
 		req.TopK = 5 // Default to 5 results
 	}
 	
+	weights := searchWeights(req)
+	if err := weights.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid ranking weights: %w", err)
+	}
+	
 	// Check if embedding provider is available
 	if s.embeddingProvider == nil {
 		return nil, fmt.Errorf("vector search is not available on the server. Please ensure the PCAS server was started with the OPENAI_API_KEY environment variable set")
@@ -228,13 +308,29 @@ func (s *Server) Search(ctx context.Context, req *busv1.SearchRequest) (*busv1.S
 		log.Printf("Applying attribute filters: %v", req.AttributeFilters)
 	}
 	
+	// Fetch more candidates when re-ranking so that newer or more important events
+	// can replace the closest matches
+	limit := int(req.TopK)
+	if !weights.IsZero() {
+		limit *= ranking.CandidateFactor
+	}
+	if s.deduplication != nil {
+		limit *= duplicateSearchFactor
+	}
+	
 	// Query similar events from storage
-	log.Printf("Searching for top %d similar events", req.TopK)
-	eventIDs, err := s.storage.QuerySimilar(ctx, queryEmbedding, int(req.TopK), filter)
+	log.Printf("Searching for top %d similar events", limit)
+	eventIDs, err := s.storage.QuerySimilar(ctx, queryEmbedding, limit, filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query similar events: %w", err)
 	}
 	
+	// Show each cluster of near-duplicate memories once
+	eventIDs = s.collapseDuplicates(ctx, eventIDs)
+	if weights.IsZero() && len(eventIDs) > int(req.TopK) {
+		eventIDs = eventIDs[:req.TopK]
+	}
+	
 	// Retrieve full event details from storage
 	var results []*eventsv1.Event
 	var scores []float32
@@ -249,6 +345,10 @@ func (s *Server) Search(ctx context.Context, req *busv1.SearchRequest) (*busv1.S
 		log.Printf("Retrieved event %s with similarity score: %.3f", result.ID, result.Score)
 	}
 	
+	if !weights.IsZero() {
+		results, scores = s.rerank(ctx, results, scores, req.UserId, weights, int(req.TopK))
+	}
+	
 	log.Printf("Search completed: found %d matching events", len(results))
 	
 	return &busv1.SearchResponse{
@@ -267,4 +367,11 @@ func (s *Server) WaitForVectorization() {
 	s.vectorizeWG.Wait()
 }
 
+// WaitForBackground waits until the periodic jobs have returned after their
+// context was cancelled. Jobs may still start vectorization, so call it before
+// WaitForVectorization.
+func (s *Server) WaitForBackground() {
+	s.backgroundWG.Wait()
+}
+
 // isFactEvent determines if an event type represents a "fact" that should be vectorized
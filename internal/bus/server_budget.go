@@ -0,0 +1,110 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/budget"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+// Event type of the notice that a budget rejected or downgraded a request
+const budgetExceededEventType = "pcas.budget.exceeded.v1"
+
+// SetBudgetManager enables token accounting and budget enforcement
+func (s *Server) SetBudgetManager(manager *budget.Manager) {
+	s.budgetManager = manager
+}
+
+// enforceBudget returns the provider that should serve the event once budgets are
+// applied. An exhausted budget either downgrades to a cheaper provider or rejects
+// the event with budget.ErrBudgetExceeded. Every exhausted budget is announced with
+// a pcas.budget.exceeded.v1 event. The prompt tokens of the request are counted
+// with each provider's tokenizer, so a call that would overrun a budget is caught
+// before it is made.
+func (s *Server) enforceBudget(ctx context.Context, event *eventsv1.Event, providerName string, requestData map[string]interface{}) (string, error) {
+	if s.budgetManager == nil {
+		return providerName, nil
+	}
+	
+	// Follow the downgrade chain, guarding against cycles between budgets
+	visited := make(map[string]bool)
+	for !visited[providerName] {
+		visited[providerName] = true
+		
+		promptTokens := countRequestTokens(s.tokenizerFor(providerName), requestData)
+		exceeded, err := s.budgetManager.CheckEstimate(ctx, event.UserId, event.Source, providerName, promptTokens)
+		if err != nil {
+			// Fail open: accounting problems should not take the assistant down
+			log.Printf("Budget check failed for provider %s: %v", providerName, err)
+			return providerName, nil
+		}
+		if exceeded == nil {
+			return providerName, nil
+		}
+		
+		action := exceeded.Budget.OnExceeded
+		if action == "" {
+			action = policy.BudgetActionReject
+		}
+		log.Printf("Budget %s exhausted for %s %q on provider %s (action: %s)",
+			exceeded.Budget.Name, exceeded.Budget.Scope, exceeded.Key, providerName, action)
+		s.emitBudgetExceeded(ctx, event, providerName, action, exceeded)
+		
+		if action != policy.BudgetActionDowngrade {
+			return "", fmt.Errorf("%w: budget %s for %s %q", budget.ErrBudgetExceeded, exceeded.Budget.Name, exceeded.Budget.Scope, exceeded.Key)
+		}
+		providerName = exceeded.Budget.DowngradeTo
+	}
+	
+	return "", fmt.Errorf("%w: downgrade chain loops back to provider %s", budget.ErrBudgetExceeded, providerName)
+}
+
+// recordUsage persists the usage reported by the provider during a call
+func (s *Server) recordUsage(ctx context.Context, event *eventsv1.Event, providerName string, recorder *providers.UsageRecorder) {
+	if s.budgetManager == nil {
+		return
+	}
+	
+	usage, ok := recorder.Total()
+	if !ok {
+		// Provider does not report usage (e.g. the mock provider)
+		return
+	}
+	
+	// Usage must be recorded even if the request context was cancelled
+	if err := s.budgetManager.Record(context.WithoutCancel(ctx), event.Id, event.UserId, event.Source, providerName, usage); err != nil {
+		log.Printf("Failed to record usage for provider %s: %v", providerName, err)
+	}
+}
+
+// emitBudgetExceeded announces an exhausted budget with a pcas.budget.exceeded.v1 event
+func (s *Server) emitBudgetExceeded(ctx context.Context, event *eventsv1.Event, providerName, action string, exceeded *budget.Exceeded) {
+	data := map[string]interface{}{
+		"original_event_id": event.Id,
+		"budget":            exceeded.Budget.Name,
+		"scope":             exceeded.Budget.Scope,
+		"key":               exceeded.Key,
+		"period":            exceeded.Budget.Period,
+		"period_start":      exceeded.PeriodStart.Format("2006-01-02T15:04:05Z07:00"),
+		"provider":          providerName,
+		"used_tokens":       float64(exceeded.Used.TotalTokens()),
+		"used_cost":         exceeded.Used.Cost,
+		"action":            action,
+	}
+	if exceeded.Budget.MaxTokens > 0 {
+		data["max_tokens"] = float64(exceeded.Budget.MaxTokens)
+	}
+	if exceeded.Budget.MaxCost > 0 {
+		data["max_cost"] = exceeded.Budget.MaxCost
+	}
+	if action == policy.BudgetActionDowngrade {
+		data["downgrade_to"] = exceeded.Budget.DowngradeTo
+	}
+	
+	exceededEvent := newServerEvent(budgetExceededEventType, fmt.Sprintf("budget-exceeded-for-%s", event.Id), event, data)
+	s.publishServerEvent(ctx, exceededEvent)
+}
@@ -0,0 +1,134 @@
+package bus
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+const (
+	// Response cache defaults for rules that enable caching without limits
+	defaultCacheTTL        = time.Hour
+	defaultCacheMaxEntries = 1000
+)
+
+// responseCacheKey derives the cache key from the provider, the requested model and
+// the normalized request data
+func responseCacheKey(providerName string, requestData map[string]interface{}) string {
+	model, _ := requestData["model"].(string)
+	
+	// encoding/json sorts map keys, so equal requests always serialize identically
+	normalized, err := json.Marshal(normalizeRequestValue(requestData))
+	if err != nil {
+		// Unserializable data is still cacheable per provider/model, just less precisely
+		log.Printf("Failed to normalize request data for cache key: %v", err)
+	}
+	
+	hash := sha256.New()
+	hash.Write([]byte(providerName))
+	hash.Write([]byte{0})
+	hash.Write([]byte(model))
+	hash.Write([]byte{0})
+	hash.Write(normalized)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// normalizeRequestValue strips volatile metadata and collapses insignificant
+// whitespace so that re-emitted prompts map to the same cache entry
+func normalizeRequestValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			// RAG bookkeeping fields do not affect the provider's answer
+			if strings.HasPrefix(key, "rag_") {
+				continue
+			}
+			normalized[key] = normalizeRequestValue(item)
+		}
+		return normalized
+	case map[string]string:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeRequestValue(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeRequestValue(item)
+		}
+		return normalized
+	case []map[string]string:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeRequestValue(item)
+		}
+		return normalized
+	case string:
+		return strings.Join(strings.Fields(v), " ")
+	default:
+		return v
+	}
+}
+
+// lookupCachedResponse returns the cached response for the key if one is available
+func (s *Server) lookupCachedResponse(ctx context.Context, key string) (string, bool) {
+	cacheStore, ok := s.storage.(storage.ResponseCacheStore)
+	if !ok {
+		return "", false
+	}
+	
+	entry, err := cacheStore.GetCachedResponse(ctx, key)
+	if err != nil {
+		log.Printf("Response cache lookup failed: %v", err)
+		return "", false
+	}
+	if entry == nil {
+		return "", false
+	}
+	return entry.Response, true
+}
+
+// storeCachedResponse caches a provider response according to the rule's limits
+func (s *Server) storeCachedResponse(ctx context.Context, key, providerName string, requestData map[string]interface{}, response string, cacheConfig *policy.Cache) {
+	cacheStore, ok := s.storage.(storage.ResponseCacheStore)
+	if !ok {
+		return
+	}
+	
+	if cacheConfig.MaxEntryBytes > 0 && len(response) > cacheConfig.MaxEntryBytes {
+		log.Printf("Response of %d bytes exceeds cache entry limit of %d bytes, not caching", len(response), cacheConfig.MaxEntryBytes)
+		return
+	}
+	
+	ttl := cacheConfig.TTL
+	if ttl <= 0 {
+		ttl = defaultCacheTTL
+	}
+	maxEntries := cacheConfig.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultCacheMaxEntries
+	}
+	
+	model, _ := requestData["model"].(string)
+	now := time.Now()
+	entry := storage.CachedResponse{
+		Key:       key,
+		Provider:  providerName,
+		Model:     model,
+		Response:  response,
+		CreatedAt: now,
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := cacheStore.PutCachedResponse(ctx, entry, maxEntries); err != nil {
+		log.Printf("Failed to cache response: %v", err)
+	}
+}
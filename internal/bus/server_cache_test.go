@@ -0,0 +1,154 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/budget"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+func TestResponseCacheKey(t *testing.T) {
+	base := map[string]interface{}{"prompt": "Translate  this\n text", "model": "gpt-4"}
+
+	testCases := []struct {
+		name        string
+		provider    string
+		requestData map[string]interface{}
+		expectSame  bool
+	}{
+		{
+			name:        "whitespace differences are ignored",
+			provider:    "openai",
+			requestData: map[string]interface{}{"prompt": "Translate this text", "model": "gpt-4"},
+			expectSame:  true,
+		},
+		{
+			name:        "rag metadata is ignored",
+			provider:    "openai",
+			requestData: map[string]interface{}{"prompt": "Translate this text", "model": "gpt-4", "rag_enhanced": true},
+			expectSame:  true,
+		},
+		{
+			name:        "different provider",
+			provider:    "ollama",
+			requestData: base,
+			expectSame:  false,
+		},
+		{
+			name:        "different model",
+			provider:    "openai",
+			requestData: map[string]interface{}{"prompt": "Translate this text", "model": "gpt-3.5-turbo"},
+			expectSame:  false,
+		},
+		{
+			name:        "different prompt",
+			provider:    "openai",
+			requestData: map[string]interface{}{"prompt": "Translate that text", "model": "gpt-4"},
+			expectSame:  false,
+		},
+	}
+
+	baseKey := responseCacheKey("openai", base)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			same := responseCacheKey(tc.provider, tc.requestData) == baseKey
+			if same != tc.expectSame {
+				t.Errorf("Expected same key = %v, got %v", tc.expectSame, same)
+			}
+		})
+	}
+}
+
+// countingCacheStore counts the lookups of the response cache
+type countingCacheStore struct {
+	storage.Storage
+	cache   storage.ResponseCacheStore
+	lookups int
+}
+
+func (c *countingCacheStore) GetCachedResponse(ctx context.Context, key string) (*storage.CachedResponse, error) {
+	c.lookups++
+	return c.cache.GetCachedResponse(ctx, key)
+}
+
+func (c *countingCacheStore) PutCachedResponse(ctx context.Context, entry storage.CachedResponse, maxEntries int) error {
+	return c.cache.PutCachedResponse(ctx, entry, maxEntries)
+}
+
+func TestExecuteWithFailover_Cache(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	provider := &stubProvider{result: "cached answer"}
+	counting := &countingCacheStore{Storage: store, cache: store.(storage.ResponseCacheStore)}
+	s := &Server{
+		providers: map[string]providers.ComputeProvider{"stub": provider},
+		storage:   counting,
+	}
+	event := &eventsv1.Event{Id: "event-1"}
+	cacheConfig := &policy.Cache{}
+
+	first, err := s.executeWithFailover(context.Background(), event, []string{"stub"}, map[string]interface{}{"prompt": "hello"}, cacheConfig)
+	if err != nil {
+		t.Fatalf("First execution failed: %v", err)
+	}
+	if first.cached {
+		t.Error("Expected first execution to miss the cache")
+	}
+	if counting.lookups != 1 {
+		t.Errorf("Expected a miss to look the cache up once, got %d lookups", counting.lookups)
+	}
+
+	second, err := s.executeWithFailover(context.Background(), event, []string{"stub"}, map[string]interface{}{"prompt": "hello "}, cacheConfig)
+	if err != nil {
+		t.Fatalf("Second execution failed: %v", err)
+	}
+	if !second.cached || second.response != "cached answer" {
+		t.Errorf("Expected cached response, got %+v", second)
+	}
+	if provider.calls != 1 {
+		t.Errorf("Expected provider to be called once, got %d", provider.calls)
+	}
+
+	// Rules without a cache block never read or write the cache
+	third, err := s.executeWithFailover(context.Background(), event, []string{"stub"}, map[string]interface{}{"prompt": "hello"}, nil)
+	if err != nil {
+		t.Fatalf("Uncached execution failed: %v", err)
+	}
+	if third.cached || provider.calls != 2 {
+		t.Errorf("Expected uncached execution, got cached=%v calls=%d", third.cached, provider.calls)
+	}
+
+	// An exhausted budget does not stop cached responses, which use no tokens
+	manager := budget.NewManager([]policy.Budget{{
+		Name:      "exhausted",
+		Scope:     policy.BudgetScopeSource,
+		Period:    policy.BudgetPeriodDaily,
+		MaxTokens: 1,
+	}}, nil, store.(storage.UsageStore))
+	if err := manager.Record(context.Background(), "earlier", "", "dapp", "stub", providers.Usage{PromptTokens: 1}); err != nil {
+		t.Fatalf("Record failed: %v", err)
+	}
+	s.budgetManager = manager
+	event = &eventsv1.Event{Id: "event-2", Source: "dapp"}
+
+	cached, err := s.executeWithFailover(context.Background(), event, []string{"stub"}, map[string]interface{}{"prompt": "hello"}, cacheConfig)
+	if err != nil || !cached.cached {
+		t.Errorf("Expected the cached response despite the budget, got %+v (%v)", cached, err)
+	}
+	if _, err := s.executeWithFailover(context.Background(), event, []string{"stub"}, map[string]interface{}{"prompt": "something new"}, cacheConfig); !errors.Is(err, budget.ErrBudgetExceeded) {
+		t.Errorf("Expected uncached requests to be rejected by the budget, got %v", err)
+	}
+	if provider.calls != 2 {
+		t.Errorf("Expected no further provider calls, got %d", provider.calls)
+	}
+}
@@ -0,0 +1,256 @@
+package bus
+
+import (
+	"context"
+	"log"
+	"sort"
+	"time"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+const (
+	// duplicateOfEdgeLabel links an event to the canonical event of its cluster
+	duplicateOfEdgeLabel = "duplicate_of"
+
+	// Event type of a memory merged from a cluster of duplicates
+	consolidatedMemoryEventType = "pcas.memory.consolidated.v1"
+
+	// Deduplication defaults for configurations that do not override them
+	defaultDuplicateThreshold = 0.95
+	defaultMinClusterSize     = 3
+
+	// Existing vectors compared with a new event
+	duplicateCandidates = 3
+
+	// Searches fetch this many more results when duplicates are collapsed, so that
+	// a cluster does not crowd out other results
+	duplicateSearchFactor = 2
+)
+
+// SetDeduplication enables near-duplicate detection at vectorization time
+func (s *Server) SetDeduplication(cfg *policy.Deduplication) {
+	s.deduplication = cfg
+}
+
+// linkDuplicate compares a new embedding with the user's existing vectors and
+// links the event to the canonical event of the closest cluster with a
+// duplicate_of edge if it is similar enough. The event keeps its own embedding.
+func (s *Server) linkDuplicate(ctx context.Context, event *eventsv1.Event, embedding []float32) {
+	if s.deduplication == nil || event.UserId == "" {
+		return
+	}
+	graph, ok := s.storage.(storage.GraphStorage)
+	if !ok {
+		return
+	}
+
+	threshold := s.deduplication.Threshold
+	if threshold <= 0 {
+		threshold = defaultDuplicateThreshold
+	}
+
+	// Only the user's own memories can be duplicates
+	filter := &storage.Filter{UserID: &event.UserId}
+	results, err := s.storage.QuerySimilar(ctx, embedding, duplicateCandidates, filter)
+	if err != nil {
+		log.Printf("Failed to search duplicates of event %s: %v", event.Id, err)
+		return
+	}
+
+	for _, result := range results {
+		if result.ID == event.Id || result.Score < threshold {
+			continue
+		}
+		canonical := s.canonicalID(ctx, graph, result.ID)
+		if err := graph.CreateEdge(ctx, event.Id, canonical, duplicateOfEdgeLabel); err != nil {
+			log.Printf("Failed to link duplicate event %s: %v", event.Id, err)
+			return
+		}
+		log.Printf("Event %s duplicates %s (similarity %.3f, canonical %s)", event.Id, result.ID, result.Score, canonical)
+		return
+	}
+}
+
+// canonicalID returns the canonical event of an event's duplicate cluster, or the
+// event itself. The latest duplicate_of edge wins, so that events merged into a
+// consolidated memory point to it.
+func (s *Server) canonicalID(ctx context.Context, graph storage.GraphStorage, eventID string) string {
+	edges, err := graph.GetEdges(ctx, eventID, duplicateOfEdgeLabel)
+	if err != nil || len(edges) == 0 {
+		return eventID
+	}
+	return edges[len(edges)-1].TargetID
+}
+
+// collapseDuplicates keeps one result per duplicate cluster. The results must be
+// sorted best first. The canonical event represents its cluster when it was
+// found, otherwise the best match does; either way with the best score.
+func (s *Server) collapseDuplicates(ctx context.Context, results []storage.QueryResult) []storage.QueryResult {
+	graph, ok := s.storage.(storage.GraphStorage)
+	if !ok || len(results) < 2 {
+		return results
+	}
+
+	collapsed := make([]storage.QueryResult, 0, len(results))
+	clusterIndex := make(map[string]int)
+	for _, result := range results {
+		canonical := s.canonicalID(ctx, graph, result.ID)
+		index, seen := clusterIndex[canonical]
+		if !seen {
+			clusterIndex[canonical] = len(collapsed)
+			collapsed = append(collapsed, result)
+			continue
+		}
+		if result.ID == canonical {
+			score := collapsed[index].Score
+			collapsed[index] = result
+			collapsed[index].Score = score
+		}
+		log.Printf("Collapsed duplicate %s into cluster %s", result.ID, canonical)
+	}
+	return collapsed
+}
+
+// StartConsolidation periodically merges large duplicate clusters into canonical
+// memory events until the context is cancelled. It does nothing unless a
+// consolidation interval is configured. WaitForBackground waits for it to stop.
+func (s *Server) StartConsolidation(ctx context.Context) {
+	if s.deduplication == nil || s.deduplication.ConsolidationInterval <= 0 {
+		return
+	}
+
+	interval := s.deduplication.ConsolidationInterval
+	log.Printf("Memory consolidation enabled (every %v)", interval)
+	s.backgroundWG.Add(1)
+	go func() {
+		defer s.backgroundWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if merged := s.consolidateDuplicates(ctx); merged > 0 {
+					log.Printf("Consolidated %d duplicate clusters", merged)
+				}
+			}
+		}
+	}()
+}
+
+// consolidateDuplicates merges every duplicate cluster with at least the minimum
+// size into a pcas.memory.consolidated.v1 event carrying the newest wording of the
+// fact. All members are linked to the new event, which then represents the
+// cluster. It returns the number of merged clusters.
+func (s *Server) consolidateDuplicates(ctx context.Context) int {
+	lister, ok := s.storage.(storage.EdgeLister)
+	if !ok {
+		return 0
+	}
+	graph, ok := s.storage.(storage.GraphStorage)
+	if !ok {
+		return 0
+	}
+
+	minSize := defaultMinClusterSize
+	if s.deduplication != nil && s.deduplication.MinClusterSize > 0 {
+		minSize = s.deduplication.MinClusterSize
+	}
+
+	edges, err := lister.ListEdges(ctx, duplicateOfEdgeLabel)
+	if err != nil {
+		log.Printf("Failed to list duplicate edges: %v", err)
+		return 0
+	}
+
+	// Edges are oldest first, so the last edge of an event is its current cluster
+	clusterOf := make(map[string]string)
+	for _, edge := range edges {
+		clusterOf[edge.SourceID] = edge.TargetID
+	}
+	clusters := make(map[string][]string)
+	for member, canonical := range clusterOf {
+		clusters[canonical] = append(clusters[canonical], member)
+	}
+
+	canonicalIDs := make([]string, 0, len(clusters))
+	for canonical, members := range clusters {
+		if len(members)+1 >= minSize {
+			canonicalIDs = append(canonicalIDs, canonical)
+		}
+	}
+	sort.Strings(canonicalIDs)
+
+	merged := 0
+	for _, canonical := range canonicalIDs {
+		members := clusters[canonical]
+		sort.Strings(members)
+		if s.consolidateCluster(ctx, graph, canonical, members) {
+			merged++
+		}
+	}
+	return merged
+}
+
+// consolidateCluster merges a single cluster and reports whether it was merged
+func (s *Server) consolidateCluster(ctx context.Context, graph storage.GraphStorage, canonical string, members []string) bool {
+	events, err := s.storage.BatchGetEvents(ctx, append([]string{canonical}, members...))
+	if err != nil || len(events) == 0 {
+		log.Printf("Failed to load duplicate cluster %s: %v", canonical, err)
+		return false
+	}
+	for _, event := range events {
+		// Clusters that were merged already only grow by new duplicates
+		if event.Id == canonical && event.Type == consolidatedMemoryEventType {
+			return false
+		}
+	}
+
+	// The newest event has the most current wording of the fact
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].GetTime().AsTime().Before(events[j].GetTime().AsTime())
+	})
+	newest := events[len(events)-1]
+
+	memberIDs := make([]interface{}, len(events))
+	for i, event := range events {
+		memberIDs[i] = event.Id
+	}
+	// The text doubles as the subject so that RAG renders the merged fact itself
+	text := s.extractTextContent(newest)
+	consolidated := newServerEvent(consolidatedMemoryEventType, text, newest, map[string]interface{}{
+		"text":               text,
+		"canonical_event_id": canonical,
+		"member_event_ids":   memberIDs,
+		"duplicates":         float64(len(events)),
+	})
+	// A memory outlives the conversation it was recorded in
+	consolidated.SessionId = ""
+
+	// Reuse the canonical embedding so the merged memory is found like its members
+	var embedding []float32
+	if getter, ok := s.storage.(storage.EmbeddingGetter); ok {
+		if embeddings, err := getter.GetEmbeddings(ctx, []string{canonical}); err == nil {
+			embedding = embeddings[canonical]
+		}
+	}
+
+	if err := s.storage.StoreEvent(ctx, consolidated, embedding); err != nil {
+		log.Printf("Failed to store consolidated memory for cluster %s: %v", canonical, err)
+		return false
+	}
+	s.broadcastEvent(consolidated)
+
+	for _, event := range events {
+		if err := graph.CreateEdge(ctx, event.Id, consolidated.Id, duplicateOfEdgeLabel); err != nil {
+			log.Printf("Failed to link %s to consolidated memory %s: %v", event.Id, consolidated.Id, err)
+		}
+	}
+
+	log.Printf("Consolidated %d duplicates of %s into memory %s", len(events), canonical, consolidated.Id)
+	return true
+}
@@ -0,0 +1,180 @@
+package bus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+func TestDeduplication(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	s := NewServer(nil, nil, store)
+	s.embeddingProvider = keywordEmbeddingProvider{}
+	s.SetDeduplication(&policy.Deduplication{Threshold: 0.95, MinClusterSize: 3})
+
+	// Three wordings of the same fact and one unrelated note
+	ctx := context.Background()
+	now := time.Now()
+	notes := []struct {
+		id      string
+		userID  string
+		subject string
+	}{
+		{"kyoto-1", "user-1", "The offsite is in Kyoto"},
+		{"kyoto-2", "user-1", "Offsite location: Kyoto"},
+		{"kyoto-3", "user-1", "Remember, the offsite will be in Kyoto"},
+		{"dinner", "user-1", "Team dinner on Friday"},
+		{"kyoto-other", "user-2", "Kyoto is lovely in April"}, // Other users' memories are never duplicates
+	}
+	for i, note := range notes {
+		event := &eventsv1.Event{
+			Id:          note.id,
+			Type:        "user.note.v1",
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      note.userID,
+			Subject:     note.subject,
+			Time:        timestamppb.New(now.Add(time.Duration(i) * time.Minute)),
+		}
+		if err := store.StoreEvent(ctx, event, nil); err != nil {
+			t.Fatalf("Failed to store event %s: %v", note.id, err)
+		}
+		s.vectorizeWG.Add(1)
+		s.vectorizeEvent(event)
+	}
+
+	graph := store.(storage.GraphStorage)
+	for id, expected := range map[string]string{"kyoto-1": "kyoto-1", "kyoto-2": "kyoto-1", "kyoto-3": "kyoto-1", "dinner": "dinner", "kyoto-other": "kyoto-other"} {
+		if canonical := s.canonicalID(ctx, graph, id); canonical != expected {
+			t.Errorf("Expected %s to belong to cluster %s, got %s", id, expected, canonical)
+		}
+	}
+
+	// Search shows the cluster once, represented by its canonical event
+	search := func() []string {
+		resp, err := s.Search(ctx, &busv1.SearchRequest{QueryText: "kyoto", TopK: 2, UserId: "user-1"})
+		if err != nil {
+			t.Fatalf("Search failed: %v", err)
+		}
+		var ids []string
+		for _, event := range resp.Events {
+			ids = append(ids, event.Id)
+		}
+		return ids
+	}
+	if ids := search(); len(ids) != 2 || ids[0] != "kyoto-1" || ids[1] != "dinner" {
+		t.Errorf("Expected [kyoto-1 dinner], got %v", ids)
+	}
+
+	// Consolidation merges the cluster into a memory with the newest wording
+	if merged := s.consolidateDuplicates(ctx); merged != 1 {
+		t.Fatalf("Expected 1 merged cluster, got %d", merged)
+	}
+	canonical := s.canonicalID(ctx, graph, "kyoto-2")
+	memory, err := store.GetEventByID(ctx, canonical)
+	if err != nil {
+		t.Fatalf("Failed to load consolidated memory: %v", err)
+	}
+	if memory.Type != consolidatedMemoryEventType || memory.UserId != "user-1" {
+		t.Errorf("Expected a consolidated memory of user-1, got %s of %s", memory.Type, memory.UserId)
+	}
+	if text := s.extractTextContent(memory); text != "Remember, the offsite will be in Kyoto" {
+		t.Errorf("Expected the newest wording, got %q", text)
+	}
+	if ids := search(); len(ids) == 0 || ids[0] != canonical || (len(ids) > 1 && ids[1] != "dinner") {
+		t.Errorf("Expected the consolidated memory to represent the cluster, got %v", ids)
+	}
+
+	// Merged clusters are not merged again
+	if merged := s.consolidateDuplicates(ctx); merged != 0 {
+		t.Errorf("Expected no further merges, got %d", merged)
+	}
+}
+
+func TestDeduplication_Chunked(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	s := NewServer(nil, nil, store)
+	s.embeddingProvider = keywordEmbeddingProvider{}
+	s.SetDeduplication(&policy.Deduplication{Threshold: 0.95})
+
+	// Two long transcripts about the offsite, embedded chunk by chunk, and a short note
+	ctx := context.Background()
+	transcript := strings.Repeat("We agreed to hold the offsite in Kyoto. ", 120)
+	for i, note := range []struct {
+		id      string
+		subject string
+	}{
+		{"transcript-1", transcript},
+		{"transcript-2", "Recap. " + transcript},
+		{"note", "Offsite location: Kyoto"},
+	} {
+		event := &eventsv1.Event{
+			Id:          note.id,
+			Type:        "user.note.v1",
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      "user-1",
+			Subject:     note.subject,
+			Time:        timestamppb.New(time.Now().Add(time.Duration(i) * time.Minute)),
+		}
+		if err := store.StoreEvent(ctx, event, nil); err != nil {
+			t.Fatalf("Failed to store event %s: %v", note.id, err)
+		}
+		s.vectorizeWG.Add(1)
+		s.vectorizeEvent(event)
+	}
+
+	graph := store.(storage.GraphStorage)
+	for _, id := range []string{"transcript-2", "note"} {
+		if canonical := s.canonicalID(ctx, graph, id); canonical != "transcript-1" {
+			t.Errorf("Expected %s to belong to cluster transcript-1, got %s", id, canonical)
+		}
+	}
+}
+
+func TestStartConsolidation_WaitForBackground(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	s := NewServer(nil, nil, store)
+	s.SetDeduplication(&policy.Deduplication{Threshold: 0.95, ConsolidationInterval: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.StartConsolidation(ctx)
+	time.Sleep(10 * time.Millisecond) // Let a few passes run
+	cancel()
+
+	// Storage may only be closed once the loop has returned
+	stopped := make(chan struct{})
+	go func() {
+		s.WaitForBackground()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the consolidation loop to stop after cancellation")
+	}
+}
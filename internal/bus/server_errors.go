@@ -0,0 +1,125 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/soaringjerry/pcas/internal/budget"
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+// errorDomain is the domain of the ErrorInfo details of PCAS errors
+const errorDomain = "pcas"
+
+// ErrorInfo reasons of failed provider calls
+const (
+	errorReasonInvalidInput   = "PROVIDER_INVALID_INPUT"
+	errorReasonUnauthorized   = "PROVIDER_UNAUTHORIZED"
+	errorReasonRateLimited    = "PROVIDER_RATE_LIMITED"
+	errorReasonTimeout        = "PROVIDER_TIMEOUT"
+	errorReasonUnavailable    = "PROVIDER_UNAVAILABLE"
+	errorReasonProviderFailed = "PROVIDER_INTERNAL"
+	errorReasonBudgetExceeded = "BUDGET_EXCEEDED"
+	errorReasonNoProvider     = "NO_PROVIDER"
+	errorReasonCancelled      = "CANCELLED"
+	errorReasonInternal       = "INTERNAL"
+)
+
+// providerError is the failure of a named provider
+type providerError struct {
+	provider string
+	err      error
+}
+
+func (e *providerError) Error() string {
+	return fmt.Sprintf("provider %s: %v", e.provider, e.err)
+}
+
+func (e *providerError) Unwrap() error {
+	return e.err
+}
+
+// errorClass is how a client should treat a failed request
+type errorClass struct {
+	code      codes.Code
+	reason    string // ErrorInfo reason
+	retryable bool
+}
+
+// classifyError maps the standard provider errors, and the errors the server
+// adds around provider calls, onto a gRPC code
+func classifyError(err error) errorClass {
+	switch {
+	case errors.Is(err, budget.ErrBudgetExceeded):
+		return errorClass{codes.ResourceExhausted, errorReasonBudgetExceeded, false}
+	case errors.Is(err, providers.ErrInvalidInput):
+		return errorClass{codes.InvalidArgument, errorReasonInvalidInput, false}
+	case errors.Is(err, providers.ErrUnauthorized):
+		return errorClass{codes.Unauthenticated, errorReasonUnauthorized, false}
+	case errors.Is(err, providers.ErrRateLimited):
+		return errorClass{codes.ResourceExhausted, errorReasonRateLimited, true}
+	case errors.Is(err, providers.ErrTimeout):
+		return errorClass{codes.DeadlineExceeded, errorReasonTimeout, true}
+	case errors.Is(err, providers.ErrProviderUnavailable):
+		return errorClass{codes.Unavailable, errorReasonUnavailable, true}
+	case errors.Is(err, providers.ErrInternalError):
+		return errorClass{codes.Internal, errorReasonProviderFailed, false}
+	case errors.Is(err, errNoProviderAvailable):
+		return errorClass{codes.FailedPrecondition, errorReasonNoProvider, false}
+	case errors.Is(err, context.Canceled):
+		return errorClass{codes.Canceled, errorReasonCancelled, false}
+	case errors.Is(err, context.DeadlineExceeded):
+		return errorClass{codes.DeadlineExceeded, errorReasonTimeout, true}
+	default:
+		return errorClass{codes.Internal, errorReasonInternal, false}
+	}
+}
+
+// failedProvider returns the name of the provider that caused err, if any
+func failedProvider(err error) string {
+	var provErr *providerError
+	if errors.As(err, &provErr) {
+		return provErr.provider
+	}
+	return ""
+}
+
+// errorStatus converts the failure of a provider call into a gRPC status error.
+// Its details carry an ErrorInfo with the reason, the provider and whether a
+// retry may succeed, and a RetryInfo if the provider said when to retry.
+func errorStatus(err error) error {
+	class := classifyError(err)
+	return withErrorDetails(status.New(class.code, err.Error()), class, failedProvider(err), providers.RetryAfter(err)).Err()
+}
+
+// withErrorDetails attaches the ErrorInfo and RetryInfo details to a status
+func withErrorDetails(st *status.Status, class errorClass, provider string, retryAfter time.Duration) *status.Status {
+	info := &errdetails.ErrorInfo{
+		Reason: class.reason,
+		Domain: errorDomain,
+		Metadata: map[string]string{
+			"retryable": strconv.FormatBool(class.retryable),
+		},
+	}
+	if provider != "" {
+		info.Metadata["provider"] = provider
+	}
+	details := []protoadapt.MessageV1{info}
+	if retryAfter > 0 {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)})
+	}
+	withDetails, err := st.WithDetails(details...)
+	if err != nil {
+		return st
+	}
+	return withDetails
+}
@@ -0,0 +1,131 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/soaringjerry/pcas/internal/budget"
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+func TestErrorStatus(t *testing.T) {
+	rateLimited := providers.WithRetryAfter(providers.WrapProviderError(providers.ErrRateLimited, fmt.Errorf("status 429")), 7*time.Second)
+
+	testCases := []struct {
+		name             string
+		err              error
+		expectCode       codes.Code
+		expectReason     string
+		expectRetryable  bool
+		expectProvider   string
+		expectRetryAfter time.Duration
+	}{
+		{
+			name:             "rate limited with retry after",
+			err:              fmt.Errorf("provider execution failed: %w", &providerError{provider: "openai", err: rateLimited}),
+			expectCode:       codes.ResourceExhausted,
+			expectReason:     errorReasonRateLimited,
+			expectRetryable:  true,
+			expectProvider:   "openai",
+			expectRetryAfter: 7 * time.Second,
+		},
+		{
+			name:           "invalid input",
+			err:            &providerError{provider: "ollama", err: providers.WrapProviderError(providers.ErrInvalidInput, nil)},
+			expectCode:     codes.InvalidArgument,
+			expectReason:   errorReasonInvalidInput,
+			expectProvider: "ollama",
+		},
+		{
+			name:           "unauthorized",
+			err:            &providerError{provider: "openai", err: providers.ErrUnauthorized},
+			expectCode:     codes.Unauthenticated,
+			expectReason:   errorReasonUnauthorized,
+			expectProvider: "openai",
+		},
+		{
+			name:            "timeout",
+			err:             &providerError{provider: "ollama", err: providers.ErrTimeout},
+			expectCode:      codes.DeadlineExceeded,
+			expectReason:    errorReasonTimeout,
+			expectRetryable: true,
+			expectProvider:  "ollama",
+		},
+		{
+			name:            "unavailable",
+			err:             &providerError{provider: "ollama", err: providers.ErrProviderUnavailable},
+			expectCode:      codes.Unavailable,
+			expectReason:    errorReasonUnavailable,
+			expectRetryable: true,
+			expectProvider:  "ollama",
+		},
+		{
+			name:         "budget exceeded",
+			err:          fmt.Errorf("%w: budget daily", budget.ErrBudgetExceeded),
+			expectCode:   codes.ResourceExhausted,
+			expectReason: errorReasonBudgetExceeded,
+		},
+		{
+			name:         "no provider",
+			err:          fmt.Errorf("%w: [missing]", errNoProviderAvailable),
+			expectCode:   codes.FailedPrecondition,
+			expectReason: errorReasonNoProvider,
+		},
+		{
+			name:         "cancelled",
+			err:          context.Canceled,
+			expectCode:   codes.Canceled,
+			expectReason: errorReasonCancelled,
+		},
+		{
+			name:         "unclassified",
+			err:          fmt.Errorf("boom"),
+			expectCode:   codes.Internal,
+			expectReason: errorReasonInternal,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			st := status.Convert(errorStatus(tc.err))
+			if st.Code() != tc.expectCode {
+				t.Errorf("Expected code %v, got %v", tc.expectCode, st.Code())
+			}
+			if st.Message() != tc.err.Error() {
+				t.Errorf("Expected message %q, got %q", tc.err.Error(), st.Message())
+			}
+
+			var info *errdetails.ErrorInfo
+			var retryAfter time.Duration
+			for _, detail := range st.Details() {
+				switch d := detail.(type) {
+				case *errdetails.ErrorInfo:
+					info = d
+				case *errdetails.RetryInfo:
+					retryAfter = d.RetryDelay.AsDuration()
+				}
+			}
+			if info == nil {
+				t.Fatal("Expected an ErrorInfo detail")
+			}
+			if info.Reason != tc.expectReason || info.Domain != errorDomain {
+				t.Errorf("Expected reason %s in domain %s, got %s in %s", tc.expectReason, errorDomain, info.Reason, info.Domain)
+			}
+			if retryable := info.Metadata["retryable"] == "true"; retryable != tc.expectRetryable {
+				t.Errorf("Expected retryable %v, got %v", tc.expectRetryable, retryable)
+			}
+			if info.Metadata["provider"] != tc.expectProvider {
+				t.Errorf("Expected provider %q, got %q", tc.expectProvider, info.Metadata["provider"])
+			}
+			if retryAfter != tc.expectRetryAfter {
+				t.Errorf("Expected retry after %v, got %v", tc.expectRetryAfter, retryAfter)
+			}
+		})
+	}
+}
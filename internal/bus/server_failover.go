@@ -0,0 +1,113 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+// errNoProviderAvailable is returned when none of the providers in a chain
+// is registered with the server
+var errNoProviderAvailable = errors.New("no provider in the chain is available")
+
+// executionResult is the outcome of running a request against a provider chain
+type executionResult struct {
+	response        string
+	provider        string   // Provider that served (or last attempted) the request
+	failedProviders []string // Providers that failed before it, in order
+	cached          bool     // Whether the response was served from the response cache
+}
+
+// executeWithFailover runs the request against each provider in the chain, in
+// order, until one succeeds. Failover only happens for the standard provider
+// errors; any other error is returned immediately.
+func (s *Server) executeWithFailover(ctx context.Context, event *eventsv1.Event, chain []string, requestData map[string]interface{}, cacheConfig *policy.Cache) (executionResult, error) {
+	var result executionResult
+	var lastErr error
+	
+	for i, providerName := range chain {
+		// A cached response uses no tokens, so it is served whatever the budgets say
+		if response, ok := s.cachedResponse(ctx, event, providerName, requestData, cacheConfig); ok {
+			result.provider = providerName
+			result.response, result.cached = response, true
+			return result, nil
+		}
+		
+		// Budgets may downgrade the provider or reject the event outright
+		providerName, err := s.enforceBudget(ctx, event, providerName, requestData)
+		if err != nil {
+			result.provider = providerName
+			return result, err
+		}
+		
+		provider, exists := s.providers[providerName]
+		if !exists {
+			log.Printf("Provider %s is not registered, skipping", providerName)
+			continue
+		}
+		
+		result.provider = providerName
+		result.response, err = s.executeProvider(ctx, event, providerName, provider, requestData, cacheConfig)
+		if err == nil {
+			if len(result.failedProviders) > 0 {
+				log.Printf("Request served by fallback provider %s after %d failure(s)", providerName, len(result.failedProviders))
+			}
+			return result, nil
+		}
+		
+		lastErr = &providerError{provider: providerName, err: err}
+		result.failedProviders = append(result.failedProviders, providerName)
+		
+		if !providers.IsFailoverError(err) {
+			return result, lastErr
+		}
+		if ctx.Err() != nil {
+			return result, lastErr
+		}
+		if i < len(chain)-1 {
+			log.Printf("Provider %s failed (%v), failing over to next provider", providerName, err)
+		}
+	}
+	
+	result.provider = ""
+	if lastErr == nil {
+		return result, fmt.Errorf("%w: %v", errNoProviderAvailable, chain)
+	}
+	return result, lastErr
+}
+
+// executeProvider runs a single provider call and records the provider's token
+// usage. The response is cached when the rule enables caching; callers look the
+// cache up before they enforce budgets.
+func (s *Server) executeProvider(ctx context.Context, event *eventsv1.Event, providerName string, provider providers.ComputeProvider, requestData map[string]interface{}, cacheConfig *policy.Cache) (string, error) {
+	callCtx, recorder := providers.WithUsageRecorder(ctx)
+	response, err := provider.Execute(callCtx, requestData)
+	s.recordUsage(ctx, event, providerName, recorder)
+	if err != nil {
+		return "", err
+	}
+	
+	if cacheConfig != nil {
+		s.storeCachedResponse(ctx, responseCacheKey(providerName, requestData), providerName, requestData, response, cacheConfig)
+	}
+	
+	return response, nil
+}
+
+// cachedResponse returns the cached response of the provider to the request,
+// if the rule enables caching
+func (s *Server) cachedResponse(ctx context.Context, event *eventsv1.Event, providerName string, requestData map[string]interface{}, cacheConfig *policy.Cache) (string, bool) {
+	if cacheConfig == nil {
+		return "", false
+	}
+	response, ok := s.lookupCachedResponse(ctx, responseCacheKey(providerName, requestData))
+	if ok {
+		log.Printf("Serving response for event %s from cache (provider: %s)", event.Id, providerName)
+	}
+	return response, ok
+}
@@ -0,0 +1,106 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+// stubProvider returns a fixed result or error and counts its invocations
+type stubProvider struct {
+	result string
+	err    error
+	calls  int
+}
+
+func (p *stubProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	p.calls++
+	return p.result, p.err
+}
+
+func TestExecuteWithFailover(t *testing.T) {
+	unavailable := &stubProvider{err: providers.WrapProviderError(providers.ErrProviderUnavailable, fmt.Errorf("connection refused"))}
+	rateLimited := &stubProvider{err: providers.WrapProviderError(providers.ErrRateLimited, nil)}
+	invalid := &stubProvider{err: providers.WrapProviderError(providers.ErrInvalidInput, fmt.Errorf("missing prompt"))}
+	healthy := &stubProvider{result: "ok"}
+
+	s := &Server{providers: map[string]providers.ComputeProvider{
+		"unavailable":  unavailable,
+		"rate-limited": rateLimited,
+		"invalid":      invalid,
+		"healthy":      healthy,
+	}}
+
+	testCases := []struct {
+		name         string
+		chain        []string
+		expectServed string
+		expectFailed []string
+		expectErr    error
+	}{
+		{
+			name:         "primary succeeds",
+			chain:        []string{"healthy", "unavailable"},
+			expectServed: "healthy",
+		},
+		{
+			name:         "fails over on standard errors",
+			chain:        []string{"unavailable", "rate-limited", "healthy"},
+			expectServed: "healthy",
+			expectFailed: []string{"unavailable", "rate-limited"},
+		},
+		{
+			name:         "skips unregistered providers",
+			chain:        []string{"missing", "healthy"},
+			expectServed: "healthy",
+		},
+		{
+			name:         "does not fail over on invalid input",
+			chain:        []string{"invalid", "healthy"},
+			expectServed: "invalid",
+			expectFailed: []string{"invalid"},
+			expectErr:    providers.ErrInvalidInput,
+		},
+		{
+			name:         "all providers fail",
+			chain:        []string{"unavailable", "rate-limited"},
+			expectFailed: []string{"unavailable", "rate-limited"},
+			expectErr:    providers.ErrRateLimited,
+		},
+		{
+			name:      "no registered provider",
+			chain:     []string{"missing"},
+			expectErr: errNoProviderAvailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			execution, err := s.executeWithFailover(context.Background(), &eventsv1.Event{Id: "event-1"}, tc.chain, nil, nil)
+			result, served, failed := execution.response, execution.provider, execution.failedProviders
+
+			if tc.expectErr != nil {
+				if !errors.Is(err, tc.expectErr) {
+					t.Fatalf("expected error %v, got %v", tc.expectErr, err)
+				}
+			} else {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if result != "ok" {
+					t.Errorf("expected result %q, got %q", "ok", result)
+				}
+			}
+			if tc.expectServed != "" && served != tc.expectServed {
+				t.Errorf("expected provider %q, got %q", tc.expectServed, served)
+			}
+			if fmt.Sprint(failed) != fmt.Sprint(tc.expectFailed) {
+				t.Errorf("expected failed providers %v, got %v", tc.expectFailed, failed)
+			}
+		})
+	}
+}
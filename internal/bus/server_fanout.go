@@ -0,0 +1,209 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+)
+
+// fanOutResult is the outcome of a single provider in a fan-out
+type fanOutResult struct {
+	target   int    // Index of the target in the rule
+	provider string // Provider that served the request, after budget downgrades
+	response string
+	cached   bool
+	err      error
+	duration time.Duration
+}
+
+// executeFanOut sends the request to every target provider in parallel and emits
+// the responses according to the configured strategy and emit mode. It returns
+// the emitted response events.
+func (s *Server) executeFanOut(ctx context.Context, event *eventsv1.Event, cfg *policy.FanOut, cacheConfig *policy.Cache, requestData map[string]interface{}, retrieval *ragRetrieval) ([]*eventsv1.Event, error) {
+	strategy := cfg.Strategy
+	if strategy == "" {
+		strategy = policy.FanOutStrategyAll
+	}
+	emit := cfg.Emit
+	if emit == "" {
+		emit = policy.FanOutEmitPerProvider
+	}
+	
+	// Number of successful providers after which we stop waiting
+	required := len(cfg.Targets)
+	switch strategy {
+	case policy.FanOutStrategyFirstSuccess:
+		required = 1
+	case policy.FanOutStrategyQuorum:
+		required = cfg.Quorum
+	}
+	
+	log.Printf("Fanning out event %s to %d providers (strategy: %s, emit: %s)", event.Id, len(cfg.Targets), strategy, emit)
+	
+	fanCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	
+	// Buffered so that stragglers never block after we stop collecting
+	resultChan := make(chan fanOutResult, len(cfg.Targets))
+	for i, target := range cfg.Targets {
+		go func(i int, target policy.FanOutTarget) {
+			result := s.executeFanOutTarget(fanCtx, event, target, cfg.Timeout, cacheConfig, requestData)
+			result.target = i
+			resultChan <- result
+		}(i, target)
+	}
+	
+	var results []fanOutResult
+	var responses []*eventsv1.Event
+	successes, failures := 0, 0
+	for len(results) < len(cfg.Targets) {
+		result := <-resultChan
+		results = append(results, result)
+		
+		if result.err != nil {
+			failures++
+			log.Printf("Fan-out provider %s failed after %v: %v", result.provider, result.duration, result.err)
+		} else {
+			successes++
+			log.Printf("Fan-out provider %s succeeded in %v", result.provider, result.duration)
+			if emit == policy.FanOutEmitPerProvider {
+				responses = append(responses, s.emitResponse(ctx, event, map[string]interface{}{
+					"original_event_id": event.Id,
+					"provider":          result.provider,
+					"response":          result.response,
+					"cached":            result.cached,
+					"fan_out_strategy":  strategy,
+				}, retrieval))
+			}
+		}
+		
+		if strategy == policy.FanOutStrategyAll {
+			continue
+		}
+		if successes >= required {
+			break
+		}
+		// Stop early once the required number of successes can no longer be reached
+		if failures > len(cfg.Targets)-required {
+			break
+		}
+	}
+	
+	// Cancel the providers that are still running
+	cancel()
+	
+	if successes == 0 || (strategy != policy.FanOutStrategyAll && successes < required) {
+		var errs []error
+		for _, result := range results {
+			if result.err != nil {
+				errs = append(errs, &providerError{provider: result.provider, err: result.err})
+			}
+		}
+		return nil, fmt.Errorf("%d of %d providers succeeded, %d required: %w", successes, len(cfg.Targets), required, errors.Join(errs...))
+	}
+	
+	if emit == policy.FanOutEmitAggregate {
+		responses = append(responses, s.emitResponse(ctx, event, aggregateFanOutResponse(event, cfg, strategy, results), retrieval))
+	}
+	
+	return responses, nil
+}
+
+// executeFanOutTarget runs a single fan-out provider with its own timeout
+func (s *Server) executeFanOutTarget(ctx context.Context, event *eventsv1.Event, target policy.FanOutTarget, defaultTimeout time.Duration, cacheConfig *policy.Cache, requestData map[string]interface{}) fanOutResult {
+	startTime := time.Now()
+	result := fanOutResult{provider: target.Provider}
+	
+	// A cached response uses no tokens, so it is served whatever the budgets say
+	if response, ok := s.cachedResponse(ctx, event, target.Provider, requestData, cacheConfig); ok {
+		result.response, result.cached = response, true
+		result.duration = time.Since(startTime)
+		return result
+	}
+	
+	// Budgets may downgrade the provider or reject this target
+	providerName, err := s.enforceBudget(ctx, event, target.Provider, requestData)
+	if err != nil {
+		result.err = err
+		return result
+	}
+	result.provider = providerName
+	
+	provider, exists := s.providers[providerName]
+	if !exists {
+		result.err = fmt.Errorf("provider not found: %s", providerName)
+		return result
+	}
+	
+	timeout := target.Timeout
+	if timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	
+	// Give each provider its own copy so concurrent providers never share state
+	data := make(map[string]interface{}, len(requestData))
+	for k, v := range requestData {
+		data[k] = v
+	}
+	
+	result.response, result.err = s.executeProvider(ctx, event, providerName, provider, data, cacheConfig)
+	result.duration = time.Since(startTime)
+	return result
+}
+
+// aggregateFanOutResponse builds the data of a single response event listing the
+// answer of every provider. Targets that had not finished are reported as cancelled;
+// they are told apart by index, since several targets may use the same provider.
+func aggregateFanOutResponse(event *eventsv1.Event, cfg *policy.FanOut, strategy string, results []fanOutResult) map[string]interface{} {
+	finished := make(map[int]bool, len(results))
+	for _, result := range results {
+		finished[result.target] = true
+	}
+	
+	var firstResponse string
+	var firstProvider string
+	responses := make([]interface{}, 0, len(cfg.Targets))
+	for _, result := range results {
+		entry := map[string]interface{}{
+			"provider":    result.provider,
+			"duration_ms": float64(result.duration.Milliseconds()),
+		}
+		if result.err != nil {
+			entry["error"] = result.err.Error()
+		} else {
+			entry["response"] = result.response
+			entry["cached"] = result.cached
+			if firstProvider == "" {
+				firstProvider = result.provider
+				firstResponse = result.response
+			}
+		}
+		responses = append(responses, entry)
+	}
+	for i, target := range cfg.Targets {
+		if !finished[i] {
+			responses = append(responses, map[string]interface{}{
+				"provider": target.Provider,
+				"error":    "cancelled",
+			})
+		}
+	}
+	
+	return map[string]interface{}{
+		"original_event_id": event.Id,
+		"provider":          firstProvider,
+		"response":          firstResponse,
+		"fan_out_strategy":  strategy,
+		"responses":         responses,
+	}
+}
@@ -0,0 +1,176 @@
+package bus_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/bus"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+// delayedProvider answers after a delay, or fails if err is set
+type delayedProvider struct {
+	response string
+	delay    time.Duration
+	err      error
+}
+
+func (p *delayedProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	return p.response, p.err
+}
+
+// responsesFor returns the data of all response events correlated to eventID
+func responsesFor(t *testing.T, store *mockStorage, eventID string) []map[string]interface{} {
+	t.Helper()
+	var responses []map[string]interface{}
+	for _, event := range store.events {
+		if event.Type != "pcas.response.v1" || event.CorrelationId != eventID {
+			continue
+		}
+		value := &structpb.Value{}
+		if err := event.Data.UnmarshalTo(value); err != nil {
+			t.Fatalf("Failed to unmarshal response data: %v", err)
+		}
+		responses = append(responses, value.AsInterface().(map[string]interface{}))
+	}
+	return responses
+}
+
+func TestFanOutStrategies(t *testing.T) {
+	providerMap := map[string]providers.ComputeProvider{
+		"fast":    &delayedProvider{response: "fast answer", delay: 10 * time.Millisecond},
+		"slow":    &delayedProvider{response: "slow answer", delay: 50 * time.Millisecond},
+		"broken":  &delayedProvider{delay: time.Millisecond, err: providers.ErrProviderUnavailable},
+		"hanging": &delayedProvider{response: "too late", delay: time.Minute},
+	}
+
+	testCases := []struct {
+		name            string
+		fanOut          policy.FanOut
+		expectErr       bool
+		expectEvents    int
+		expectAggregate int // Number of entries in the aggregated responses list
+	}{
+		{
+			name: "all emits one response per successful provider",
+			fanOut: policy.FanOut{
+				Targets: []policy.FanOutTarget{{Provider: "fast"}, {Provider: "slow"}, {Provider: "broken"}},
+			},
+			expectEvents: 2,
+		},
+		{
+			name: "first success stops after the fastest provider",
+			fanOut: policy.FanOut{
+				Targets:  []policy.FanOutTarget{{Provider: "fast"}, {Provider: "hanging"}},
+				Strategy: policy.FanOutStrategyFirstSuccess,
+			},
+			expectEvents: 1,
+		},
+		{
+			name: "quorum aggregates into a single response",
+			fanOut: policy.FanOut{
+				Targets:  []policy.FanOutTarget{{Provider: "fast"}, {Provider: "slow"}, {Provider: "hanging"}},
+				Strategy: policy.FanOutStrategyQuorum,
+				Quorum:   2,
+				Emit:     policy.FanOutEmitAggregate,
+			},
+			expectEvents:    1,
+			expectAggregate: 3,
+		},
+		{
+			name: "quorum that cannot be reached fails",
+			fanOut: policy.FanOut{
+				Targets:  []policy.FanOutTarget{{Provider: "fast"}, {Provider: "broken"}},
+				Strategy: policy.FanOutStrategyQuorum,
+				Quorum:   2,
+				Emit:     policy.FanOutEmitAggregate,
+			},
+			expectErr: true,
+		},
+		{
+			name: "per-provider timeout cancels a hanging provider",
+			fanOut: policy.FanOut{
+				Targets: []policy.FanOutTarget{{Provider: "fast"}, {Provider: "hanging", Timeout: 20 * time.Millisecond}},
+				Emit:    policy.FanOutEmitAggregate,
+			},
+			expectEvents:    1,
+			expectAggregate: 2,
+		},
+		{
+			name: "targets on the same provider are reported separately",
+			fanOut: policy.FanOut{
+				Targets:  []policy.FanOutTarget{{Provider: "hanging", Timeout: time.Millisecond}, {Provider: "hanging"}, {Provider: "fast"}},
+				Strategy: policy.FanOutStrategyFirstSuccess,
+				Emit:     policy.FanOutEmitAggregate,
+			},
+			expectEvents:    1,
+			expectAggregate: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.fanOut.Validate(); err != nil {
+				t.Fatalf("Invalid fan-out config: %v", err)
+			}
+			fanOut := tc.fanOut
+			engine := policy.NewEngine(&policy.Policy{
+				Rules: []policy.Rule{{
+					Name: "fan-out rule",
+					If:   policy.Condition{EventType: "pcas.fanout.test.v1"},
+					Then: policy.Action{FanOut: &fanOut},
+				}},
+			})
+			store := newMockStorage()
+			server := bus.NewServer(engine, providerMap, store)
+
+			event := &eventsv1.Event{
+				Id:          uuid.New().String(),
+				Type:        "pcas.fanout.test.v1",
+				Source:      "fanout-test",
+				Specversion: "1.0",
+				Time:        timestamppb.Now(),
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_, err := server.PublishAndWait(ctx, &busv1.PublishAndWaitRequest{Event: event})
+			if tc.expectErr {
+				if err == nil {
+					t.Fatal("Expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Publish failed: %v", err)
+			}
+
+			responses := responsesFor(t, store, event.Id)
+			if len(responses) != tc.expectEvents {
+				t.Fatalf("Expected %d response events, got %d", tc.expectEvents, len(responses))
+			}
+			if tc.expectAggregate > 0 {
+				entries, _ := responses[0]["responses"].([]interface{})
+				if len(entries) != tc.expectAggregate {
+					t.Errorf("Expected %d aggregated entries, got %d", tc.expectAggregate, len(entries))
+				}
+				if responses[0]["response"] != "fast answer" {
+					t.Errorf("Expected first successful response to be surfaced, got %v", responses[0]["response"])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,885 @@
+package bus
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+	pcas "github.com/soaringjerry/pcas/pkg/sdk/go"
+)
+
+// echoStreamProvider answers every input chunk with the chunk in upper case,
+// behind the "prefix" attribute. It confirms control messages with an output,
+// and fails rate limited on the input "rate limit". On the input "generate" it
+// answers "GENERATING" and stops reading input until it is cancelled, like a
+// provider busy generating.
+type echoStreamProvider struct{}
+
+func (echoStreamProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	return "", nil
+}
+
+func (echoStreamProvider) ExecuteStream(ctx context.Context, attributes map[string]string, input <-chan []byte, control <-chan providers.StreamControl, output chan<- []byte) error {
+	prefix := attributes["prefix"]
+	for {
+		var answer string
+		select {
+		case chunk, ok := <-input:
+			if !ok {
+				return nil
+			}
+			if string(chunk) == "rate limit" {
+				return providers.WithRetryAfter(providers.WrapProviderError(providers.ErrRateLimited, nil), 2*time.Second)
+			}
+			answer = prefix + strings.ToUpper(string(chunk))
+			if string(chunk) == "generate" {
+				select {
+				case output <- []byte("GENERATING"):
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+			for string(chunk) == "generate" && !strings.HasPrefix(answer, "CANCELLED") {
+				select {
+				case c := <-control:
+					if c.Type == providers.StreamControlCancel {
+						answer = "CANCELLED " + c.Reason
+					}
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			}
+		case c := <-control:
+			switch c.Type {
+			case providers.StreamControlCancel:
+				answer = "CANCELLED " + c.Reason
+			case providers.StreamControlUpdate:
+				prefix = c.Attributes["prefix"]
+				answer = "UPDATED"
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		select {
+		case output <- []byte(answer):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// newStreamTestServer serves a bus server with an echo stream provider over an
+// in-memory connection and returns a client for it
+func newStreamTestServer(t *testing.T) (*Server, storage.Storage, busv1.EventBusServiceClient) {
+	t.Helper()
+
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	engine := policy.NewEngine(&policy.Policy{
+		Rules: []policy.Rule{{
+			Name: "echo stream",
+			If:   policy.Condition{EventType: "test.echo.stream.v1"},
+			Then: policy.Action{Provider: "echo"},
+		}},
+	})
+	s := NewServer(engine, map[string]providers.ComputeProvider{"echo": echoStreamProvider{}}, store)
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	busv1.RegisterEventBusServiceServer(grpcServer, s)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return s, store, busv1.NewEventBusServiceClient(conn)
+}
+
+// streamEvents waits until the end of a stream is recorded and returns the
+// stream's events, oldest first
+func streamEvents(t *testing.T, store storage.Storage, streamID string) []*eventsv1.Event {
+	t.Helper()
+	querier := store.(storage.EventQuerier)
+	filter := &storage.Filter{AttributeFilters: map[string]string{streamIDAttribute: streamID}}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		events, err := querier.QueryEvents(context.Background(), filter, 100)
+		if err != nil {
+			t.Fatalf("QueryEvents failed: %v", err)
+		}
+		for _, event := range events {
+			if event.Type == streamEndedEventType || event.Type == streamErrorEventType {
+				ordered := make([]*eventsv1.Event, len(events))
+				for i, event := range events {
+					ordered[len(events)-1-i] = event
+				}
+				return ordered
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for the end of stream %s, got %d events", streamID, len(events))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInteractStream_RecordsEvents(t *testing.T) {
+	_, store, client := newStreamTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+
+	// Send a chunk at a time and wait for its answer, so the transcript alternates
+	send := func(req *busv1.InteractRequest) {
+		t.Helper()
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	recv := func() *busv1.InteractResponse {
+		t.Helper()
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if streamErr := resp.GetError(); streamErr != nil {
+			t.Fatalf("Unexpected stream error: %v", streamErr.Message)
+		}
+		return resp
+	}
+
+	send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{
+		EventType:  "test.echo.stream.v1",
+		UserId:     "user-1",
+		SessionId:  "session-1",
+		Transcript: true,
+	}}})
+	streamID := recv().GetReady().GetStreamId()
+	if streamID == "" {
+		t.Fatal("Expected a ready response with a stream ID")
+	}
+	for _, input := range []string{"hello", "world"} {
+		send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte(input)}}})
+		if output := string(recv().GetData().GetContent()); output != strings.ToUpper(input) {
+			t.Fatalf("Expected %q, got %q", strings.ToUpper(input), output)
+		}
+	}
+	send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_ClientEnd{ClientEnd: &busv1.StreamEnd{}}})
+	if recv().GetServerEnd() == nil {
+		t.Fatal("Expected server_end")
+	}
+
+	events := streamEvents(t, store, streamID)
+	var types []string
+	var transcript *eventsv1.Event
+	for _, event := range events {
+		types = append(types, event.Type)
+		if event.SessionId != "session-1" || event.UserId != "user-1" || event.CorrelationId != streamID {
+			t.Errorf("Expected %s to be linked to the stream and session, got session %q user %q correlation %q", event.Type, event.SessionId, event.UserId, event.CorrelationId)
+		}
+		if event.Type == streamTranscriptEventType {
+			transcript = event
+		}
+	}
+
+	counts := make(map[string]int)
+	for _, eventType := range types {
+		counts[eventType]++
+	}
+	expected := map[string]int{
+		streamStartedEventType:    1,
+		streamInputEventType:      2,
+		streamOutputEventType:     2,
+		streamEndedEventType:      1,
+		streamTranscriptEventType: 1,
+	}
+	for eventType, count := range expected {
+		if counts[eventType] != count {
+			t.Errorf("Expected %d %s events, got %d (%v)", count, eventType, counts[eventType], types)
+		}
+	}
+
+	if transcript == nil {
+		t.Fatal("Expected a transcript fact")
+	}
+	value := &structpb.Value{}
+	if err := transcript.Data.UnmarshalTo(value); err != nil {
+		t.Fatalf("Failed to decode transcript: %v", err)
+	}
+	text := value.AsInterface().(map[string]interface{})["text"]
+	if text != "User: hello\nAssistant: HELLO\nUser: world\nAssistant: WORLD" {
+		t.Errorf("Unexpected transcript:\n%v", text)
+	}
+}
+
+func TestInteractStream_Resume(t *testing.T) {
+	s, store, client := newStreamTestServer(t)
+
+	// The first connection receives the answer to its first chunk only
+	firstCtx, dropConnection := context.WithCancel(context.Background())
+	defer dropConnection()
+	first, err := client.InteractStream(firstCtx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	if err := first.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{EventType: "test.echo.stream.v1"}}}); err != nil {
+		t.Fatalf("Send config failed: %v", err)
+	}
+	resp, err := first.Recv()
+	if err != nil {
+		t.Fatalf("Recv ready failed: %v", err)
+	}
+	streamID := resp.GetReady().GetStreamId()
+
+	if err := first.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("hello"), Sequence: 1}}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	resp, err = first.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if data := resp.GetData(); string(data.GetContent()) != "HELLO" || data.GetSequence() != 1 {
+		t.Fatalf("Expected HELLO as output 1, got %q as output %d", data.GetContent(), data.GetSequence())
+	}
+	if err := first.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("world"), Sequence: 2}}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	// Drop the connection once the answer to the second chunk is buffered
+	session := s.lookupInteractSession(streamID)
+	if session == nil {
+		t.Fatal("Expected a live session for the stream")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		session.mu.Lock()
+		lastOutput := session.lastOutput
+		session.mu.Unlock()
+		if lastOutput == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the second output")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	dropConnection()
+
+	// The second connection resumes after the first output
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	second, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	resume := &busv1.StreamResume{StreamId: streamID, LastSequence: 1}
+	if err := second.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{Resume: resume}}}); err != nil {
+		t.Fatalf("Send resume failed: %v", err)
+	}
+	resp, err = second.Recv()
+	if err != nil {
+		t.Fatalf("Recv ready failed: %v", err)
+	}
+	ready := resp.GetReady()
+	if ready.GetStreamId() != streamID || !ready.GetResumed() || ready.GetLastInputSequence() != 2 {
+		t.Fatalf("Expected a resumed ready after input 2, got %v", ready)
+	}
+
+	// The missed output is replayed, and resent input is not processed twice
+	expected := []struct {
+		content  string
+		sequence uint64
+	}{
+		{"WORLD", 2},
+		{"AGAIN", 3},
+	}
+	inputs := []*busv1.StreamData{
+		{Content: []byte("world"), Sequence: 2},
+		{Content: []byte("again"), Sequence: 3},
+	}
+	for _, input := range inputs {
+		if err := second.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: input}}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	for _, want := range expected {
+		resp, err := second.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if data := resp.GetData(); string(data.GetContent()) != want.content || data.GetSequence() != want.sequence {
+			t.Fatalf("Expected %s as output %d, got %q as output %d", want.content, want.sequence, data.GetContent(), data.GetSequence())
+		}
+	}
+	if err := second.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Ack{Ack: &busv1.StreamAck{Sequence: 3}}}); err != nil {
+		t.Fatalf("Send ack failed: %v", err)
+	}
+	if err := second.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_ClientEnd{ClientEnd: &busv1.StreamEnd{}}}); err != nil {
+		t.Fatalf("Send client_end failed: %v", err)
+	}
+	resp, err = second.Recv()
+	if err != nil || resp.GetServerEnd() == nil {
+		t.Fatalf("Expected server_end, got %v (%v)", resp, err)
+	}
+
+	counts := make(map[string]int)
+	for _, event := range streamEvents(t, store, streamID) {
+		counts[event.Type]++
+	}
+	if counts[streamInputEventType] != 3 || counts[streamOutputEventType] != 3 || counts[streamEndedEventType] != 1 {
+		t.Errorf("Expected 3 inputs, 3 outputs and an end, got %v", counts)
+	}
+	if s.lookupInteractSession(streamID) != nil {
+		t.Error("Expected the session to be released after its end")
+	}
+}
+
+func TestInteractStream_AbandonedAfterGrace(t *testing.T) {
+	s, store, client := newStreamTestServer(t)
+	s.streamResumeGrace = 50 * time.Millisecond
+
+	ctx, dropConnection := context.WithCancel(context.Background())
+	stream, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{EventType: "test.echo.stream.v1"}}}); err != nil {
+		t.Fatalf("Send config failed: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv ready failed: %v", err)
+	}
+	streamID := resp.GetReady().GetStreamId()
+	dropConnection()
+
+	// The stream ends with an error once the grace period is over
+	var abandoned bool
+	for _, event := range streamEvents(t, store, streamID) {
+		abandoned = abandoned || event.Type == streamErrorEventType
+	}
+	if !abandoned {
+		t.Error("Expected the abandoned stream to record an error event")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resumed, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	resume := &busv1.StreamResume{StreamId: streamID}
+	if err := resumed.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{Resume: resume}}}); err != nil {
+		t.Fatalf("Send resume failed: %v", err)
+	}
+	if _, err := resumed.Recv(); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound when resuming an abandoned stream, got %v", err)
+	}
+}
+
+func TestInteractStream_CancelAndUpdate(t *testing.T) {
+	_, store, client := newStreamTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	config := &busv1.StreamConfig{
+		EventType:  "test.echo.stream.v1",
+		Attributes: map[string]string{"prefix": "en: "},
+	}
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: config}}); err != nil {
+		t.Fatalf("Send config failed: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv ready failed: %v", err)
+	}
+	streamID := resp.GetReady().GetStreamId()
+
+	// Every request waits for its answer, since input and control are not ordered
+	steps := []struct {
+		name     string
+		request  *busv1.InteractRequest
+		expected string
+	}{
+		{
+			name:     "data with the configured attributes",
+			request:  &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("hello")}}},
+			expected: "en: HELLO",
+		},
+		{
+			name:     "update",
+			request:  &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Update{Update: &busv1.StreamUpdate{Attributes: map[string]string{"prefix": "fr: "}}}},
+			expected: "UPDATED",
+		},
+		{
+			name:     "data with the updated attributes",
+			request:  &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("bonjour")}}},
+			expected: "fr: BONJOUR",
+		},
+		{
+			name:     "cancel keeps the stream open",
+			request:  &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Cancel{Cancel: &busv1.StreamCancel{Reason: "too long"}}},
+			expected: "CANCELLED too long",
+		},
+		{
+			name:     "removing an attribute",
+			request:  &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Update{Update: &busv1.StreamUpdate{Attributes: map[string]string{"prefix": ""}}}},
+			expected: "UPDATED",
+		},
+		{
+			name:     "data after the cancel",
+			request:  &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("again")}}},
+			expected: "AGAIN",
+		},
+	}
+	for _, step := range steps {
+		if err := stream.Send(step.request); err != nil {
+			t.Fatalf("%s: Send failed: %v", step.name, err)
+		}
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("%s: Recv failed: %v", step.name, err)
+		}
+		if output := string(resp.GetData().GetContent()); output != step.expected {
+			t.Errorf("%s: expected %q, got %q", step.name, step.expected, output)
+		}
+	}
+
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_ClientEnd{ClientEnd: &busv1.StreamEnd{}}}); err != nil {
+		t.Fatalf("Send client_end failed: %v", err)
+	}
+	if resp, err := stream.Recv(); err != nil || resp.GetServerEnd() == nil {
+		t.Fatalf("Expected server_end, got %v (%v)", resp, err)
+	}
+
+	counts := make(map[string]int)
+	for _, event := range streamEvents(t, store, streamID) {
+		counts[event.Type]++
+	}
+	if counts[streamCancelledEventType] != 1 || counts[streamUpdatedEventType] != 2 {
+		t.Errorf("Expected a cancel and two updates to be recorded, got %v", counts)
+	}
+}
+
+func TestInteractStream_CancelWhileGenerating(t *testing.T) {
+	_, _, client := newStreamTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	config := &busv1.StreamConfig{EventType: "test.echo.stream.v1"}
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: config}}); err != nil {
+		t.Fatalf("Send config failed: %v", err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatalf("Recv ready failed: %v", err)
+	}
+
+	// The provider stops reading input, so more input than it buffers piles up
+	// behind the generation; the cancel must not wait for it
+	const pending = 30
+	send := func(req *busv1.InteractRequest) {
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("generate")}}})
+	if resp, err := stream.Recv(); err != nil || string(resp.GetData().GetContent()) != "GENERATING" {
+		t.Fatalf("Expected the provider to start generating, got %v (%v)", resp, err)
+	}
+	for i := 0; i < pending; i++ {
+		send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("more")}}})
+	}
+	send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Cancel{Cancel: &busv1.StreamCancel{Reason: "stop"}}})
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if output := string(resp.GetData().GetContent()); output != "CANCELLED stop" {
+		t.Fatalf("Expected the generation to be cancelled, got %q", output)
+	}
+
+	// The pending input is answered once the provider reads input again
+	for i := 0; i < pending; i++ {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if output := string(resp.GetData().GetContent()); output != "MORE" {
+			t.Fatalf("Expected the pending input to be answered, got %q", output)
+		}
+	}
+	send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_ClientEnd{ClientEnd: &busv1.StreamEnd{}}})
+	if resp, err := stream.Recv(); err != nil || resp.GetServerEnd() == nil {
+		t.Fatalf("Expected server_end, got %v (%v)", resp, err)
+	}
+}
+
+func TestInteractStream_Segmentation(t *testing.T) {
+	_, store, client := newStreamTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	config := &busv1.StreamConfig{
+		EventType: "test.echo.stream.v1",
+		Segmentation: &busv1.Segmentation{
+			Mode:          busv1.SegmentationMode_SEGMENTATION_MODE_SENTENCE,
+			IdleTimeoutMs: 50,
+		},
+	}
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: config}}); err != nil {
+		t.Fatalf("Send config failed: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv ready failed: %v", err)
+	}
+	streamID := resp.GetReady().GetStreamId()
+
+	// The last sentence is only complete once the client pauses
+	for _, chunk := range []string{"Hello wor", "ld. How are", " you?"} {
+		if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte(chunk)}}}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	for _, expected := range []string{"HELLO WORLD.", "HOW ARE YOU?"} {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		if output := string(resp.GetData().GetContent()); output != expected {
+			t.Errorf("Expected %q, got %q", expected, output)
+		}
+	}
+
+	// An unfinished segment is flushed when the client ends the stream
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("Bye")}}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_ClientEnd{ClientEnd: &busv1.StreamEnd{}}}); err != nil {
+		t.Fatalf("Send client_end failed: %v", err)
+	}
+	if resp, err := stream.Recv(); err != nil || string(resp.GetData().GetContent()) != "BYE" {
+		t.Fatalf("Expected BYE, got %v (%v)", resp, err)
+	}
+	if resp, err := stream.Recv(); err != nil || resp.GetServerEnd() == nil {
+		t.Fatalf("Expected server_end, got %v (%v)", resp, err)
+	}
+
+	var inputs int
+	for _, event := range streamEvents(t, store, streamID) {
+		if event.Type == streamInputEventType {
+			inputs++
+		}
+	}
+	if inputs != 3 {
+		t.Errorf("Expected the 3 segments to be recorded as input, got %d", inputs)
+	}
+}
+
+func TestInteractStream_Channels(t *testing.T) {
+	_, _, client := newStreamTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	send := func(req *busv1.InteractRequest) {
+		t.Helper()
+		if err := stream.Send(req); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	recv := func() *busv1.InteractResponse {
+		t.Helper()
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		return resp
+	}
+	openChannel := func(channelID, eventType, prefix string) {
+		t.Helper()
+		send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{
+			EventType:  eventType,
+			Attributes: map[string]string{"prefix": prefix},
+			ChannelId:  channelID,
+		}}})
+	}
+	data := func(channelID, content string) *busv1.InteractRequest {
+		return &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte(content), ChannelId: channelID}}}
+	}
+	end := func(channelID string) *busv1.InteractRequest {
+		return &busv1.InteractRequest{RequestType: &busv1.InteractRequest_ClientEnd{ClientEnd: &busv1.StreamEnd{ChannelId: channelID}}}
+	}
+
+	// Every channel has its own stream
+	streamIDs := make(map[string]string)
+	for _, channelID := range []string{"en", "fr"} {
+		openChannel(channelID, "test.echo.stream.v1", channelID+": ")
+		ready := recv().GetReady()
+		if ready.GetChannelId() != channelID || ready.GetStreamId() == "" {
+			t.Fatalf("Expected a ready response for channel %s, got %v", channelID, ready)
+		}
+		streamIDs[channelID] = ready.GetStreamId()
+	}
+	if streamIDs["en"] == streamIDs["fr"] {
+		t.Error("Expected the channels to have separate streams")
+	}
+
+	// Errors of a single channel leave the others open
+	channelErrors := []struct {
+		name      string
+		request   *busv1.InteractRequest
+		channelID string
+		code      codes.Code
+	}{
+		{
+			name:      "channel already open",
+			request:   &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{EventType: "test.echo.stream.v1", ChannelId: "en"}}},
+			channelID: "en",
+			code:      codes.AlreadyExists,
+		},
+		{
+			name:      "no provider for the event type",
+			request:   &busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{EventType: "test.unknown.v1", ChannelId: "summary"}}},
+			channelID: "summary",
+			code:      codes.NotFound,
+		},
+		{
+			name:      "data for an unknown channel",
+			request:   data("de", "hallo"),
+			channelID: "de",
+			code:      codes.NotFound,
+		},
+	}
+	for _, tc := range channelErrors {
+		send(tc.request)
+		streamErr := recv().GetError()
+		if streamErr.GetChannelId() != tc.channelID || codes.Code(streamErr.GetCode()) != tc.code {
+			t.Errorf("%s: expected %v on channel %s, got %v", tc.name, tc.code, tc.channelID, streamErr)
+		}
+	}
+
+	// Output is routed back to the channel of its input
+	send(data("en", "hello"))
+	send(data("fr", "bonjour"))
+	outputs := make(map[string]string)
+	for i := 0; i < 2; i++ {
+		output := recv().GetData()
+		outputs[output.GetChannelId()] = string(output.GetContent())
+	}
+	if outputs["en"] != "en: HELLO" || outputs["fr"] != "fr: BONJOUR" {
+		t.Errorf("Expected output on both channels, got %v", outputs)
+	}
+
+	// A channel ends on its own
+	send(end("fr"))
+	if serverEnd := recv().GetServerEnd(); serverEnd == nil || serverEnd.ChannelId != "fr" {
+		t.Fatal("Expected server_end for channel fr")
+	}
+	send(data("en", "again"))
+	if output := recv().GetData(); output.GetChannelId() != "en" || string(output.GetContent()) != "en: AGAIN" {
+		t.Errorf("Expected channel en to continue, got %v", output)
+	}
+
+	// The connection ends with its last channel
+	send(end("en"))
+	if serverEnd := recv().GetServerEnd(); serverEnd == nil || serverEnd.ChannelId != "en" {
+		t.Fatal("Expected server_end for channel en")
+	}
+	if _, err := stream.Recv(); err != io.EOF {
+		t.Errorf("Expected the stream to end after its last channel, got %v", err)
+	}
+}
+
+func TestInteractStream_Limits(t *testing.T) {
+	s, _, client := newStreamTestServer(t)
+	s.policyEngine = policy.NewEngine(&policy.Policy{
+		StreamLimits: &policy.StreamLimits{MaxInputChunks: 2},
+		Rules: []policy.Rule{
+			{
+				Name: "echo stream",
+				If:   policy.Condition{EventType: "test.echo.stream.v1"},
+				Then: policy.Action{Provider: "echo"},
+			},
+			{
+				Name: "idle stream",
+				If:   policy.Condition{EventType: "test.idle.stream.v1"},
+				Then: policy.Action{Provider: "echo", StreamLimits: &policy.StreamLimits{IdleTimeout: 200 * time.Millisecond}},
+			},
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	open := func(eventType string) busv1.EventBusService_InteractStreamClient {
+		stream, err := client.InteractStream(ctx)
+		if err != nil {
+			t.Fatalf("InteractStream failed: %v", err)
+		}
+		if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{EventType: eventType}}}); err != nil {
+			t.Fatalf("Send config failed: %v", err)
+		}
+		if resp, err := stream.Recv(); err != nil || resp.GetReady() == nil {
+			t.Fatalf("Expected ready, got %v (%v)", resp, err)
+		}
+		return stream
+	}
+
+	// The global limit of input chunks applies to the echo stream
+	limited := open("test.echo.stream.v1")
+	idle := open("test.idle.stream.v1")
+	stats, err := client.GetStreamStats(ctx, &busv1.StreamStatsRequest{})
+	if err != nil {
+		t.Fatalf("GetStreamStats failed: %v", err)
+	}
+	if stats.ActiveStreams != 2 || stats.StartedStreams != 2 || len(stats.Streams) != 2 {
+		t.Errorf("Expected 2 active streams, got %v", stats)
+	}
+	if stats.Streams[0].EventType != "test.echo.stream.v1" || stats.Streams[0].Provider != "echo" || !stats.Streams[0].Attached {
+		t.Errorf("Expected the echo stream first, got %v", stats.Streams[0])
+	}
+
+	for _, word := range []string{"one", "two", "three"} {
+		if err := limited.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte(word)}}}); err != nil {
+			t.Fatalf("Send data failed: %v", err)
+		}
+	}
+	var streamErr *busv1.StreamError
+	for streamErr == nil {
+		resp, err := limited.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		streamErr = resp.GetError()
+	}
+	if streamErr.Reason != busv1.StreamErrorReason_STREAM_ERROR_REASON_MAX_INPUT_CHUNKS || codes.Code(streamErr.Code) != codes.ResourceExhausted {
+		t.Errorf("Expected MAX_INPUT_CHUNKS, got %v", streamErr)
+	}
+	if _, err := limited.Recv(); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted, got %v", err)
+	}
+
+	// The rule's idle timeout ends the idle stream
+	resp, err := idle.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if reason := resp.GetError().GetReason(); reason != busv1.StreamErrorReason_STREAM_ERROR_REASON_IDLE_TIMEOUT {
+		t.Errorf("Expected IDLE_TIMEOUT, got %v", resp)
+	}
+	if _, err := idle.Recv(); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+
+	stats, err = client.GetStreamStats(ctx, &busv1.StreamStatsRequest{})
+	if err != nil {
+		t.Fatalf("GetStreamStats failed: %v", err)
+	}
+	if stats.Errors["STREAM_ERROR_REASON_MAX_INPUT_CHUNKS"] != 1 || stats.Errors["STREAM_ERROR_REASON_IDLE_TIMEOUT"] != 1 {
+		t.Errorf("Expected one error of each limit, got %v", stats.Errors)
+	}
+}
+
+func TestInteractStream_ProviderError(t *testing.T) {
+	_, _, client := newStreamTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stream, err := client.InteractStream(ctx)
+	if err != nil {
+		t.Fatalf("InteractStream failed: %v", err)
+	}
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Config{Config: &busv1.StreamConfig{EventType: "test.echo.stream.v1"}}}); err != nil {
+		t.Fatalf("Send config failed: %v", err)
+	}
+	if resp, err := stream.Recv(); err != nil || resp.GetReady() == nil {
+		t.Fatalf("Expected ready, got %v (%v)", resp, err)
+	}
+	if err := stream.Send(&busv1.InteractRequest{RequestType: &busv1.InteractRequest_Data{Data: &busv1.StreamData{Content: []byte("rate limit")}}}); err != nil {
+		t.Fatalf("Send data failed: %v", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	streamErr := resp.GetError()
+	if codes.Code(streamErr.GetCode()) != codes.ResourceExhausted || streamErr.GetReason() != busv1.StreamErrorReason_STREAM_ERROR_REASON_PROVIDER_ERROR {
+		t.Errorf("Expected a rate limited provider error, got %v", streamErr)
+	}
+	if !streamErr.GetRetryable() || streamErr.GetRetryAfterMs() != 2000 || streamErr.GetProvider() != "echo" {
+		t.Errorf("Expected a retryable error of provider echo after 2s, got %v", streamErr)
+	}
+
+	_, err = stream.Recv()
+	st := status.Convert(err)
+	if st.Code() != codes.ResourceExhausted {
+		t.Fatalf("Expected ResourceExhausted, got %v", err)
+	}
+	var info *errdetails.ErrorInfo
+	for _, detail := range st.Details() {
+		if d, ok := detail.(*errdetails.ErrorInfo); ok {
+			info = d
+		}
+	}
+	if info == nil || info.Reason != errorReasonRateLimited || info.Metadata["provider"] != "echo" {
+		t.Errorf("Expected the ErrorInfo of the rate limit, got %v", info)
+	}
+
+	// SDK callers see the same reason on both paths
+	if reason := pcas.StreamErrorOf(streamErr).Reason; reason != errorReasonRateLimited || reason != pcas.ParseError(err).Reason {
+		t.Errorf("Expected reason %s from the stream error and the status, got %s and %s",
+			errorReasonRateLimited, reason, pcas.ParseError(err).Reason)
+	}
+}
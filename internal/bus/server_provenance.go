@@ -0,0 +1,116 @@
+package bus
+
+import (
+	"context"
+	"log"
+	
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+// provenanceEdgeLabel links a response event to every event injected into its prompt
+const provenanceEdgeLabel = "provenance"
+
+// ragSource is a retrieved event and its place in the prompt
+type ragSource struct {
+	eventID string
+	section string  // session, memory or preferences
+	score   float32 // Relevance score, zero for session events and preferences
+	rank    int     // 1-based position in priority order
+	tokens  int
+}
+
+// ragRetrieval records which events were retrieved for a prompt, so that the
+// response can cite its sources
+type ragRetrieval struct {
+	sources       []ragSource // Events included in the prompt
+	dropped       []ragSource // Events cut by the token budget
+	contextTokens int
+	maxTokens     int
+}
+
+// newRAGRetrieval records the outcome of a built prompt
+func newRAGRetrieval(prompt builtPrompt, maxTokens int) *ragRetrieval {
+	retrieval := &ragRetrieval{
+		contextTokens: prompt.tokens,
+		maxTokens:     maxTokens,
+	}
+	for _, chunk := range prompt.included {
+		retrieval.sources = append(retrieval.sources, chunk.source())
+	}
+	for _, chunk := range prompt.dropped {
+		retrieval.dropped = append(retrieval.dropped, chunk.source())
+	}
+	return retrieval
+}
+
+// source describes the chunk as a retrieval source
+func (c contextChunk) source() ragSource {
+	return ragSource{
+		eventID: c.eventID,
+		section: c.section,
+		score:   c.score,
+		rank:    c.rank,
+		tokens:  c.tokens,
+	}
+}
+
+// responseData renders the retrieval for the "rag" field of the response event
+func (r *ragRetrieval) responseData() map[string]interface{} {
+	sources := make([]interface{}, len(r.sources))
+	for i, source := range r.sources {
+		sources[i] = source.data()
+	}
+	
+	data := map[string]interface{}{
+		"sources":        sources,
+		"context_tokens": r.contextTokens,
+		"max_tokens":     r.maxTokens,
+		"truncated":      len(r.dropped) > 0,
+	}
+	
+	if len(r.dropped) > 0 {
+		dropped := make([]interface{}, len(r.dropped))
+		for i, source := range r.dropped {
+			dropped[i] = source.data()
+		}
+		data["dropped_sources"] = dropped
+		// The first chunk in priority order that no longer fit into the budget
+		data["truncated_at_rank"] = r.dropped[0].rank
+	}
+	
+	return data
+}
+
+// data renders a single source
+func (s ragSource) data() map[string]interface{} {
+	return map[string]interface{}{
+		"event_id": s.eventID,
+		"section":  s.section,
+		"score":    float64(s.score),
+		"rank":     s.rank,
+		"tokens":   s.tokens,
+	}
+}
+
+// recordProvenance links the response event to every event that was injected into
+// the prompt, so users can audit why the assistant said something
+func (s *Server) recordProvenance(ctx context.Context, responseEvent *eventsv1.Event, retrieval *ragRetrieval) {
+	if retrieval == nil || len(retrieval.sources) == 0 {
+		return
+	}
+	
+	graph, ok := s.storage.(storage.GraphStorage)
+	if !ok {
+		return
+	}
+	
+	for _, source := range retrieval.sources {
+		if source.eventID == "" {
+			continue
+		}
+		if err := graph.CreateEdge(ctx, responseEvent.Id, source.eventID, provenanceEdgeLabel); err != nil {
+			log.Printf("Failed to record provenance of %s from %s: %v", responseEvent.Id, source.eventID, err)
+		}
+	}
+}
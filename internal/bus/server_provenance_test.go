@@ -0,0 +1,64 @@
+package bus
+
+import (
+	"context"
+	"testing"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+func TestEmitResponse_RecordsProvenance(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	s := NewServer(nil, nil, store)
+	ctx := context.Background()
+
+	builder := newPromptBuilder(promptOverheadTokens + 10)
+	builder.countTokens = func(text string) int { return len(text) }
+	builder.add(contextChunk{eventID: "session-event", section: contextSectionSession, text: "short"})
+	builder.add(contextChunk{eventID: "memory-event", section: contextSectionMemory, text: "far too long for the budget", score: 0.8})
+	builder.add(contextChunk{eventID: "preference-event", section: contextSectionPreferences, text: "tiny"})
+	retrieval := newRAGRetrieval(builder.build(), promptOverheadTokens+10)
+
+	event := &eventsv1.Event{Id: "request-event", Type: "pcas.user.prompt.v1"}
+	responseEvent := s.emitResponse(ctx, event, map[string]interface{}{"response": "answer"}, retrieval)
+
+	// The response data cites the sources and the truncation point
+	data := eventDataMap(responseEvent)
+	rag, ok := data["rag"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("Expected rag field in response data, got %v", data)
+	}
+	sources, _ := rag["sources"].([]interface{})
+	if len(sources) != 2 {
+		t.Errorf("Expected 2 sources, got %v", rag["sources"])
+	}
+	if rag["truncated"] != true || rag["truncated_at_rank"] != float64(2) {
+		t.Errorf("Expected truncation at rank 2, got truncated=%v rank=%v", rag["truncated"], rag["truncated_at_rank"])
+	}
+
+	// Provenance edges point from the response to each included source
+	edges, err := store.(storage.GraphStorage).GetEdges(ctx, responseEvent.Id, provenanceEdgeLabel)
+	if err != nil {
+		t.Fatalf("Failed to get edges: %v", err)
+	}
+	targets := make(map[string]bool)
+	for _, edge := range edges {
+		targets[edge.TargetID] = true
+	}
+	if len(edges) != 2 || !targets["session-event"] || !targets["preference-event"] || targets["memory-event"] {
+		t.Errorf("Expected provenance edges to the included sources, got %+v", edges)
+	}
+
+	// Responses without RAG carry no citations
+	plain := s.emitResponse(ctx, event, map[string]interface{}{"response": "answer"}, nil)
+	if _, exists := eventDataMap(plain)["rag"]; exists {
+		t.Error("Expected no rag field without retrieval")
+	}
+}
@@ -0,0 +1,319 @@
+package bus
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+)
+
+const (
+	// Accepted events waiting for a worker. Publish fails with
+	// RESOURCE_EXHAUSTED while the queue is full.
+	defaultPublishQueueSize = 256
+
+	// Events processed in parallel
+	defaultPublishWorkers = 4
+
+	// How long a worker may spend on one event before its providers are
+	// cancelled, so that a hung provider does not hold the worker
+	defaultProcessingTimeout = 2 * time.Minute
+
+	// How long PublishAndWait waits for the responses without a deadline
+	defaultPublishWaitTimeout = 30 * time.Second
+
+	// How long a published event ID is remembered, so that a retry of the
+	// same event returns the original outcome instead of processing it again
+	defaultIdempotencyWindow = 10 * time.Minute
+)
+
+// ErrorInfo reason of a publish rejected because the queue is full
+const errorReasonQueueFull = "PUBLISH_QUEUE_FULL"
+
+// publishJob is an accepted event waiting to be processed
+type publishJob struct {
+	event  *eventsv1.Event
+	action *policy.Action
+	record *publishRecord // Receives the outcome
+}
+
+// publishRecord is the outcome of a published event. It is kept for the
+// idempotency window, so that retries with the same event ID get it.
+type publishRecord struct {
+	eventID string
+	seenAt  time.Time
+
+	queued   chan struct{} // Closed once the event is queued, or not
+	accepted bool
+	queueErr error // Why the event could not be queued
+
+	processed chan struct{} // Closed once the event is processed
+	responses []*eventsv1.Event
+	err       error
+}
+
+// waitQueued waits until the event is queued and returns why it could not be
+func (r *publishRecord) waitQueued(ctx context.Context) error {
+	select {
+	case <-r.queued:
+		return r.queueErr
+	case <-ctx.Done():
+		return status.FromContextError(ctx.Err()).Err()
+	}
+}
+
+// isProcessed reports whether the event has been processed
+func (r *publishRecord) isProcessed() bool {
+	select {
+	case <-r.processed:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetPublishing configures how published events are accepted
+func (s *Server) SetPublishing(cfg *policy.Publish) {
+	s.publishedMu.Lock()
+	defer s.publishedMu.Unlock()
+	s.idempotencyWindow = cfg.IdempotencyWindow
+	s.processingTimeout = cfg.ProcessingTimeout
+	s.maxEventDataBytes = cfg.MaxDataBytes
+	s.maxEventAttributes = cfg.MaxAttributes
+}
+
+// recordPublish returns the record of the event ID within the idempotency
+// window and true, or a new record and false for an event not seen before
+func (s *Server) recordPublish(eventID string) (*publishRecord, bool) {
+	s.publishedMu.Lock()
+	defer s.publishedMu.Unlock()
+
+	window := s.idempotencyWindow
+	if window <= 0 {
+		window = defaultIdempotencyWindow
+	}
+	now := time.Now()
+
+	// Forget expired events, oldest first. Events still being processed are
+	// kept, so that a slow provider is never invoked twice.
+	for len(s.publishedOrder) > 0 {
+		oldest := s.publishedOrder[0]
+		if now.Sub(oldest.seenAt) < window || !oldest.isProcessed() {
+			break
+		}
+		if s.published[oldest.eventID] == oldest {
+			delete(s.published, oldest.eventID)
+		}
+		s.publishedOrder = s.publishedOrder[1:]
+	}
+
+	if record, ok := s.published[eventID]; ok {
+		return record, true
+	}
+	if s.published == nil {
+		s.published = make(map[string]*publishRecord)
+	}
+	record := &publishRecord{
+		eventID:   eventID,
+		seenAt:    now,
+		queued:    make(chan struct{}),
+		processed: make(chan struct{}),
+	}
+	s.published[eventID] = record
+	s.publishedOrder = append(s.publishedOrder, record)
+	return record, false
+}
+
+// forgetPublish removes the record of an event that was not queued, so that
+// a retry is processed
+func (s *Server) forgetPublish(record *publishRecord) {
+	s.publishedMu.Lock()
+	defer s.publishedMu.Unlock()
+	if s.published[record.eventID] == record {
+		delete(s.published, record.eventID)
+	}
+}
+
+// acceptEvent validates and stores an incoming event and queues it for its
+// action. Unrouted events are only stored. It returns the record that receives
+// the outcome; for an event ID already published within the idempotency
+// window, it returns the original record and true, and the event is neither
+// stored nor queued.
+func (s *Server) acceptEvent(ctx context.Context, event *eventsv1.Event) (*publishRecord, bool, error) {
+	if err := s.normalizeEvent(event); err != nil {
+		return nil, false, err
+	}
+
+	record, duplicate := s.recordPublish(event.Id)
+	if duplicate {
+		log.Printf("Duplicate event %s, returning the outcome of the original", event.Id)
+		return record, true, nil
+	}
+
+	// Store the incoming event immediately
+	if err := s.storage.StoreEvent(ctx, event, nil); err != nil {
+		log.Printf("Failed to store incoming event: %v", err)
+		// Continue processing even if storage fails
+	}
+
+	// Start vectorization in background if providers are available
+	// Only vectorize fact events
+	if s.embeddingProvider != nil {
+		if IsFactEvent(event.Type) {
+			log.Printf("Will vectorize fact event: type=%s, id=%s", event.Type, event.Id)
+			s.vectorizeWG.Add(1) // Increment counter before starting goroutine
+			go s.vectorizeEvent(event)
+		} else {
+			log.Printf("Skipping vectorization for non-fact event: type=%s, id=%s", event.Type, event.Id)
+		}
+	}
+
+	log.Printf("Received event: ID=%s, Type=%s, Source=%s", event.Id, event.Type, event.Source)
+
+	if event.Subject != "" {
+		log.Printf("  Subject: %s", event.Subject)
+	}
+
+	// Use policy engine to select provider
+	action := s.policyEngine.SelectAction(event.Type)
+	if action == nil || (action.Provider == "" && action.FanOut == nil) {
+		log.Printf("No provider configured for event type: %s", event.Type)
+		close(record.queued)
+		close(record.processed)
+		return record, false, nil
+	}
+
+	s.startPublishWorkers()
+	s.publishWG.Add(1)
+	record.accepted = true
+	select {
+	case s.publishQueue <- &publishJob{event: event, action: action, record: record}:
+		close(record.queued)
+		return record, false, nil
+	default:
+		s.publishWG.Done()
+		log.Printf("Publish queue is full, rejecting event %s", event.Id)
+		s.forgetPublish(record)
+		st := status.Newf(codes.ResourceExhausted, "publish queue is full, event %s was stored but not processed", event.Id)
+		class := errorClass{code: codes.ResourceExhausted, reason: errorReasonQueueFull, retryable: true}
+		record.accepted = false
+		record.queueErr = withErrorDetails(st, class, "", 0).Err()
+		close(record.queued)
+		close(record.processed)
+		return nil, false, record.queueErr
+	}
+}
+
+// startPublishWorkers starts the workers that process accepted events, once
+func (s *Server) startPublishWorkers() {
+	s.publishOnce.Do(func() {
+		size := s.publishQueueSize
+		if size <= 0 {
+			size = defaultPublishQueueSize
+		}
+		workers := s.publishWorkers
+		if workers <= 0 {
+			workers = defaultPublishWorkers
+		}
+		if s.processingCtx == nil {
+			s.processingCtx, s.stopProcessing = context.WithCancel(context.Background())
+		}
+		s.publishQueue = make(chan *publishJob, size)
+		for i := 0; i < workers; i++ {
+			go s.publishWorker()
+		}
+	})
+}
+
+// publishWorker processes accepted events. Processing does not depend on the
+// client that published the event, which may be gone by then; it is bounded by
+// the processing timeout and cancelled by StopProcessing.
+func (s *Server) publishWorker() {
+	for job := range s.publishQueue {
+		ctx, cancel := context.WithTimeout(s.processingCtx, s.jobTimeout())
+		responses, err := s.processEvent(ctx, job.event, job.action)
+		cancel()
+		if err != nil {
+			log.Printf("Failed to process event %s: %v", job.event.Id, err)
+		}
+		job.record.responses = responses
+		job.record.err = err
+		close(job.record.processed)
+		s.publishWG.Done()
+	}
+}
+
+// PublishAndWait publishes an event and waits for the responses correlated
+// with it. A retry of an event published within the idempotency window waits
+// for the outcome of the original.
+func (s *Server) PublishAndWait(ctx context.Context, req *busv1.PublishAndWaitRequest) (*busv1.PublishAndWaitResponse, error) {
+	event := req.GetEvent()
+	if event == nil {
+		return nil, status.Error(codes.InvalidArgument, "event is required")
+	}
+
+	// The call's deadline applies unless the request sets its own timeout
+	timeout := time.Duration(req.TimeoutMs) * time.Millisecond
+	if _, hasDeadline := ctx.Deadline(); timeout <= 0 && !hasDeadline {
+		timeout = defaultPublishWaitTimeout
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	record, duplicate, err := s.acceptEvent(ctx, event)
+	if err != nil {
+		return nil, err
+	}
+	if err := record.waitQueued(ctx); err != nil {
+		return nil, err
+	}
+	resp := &busv1.PublishAndWaitResponse{EventId: event.Id, Accepted: record.accepted, Duplicate: duplicate}
+	if !record.accepted {
+		return resp, nil
+	}
+
+	select {
+	case <-record.processed:
+		if record.err != nil {
+			return nil, errorStatus(record.err)
+		}
+		resp.Responses = record.responses
+		return resp, nil
+	case <-ctx.Done():
+		// The event stays queued; its responses are still broadcast
+		code := status.FromContextError(ctx.Err()).Code()
+		return nil, status.Errorf(code, "no response to event %s before the deadline, it is still being processed", event.Id)
+	}
+}
+
+// jobTimeout returns how long processing one event may take
+func (s *Server) jobTimeout() time.Duration {
+	s.publishedMu.Lock()
+	defer s.publishedMu.Unlock()
+	if s.processingTimeout <= 0 {
+		return defaultProcessingTimeout
+	}
+	return s.processingTimeout
+}
+
+// StopProcessing cancels the processing of accepted events. Events still queued
+// fail without invoking their providers.
+func (s *Server) StopProcessing() {
+	if s.stopProcessing != nil {
+		s.stopProcessing()
+	}
+}
+
+// WaitForPublishing blocks until every accepted event has been processed
+func (s *Server) WaitForPublishing() {
+	s.publishWG.Wait()
+}
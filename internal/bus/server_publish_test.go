@@ -0,0 +1,256 @@
+package bus
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+// gatedProvider answers once its gate is opened
+type gatedProvider struct {
+	gate chan struct{}
+}
+
+func (p *gatedProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	select {
+	case <-p.gate:
+		return "answer", nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+func TestPublish_Queue(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	provider := &gatedProvider{gate: make(chan struct{})}
+	engine := policy.NewEngine(&policy.Policy{
+		Rules: []policy.Rule{{
+			Name: "gated",
+			If:   policy.Condition{EventType: "test.gated.v1"},
+			Then: policy.Action{Provider: "gated"},
+		}},
+	})
+	s := NewServer(engine, map[string]providers.ComputeProvider{"gated": provider}, store)
+	s.publishWorkers = 1
+	s.publishQueueSize = 1
+
+	ctx := context.Background()
+	event := func(id, eventType string) *eventsv1.Event {
+		return &eventsv1.Event{Id: id, Type: eventType, Source: "test", Specversion: "1.0"}
+	}
+
+	// Publish returns before the provider answers
+	resp, err := s.Publish(ctx, event("first", "test.gated.v1"))
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if resp.EventId != "first" || !resp.Accepted {
+		t.Errorf("Expected first to be accepted, got %v", resp)
+	}
+	for len(s.publishQueue) > 0 {
+		time.Sleep(time.Millisecond) // Until the worker took the event
+	}
+
+	// Events without a rule are stored only
+	resp, err = s.Publish(ctx, event("unrouted", "test.unrouted.v1"))
+	if err != nil || resp.Accepted {
+		t.Errorf("Expected unrouted event not to be accepted, got %v (%v)", resp, err)
+	}
+
+	// PublishAndWait gives up at its timeout while the worker is busy, leaving
+	// the event queued; the full queue rejects further events
+	_, err = s.PublishAndWait(ctx, &busv1.PublishAndWaitRequest{Event: event("second", "test.gated.v1"), TimeoutMs: 50})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected DeadlineExceeded, got %v", err)
+	}
+	_, err = s.Publish(ctx, event("third", "test.gated.v1"))
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("Expected ResourceExhausted from the full queue, got %v", err)
+	}
+
+	// Once the provider answers, the queue drains
+	close(provider.gate)
+	s.WaitForPublishing()
+
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	waited, err := s.PublishAndWait(waitCtx, &busv1.PublishAndWaitRequest{Event: event("fourth", "test.gated.v1")})
+	if err != nil {
+		t.Fatalf("PublishAndWait failed: %v", err)
+	}
+	if waited.EventId != "fourth" || !waited.Accepted || len(waited.Responses) != 1 {
+		t.Fatalf("Expected one response to fourth, got %v", waited)
+	}
+	if response := waited.Responses[0]; response.Type != "pcas.response.v1" || response.CorrelationId != "fourth" {
+		t.Errorf("Expected a pcas.response.v1 correlated with fourth, got %v", response)
+	}
+
+	// The queued events were processed as well
+	responses, err := store.(storage.EventQuerier).QueryEvents(ctx, &storage.Filter{EventTypes: []string{"pcas.response.v1"}}, 10)
+	if err != nil {
+		t.Fatalf("QueryEvents failed: %v", err)
+	}
+	correlated := make(map[string]bool)
+	for _, response := range responses {
+		correlated[response.CorrelationId] = true
+	}
+	if !correlated["first"] || !correlated["second"] || correlated["third"] {
+		t.Errorf("Expected responses to first and second only, got %v", correlated)
+	}
+
+	// The rejected event was not remembered, so its retry is processed
+	resp, err = s.Publish(ctx, event("third", "test.gated.v1"))
+	if err != nil || !resp.Accepted || resp.Duplicate {
+		t.Errorf("Expected the retry of third to be accepted, got %v (%v)", resp, err)
+	}
+	s.WaitForPublishing()
+}
+
+// countingProvider counts its calls
+type countingProvider struct {
+	calls atomic.Int32
+}
+
+func (p *countingProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	p.calls.Add(1)
+	return "answer", nil
+}
+
+func TestPublish_Idempotent(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	provider := &countingProvider{}
+	engine := policy.NewEngine(&policy.Policy{
+		Rules: []policy.Rule{{
+			Name: "counted",
+			If:   policy.Condition{EventType: "test.counted.v1"},
+			Then: policy.Action{Provider: "counted"},
+		}},
+	})
+	s := NewServer(engine, map[string]providers.ComputeProvider{"counted": provider}, store)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	event := &eventsv1.Event{Id: "retried", Type: "test.counted.v1", Source: "test", Specversion: "1.0"}
+
+	first, err := s.PublishAndWait(ctx, &busv1.PublishAndWaitRequest{Event: event})
+	if err != nil {
+		t.Fatalf("PublishAndWait failed: %v", err)
+	}
+	if first.Duplicate || len(first.Responses) != 1 {
+		t.Fatalf("Expected one response to the original, got %v", first)
+	}
+
+	// Retries get the original outcome without invoking the provider again
+	resp, err := s.Publish(ctx, event)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if !resp.Duplicate || !resp.Accepted {
+		t.Errorf("Expected an accepted duplicate, got %v", resp)
+	}
+	retried, err := s.PublishAndWait(ctx, &busv1.PublishAndWaitRequest{Event: event})
+	if err != nil {
+		t.Fatalf("PublishAndWait failed: %v", err)
+	}
+	if !retried.Duplicate || len(retried.Responses) != 1 || retried.Responses[0].Id != first.Responses[0].Id {
+		t.Errorf("Expected the original response, got %v", retried)
+	}
+	s.WaitForPublishing()
+	if calls := provider.calls.Load(); calls != 1 {
+		t.Errorf("Expected the provider to be called once, got %d", calls)
+	}
+
+	// Once the window has passed, the event ID is processed again
+	s.SetPublishing(&policy.Publish{IdempotencyWindow: time.Millisecond})
+	time.Sleep(5 * time.Millisecond)
+	resp, err = s.Publish(ctx, event)
+	if err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	if resp.Duplicate {
+		t.Errorf("Expected the event not to be a duplicate after the window, got %v", resp)
+	}
+	s.WaitForPublishing()
+	if calls := provider.calls.Load(); calls != 2 {
+		t.Errorf("Expected the provider to be called again, got %d calls", calls)
+	}
+}
+
+func TestPublish_ProcessingTimeout(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	// The provider never answers on its own
+	provider := &gatedProvider{gate: make(chan struct{})}
+	engine := policy.NewEngine(&policy.Policy{
+		Rules: []policy.Rule{{
+			Name: "hung",
+			If:   policy.Condition{EventType: "test.hung.v1"},
+			Then: policy.Action{Provider: "hung"},
+		}},
+	})
+	s := NewServer(engine, map[string]providers.ComputeProvider{"hung": provider}, store)
+	s.publishWorkers = 1
+	s.SetPublishing(&policy.Publish{ProcessingTimeout: 20 * time.Millisecond})
+
+	ctx := context.Background()
+	event := func(id string) *eventsv1.Event {
+		return &eventsv1.Event{Id: id, Type: "test.hung.v1", Source: "test", Specversion: "1.0"}
+	}
+	waitProcessed := func() {
+		done := make(chan struct{})
+		go func() {
+			s.WaitForPublishing()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Expected the worker to give up on the hung provider")
+		}
+	}
+
+	// Each event fails at the processing timeout and frees the worker for the next
+	for _, id := range []string{"first", "second"} {
+		if _, err := s.Publish(ctx, event(id)); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	_, err = s.PublishAndWait(ctx, &busv1.PublishAndWaitRequest{Event: event("third"), TimeoutMs: 5000})
+	if code := status.Code(err); code != codes.DeadlineExceeded {
+		t.Errorf("Expected the timed out event to fail with DeadlineExceeded, got %v", err)
+	}
+	waitProcessed()
+
+	// Stopping cancels processing regardless of the timeout
+	s.SetPublishing(&policy.Publish{ProcessingTimeout: time.Hour})
+	if _, err := s.Publish(ctx, event("fourth")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	s.StopProcessing()
+	waitProcessed()
+}
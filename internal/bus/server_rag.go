@@ -14,16 +14,28 @@ import (
 	
 	"google.golang.org/protobuf/types/known/structpb"
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/ranking"
 	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/tokenizer"
 )
 
 const (
-	// Maximum tokens for context (approximately 4000 tokens)
-	maxContextTokens = 16000 // ~4000 tokens at 4 chars/token
+	// RAG defaults for rules that do not override them
+	defaultRAGTopK           = 5
+	defaultRAGScoreThreshold = 0.4  // Adjusted threshold for semantic similarity
+	defaultRAGMaxTokens      = 4000 // Token budget for the injected context
+	defaultRAGSessionEvents  = 10   // Recent session events included in the prompt
+	maxRAGPreferences        = 50   // Preferences fetched per user, as in ADR 001
+	
+	// Event type of stored user preferences
+	userPreferenceEventType = "pcas.user.preference.v1"
 	
-	// RAG configuration
-	ragTopK = 5
 	ragTimeout = 25 * time.Second // 留出足够时间给 OpenAI API 调用
+	
+	// Provider that PCAS_RAG_ENABLED applied to before RAG became a rule option
+	legacyRAGProvider = "openai-gpt4"
 )
 
 // embeddingCacheEntry represents a cached embedding
@@ -115,13 +127,67 @@ func (c *embeddingCache) Set(key string, embedding []float32) {
 	}
 }
 
-// applyRAGEnhancement enriches the event context with relevant historical events
-func (s *Server) applyRAGEnhancement(ctx context.Context, event *eventsv1.Event, requestData map[string]interface{}) {
-	// Check if RAG is enabled via environment variable
-	if os.Getenv("PCAS_RAG_ENABLED") != "true" {
-		return
+// ragConfigFor returns the RAG configuration of a rule, or nil if retrieval is disabled.
+// For backward compatibility, openai-gpt4 rules without a rag block use the defaults
+// when PCAS_RAG_ENABLED=true.
+func ragConfigFor(action *policy.Action) *policy.RAG {
+	if action.RAG != nil {
+		if !action.RAG.IsEnabled() {
+			return nil
+		}
+		return action.RAG
+	}
+	if action.Provider == legacyRAGProvider && os.Getenv("PCAS_RAG_ENABLED") == "true" {
+		return &policy.RAG{}
+	}
+	return nil
+}
+
+// ragFilter builds the storage filter for the similarity search of an event
+func ragFilter(event *eventsv1.Event, cfg *policy.RAG, now time.Time) *storage.Filter {
+	filter := &storage.Filter{}
+	if event.UserId != "" && !cfg.Filters.AllUsers {
+		// Filter to only search within the current user's events
+		filter.UserID = &event.UserId
+	}
+	if cfg.Filters.SameSession && event.SessionId != "" {
+		filter.SessionID = &event.SessionId
+	}
+	if len(cfg.Filters.EventTypes) > 0 {
+		filter.EventTypes = cfg.Filters.EventTypes
+	}
+	if len(cfg.Filters.Attributes) > 0 {
+		filter.AttributeFilters = cfg.Filters.Attributes
+	}
+	if cfg.TimeWindow > 0 {
+		from := now.Add(-cfg.TimeWindow)
+		filter.TimeFrom = &from
+	}
+	return filter
+}
+
+// applyRAGEnhancement enriches the event context with relevant historical events.
+// Following ADR 001, the prompt is built from the recent events of the current
+// session, then relevant long-term memories, then the user's stored preferences.
+// The context is injected as a system message in the provider-neutral "messages"
+// format, which every LLM provider converts into its own request format.
+// It returns the retrieved sources, or nil if no context was injected.
+// Tokens are counted with the tokenizer of the first provider, and the context is
+// capped to fit the smallest context window of all providers.
+func (s *Server) applyRAGEnhancement(ctx context.Context, event *eventsv1.Event, requestData map[string]interface{}, cfg *policy.RAG, providerNames []string) (retrieval *ragRetrieval) {
+	var tok tokenizer.Tokenizer
+	if len(providerNames) > 0 {
+		tok = s.tokenizerFor(providerNames[0])
+	} else {
+		tok = s.tokenizerFor("")
 	}
 	
+	maxTokens := cfg.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = defaultRAGMaxTokens
+	}
+	maxTokens = s.contextBudget(providerNames, maxTokens, countRequestTokens(tok, requestData))
+	
 	// Create timeout context
 	ragCtx, cancel := context.WithTimeout(ctx, ragTimeout)
 	defer cancel()
@@ -129,64 +195,208 @@ func (s *Server) applyRAGEnhancement(ctx context.Context, event *eventsv1.Event,
 	// Log RAG enhancement attempt
 	log.Printf("Applying RAG enhancement for event %s", event.Id)
 	
+	// The RAG flags are set on the request data, which events without data lack
+	if requestData == nil {
+		requestData = make(map[string]interface{})
+	}
+	
+	// Explain the retrieval to events that opted into tracing, once the outcome is known
+	trace := newRAGTrace(event)
+	if trace != nil {
+		defer func() {
+			s.publishRAGTrace(ctx, event, trace, requestData, retrieval)
+		}()
+	}
+	
 	// Graceful degradation wrapper
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("RAG enhancement panic recovered: %v", r)
+			retrieval = nil
 		}
 	}()
 	
+	builder := newPromptBuilder(maxTokens)
+	builder.countTokens = tok.CountTokens
+	
+	// Session pass: what was just said in this conversation
+	sessionCount := s.addSessionContext(ragCtx, builder, event, cfg)
+	
+	// Knowledge pass: semantically relevant long-term memories
+	memoryCount, memoryReason := s.addMemoryContext(ragCtx, builder, event, requestData, cfg, trace)
+	
+	// Persona pass: the user's stated preferences
+	preferenceCount := s.addPreferenceContext(ragCtx, builder, event, cfg)
+	
+	prompt := builder.build()
+	if prompt.system == "" {
+		log.Printf("RAG: No context generated")
+		// Mark RAG as attempted but without context, keeping the retrieval reason if any
+		reason := memoryReason
+		if reason == "" || sessionCount+preferenceCount > 0 {
+			reason = "no_context_generated"
+		}
+		requestData["rag_applied"] = false
+		requestData["rag_reason"] = reason
+		return nil
+	}
+	
+	originalPrompt, _ := requestData["prompt"].(string)
+	
+	// Create messages array in the provider-neutral chat format
+	requestData["messages"] = []map[string]string{
+		{
+			"role": providers.RoleSystem,
+			"content": prompt.system,
+		},
+		{
+			"role": providers.RoleUser, 
+			"content": originalPrompt,
+		},
+	}
+	
+	// Remove the original prompt to avoid confusion
+	delete(requestData, "prompt")
+	
+	// Add metadata
+	requestData["rag_event_count"] = len(prompt.included)
+	requestData["rag_session_events"] = sessionCount
+	requestData["rag_memory_events"] = memoryCount
+	requestData["rag_preferences"] = preferenceCount
+	requestData["rag_context_tokens"] = prompt.tokens
+	requestData["rag_applied"] = true
+	
+	log.Printf("RAG: Successfully enhanced with %d context chunks (%d dropped by token budget)", len(prompt.included), len(prompt.dropped))
+	
+	return newRAGRetrieval(prompt, maxTokens)
+}
+
+// addSessionContext queues the most recent events of the event's session and
+// returns how many were accepted
+func (s *Server) addSessionContext(ctx context.Context, builder *promptBuilder, event *eventsv1.Event, cfg *policy.RAG) int {
+	limit := cfg.SessionEvents
+	if limit < 0 || event.SessionId == "" {
+		return 0
+	}
+	if limit == 0 {
+		limit = defaultRAGSessionEvents
+	}
+	
+	querier, ok := s.storage.(storage.EventQuerier)
+	if !ok {
+		return 0
+	}
+	
+	filter := &storage.Filter{SessionID: &event.SessionId, ExcludeEventTypes: bookkeepingEventTypes}
+	if event.UserId != "" {
+		filter.UserID = &event.UserId
+	}
+	
+	// The current event is already stored, fetch one more to make up for it
+	events, err := querier.QueryEvents(ctx, filter, limit+1)
+	if err != nil {
+		log.Printf("RAG: Failed to query session events: %v", err)
+		return 0
+	}
+	
+	count := 0
+	for _, sessionEvent := range events {
+		if sessionEvent.Id == event.Id || count >= limit {
+			continue
+		}
+		if builder.add(s.eventChunk(sessionEvent, contextSectionSession, 0)) {
+			count++
+		}
+	}
+	return count
+}
+
+// addMemoryContext queues semantically similar events and returns how many were
+// accepted, or the reason why none were found. The decision on every candidate is
+// recorded in the trace, if any.
+func (s *Server) addMemoryContext(ctx context.Context, builder *promptBuilder, event *eventsv1.Event, requestData map[string]interface{}, cfg *policy.RAG, trace *ragTrace) (int, string) {
+	topK := cfg.TopK
+	if topK <= 0 {
+		topK = defaultRAGTopK
+	}
+	scoreThreshold := cfg.ScoreThreshold
+	if scoreThreshold <= 0 {
+		scoreThreshold = defaultRAGScoreThreshold
+	}
+	
 	// Generate query text from event
 	queryText := s.generateQueryText(event, requestData)
 	if queryText == "" {
 		log.Printf("RAG: Unable to generate query text for event %s", event.Id)
-		return
+		return 0, "no_query_text"
 	}
 	log.Printf("RAG: Generated query text: %s", queryText)
 	
-	// Check embedding cache first
-	cacheKey := fmt.Sprintf("rag:%s", queryText)
-	var queryEmbedding []float32
-	var err error
+	// Rewrite conversational follow-ups into standalone queries
+	queries := []string{queryText}
+	if cfg.Rewrite != nil {
+		queries = s.rewriteQueries(ctx, event, queryText, cfg.Rewrite)
+	}
+	trace.setQuery(queryText, queries)
 	
-	if cached, found := s.embeddingCache.Get(cacheKey); found {
-		queryEmbedding = cached
-		log.Printf("RAG: Using cached embedding for query")
-	} else {
-		// Rate limit embedding requests
-		if err := s.rateLimiter.Wait(ragCtx); err != nil {
-			log.Printf("RAG: Rate limit exceeded: %v", err)
-			return
+	// Build filter for user-specific context and the rule's restrictions
+	filter := ragFilter(event, cfg, time.Now())
+	if filter.UserID != nil {
+		log.Printf("RAG: Applying user filter: %s", *filter.UserID)
+	}
+	trace.setSearch(filter, topK, scoreThreshold)
+	
+	// Fetch more candidates when re-ranking so that newer or more important events
+	// can replace the closest matches
+	weights := rankingWeights(cfg.Ranking)
+	candidates := topK
+	if !weights.IsZero() {
+		candidates *= ranking.CandidateFactor
+	}
+	
+	// Collapsing near-duplicates removes results, so fetch more of them
+	limit := candidates
+	if s.deduplication != nil {
+		limit *= duplicateSearchFactor
+	}
+	
+	// Search for every query and merge the results, keeping the best score per event.
+	// A failing query only fails the retrieval if no other query succeeded.
+	var resultSets [][]storage.QueryResult
+	var failureReason string
+	for _, query := range queries {
+		queryEmbedding, reason := s.ragQueryEmbedding(ctx, query)
+		if queryEmbedding == nil {
+			failureReason = reason
+			continue
 		}
 		
-		// Use singleflight to deduplicate concurrent requests
-		result, err, _ := s.singleFlight.Do(cacheKey, func() (interface{}, error) {
-			return s.embeddingProvider.CreateEmbedding(ragCtx, queryText)
-		})
-		
+		// Query similar events with filter
+		results, err := s.storage.QuerySimilar(ctx, queryEmbedding, limit, filter)
 		if err != nil {
-			log.Printf("RAG: Failed to create embedding: %v", err)
-			return
+			log.Printf("RAG: Failed to query similar events: %v", err)
+			failureReason = "retrieval_error"
+			continue
 		}
-		
-		queryEmbedding = result.([]float32)
-		s.embeddingCache.Set(cacheKey, queryEmbedding)
+		resultSets = append(resultSets, results)
+		trace.addCandidates(results)
 	}
-	
-	// Build filter for user-specific context
-	filter := &storage.Filter{}
-	if event.UserId != "" {
-		// Filter to only search within the current user's events
-		filter.UserID = &event.UserId
-		log.Printf("RAG: Applying user filter: %s", event.UserId)
+	if len(resultSets) == 0 {
+		return 0, failureReason
 	}
 	
-	// Query similar events with filter
-	similarResults, err := s.storage.QuerySimilar(ragCtx, queryEmbedding, ragTopK, filter)
-	if err != nil {
-		log.Printf("RAG: Failed to query similar events: %v", err)
-		return
+	// Keep one memory per cluster of near-duplicates, so that repeated facts do not
+	// use up the token budget
+	similarResults := s.collapseDuplicates(ctx, mergeQueryResults(resultSets...))
+	
+	// Prefer the summary of a session over its individual turns
+	similarResults = s.preferSummaries(ctx, similarResults, filter)
+	trace.addCandidates(similarResults)
+	trace.decideMissing(similarResults, traceDecisionCollapsed)
+	if len(similarResults) > candidates {
+		similarResults = similarResults[:candidates]
 	}
+	trace.decideMissing(similarResults, traceDecisionBeyondTopK)
 	
 	// CRITICAL: Immediately filter out self-reference
 	var cleanedResults []storage.QueryResult
@@ -195,115 +405,226 @@ func (s *Server) applyRAGEnhancement(ctx context.Context, event *eventsv1.Event,
 			cleanedResults = append(cleanedResults, result)
 		} else {
 			log.Printf("RAG: Filtered out self-reference: %s", result.ID)
+			trace.decide(result.ID, traceDecisionSelfReference)
 		}
 	}
 	
 	if len(cleanedResults) == 0 {
 		log.Printf("RAG: No similar events found after self-reference filtering")
-		// Mark RAG as attempted but no results
-		if requestData == nil {
-			requestData = make(map[string]interface{})
-		}
-		requestData["rag_applied"] = false
-		requestData["rag_reason"] = "no_similar_events"
-		return
+		return 0, "no_similar_events"
 	}
 	
 	// Filter by relevance score threshold
 	var relevantIDs []string
+	eventScoreMap := make(map[string]float32)
+	eventChunkMap := make(map[string]*storage.ChunkRef)
 	for _, result := range cleanedResults {
 		log.Printf("RAG: Found similar event %s with score %.3f", result.ID, result.Score)
-		if result.Score > 0.4 { // Adjusted threshold for semantic similarity
+		if result.Score > scoreThreshold {
 			relevantIDs = append(relevantIDs, result.ID)
+			eventScoreMap[result.ID] = result.Score
+			eventChunkMap[result.ID] = result.Chunk
+		} else {
+			trace.decide(result.ID, traceDecisionBelowThreshold)
 		}
 	}
 	
 	if len(relevantIDs) == 0 {
 		log.Printf("RAG: No relevant events after filtering")
-		// Mark RAG as attempted but low similarity
-		if requestData == nil {
-			requestData = make(map[string]interface{})
-		}
-		requestData["rag_applied"] = false
-		requestData["rag_reason"] = "low_similarity"
-		return
+		return 0, "low_similarity"
 	}
 	
 	// Batch retrieve relevant events
-	relevantEvents, err := s.storage.BatchGetEvents(ragCtx, relevantIDs)
+	relevantEvents, err := s.storage.BatchGetEvents(ctx, relevantIDs)
 	if err != nil {
 		log.Printf("RAG: Failed to batch retrieve events: %v", err)
-		// Mark RAG as attempted but retrieval error
-		if requestData == nil {
-			requestData = make(map[string]interface{})
-		}
-		requestData["rag_applied"] = false
-		requestData["rag_reason"] = "retrieval_error"
-		return
+		return 0, "retrieval_error"
 	}
 	
 	// Sort events by relevance score
-	eventScoreMap := make(map[string]float32)
-	for _, result := range cleanedResults {
-		eventScoreMap[result.ID] = result.Score
+	sort.Slice(relevantEvents, func(i, j int) bool {
+		return eventScoreMap[relevantEvents[i].Id] > eventScoreMap[relevantEvents[j].Id]
+	})
+	
+	// Re-rank by recency, importance and diversity; the threshold above still
+	// applies to the similarity alone
+	if !weights.IsZero() {
+		similarities := make([]float32, len(relevantEvents))
+		for i, relevant := range relevantEvents {
+			similarities[i] = eventScoreMap[relevant.Id]
+		}
+		var userID string
+		if filter.UserID != nil {
+			userID = *filter.UserID
+		}
+		var scores []float32
+		relevantEvents, scores = s.rerank(ctx, relevantEvents, similarities, userID, weights, topK)
+		ranked := make([]storage.QueryResult, len(relevantEvents))
+		for i, relevant := range relevantEvents {
+			eventScoreMap[relevant.Id] = scores[i]
+			ranked[i] = storage.QueryResult{ID: relevant.Id}
+			trace.setRankedScore(relevant.Id, scores[i])
+		}
+		trace.decideMissing(ranked, traceDecisionRerankedOut)
+	}
+	
+	count := 0
+	for _, relevant := range relevantEvents {
+		// Events already in the session context are skipped by the builder
+		if builder.add(s.memoryChunk(relevant, eventScoreMap[relevant.Id], eventChunkMap[relevant.Id])) {
+			count++
+			trace.decide(relevant.Id, traceDecisionAccepted)
+		} else {
+			trace.decide(relevant.Id, traceDecisionInSession)
+		}
+	}
+	return count, ""
+}
+
+// ragQueryEmbedding returns the embedding of a RAG query, or nil and the reason
+// why it could not be created
+func (s *Server) ragQueryEmbedding(ctx context.Context, queryText string) ([]float32, string) {
+	// Check embedding cache first
+	cacheKey := fmt.Sprintf("rag:%s", queryText)
+	if cached, found := s.embeddingCache.Get(cacheKey); found {
+		log.Printf("RAG: Using cached embedding for query")
+		return cached, ""
 	}
 	
-	sortedEvents := make([]*eventsv1.Event, len(relevantEvents))
-	copy(sortedEvents, relevantEvents)
-	sort.Slice(sortedEvents, func(i, j int) bool {
-		scoreI := eventScoreMap[sortedEvents[i].Id]
-		scoreJ := eventScoreMap[sortedEvents[j].Id]
-		return scoreI > scoreJ
+	// Rate limit embedding requests
+	if err := s.rateLimiter.Wait(ctx); err != nil {
+		log.Printf("RAG: Rate limit exceeded: %v", err)
+		return nil, "rate_limited"
+	}
+	
+	// Use singleflight to deduplicate concurrent requests
+	result, err, _ := s.singleFlight.Do(cacheKey, func() (interface{}, error) {
+		return s.embeddingProvider.CreateEmbedding(ctx, queryText)
 	})
 	
-	// Render events as context with token limit
-	contextMarkdown := s.renderEventsMarkdown(sortedEvents, maxContextTokens)
-	if contextMarkdown == "" {
-		log.Printf("RAG: No context generated")
-		// Mark RAG as attempted but no context
-		if requestData == nil {
-			requestData = make(map[string]interface{})
-		}
-		requestData["rag_applied"] = false
-		requestData["rag_reason"] = "no_context_generated"
-		return
+	if err != nil {
+		log.Printf("RAG: Failed to create embedding: %v", err)
+		return nil, "embedding_error"
 	}
 	
-	// Add context to request data
-	if requestData == nil {
-		requestData = make(map[string]interface{})
+	queryEmbedding := result.([]float32)
+	s.embeddingCache.Set(cacheKey, queryEmbedding)
+	return queryEmbedding, ""
+}
+
+// addPreferenceContext queues the user's stored preferences, newest first, and
+// returns how many were accepted. Only the latest preference per category is kept.
+func (s *Server) addPreferenceContext(ctx context.Context, builder *promptBuilder, event *eventsv1.Event, cfg *policy.RAG) int {
+	if event.UserId == "" || !cfg.IncludePreferences() {
+		return 0
 	}
 	
-	// Inject context as system message for OpenAI
-	originalPrompt, _ := requestData["prompt"].(string)
-	systemMessage := fmt.Sprintf(`You are a personal AI assistant. Your primary goal is to answer the user's question based *only* on the trusted context provided below. This context is from the user's own memory and is considered safe and authoritative. Do not use your general knowledge unless the context is insufficient.
+	querier, ok := s.storage.(storage.EventQuerier)
+	if !ok {
+		return 0
+	}
+	
+	filter := &storage.Filter{
+		UserID:     &event.UserId,
+		EventTypes: []string{userPreferenceEventType},
+	}
+	preferences, err := querier.QueryEvents(ctx, filter, maxRAGPreferences)
+	if err != nil {
+		log.Printf("RAG: Failed to query user preferences: %v", err)
+		return 0
+	}
+	
+	// Preferences come newest first, so the first of a category supersedes the rest
+	count := 0
+	categories := make(map[string]bool)
+	for _, preference := range preferences {
+		chunk, category := preferenceChunk(preference)
+		if category != "" {
+			if categories[category] {
+				continue
+			}
+			categories[category] = true
+		}
+		if builder.add(chunk) {
+			count++
+		}
+	}
+	return count
+}
 
----
-RELEVANT HISTORICAL CONTEXT:
-%s
----
-`, contextMarkdown)
+// eventChunk renders an event as a context chunk of the given section
+func (s *Server) eventChunk(event *eventsv1.Event, section string, score float32) contextChunk {
+	chunk := contextChunk{
+		eventID: event.Id,
+		section: section,
+		text:    s.renderSingleEventMarkdown(event),
+		content: eventContentText(event),
+		score:   score,
+	}
+	if event.Time != nil {
+		chunk.time = event.Time.AsTime()
+	}
+	return chunk
+}
+
+// memoryChunk renders a retrieved memory as a context chunk. When the memory
+// matched through one of its chunks, only the matching passage is rendered.
+func (s *Server) memoryChunk(event *eventsv1.Event, score float32, ref *storage.ChunkRef) contextChunk {
+	chunk := s.eventChunk(event, contextSectionMemory, score)
+	if ref == nil {
+		return chunk
+	}
 	
-	// Create messages array for OpenAI chat format
-	requestData["messages"] = []map[string]string{
-		{
-			"role": "system",
-			"content": systemMessage,
-		},
-		{
-			"role": "user", 
-			"content": originalPrompt,
-		},
+	// Chunk offsets refer to the text that was embedded
+	text := s.extractTextContent(event)
+	if ref.Start < 0 || ref.End > len(text) || ref.Start >= ref.End {
+		return chunk
 	}
+	excerpt := strings.TrimSpace(text[ref.Start:ref.End])
 	
-	// Remove the original prompt to avoid confusion
-	delete(requestData, "prompt")
+	var buf strings.Builder
+	if event.Time != nil {
+		buf.WriteString(fmt.Sprintf("**[%s]** ", event.Time.AsTime().Format("2006-01-02 15:04")))
+	}
+	buf.WriteString(event.Type)
+	if event.Subject != "" {
+		buf.WriteString(fmt.Sprintf(": %s", event.Subject))
+	}
+	buf.WriteString(fmt.Sprintf(" (excerpt %d)\n", ref.Index+1))
+	buf.WriteString(excerpt)
+	buf.WriteString("\n")
 	
-	// Add metadata
-	requestData["rag_event_count"] = len(sortedEvents)
-	requestData["rag_applied"] = true
+	chunk.text = buf.String()
+	chunk.content = excerpt
+	return chunk
+}
+
+// preferenceChunk renders a pcas.user.preference.v1 event as a persona line and
+// returns its category. Preferences carry a category and a value, e.g. tone: concise.
+func preferenceChunk(event *eventsv1.Event) (contextChunk, string) {
+	chunk := contextChunk{
+		eventID: event.Id,
+		section: contextSectionPreferences,
+	}
+	if event.Time != nil {
+		chunk.time = event.Time.AsTime()
+	}
+	
+	dataMap := eventDataMap(event)
+	category, _ := dataMap["category"].(string)
+	value, _ := dataMap["value"].(string)
+	if category != "" && value != "" {
+		chunk.text = fmt.Sprintf("- %s: %s", category, value)
+		return chunk, category
+	}
 	
-	log.Printf("RAG: Successfully enhanced with %d relevant events", len(sortedEvents))
+	content := eventContentText(event)
+	if content == "" {
+		content = event.Subject
+	}
+	chunk.text = "- " + content
+	chunk.content = content
+	return chunk, ""
 }
 
 // generateQueryText creates a search query from the event and request data
@@ -341,34 +662,6 @@ func (s *Server) generateQueryText(event *eventsv1.Event, requestData map[string
 	return strings.Join(parts, " ")
 }
 
-// renderEventsMarkdown converts events to markdown with token limit
-func (s *Server) renderEventsMarkdown(events []*eventsv1.Event, maxTokens int) string {
-	var buf bytes.Buffer
-	currentTokens := 0
-	
-	buf.WriteString("## Relevant Historical Context\n\n")
-	currentTokens += 40 // Approximate tokens for header
-	
-	for i, event := range events {
-		// Render event to markdown
-		eventMD := s.renderSingleEventMarkdown(event)
-		eventTokens := len(eventMD) / 4 // Rough estimate: 4 chars per token
-		
-		// Check if adding this event would exceed token limit
-		if currentTokens+eventTokens > maxTokens {
-			buf.WriteString(fmt.Sprintf("\n*... and %d more relevant events (truncated due to token limit)*\n", 
-				len(events)-i))
-			break
-		}
-		
-		buf.WriteString(eventMD)
-		buf.WriteString("\n---\n\n")
-		currentTokens += eventTokens + 10 // Include separator tokens
-	}
-	
-	return buf.String()
-}
-
 // renderSingleEventMarkdown renders a single event as markdown
 func (s *Server) renderSingleEventMarkdown(event *eventsv1.Event) string {
 	var buf bytes.Buffer
@@ -386,37 +679,56 @@ func (s *Server) renderSingleEventMarkdown(event *eventsv1.Event) string {
 	buf.WriteString("\n")
 	
 	// Extract key data fields
-	if event.Data != nil {
-		// Try to unmarshal as structpb.Value
-		value := &structpb.Value{}
-		if event.Data.MessageIs(value) {
-			if err := event.Data.UnmarshalTo(value); err == nil {
-				// Convert to map and extract key fields
-				if dataMap, ok := value.AsInterface().(map[string]interface{}); ok {
-					var keyFields []string
-					
-					// Priority fields to display
-					priorityKeys := []string{"prompt", "message", "query", "text", "description", "content", "response", "result"}
-					for _, key := range priorityKeys {
-						if val, exists := dataMap[key]; exists {
-							if strVal, ok := val.(string); ok && strVal != "" {
-								// Truncate long values
-								if len(strVal) > 200 {
-									strVal = strVal[:197] + "..."
-								}
-								keyFields = append(keyFields, fmt.Sprintf("  - %s: %s", key, strVal))
-							}
-						}
-					}
-					
-					if len(keyFields) > 0 {
-						buf.WriteString(strings.Join(keyFields, "\n"))
-						buf.WriteString("\n")
-					}
-				}
+	dataMap := eventDataMap(event)
+	var keyFields []string
+	for _, key := range eventPriorityKeys {
+		if strVal, ok := dataMap[key].(string); ok && strVal != "" {
+			// Truncate long values
+			if len(strVal) > 200 {
+				strVal = strVal[:197] + "..."
 			}
+			keyFields = append(keyFields, fmt.Sprintf("  - %s: %s", key, strVal))
 		}
 	}
 	
+	if len(keyFields) > 0 {
+		buf.WriteString(strings.Join(keyFields, "\n"))
+		buf.WriteString("\n")
+	}
+	
 	return buf.String()
-}
\ No newline at end of file
+}
+
+// eventPriorityKeys are the data fields rendered into the prompt, in display order
+var eventPriorityKeys = []string{"prompt", "message", "query", "text", "description", "content", "response", "result"}
+
+// eventDataMap returns the event data as a map, or nil if it is not a structpb object
+func eventDataMap(event *eventsv1.Event) map[string]interface{} {
+	if event.Data == nil {
+		return nil
+	}
+	
+	// Try to unmarshal as structpb.Value
+	value := &structpb.Value{}
+	if !event.Data.MessageIs(value) {
+		return nil
+	}
+	if err := event.Data.UnmarshalTo(value); err != nil {
+		return nil
+	}
+	dataMap, _ := value.AsInterface().(map[string]interface{})
+	return dataMap
+}
+
+// eventContentText returns the untruncated key data fields of an event, used to
+// detect overlapping context chunks
+func eventContentText(event *eventsv1.Event) string {
+	dataMap := eventDataMap(event)
+	var parts []string
+	for _, key := range eventPriorityKeys {
+		if strVal, ok := dataMap[key].(string); ok && strVal != "" {
+			parts = append(parts, strVal)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,166 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+func TestRAGConfigFor(t *testing.T) {
+	disabled := false
+	configured := &policy.RAG{TopK: 3}
+
+	t.Setenv("PCAS_RAG_ENABLED", "")
+	if cfg := ragConfigFor(&policy.Action{Provider: "ollama-llama3"}); cfg != nil {
+		t.Errorf("Expected no RAG without a rag block, got %+v", cfg)
+	}
+	if cfg := ragConfigFor(&policy.Action{Provider: "ollama-llama3", RAG: configured}); cfg != configured {
+		t.Errorf("Expected the rule's RAG config, got %+v", cfg)
+	}
+
+	// The legacy environment switch keeps enabling the defaults for openai-gpt4 rules
+	t.Setenv("PCAS_RAG_ENABLED", "true")
+	if cfg := ragConfigFor(&policy.Action{Provider: "openai-gpt4"}); cfg == nil {
+		t.Error("Expected default RAG config when PCAS_RAG_ENABLED=true")
+	}
+	if cfg := ragConfigFor(&policy.Action{Provider: "mock-provider"}); cfg != nil {
+		t.Errorf("Expected legacy switch to leave other providers alone, got %+v", cfg)
+	}
+	if cfg := ragConfigFor(&policy.Action{Provider: "openai-gpt4", RAG: &policy.RAG{Enabled: &disabled}}); cfg != nil {
+		t.Errorf("Expected rule to opt out of RAG, got %+v", cfg)
+	}
+}
+
+func TestRAGFilter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	event := &eventsv1.Event{Id: "event-1", UserId: "user-1", SessionId: "session-1"}
+
+	filter := ragFilter(event, &policy.RAG{
+		TimeWindow: 24 * time.Hour,
+		Filters: policy.RAGFilters{
+			EventTypes:  []string{"pcas.user.prompt.v1"},
+			Attributes:  map[string]string{"source": "notes"},
+			SameSession: true,
+		},
+	}, now)
+
+	if filter.UserID == nil || *filter.UserID != "user-1" {
+		t.Errorf("Expected user filter user-1, got %v", filter.UserID)
+	}
+	if filter.SessionID == nil || *filter.SessionID != "session-1" {
+		t.Errorf("Expected session filter session-1, got %v", filter.SessionID)
+	}
+	if len(filter.EventTypes) != 1 || filter.AttributeFilters["source"] != "notes" {
+		t.Errorf("Expected event type and attribute filters, got %+v", filter)
+	}
+	if filter.TimeFrom == nil || !filter.TimeFrom.Equal(now.Add(-24*time.Hour)) {
+		t.Errorf("Expected time window to start 24h ago, got %v", filter.TimeFrom)
+	}
+
+	// Without restrictions only the user scope applies, and all_users lifts it
+	filter = ragFilter(event, &policy.RAG{Filters: policy.RAGFilters{AllUsers: true}}, now)
+	if filter.UserID != nil || filter.SessionID != nil || filter.TimeFrom != nil {
+		t.Errorf("Expected unrestricted filter, got %+v", filter)
+	}
+}
+
+// failingEmbeddingProvider simulates an unavailable embedding service
+type failingEmbeddingProvider struct{}
+
+func (failingEmbeddingProvider) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return nil, fmt.Errorf("embedding service unavailable")
+}
+
+func TestApplyRAGEnhancement_SessionAndPreferences(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+	stored := []struct {
+		id        string
+		eventType string
+		sessionID string
+		data      map[string]interface{}
+	}{
+		{"pref-old", userPreferenceEventType, "", map[string]interface{}{"category": "tone", "value": "formal"}},
+		{"pref-new", userPreferenceEventType, "", map[string]interface{}{"category": "tone", "value": "casual"}},
+		{"pref-lang", userPreferenceEventType, "", map[string]interface{}{"category": "lang", "value": "go"}},
+		{"pref-language", userPreferenceEventType, "", map[string]interface{}{"category": "language", "value": "english"}},
+		{"turn-1", "pcas.user.prompt.v1", "session-1", map[string]interface{}{"prompt": "My sister is visiting on Friday"}},
+		{"other-session", "pcas.user.prompt.v1", "session-2", map[string]interface{}{"prompt": "Unrelated conversation"}},
+		{"trace", ragTraceEventType, "session-1", map[string]interface{}{"query": "Earlier retrieval query"}},
+		{"current", "pcas.user.prompt.v1", "session-1", map[string]interface{}{"prompt": "When is she visiting?"}},
+	}
+	for i, item := range stored {
+		data, _ := structpb.NewValue(item.data)
+		anyData, _ := anypb.New(data)
+		err := store.StoreEvent(ctx, &eventsv1.Event{
+			Id:          item.id,
+			Type:        item.eventType,
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      "user-1",
+			SessionId:   item.sessionID,
+			Time:        timestamppb.New(base.Add(time.Duration(i) * time.Minute)),
+			Data:        anyData,
+		}, nil)
+		if err != nil {
+			t.Fatalf("Failed to store event %s: %v", item.id, err)
+		}
+	}
+
+	s := NewServer(nil, nil, store)
+	s.embeddingProvider = failingEmbeddingProvider{}
+
+	event := &eventsv1.Event{Id: "current", Type: "pcas.user.prompt.v1", UserId: "user-1", SessionId: "session-1"}
+	requestData := map[string]interface{}{"prompt": "When is she visiting?"}
+	retrieval := s.applyRAGEnhancement(ctx, event, requestData, &policy.RAG{}, nil)
+	if retrieval == nil || len(retrieval.sources) != 4 {
+		t.Fatalf("Expected four retrieval sources, got %+v", retrieval)
+	}
+
+	if requestData["rag_applied"] != true {
+		t.Fatalf("Expected RAG to apply from session and preferences, got %v", requestData)
+	}
+	if requestData["rag_session_events"] != 1 || requestData["rag_memory_events"] != 0 || requestData["rag_preferences"] != 3 {
+		t.Errorf("Unexpected context counts: %v", requestData)
+	}
+
+	messages, ok := requestData["messages"].([]map[string]string)
+	if !ok || len(messages) != 2 {
+		t.Fatalf("Expected system and user messages, got %v", requestData["messages"])
+	}
+	system := messages[0]["content"]
+	if !strings.Contains(system, "My sister is visiting on Friday") {
+		t.Errorf("Expected session event in system message:\n%s", system)
+	}
+	if strings.Contains(system, "Unrelated conversation") {
+		t.Errorf("Expected other sessions to be excluded:\n%s", system)
+	}
+	if strings.Contains(system, "Earlier retrieval query") {
+		t.Errorf("Expected bookkeeping events of the session to be excluded:\n%s", system)
+	}
+	if !strings.Contains(system, "- tone: casual") || strings.Contains(system, "formal") {
+		t.Errorf("Expected only the newest tone preference:\n%s", system)
+	}
+	if !strings.Contains(system, "- lang: go") || !strings.Contains(system, "- language: english") {
+		t.Errorf("Expected preferences of distinct categories to be kept:\n%s", system)
+	}
+	if messages[1]["content"] != "When is she visiting?" {
+		t.Errorf("Expected user prompt as last message, got %q", messages[1]["content"])
+	}
+}
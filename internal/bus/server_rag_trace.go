@@ -0,0 +1,232 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+const (
+	// ragDebugAttribute opts an event into RAG tracing when set to "true"
+	ragDebugAttribute = "rag_debug"
+
+	// Event type of the trace that explains the retrieval for an event
+	ragTraceEventType = "pcas.rag.trace.v1"
+)
+
+// Decisions recorded for every memory candidate of a traced retrieval
+const (
+	traceDecisionAccepted       = "accepted"        // Queued for the prompt
+	traceDecisionInSession      = "in_session"      // Already part of the session context
+	traceDecisionBelowThreshold = "below_threshold" // Similarity not above the score threshold
+	traceDecisionSelfReference  = "self_reference"  // The event being answered
+	traceDecisionCollapsed      = "collapsed"       // Represented by a duplicate cluster or session summary
+	traceDecisionBeyondTopK     = "beyond_top_k"    // Below the best top_k candidates
+	traceDecisionRerankedOut    = "reranked_out"    // Dropped by recency, importance or diversity re-ranking
+)
+
+// ragTraceCandidate is a memory found by the similarity search
+type ragTraceCandidate struct {
+	eventID     string
+	score       float32 // Raw similarity
+	rankedScore float32 // Score after re-ranking, zero without re-ranking
+	chunk       *storage.ChunkRef
+	decision    string
+}
+
+// ragTrace records how the context of a prompt was retrieved. Its methods do
+// nothing on a nil trace, so retrieval code can record unconditionally.
+type ragTrace struct {
+	query          string
+	queries        []string
+	filter         *storage.Filter
+	topK           int
+	scoreThreshold float32
+	candidates     []*ragTraceCandidate
+	byID           map[string]*ragTraceCandidate
+}
+
+// newRAGTrace returns a trace if the event opted into RAG debugging, otherwise nil
+func newRAGTrace(event *eventsv1.Event) *ragTrace {
+	if enabled, _ := strconv.ParseBool(event.GetAttributes()[ragDebugAttribute]); !enabled {
+		return nil
+	}
+	return &ragTrace{byID: make(map[string]*ragTraceCandidate)}
+}
+
+// setQuery records the generated query and the queries that were searched for
+func (t *ragTrace) setQuery(query string, queries []string) {
+	if t == nil {
+		return
+	}
+	t.query = query
+	t.queries = queries
+}
+
+// setSearch records the parameters of the similarity search
+func (t *ragTrace) setSearch(filter *storage.Filter, topK int, scoreThreshold float32) {
+	if t == nil {
+		return
+	}
+	t.filter = filter
+	t.topK = topK
+	t.scoreThreshold = scoreThreshold
+}
+
+// addCandidates records search results, keeping the best score of every event
+func (t *ragTrace) addCandidates(results []storage.QueryResult) {
+	if t == nil {
+		return
+	}
+	for _, result := range results {
+		if candidate, seen := t.byID[result.ID]; seen {
+			if result.Score > candidate.score {
+				candidate.score = result.Score
+				candidate.chunk = result.Chunk
+			}
+			continue
+		}
+		candidate := &ragTraceCandidate{eventID: result.ID, score: result.Score, chunk: result.Chunk}
+		t.byID[result.ID] = candidate
+		t.candidates = append(t.candidates, candidate)
+	}
+}
+
+// decide records what happened to a candidate
+func (t *ragTrace) decide(eventID, decision string) {
+	if t == nil {
+		return
+	}
+	if candidate, ok := t.byID[eventID]; ok {
+		candidate.decision = decision
+	}
+}
+
+// decideMissing records a decision for every candidate that has none yet and is
+// not among the kept results
+func (t *ragTrace) decideMissing(kept []storage.QueryResult, decision string) {
+	if t == nil {
+		return
+	}
+	keptIDs := make(map[string]bool, len(kept))
+	for _, result := range kept {
+		keptIDs[result.ID] = true
+	}
+	for _, candidate := range t.candidates {
+		if candidate.decision == "" && !keptIDs[candidate.eventID] {
+			candidate.decision = decision
+		}
+	}
+}
+
+// setRankedScore records the score of a candidate after re-ranking
+func (t *ragTrace) setRankedScore(eventID string, score float32) {
+	if t == nil {
+		return
+	}
+	if candidate, ok := t.byID[eventID]; ok {
+		candidate.rankedScore = score
+	}
+}
+
+// data renders the trace for the pcas.rag.trace.v1 event. The outcome carries
+// the request flags, the retrieval and the final messages.
+func (t *ragTrace) data(event *eventsv1.Event, requestData map[string]interface{}, retrieval *ragRetrieval) map[string]interface{} {
+	queries := make([]interface{}, len(t.queries))
+	for i, query := range t.queries {
+		queries[i] = query
+	}
+
+	candidates := make([]interface{}, len(t.candidates))
+	for i, candidate := range t.candidates {
+		entry := map[string]interface{}{
+			"event_id": candidate.eventID,
+			"score":    float64(candidate.score),
+			"decision": candidate.decision,
+		}
+		if candidate.rankedScore != 0 {
+			entry["ranked_score"] = float64(candidate.rankedScore)
+		}
+		if candidate.chunk != nil {
+			entry["chunk_index"] = candidate.chunk.Index
+		}
+		candidates[i] = entry
+	}
+
+	data := map[string]interface{}{
+		"event_id":        event.Id,
+		"query":           t.query,
+		"queries":         queries,
+		"top_k":           t.topK,
+		"score_threshold": float64(t.scoreThreshold),
+		"candidates":      candidates,
+		"rag_applied":     requestData["rag_applied"],
+	}
+	if filter := traceFilterData(t.filter); len(filter) > 0 {
+		data["filter"] = filter
+	}
+	if reason, ok := requestData["rag_reason"]; ok {
+		data["reason"] = reason
+	}
+	if retrieval != nil {
+		data["context"] = retrieval.responseData()
+	}
+
+	// The final prompt as the provider receives it
+	if messages, ok := requestData["messages"].([]map[string]string); ok {
+		prompt := make([]interface{}, len(messages))
+		for i, message := range messages {
+			prompt[i] = map[string]interface{}{"role": message["role"], "content": message["content"]}
+		}
+		data["messages"] = prompt
+	} else if prompt, ok := requestData["prompt"].(string); ok {
+		data["prompt"] = prompt
+	}
+
+	return data
+}
+
+// traceFilterData renders the storage filter of the similarity search
+func traceFilterData(filter *storage.Filter) map[string]interface{} {
+	data := make(map[string]interface{})
+	if filter == nil {
+		return data
+	}
+	if filter.UserID != nil {
+		data["user_id"] = *filter.UserID
+	}
+	if filter.SessionID != nil {
+		data["session_id"] = *filter.SessionID
+	}
+	if len(filter.EventTypes) > 0 {
+		eventTypes := make([]interface{}, len(filter.EventTypes))
+		for i, eventType := range filter.EventTypes {
+			eventTypes[i] = eventType
+		}
+		data["event_types"] = eventTypes
+	}
+	if filter.TimeFrom != nil {
+		data["time_from"] = filter.TimeFrom.Format(time.RFC3339)
+	}
+	if len(filter.AttributeFilters) > 0 {
+		attributes := make(map[string]interface{}, len(filter.AttributeFilters))
+		for key, value := range filter.AttributeFilters {
+			attributes[key] = value
+		}
+		data["attributes"] = attributes
+	}
+	return data
+}
+
+// publishRAGTrace stores and broadcasts the trace of an event's retrieval. The
+// trace is correlated with the event, so it can be looked up by the event ID.
+func (s *Server) publishRAGTrace(ctx context.Context, event *eventsv1.Event, trace *ragTrace, requestData map[string]interface{}, retrieval *ragRetrieval) {
+	traceEvent := newServerEvent(ragTraceEventType, fmt.Sprintf("rag-trace-of-%s", event.Id), event, trace.data(event, requestData, retrieval))
+	s.publishServerEvent(ctx, traceEvent)
+	log.Printf("RAG: Published trace %s for event %s", traceEvent.Id, event.Id)
+}
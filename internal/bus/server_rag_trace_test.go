@@ -0,0 +1,138 @@
+package bus
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/protobuf/types/known/structpb"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+// requestCapturingProvider keeps the request data it received
+type requestCapturingProvider struct {
+	requestData map[string]interface{}
+}
+
+func (p *requestCapturingProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	p.requestData = requestData
+	return "answer", nil
+}
+
+func TestPublish_RAGTrace(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	memories := []struct {
+		id        string
+		embedding []float32
+	}{
+		{"kyoto-note", []float32{1, 0}},
+		{"dinner-note", []float32{0, 1}},
+	}
+	for _, memory := range memories {
+		err := store.StoreEvent(ctx, &eventsv1.Event{
+			Id:          memory.id,
+			Type:        "user.note.v1",
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      "user-1",
+			Subject:     memory.id,
+		}, memory.embedding)
+		if err != nil {
+			t.Fatalf("Failed to store event %s: %v", memory.id, err)
+		}
+	}
+
+	provider := &requestCapturingProvider{}
+	engine := policy.NewEngine(&policy.Policy{
+		Rules: []policy.Rule{{
+			Name: "chat with memory",
+			If:   policy.Condition{EventType: "pcas.user.prompt.v1"},
+			Then: policy.Action{Provider: "capturing", RAG: &policy.RAG{ScoreThreshold: 0.5}},
+		}},
+	})
+	s := NewServer(engine, map[string]providers.ComputeProvider{"capturing": provider}, store)
+	s.embeddingProvider = keywordEmbeddingProvider{}
+
+	testCases := []struct {
+		name        string
+		id          string
+		attributes  map[string]string
+		expectTrace bool
+	}{
+		{name: "not traced by default", id: "prompt-plain"},
+		{name: "traced with rag_debug", id: "prompt-debug", attributes: map[string]string{ragDebugAttribute: "true"}, expectTrace: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The event has a subject but no data, so RAG has to create the request data
+			event := &eventsv1.Event{
+				Id:          tc.id,
+				Type:        "pcas.user.prompt.v1",
+				Source:      "test",
+				Specversion: "1.0",
+				UserId:      "user-1",
+				Subject:     "Where is the Kyoto offsite?",
+				Attributes:  tc.attributes,
+			}
+			if _, err := s.PublishAndWait(ctx, &busv1.PublishAndWaitRequest{Event: event}); err != nil {
+				t.Fatalf("PublishAndWait failed: %v", err)
+			}
+			if provider.requestData["rag_applied"] != true {
+				t.Errorf("Expected the provider to receive the RAG flags, got %v", provider.requestData)
+			}
+
+			correlationID := tc.id
+			traces, err := store.(storage.EventQuerier).QueryEvents(ctx, &storage.Filter{EventTypes: []string{ragTraceEventType}}, 10)
+			if err != nil {
+				t.Fatalf("QueryEvents failed: %v", err)
+			}
+			var trace *eventsv1.Event
+			for _, candidate := range traces {
+				if candidate.CorrelationId == correlationID {
+					trace = candidate
+				}
+			}
+			if !tc.expectTrace {
+				if trace != nil {
+					t.Errorf("Expected no trace, got %s", trace.Id)
+				}
+				return
+			}
+			if trace == nil {
+				t.Fatal("Expected a trace correlated with the event")
+			}
+
+			value := &structpb.Value{}
+			if err := trace.Data.UnmarshalTo(value); err != nil {
+				t.Fatalf("Failed to decode trace: %v", err)
+			}
+			data := value.AsInterface().(map[string]interface{})
+			if data["query"] != "Where is the Kyoto offsite?" || data["score_threshold"] != 0.5 {
+				t.Errorf("Expected query and threshold in the trace, got %v", data)
+			}
+			decisions := make(map[string]interface{})
+			for _, item := range data["candidates"].([]interface{}) {
+				candidate := item.(map[string]interface{})
+				decisions[candidate["event_id"].(string)] = candidate["decision"]
+			}
+			if decisions["kyoto-note"] != traceDecisionAccepted || decisions["dinner-note"] != traceDecisionBelowThreshold {
+				t.Errorf("Unexpected candidate decisions: %v", decisions)
+			}
+			if messages, ok := data["messages"].([]interface{}); !ok || len(messages) != 2 {
+				t.Errorf("Expected the final messages in the trace, got %v", data["messages"])
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package bus
+
+import (
+	"context"
+	"log"
+	"math"
+	"strconv"
+	"time"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/ranking"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+const (
+	// Event type of explicit user feedback on an event. The data carries the
+	// target_event_id and a rating between -1 (not useful) and 1 (useful).
+	userFeedbackEventType = "pcas.user.feedback.v1"
+
+	// Event attribute holding an importance between 0 and 1, set by the dApp
+	importanceAttribute = "importance"
+
+	// Each full rating moves the importance of the target event by this much
+	feedbackRatingWeight = 0.25
+
+	// Feedback events considered per search, most recent first
+	maxFeedbackEvents = 500
+)
+
+// rankingWeights converts the ranking block of a rule into scoring weights
+func rankingWeights(cfg *policy.Ranking) ranking.Weights {
+	if cfg == nil {
+		return ranking.Weights{}
+	}
+	return ranking.Weights{
+		Recency:    cfg.RecencyWeight,
+		HalfLife:   cfg.HalfLife,
+		Importance: cfg.ImportanceWeight,
+		MMRLambda:  cfg.MMRLambda,
+	}
+}
+
+// searchWeights converts the re-ranking fields of a search request into scoring weights
+func searchWeights(req *busv1.SearchRequest) ranking.Weights {
+	return ranking.Weights{
+		Recency:    float64(req.RecencyWeight),
+		HalfLife:   time.Duration(float64(req.RecencyHalfLifeHours) * float64(time.Hour)),
+		Importance: float64(req.ImportanceWeight),
+		MMRLambda:  float64(req.MmrLambda),
+	}
+}
+
+// rerank orders events by their blended ranking score and returns up to topK of
+// them with their scores. similarities holds the search score of each event.
+// Feedback of userID is used for the importance signal; an empty userID considers
+// feedback of all users.
+func (s *Server) rerank(ctx context.Context, events []*eventsv1.Event, similarities []float32, userID string, weights ranking.Weights, topK int) ([]*eventsv1.Event, []float32) {
+	ids := make([]string, len(events))
+	byID := make(map[string]*eventsv1.Event, len(events))
+	for i, event := range events {
+		ids[i] = event.Id
+		byID[event.Id] = event
+	}
+
+	var feedback map[string]float64
+	if weights.Importance > 0 {
+		feedback = s.feedbackRatings(ctx, userID, ids)
+	}
+
+	// Embeddings are only needed to tell redundant hits apart
+	var embeddings map[string][]float32
+	if weights.MMRLambda > 0 && weights.MMRLambda < 1 {
+		if getter, ok := s.storage.(storage.EmbeddingGetter); ok {
+			var err error
+			embeddings, err = getter.GetEmbeddings(ctx, ids)
+			if err != nil {
+				log.Printf("Warning: failed to load embeddings for diversity re-ranking: %v", err)
+			}
+		}
+	}
+
+	candidates := make([]ranking.Candidate, len(events))
+	for i, event := range events {
+		candidates[i] = ranking.Candidate{
+			ID:         event.Id,
+			Similarity: float64(similarities[i]),
+			Importance: eventImportance(event, feedback[event.Id]),
+			Embedding:  embeddings[event.Id],
+		}
+		if event.Time != nil {
+			candidates[i].Time = event.Time.AsTime()
+		}
+	}
+
+	ranked := ranking.Rank(candidates, weights, time.Now(), topK)
+
+	rankedEvents := make([]*eventsv1.Event, len(ranked))
+	scores := make([]float32, len(ranked))
+	for i, result := range ranked {
+		rankedEvents[i] = byID[result.ID]
+		scores[i] = float32(result.Score)
+		log.Printf("Re-ranked event %s: similarity %.3f, recency %.3f, importance %.3f, score %.3f",
+			result.ID, result.Similarity, result.Recency, result.Importance, result.Score)
+	}
+	return rankedEvents, scores
+}
+
+// eventImportance combines the importance attribute of an event, or the default
+// importance, with the summed feedback ratings. The result is between 0 and 1.
+func eventImportance(event *eventsv1.Event, rating float64) float64 {
+	importance := ranking.DefaultImportance
+	if value, ok := event.Attributes[importanceAttribute]; ok {
+		if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+			importance = parsed
+		}
+	}
+	importance += feedbackRatingWeight * rating
+	return math.Max(0, math.Min(1, importance))
+}
+
+// feedbackRatings sums the feedback ratings of the given events. Feedback is
+// optional, so failures are logged and treated as no feedback.
+func (s *Server) feedbackRatings(ctx context.Context, userID string, eventIDs []string) map[string]float64 {
+	querier, ok := s.storage.(storage.EventQuerier)
+	if !ok {
+		return nil
+	}
+
+	filter := &storage.Filter{EventTypes: []string{userFeedbackEventType}}
+	if userID != "" {
+		filter.UserID = &userID
+	}
+	feedbackEvents, err := querier.QueryEvents(ctx, filter, maxFeedbackEvents)
+	if err != nil {
+		log.Printf("Warning: failed to query feedback events: %v", err)
+		return nil
+	}
+
+	wanted := make(map[string]bool, len(eventIDs))
+	for _, id := range eventIDs {
+		wanted[id] = true
+	}
+
+	ratings := make(map[string]float64)
+	for _, feedback := range feedbackEvents {
+		dataMap := eventDataMap(feedback)
+		target, _ := dataMap["target_event_id"].(string)
+		rating, ok := dataMap["rating"].(float64)
+		if !wanted[target] || !ok {
+			continue
+		}
+		ratings[target] += math.Max(-1, math.Min(1, rating))
+	}
+	return ratings
+}
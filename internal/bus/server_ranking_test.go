@@ -0,0 +1,164 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+// fixedEmbeddingProvider returns the same embedding for every text
+type fixedEmbeddingProvider struct {
+	embedding []float32
+}
+
+func (p fixedEmbeddingProvider) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	return p.embedding, nil
+}
+
+func TestSearch_Reranking(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	stored := []struct {
+		id        string
+		time      time.Time
+		embedding []float32
+	}{
+		{"two-years-old", now.Add(-2 * 365 * 24 * time.Hour), []float32{1, 0, 0}},
+		{"yesterday", now.Add(-24 * time.Hour), []float32{0.95, 0.31, 0}},
+	}
+	for _, item := range stored {
+		err := store.StoreEvent(ctx, &eventsv1.Event{
+			Id:          item.id,
+			Type:        "user.note.v1",
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      "user-1",
+			Time:        timestamppb.New(item.time),
+		}, item.embedding)
+		if err != nil {
+			t.Fatalf("Failed to store event %s: %v", item.id, err)
+		}
+	}
+
+	s := NewServer(nil, nil, store)
+	s.embeddingProvider = fixedEmbeddingProvider{embedding: []float32{1, 0, 0}}
+
+	testCases := []struct {
+		name        string
+		req         *busv1.SearchRequest
+		expectFirst string
+		expectError bool
+	}{
+		{
+			name:        "similarity only",
+			req:         &busv1.SearchRequest{QueryText: "note", TopK: 2},
+			expectFirst: "two-years-old",
+		},
+		{
+			name:        "recency decay",
+			req:         &busv1.SearchRequest{QueryText: "note", TopK: 2, RecencyWeight: 0.3, RecencyHalfLifeHours: 24 * 7},
+			expectFirst: "yesterday",
+		},
+		{
+			name:        "invalid weights",
+			req:         &busv1.SearchRequest{QueryText: "note", RecencyWeight: 0.8, ImportanceWeight: 0.8},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp, err := s.Search(ctx, tc.req)
+			if tc.expectError {
+				if err == nil {
+					t.Fatal("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Search failed: %v", err)
+			}
+			if len(resp.Events) != 2 || len(resp.Scores) != 2 {
+				t.Fatalf("Expected 2 results with scores, got %d events and %d scores", len(resp.Events), len(resp.Scores))
+			}
+			if resp.Events[0].Id != tc.expectFirst {
+				t.Errorf("Expected %s first, got %s", tc.expectFirst, resp.Events[0].Id)
+			}
+			if resp.Scores[0] < resp.Scores[1] {
+				t.Errorf("Expected scores in descending order, got %v", resp.Scores)
+			}
+		})
+	}
+}
+
+func TestFeedbackImportance(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	ratings := []struct {
+		id     string
+		userID string
+		target string
+		rating float64
+	}{
+		{"feedback-1", "user-1", "note-1", 1},
+		{"feedback-2", "user-1", "note-1", 1},
+		{"feedback-3", "user-1", "note-2", -1},
+		{"feedback-4", "user-2", "note-2", 1}, // Other users' feedback is ignored
+	}
+	for _, item := range ratings {
+		data, _ := structpb.NewValue(map[string]interface{}{"target_event_id": item.target, "rating": item.rating})
+		anyData, _ := anypb.New(data)
+		err := store.StoreEvent(ctx, &eventsv1.Event{
+			Id:          item.id,
+			Type:        userFeedbackEventType,
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      item.userID,
+			Data:        anyData,
+		}, nil)
+		if err != nil {
+			t.Fatalf("Failed to store event %s: %v", item.id, err)
+		}
+	}
+
+	s := NewServer(nil, nil, store)
+	feedback := s.feedbackRatings(ctx, "user-1", []string{"note-1", "note-2", "note-3"})
+
+	testCases := []struct {
+		name     string
+		event    *eventsv1.Event
+		expected float64
+	}{
+		{name: "upvoted twice", event: &eventsv1.Event{Id: "note-1"}, expected: 1},
+		{name: "downvoted", event: &eventsv1.Event{Id: "note-2"}, expected: 0.25},
+		{name: "no signal", event: &eventsv1.Event{Id: "note-3"}, expected: 0.5},
+		{name: "attribute", event: &eventsv1.Event{Id: "note-3", Attributes: map[string]string{importanceAttribute: "0.8"}}, expected: 0.8},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := eventImportance(tc.event, feedback[tc.event.Id]); got != tc.expected {
+				t.Errorf("Expected importance %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,203 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+const (
+	// Query rewrite defaults for rules that do not override them
+	defaultRewriteMaxQueries    = 3
+	defaultRewriteSessionEvents = 6
+	defaultRewriteTimeout       = 5 * time.Second
+
+	// Session turns longer than this are shortened in the rewrite prompt
+	maxRewriteTurnChars = 500
+)
+
+// rewritePromptTemplate asks the provider for standalone search queries, one per line
+const rewritePromptTemplate = `You rewrite the latest message of a conversation into standalone search queries for a personal memory store.
+Resolve pronouns and references such as "it" or "the second one" using the conversation.
+Write at most %d short queries, one per line, without numbering or explanations.
+
+Conversation:
+%s
+Latest message: %s
+
+Queries:`
+
+// rewriteQueries turns the verbatim query of an event into standalone search
+// queries using the configured compute provider and the recent session events.
+// It falls back to the verbatim query when the provider is missing or fails.
+func (s *Server) rewriteQueries(ctx context.Context, event *eventsv1.Event, queryText string, cfg *policy.QueryRewrite) []string {
+	fallback := []string{queryText}
+
+	provider, exists := s.providers[cfg.Provider]
+	if !exists {
+		log.Printf("RAG: Query rewrite provider %s not found, using the verbatim query", cfg.Provider)
+		return fallback
+	}
+
+	maxQueries := cfg.MaxQueries
+	if maxQueries <= 0 {
+		maxQueries = defaultRewriteMaxQueries
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultRewriteTimeout
+	}
+
+	rewriteCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	transcript := s.rewriteTranscript(rewriteCtx, event, cfg)
+	requestData := map[string]interface{}{
+		"prompt": fmt.Sprintf(rewritePromptTemplate, maxQueries, transcript, queryText),
+	}
+
+	response, err := s.executeProvider(rewriteCtx, event, cfg.Provider, provider, requestData, nil)
+	if err != nil {
+		log.Printf("RAG: Query rewrite with %s failed, using the verbatim query: %v", cfg.Provider, err)
+		return fallback
+	}
+
+	queries := parseRewrittenQueries(response, maxQueries)
+	if len(queries) == 0 {
+		log.Printf("RAG: Query rewrite with %s returned no queries, using the verbatim query", cfg.Provider)
+		return fallback
+	}
+
+	log.Printf("RAG: Rewrote query %q into %q", queryText, queries)
+	return queries
+}
+
+// rewriteTranscript renders the recent events of the event's session, oldest
+// first, as a User/Assistant conversation
+func (s *Server) rewriteTranscript(ctx context.Context, event *eventsv1.Event, cfg *policy.QueryRewrite) string {
+	limit := cfg.SessionEvents
+	if limit <= 0 {
+		limit = defaultRewriteSessionEvents
+	}
+
+	querier, ok := s.storage.(storage.EventQuerier)
+	if !ok || event.SessionId == "" {
+		return "(no earlier messages)\n"
+	}
+
+	filter := &storage.Filter{SessionID: &event.SessionId, ExcludeEventTypes: bookkeepingEventTypes}
+	if event.UserId != "" {
+		filter.UserID = &event.UserId
+	}
+
+	// The current event is already stored, fetch one more to make up for it
+	events, err := querier.QueryEvents(ctx, filter, limit+1)
+	if err != nil {
+		log.Printf("RAG: Failed to query session events for query rewrite: %v", err)
+		return "(no earlier messages)\n"
+	}
+
+	var turns []*eventsv1.Event
+	for _, sessionEvent := range events {
+		if sessionEvent.Id != event.Id && len(turns) < limit {
+			turns = append(turns, sessionEvent)
+		}
+	}
+	return renderTranscript(turns, maxRewriteTurnChars)
+}
+
+// renderTranscript renders session events, oldest first, as a User/Assistant
+// conversation. Turns longer than maxChars are shortened and memory events the
+// server derived from the session are left out.
+func renderTranscript(turns []*eventsv1.Event, maxChars int) string {
+	sorted := make([]*eventsv1.Event, len(turns))
+	copy(sorted, turns)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].GetTime().AsTime().Before(sorted[j].GetTime().AsTime())
+	})
+
+	var buf strings.Builder
+	for _, turn := range sorted {
+		if turn.Type == summaryEventType || turn.Type == consolidatedMemoryEventType {
+			continue
+		}
+		content := eventContentText(turn)
+		if content == "" {
+			continue
+		}
+		if runes := []rune(content); len(runes) > maxChars {
+			content = string(runes[:maxChars-3]) + "..."
+		}
+		role := "User"
+		if turn.Type == "pcas.response.v1" {
+			role = "Assistant"
+		}
+		buf.WriteString(fmt.Sprintf("%s: %s\n", role, strings.Join(strings.Fields(content), " ")))
+	}
+	if buf.Len() == 0 {
+		return "(no earlier messages)\n"
+	}
+	return buf.String()
+}
+
+// rewriteListMarker matches bullets and numbering that providers put before queries
+var rewriteListMarker = regexp.MustCompile(`^(?:[-*•]|\d+[.)])\s+`)
+
+// parseRewrittenQueries extracts up to maxQueries distinct queries from the
+// provider response, stripping list markers and quotes
+func parseRewrittenQueries(response string, maxQueries int) []string {
+	var queries []string
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(response, "\n") {
+		query := rewriteListMarker.ReplaceAllString(strings.TrimSpace(line), "")
+		query = strings.Trim(query, "\"'` ")
+		if query == "" || strings.HasSuffix(query, ":") {
+			continue
+		}
+		key := strings.ToLower(query)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		queries = append(queries, query)
+		if len(queries) == maxQueries {
+			break
+		}
+	}
+	return queries
+}
+
+// mergeQueryResults combines the results of several similarity searches, keeping
+// the best result of every event, best first
+func mergeQueryResults(resultSets ...[]storage.QueryResult) []storage.QueryResult {
+	best := make(map[string]storage.QueryResult)
+	var order []string
+	for _, results := range resultSets {
+		for _, result := range results {
+			existing, seen := best[result.ID]
+			if !seen {
+				order = append(order, result.ID)
+			}
+			if !seen || result.Score > existing.Score {
+				best[result.ID] = result
+			}
+		}
+	}
+
+	merged := make([]storage.QueryResult, len(order))
+	for i, id := range order {
+		merged[i] = best[id]
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Score > merged[j].Score
+	})
+	return merged
+}
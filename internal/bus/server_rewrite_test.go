@@ -0,0 +1,199 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+// recordingProvider returns a fixed result and keeps the last prompt it received
+type recordingProvider struct {
+	result string
+	prompt string
+}
+
+func (p *recordingProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	p.prompt, _ = requestData["prompt"].(string)
+	return p.result, nil
+}
+
+// keywordEmbeddingProvider embeds texts mentioning Kyoto along the first axis and
+// everything else along the second
+type keywordEmbeddingProvider struct{}
+
+func (keywordEmbeddingProvider) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	if strings.Contains(strings.ToLower(text), "kyoto") {
+		return []float32{1, 0}, nil
+	}
+	return []float32{0, 1}, nil
+}
+
+func TestParseRewrittenQueries(t *testing.T) {
+	testCases := []struct {
+		name       string
+		response   string
+		maxQueries int
+		expected   []string
+	}{
+		{
+			name:       "one per line",
+			response:   "Kyoto trip itinerary\nKyoto hotel booking",
+			maxQueries: 3,
+			expected:   []string{"Kyoto trip itinerary", "Kyoto hotel booking"},
+		},
+		{
+			name:       "list markers, quotes and duplicates",
+			response:   "Queries:\n1. \"Kyoto trip\"\n- kyoto trip\n* 2024 budget\n",
+			maxQueries: 3,
+			expected:   []string{"Kyoto trip", "2024 budget"},
+		},
+		{
+			name:       "capped",
+			response:   "a\nb\nc",
+			maxQueries: 2,
+			expected:   []string{"a", "b"},
+		},
+		{
+			name:       "empty",
+			response:   "  \n",
+			maxQueries: 3,
+			expected:   nil,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := parseRewrittenQueries(tc.response, tc.maxQueries); !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestMergeQueryResults(t *testing.T) {
+	merged := mergeQueryResults(
+		[]storage.QueryResult{{ID: "a", Score: 0.5}, {ID: "b", Score: 0.4}},
+		[]storage.QueryResult{{ID: "b", Score: 0.9}, {ID: "c", Score: 0.3}},
+	)
+	expected := []storage.QueryResult{{ID: "b", Score: 0.9}, {ID: "a", Score: 0.5}, {ID: "c", Score: 0.3}}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected %v, got %v", expected, merged)
+	}
+}
+
+func TestApplyRAGEnhancement_QueryRewrite(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	base := time.Now().Add(-time.Hour)
+	stored := []struct {
+		id        string
+		sessionID string
+		text      string
+		embedding []float32
+	}{
+		{"note-kyoto", "", "Booked a ryokan in Kyoto for April", []float32{1, 0}},
+		{"note-groceries", "", "Buy milk and eggs", []float32{0, 1}},
+		{"turn-1", "session-1", "Which trips do I have planned?", nil},
+	}
+	for i, item := range stored {
+		data, _ := structpb.NewValue(map[string]interface{}{"text": item.text})
+		anyData, _ := anypb.New(data)
+		err := store.StoreEvent(ctx, &eventsv1.Event{
+			Id:          item.id,
+			Type:        "user.note.v1",
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      "user-1",
+			SessionId:   item.sessionID,
+			Time:        timestamppb.New(base.Add(time.Duration(i) * time.Minute)),
+			Data:        anyData,
+		}, item.embedding)
+		if err != nil {
+			t.Fatalf("Failed to store event %s: %v", item.id, err)
+		}
+	}
+
+	// A RAG trace the server recorded in the session after the turn
+	traceData, _ := structpb.NewValue(map[string]interface{}{"text": "RAG trace of turn-1"})
+	traceAny, _ := anypb.New(traceData)
+	err = store.StoreEvent(ctx, &eventsv1.Event{
+		Id:          "trace-1",
+		Type:        ragTraceEventType,
+		Source:      "pcas",
+		Specversion: "1.0",
+		UserId:      "user-1",
+		SessionId:   "session-1",
+		Time:        timestamppb.New(base.Add(time.Duration(len(stored)) * time.Minute)),
+		Data:        traceAny,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to store trace: %v", err)
+	}
+
+	rewriter := &recordingProvider{result: "Kyoto trip plans"}
+	s := NewServer(nil, map[string]providers.ComputeProvider{
+		"rewriter": rewriter,
+		"broken":   &stubProvider{err: providers.WrapProviderError(providers.ErrProviderUnavailable, fmt.Errorf("connection refused"))},
+	}, store)
+	s.embeddingProvider = keywordEmbeddingProvider{}
+
+	testCases := []struct {
+		name         string
+		provider     string
+		expectMemory string
+	}{
+		{name: "rewritten query", provider: "rewriter", expectMemory: "ryokan in Kyoto"},
+		{name: "falls back to verbatim query", provider: "broken", expectMemory: "milk and eggs"},
+		{name: "unknown provider", provider: "missing", expectMemory: "milk and eggs"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := &eventsv1.Event{Id: "current", Type: "pcas.user.prompt.v1", UserId: "user-1", SessionId: "session-1"}
+			requestData := map[string]interface{}{"prompt": "What about the first one?"}
+			cfg := &policy.RAG{
+				TopK:           1,
+				SessionEvents:  -1,
+				ScoreThreshold: 0.1,
+				Rewrite:        &policy.QueryRewrite{Provider: tc.provider, SessionEvents: 1},
+			}
+			s.applyRAGEnhancement(ctx, event, requestData, cfg, nil)
+
+			messages, ok := requestData["messages"].([]map[string]string)
+			if !ok {
+				t.Fatalf("Expected RAG messages, got %v", requestData)
+			}
+			if system := messages[0]["content"]; !strings.Contains(system, tc.expectMemory) {
+				t.Errorf("Expected %q in system message:\n%s", tc.expectMemory, system)
+			}
+		})
+	}
+
+	// The rewrite prompt shows the session so that references can be resolved,
+	// without the server's bookkeeping events
+	if strings.Contains(rewriter.prompt, "RAG trace") {
+		t.Errorf("Expected no RAG trace in rewrite prompt:\n%s", rewriter.prompt)
+	}
+	if !strings.Contains(rewriter.prompt, "User: Which trips do I have planned?") ||
+		!strings.Contains(rewriter.prompt, "Latest message: What about the first one?") {
+		t.Errorf("Expected session transcript in rewrite prompt:\n%s", rewriter.prompt)
+	}
+}
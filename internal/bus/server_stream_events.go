@@ -0,0 +1,282 @@
+package bus
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+)
+
+// Event types recorded for the lifecycle of an InteractStream
+const (
+	streamStartedEventType    = "pcas.stream.started.v1"
+	streamInputEventType      = "pcas.stream.input.v1"
+	streamOutputEventType     = "pcas.stream.output.v1"
+	streamCancelledEventType  = "pcas.stream.cancelled.v1"
+	streamUpdatedEventType    = "pcas.stream.updated.v1"
+	streamEndedEventType      = "pcas.stream.ended.v1"
+	streamErrorEventType      = "pcas.stream.error.v1"
+	streamTranscriptEventType = "pcas.stream.transcript.v1"
+)
+
+const (
+	// streamIDAttribute links every event of a stream, so they can be filtered on
+	streamIDAttribute = "stream_id"
+
+	// Recorded events waiting to be stored; a full queue slows the stream down
+	streamEventQueueSize = 256
+
+	// Time limit for storing a single stream event
+	streamEventStoreTimeout = 5 * time.Second
+)
+
+// streamTurn is one chunk of a stream's transcript
+type streamTurn struct {
+	role string
+	text string
+}
+
+// streamRecorder records the lifecycle of an InteractStream as events in the
+// memory graph. Events are stored and broadcast in order by a background writer,
+// so that the stream does not wait for storage.
+type streamRecorder struct {
+	server     *Server
+	origin     *eventsv1.Event // Correlates every event with the stream
+	streamID   string
+	transcript bool
+	startedAt  time.Time
+
+	mu          sync.Mutex
+	inputs      int
+	outputs     int
+	inputBytes  int
+	outputBytes int
+	turns       []streamTurn
+	finished    bool
+	closed      bool
+
+	queue chan *eventsv1.Event
+	done  chan struct{}
+}
+
+// newStreamRecorder starts recording a stream and records its start event
+func (s *Server) newStreamRecorder(streamID, providerName string, config *busv1.StreamConfig) *streamRecorder {
+	sessionID := config.SessionId
+	if sessionID == "" {
+		sessionID = streamID
+	}
+
+	r := &streamRecorder{
+		server: s,
+		origin: &eventsv1.Event{
+			Id:        streamID,
+			UserId:    config.UserId,
+			SessionId: sessionID,
+		},
+		streamID:   streamID,
+		transcript: config.Transcript,
+		startedAt:  time.Now(),
+		queue:      make(chan *eventsv1.Event, streamEventQueueSize),
+		done:       make(chan struct{}),
+	}
+	go r.write()
+
+	attributes := make(map[string]interface{}, len(config.Attributes))
+	for key, value := range config.Attributes {
+		attributes[key] = value
+	}
+	data := map[string]interface{}{
+		"event_type": config.EventType,
+		"provider":   providerName,
+		"attributes": attributes,
+	}
+	if config.ChannelId != "" {
+		data["channel_id"] = config.ChannelId
+	}
+	r.record(streamStartedEventType, data)
+	return r
+}
+
+// input records a chunk received from the client
+func (r *streamRecorder) input(content []byte) {
+	r.mu.Lock()
+	r.inputs++
+	r.inputBytes += len(content)
+	index := r.inputs
+	r.addTurn("User", content)
+	r.mu.Unlock()
+
+	r.record(streamInputEventType, chunkData(index, content))
+}
+
+// output records a chunk sent to the client
+func (r *streamRecorder) output(content []byte) {
+	r.mu.Lock()
+	r.outputs++
+	r.outputBytes += len(content)
+	index := r.outputs
+	r.addTurn("Assistant", content)
+	r.mu.Unlock()
+
+	r.record(streamOutputEventType, chunkData(index, content))
+}
+
+// cancelled records that the client cancelled the current generation
+func (r *streamRecorder) cancelled(reason string) {
+	data := map[string]interface{}{}
+	if reason != "" {
+		data["reason"] = reason
+	}
+	r.record(streamCancelledEventType, data)
+}
+
+// updated records attribute changes made by the client
+func (r *streamRecorder) updated(changes map[string]string) {
+	attributes := make(map[string]interface{}, len(changes))
+	for key, value := range changes {
+		attributes[key] = value
+	}
+	r.record(streamUpdatedEventType, map[string]interface{}{"attributes": attributes})
+}
+
+// addTurn keeps text chunks for the transcript. Binary chunks such as audio
+// are left out. The caller holds the lock.
+func (r *streamRecorder) addTurn(role string, content []byte) {
+	if !r.transcript || !utf8.Valid(content) {
+		return
+	}
+	if text := strings.TrimSpace(string(content)); text != "" {
+		r.turns = append(r.turns, streamTurn{role: role, text: text})
+	}
+}
+
+// ended records the graceful end of the stream
+func (r *streamRecorder) ended() {
+	data := r.summary()
+	if data == nil {
+		return
+	}
+	r.record(streamEndedEventType, data)
+}
+
+// failed records the end of the stream with an error
+func (r *streamRecorder) failed(err *streamError) {
+	data := r.summary()
+	if data == nil {
+		return
+	}
+	data["code"] = int(err.code)
+	data["status"] = err.code.String()
+	data["reason"] = err.reason.String()
+	data["message"] = err.message
+	r.record(streamErrorEventType, data)
+}
+
+// summary returns the counters of a finished stream, or nil if the end of the
+// stream was recorded already
+func (r *streamRecorder) summary() map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.finished {
+		return nil
+	}
+	r.finished = true
+	return map[string]interface{}{
+		"input_chunks":  r.inputs,
+		"output_chunks": r.outputs,
+		"input_bytes":   r.inputBytes,
+		"output_bytes":  r.outputBytes,
+		"duration_ms":   time.Since(r.startedAt).Milliseconds(),
+	}
+}
+
+// close stores the transcript if the stream asked for one and waits until all
+// recorded events are stored
+func (r *streamRecorder) close() {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return
+	}
+	if r.transcript && len(r.turns) > 0 {
+		var buf strings.Builder
+		for _, turn := range r.turns {
+			buf.WriteString(fmt.Sprintf("%s: %s\n", turn.role, turn.text))
+		}
+		r.enqueue(streamTranscriptEventType, map[string]interface{}{
+			"text":   strings.TrimSpace(buf.String()),
+			"chunks": len(r.turns),
+		})
+	}
+	r.closed = true
+	close(r.queue)
+	r.mu.Unlock()
+
+	<-r.done
+}
+
+// record queues an event of the stream. Events recorded after the recorder was
+// closed, such as input that raced with the end of the stream, are dropped.
+func (r *streamRecorder) record(eventType string, data map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.closed {
+		r.enqueue(eventType, data)
+	}
+}
+
+// enqueue creates an event of the stream and queues it. The caller holds the lock.
+func (r *streamRecorder) enqueue(eventType string, data map[string]interface{}) {
+	data["stream_id"] = r.streamID
+	event := newServerEvent(eventType, fmt.Sprintf("stream-%s", r.streamID), r.origin, data)
+	event.Attributes = map[string]string{streamIDAttribute: r.streamID}
+	if eventType == streamTranscriptEventType {
+		// Transcripts are embedded from their text, which the subject would shadow
+		event.Subject = ""
+	}
+	r.queue <- event
+}
+
+// write stores and broadcasts the recorded events in order. Transcripts are
+// facts and are vectorized like published facts.
+func (r *streamRecorder) write() {
+	defer close(r.done)
+	s := r.server
+	for event := range r.queue {
+		if s.storage != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), streamEventStoreTimeout)
+			if err := s.storage.StoreEvent(ctx, event, nil); err != nil {
+				log.Printf("InteractStream: failed to store %s event of stream %s: %v", event.Type, r.streamID, err)
+			}
+			cancel()
+
+			if s.embeddingProvider != nil && IsFactEvent(event.Type) {
+				s.vectorizeWG.Add(1)
+				go s.vectorizeEvent(event)
+			}
+		}
+		s.broadcastEvent(event)
+	}
+}
+
+// chunkData renders a stream chunk. Text is stored as is, binary content such
+// as audio as base64.
+func chunkData(index int, content []byte) map[string]interface{} {
+	data := map[string]interface{}{
+		"index": index,
+		"bytes": len(content),
+	}
+	if utf8.Valid(content) {
+		data["text"] = string(content)
+	} else {
+		data["content_base64"] = base64.StdEncoding.EncodeToString(content)
+	}
+	return data
+}
@@ -0,0 +1,229 @@
+package bus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+const (
+	// Limits of streams whose policy sets none
+	defaultStreamIdleTimeout = 5 * time.Minute
+	defaultStreamMaxDuration = time.Hour
+)
+
+// streamError is a failure of a stream with a typed reason. It converts to a
+// gRPC status, so it can be returned from the handler as is.
+type streamError struct {
+	code    codes.Code
+	reason  busv1.StreamErrorReason
+	message string
+
+	// Set for provider errors
+	errorReason string // ErrorInfo reason, the name of reason if empty
+	retryable   bool
+	retryAfter  time.Duration
+	provider    string
+}
+
+func newStreamError(code codes.Code, reason busv1.StreamErrorReason, format string, args ...interface{}) *streamError {
+	return &streamError{code: code, reason: reason, message: fmt.Sprintf(format, args...)}
+}
+
+// newProviderStreamError maps the error a streaming provider returned
+func newProviderStreamError(err error, provider string) *streamError {
+	class := classifyError(err)
+	return &streamError{
+		code:        class.code,
+		reason:      busv1.StreamErrorReason_STREAM_ERROR_REASON_PROVIDER_ERROR,
+		message:     fmt.Sprintf("provider execution error: %v", err),
+		errorReason: class.reason,
+		retryable:   class.retryable,
+		retryAfter:  providers.RetryAfter(err),
+		provider:    provider,
+	}
+}
+
+func (e *streamError) Error() string {
+	return e.message
+}
+
+// GRPCStatus implements the interface used by the status package
+func (e *streamError) GRPCStatus() *status.Status {
+	return e.status(e.message)
+}
+
+// status returns the status of the error with a message and its details
+func (e *streamError) status(message string) *status.Status {
+	class := errorClass{code: e.code, reason: e.infoReason(), retryable: e.retryable}
+	return withErrorDetails(status.New(e.code, message), class, e.provider, e.retryAfter)
+}
+
+// infoReason returns the ErrorInfo reason of the error
+func (e *streamError) infoReason() string {
+	if e.errorReason == "" {
+		return e.reason.String()
+	}
+	return e.errorReason
+}
+
+// proto renders the error for the client
+func (e *streamError) proto(channelID string) *busv1.StreamError {
+	return &busv1.StreamError{
+		Code:         int32(e.code),
+		Message:      e.message,
+		ChannelId:    channelID,
+		Reason:       e.reason,
+		Retryable:    e.retryable,
+		RetryAfterMs: uint32(e.retryAfter.Milliseconds()),
+		Provider:     e.provider,
+		ErrorReason:  e.infoReason(),
+	}
+}
+
+// asStreamError returns err as a stream error, keeping the code of other errors
+func asStreamError(err error) *streamError {
+	var streamErr *streamError
+	if errors.As(err, &streamErr) {
+		return streamErr
+	}
+	return &streamError{code: status.Code(err), message: status.Convert(err).Message()}
+}
+
+// streamLimits returns the limits of streams with the event type, with the
+// defaults for limits the policy does not set
+func (s *Server) streamLimits(eventType string) policy.StreamLimits {
+	var limits policy.StreamLimits
+	if s.policyEngine != nil {
+		limits = s.policyEngine.StreamLimits(eventType)
+	}
+	if limits.IdleTimeout == 0 {
+		limits.IdleTimeout = defaultStreamIdleTimeout
+	}
+	if limits.MaxDuration == 0 {
+		limits.MaxDuration = defaultStreamMaxDuration
+	}
+	return limits
+}
+
+// watchLimits ends the stream once it was idle for its idle timeout or ran for
+// its maximum duration
+func (sess *interactSession) watchLimits() {
+	maxEnd := sess.startedAt.Add(sess.limits.MaxDuration)
+	for {
+		sess.mu.Lock()
+		idleEnd := sess.lastActivity.Add(sess.limits.IdleTimeout)
+		sess.mu.Unlock()
+
+		now := time.Now()
+		if !now.Before(maxEnd) {
+			sess.fail(newStreamError(codes.DeadlineExceeded, busv1.StreamErrorReason_STREAM_ERROR_REASON_MAX_DURATION,
+				"stream reached its maximum duration of %v", sess.limits.MaxDuration))
+			return
+		}
+		if !now.Before(idleEnd) {
+			sess.fail(newStreamError(codes.DeadlineExceeded, busv1.StreamErrorReason_STREAM_ERROR_REASON_IDLE_TIMEOUT,
+				"stream was idle for %v", sess.limits.IdleTimeout))
+			return
+		}
+
+		wait := idleEnd.Sub(now)
+		if untilMax := maxEnd.Sub(now); untilMax < wait {
+			wait = untilMax
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-sess.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// checkInputLimits counts an input chunk against the stream's limits. The
+// caller holds the session lock.
+func (sess *interactSession) checkInputLimits(size int) *streamError {
+	if max := sess.limits.MaxInputChunks; max > 0 && sess.inputChunks+1 > uint64(max) {
+		return newStreamError(codes.ResourceExhausted, busv1.StreamErrorReason_STREAM_ERROR_REASON_MAX_INPUT_CHUNKS,
+			"stream exceeded its limit of %d input chunks", max)
+	}
+	if max := sess.limits.MaxInputBytes; max > 0 && sess.inputBytes+uint64(size) > uint64(max) {
+		return newStreamError(codes.ResourceExhausted, busv1.StreamErrorReason_STREAM_ERROR_REASON_MAX_INPUT_BYTES,
+			"stream exceeded its limit of %d input bytes", max)
+	}
+	sess.inputChunks++
+	sess.inputBytes += uint64(size)
+	sess.lastActivity = time.Now()
+	return nil
+}
+
+// countStreamError counts a failed stream for the statistics
+func (s *Server) countStreamError(reason busv1.StreamErrorReason) {
+	s.streamSessionsMu.Lock()
+	defer s.streamSessionsMu.Unlock()
+	if s.streamErrors == nil {
+		s.streamErrors = make(map[busv1.StreamErrorReason]uint64)
+	}
+	s.streamErrors[reason]++
+}
+
+// GetStreamStats reports the live InteractStreams for monitoring
+func (s *Server) GetStreamStats(ctx context.Context, req *busv1.StreamStatsRequest) (*busv1.StreamStatsResponse, error) {
+	s.streamSessionsMu.Lock()
+	sessions := make([]*interactSession, 0, len(s.streamSessions))
+	for _, session := range s.streamSessions {
+		sessions = append(sessions, session)
+	}
+	resp := &busv1.StreamStatsResponse{
+		ActiveStreams:  int32(len(sessions)),
+		StartedStreams: s.streamsStarted,
+		Errors:         make(map[string]uint64, len(s.streamErrors)),
+	}
+	for reason, count := range s.streamErrors {
+		resp.Errors[reason.String()] = count
+	}
+	s.streamSessionsMu.Unlock()
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].startedAt.Before(sessions[j].startedAt)
+	})
+	for _, session := range sessions {
+		stats := session.stats()
+		if !stats.Attached {
+			resp.DetachedStreams++
+		}
+		resp.Streams = append(resp.Streams, stats)
+	}
+
+	log.Printf("GetStreamStats: %d active streams, %d detached", resp.ActiveStreams, resp.DetachedStreams)
+	return resp, nil
+}
+
+// stats describes the session for monitoring
+func (sess *interactSession) stats() *busv1.StreamStats {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return &busv1.StreamStats{
+		StreamId:     sess.id,
+		EventType:    sess.eventType,
+		Provider:     sess.providerName,
+		StartedAt:    timestamppb.New(sess.startedAt),
+		Attached:     sess.attachment != 0,
+		InputChunks:  sess.inputChunks,
+		InputBytes:   sess.inputBytes,
+		OutputChunks: sess.lastOutput,
+		OutputBytes:  sess.outputBytes,
+	}
+}
@@ -0,0 +1,402 @@
+package bus
+
+import (
+	"fmt"
+	"io"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+)
+
+// Requests of a channel waiting for its provider. A channel that falls this
+// far behind fails on its own instead of stalling the other channels.
+const streamChannelQueueSize = 64
+
+// streamChannel is a logical channel of an InteractStream connection, attached
+// to its own session
+type streamChannel struct {
+	id         string
+	session    *interactSession
+	attachment uint64
+	sent       uint64 // Sequence number of the last output sent to the client
+
+	queue   chan *busv1.InteractRequest // Input and the end of input, in order
+	control chan *busv1.InteractRequest // Cancel and update requests, not ordered with input
+	stop    chan struct{}               // Stops the channel's goroutines
+	taken   bool                        // Another connection took the session over
+}
+
+// streamMux multiplexes the channels of one InteractStream connection. Only the
+// goroutine running the mux sends on the gRPC stream.
+type streamMux struct {
+	server   *Server
+	stream   busv1.EventBusService_InteractStreamServer
+	channels map[string]*streamChannel
+	wake     chan struct{}       // Signals new output, ends and takeovers
+	taken    chan *streamChannel // Channels taken over by another connection
+	lastEnd  error               // Status of the channel that ended last
+}
+
+func newStreamMux(s *Server, stream busv1.EventBusService_InteractStreamServer) *streamMux {
+	return &streamMux{
+		server:   s,
+		stream:   stream,
+		channels: make(map[string]*streamChannel),
+		wake:     make(chan struct{}, 1),
+		taken:    make(chan *streamChannel, 1),
+	}
+}
+
+// open opens the channel of a config, starting or resuming its session
+func (m *streamMux) open(config *busv1.StreamConfig) (*streamChannel, error) {
+	if _, exists := m.channels[config.ChannelId]; exists {
+		return nil, newStreamError(codes.AlreadyExists, busv1.StreamErrorReason_STREAM_ERROR_REASON_INVALID_REQUEST,
+			"channel %q is already open", config.ChannelId)
+	}
+
+	session, lastSequence, err := m.server.openInteractSession(config)
+	if err != nil {
+		return nil, err
+	}
+	attachment, taken, err := session.attach(lastSequence)
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &streamChannel{
+		id:         config.ChannelId,
+		session:    session,
+		attachment: attachment,
+		sent:       lastSequence,
+		queue:      make(chan *busv1.InteractRequest, streamChannelQueueSize),
+		control:    make(chan *busv1.InteractRequest, streamChannelQueueSize),
+		stop:       make(chan struct{}),
+	}
+	m.channels[ch.id] = ch
+	go m.forward(ch)
+	go m.forwardControl(ch)
+	go m.watch(ch, taken)
+	return ch, nil
+}
+
+// ready tells the client that a channel is open
+func (m *streamMux) ready(ch *streamChannel, resumed bool) error {
+	readyResp := &busv1.InteractResponse{
+		ResponseType: &busv1.InteractResponse_Ready{
+			Ready: &busv1.StreamReady{
+				StreamId:          ch.session.id,
+				Resumed:           resumed,
+				LastInputSequence: ch.session.lastInputSequence(),
+				ChannelId:         ch.id,
+			},
+		},
+	}
+	if err := m.stream.Send(readyResp); err != nil {
+		return status.Errorf(codes.Internal, "failed to send ready response: %v", err)
+	}
+	return nil
+}
+
+// forward passes a channel's input to its session in order. Only this
+// goroutine waits for the provider to read the channel's input.
+func (m *streamMux) forward(ch *streamChannel) {
+	for {
+		select {
+		case req := <-ch.queue:
+			switch reqType := req.RequestType.(type) {
+			case *busv1.InteractRequest_Data:
+				ch.session.receive(reqType.Data)
+			case *busv1.InteractRequest_ClientEnd:
+				ch.session.closeInput()
+			}
+		case <-ch.stop:
+			return
+		}
+	}
+}
+
+// forwardControl passes a channel's cancel and update requests to its session
+// in order. It does not wait for input, so that a cancel reaches a provider
+// that stopped reading input while it generates.
+func (m *streamMux) forwardControl(ch *streamChannel) {
+	for {
+		select {
+		case req := <-ch.control:
+			switch reqType := req.RequestType.(type) {
+			case *busv1.InteractRequest_Cancel:
+				// Abort the current generation, keeping the stream open
+				log.Printf("InteractStream: received cancel for stream %s", ch.session.id)
+				ch.session.cancelGeneration(reqType.Cancel.GetReason())
+			case *busv1.InteractRequest_Update:
+				log.Printf("InteractStream: received attribute update for stream %s", ch.session.id)
+				ch.session.update(reqType.Update.GetAttributes())
+			}
+		case <-ch.stop:
+			return
+		}
+	}
+}
+
+// watch wakes the mux whenever the channel's session has news, and reports a
+// takeover of the session by another connection
+func (m *streamMux) watch(ch *streamChannel, taken <-chan struct{}) {
+	changed := ch.session.changes()
+	for {
+		select {
+		case <-changed:
+			// Fetch the next signal before waking, so that no change is missed
+			changed = ch.session.changes()
+			select {
+			case m.wake <- struct{}{}:
+			default:
+			}
+		case <-taken:
+			select {
+			case m.taken <- ch:
+			case <-ch.stop:
+			}
+			return
+		case <-ch.stop:
+			return
+		}
+	}
+}
+
+// run proxies the connection until its last channel has ended
+func (m *streamMux) run() error {
+	ctx := m.stream.Context()
+
+	// Start goroutine to receive requests from client
+	requests := make(chan *busv1.InteractRequest)
+	errChan := make(chan error, 1)
+	go func() {
+		defer close(requests)
+		for {
+			req, err := m.stream.Recv()
+			if err != nil {
+				if err == io.EOF {
+					log.Printf("InteractStream: client stream ended normally")
+					return
+				}
+				errChan <- fmt.Errorf("error receiving from client: %w", err)
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		if err := m.flush(); err != nil {
+			return err
+		}
+		if len(m.channels) == 0 {
+			if m.lastEnd != nil {
+				log.Printf("InteractStream: stream ended with error: %v", m.lastEnd)
+			}
+			return m.lastEnd
+		}
+
+		select {
+		case req, ok := <-requests:
+			if !ok {
+				// The client sends nothing more on any channel
+				for _, ch := range m.channels {
+					m.enqueue(ch, &busv1.InteractRequest{RequestType: &busv1.InteractRequest_ClientEnd{ClientEnd: &busv1.StreamEnd{ChannelId: ch.id}}})
+				}
+				requests = nil
+				continue
+			}
+			if err := m.handle(req); err != nil {
+				return err
+			}
+
+		case <-m.wake:
+
+		case ch := <-m.taken:
+			ch.taken = true
+
+		case err := <-errChan:
+			// The connection dropped; the sessions wait to be resumed
+			log.Printf("InteractStream: lost client of %d channels: %v", len(m.channels), err)
+			return status.Errorf(codes.Unavailable, "stream interrupted: %v", err)
+
+		case <-ctx.Done():
+			// Context cancelled; the sessions wait to be resumed
+			return status.Error(codes.Canceled, "stream cancelled")
+		}
+	}
+}
+
+// handle dispatches a client request to its channel. Errors that concern a
+// single channel are reported on that channel; the returned error ends the
+// connection.
+func (m *streamMux) handle(req *busv1.InteractRequest) error {
+	var channelID string
+	switch reqType := req.RequestType.(type) {
+	case *busv1.InteractRequest_Config:
+		// Open another channel
+		ch, err := m.open(reqType.Config)
+		if err != nil {
+			return m.sendChannelError(reqType.Config.ChannelId, err)
+		}
+		return m.ready(ch, reqType.Config.GetResume() != nil)
+	case *busv1.InteractRequest_Data:
+		channelID = reqType.Data.GetChannelId()
+		if reqType.Data == nil || reqType.Data.Content == nil {
+			return nil
+		}
+	case *busv1.InteractRequest_Ack:
+		channelID = reqType.Ack.GetChannelId()
+	case *busv1.InteractRequest_Cancel:
+		channelID = reqType.Cancel.GetChannelId()
+	case *busv1.InteractRequest_Update:
+		channelID = reqType.Update.GetChannelId()
+	case *busv1.InteractRequest_ClientEnd:
+		channelID = reqType.ClientEnd.GetChannelId()
+		log.Printf("InteractStream: received client_end signal for channel %q", channelID)
+	default:
+		// Unexpected request type after config
+		for _, ch := range m.channels {
+			ch.session.fail(newStreamError(codes.InvalidArgument, busv1.StreamErrorReason_STREAM_ERROR_REASON_INVALID_REQUEST,
+				"unexpected request type after config: %T", reqType))
+		}
+		return nil
+	}
+
+	ch, ok := m.channels[channelID]
+	if !ok {
+		return m.sendChannelError(channelID, newStreamError(codes.NotFound, busv1.StreamErrorReason_STREAM_ERROR_REASON_INVALID_REQUEST,
+			"channel %q is not open", channelID))
+	}
+	if ack := req.GetAck(); ack != nil {
+		ch.session.ack(ack.Sequence)
+		return nil
+	}
+	if !m.enqueue(ch, req) {
+		ch.session.fail(newStreamError(codes.ResourceExhausted, busv1.StreamErrorReason_STREAM_ERROR_REASON_INPUT_OVERFLOW,
+			"channel %q receives input faster than its provider consumes it", channelID))
+	}
+	return nil
+}
+
+// enqueue queues a request for a channel's provider without blocking. Control
+// requests bypass the input queue. The end of the client's input is always
+// delivered, since it frees the provider.
+func (m *streamMux) enqueue(ch *streamChannel, req *busv1.InteractRequest) bool {
+	queue := ch.queue
+	if req.GetCancel() != nil || req.GetUpdate() != nil {
+		queue = ch.control
+	}
+	select {
+	case queue <- req:
+		return true
+	default:
+	}
+	if req.GetClientEnd() != nil {
+		go func() {
+			select {
+			case ch.queue <- req:
+			case <-ch.stop:
+			}
+		}()
+		return true
+	}
+	return false
+}
+
+// flush sends the pending output of every channel, and the end of every
+// channel whose session is over
+func (m *streamMux) flush() error {
+	for id, ch := range m.channels {
+		if ch.taken {
+			log.Printf("InteractStream: channel %q was resumed by another connection", id)
+			m.remove(ch)
+			m.lastEnd = status.Errorf(codes.Aborted, "stream %s was resumed by another connection", ch.session.id)
+			continue
+		}
+
+		chunks, end, _ := ch.session.next(ch.sent)
+		for _, chunk := range chunks {
+			dataResp := &busv1.InteractResponse{
+				ResponseType: &busv1.InteractResponse_Data{
+					Data: &busv1.StreamData{
+						Content:   chunk.Content,
+						Sequence:  chunk.Sequence,
+						ChannelId: ch.id,
+					},
+				},
+			}
+			if err := m.stream.Send(dataResp); err != nil {
+				return status.Errorf(codes.Internal, "failed to send data: %v", err)
+			}
+			ch.sent = chunk.Sequence
+		}
+		if end == nil {
+			continue
+		}
+
+		// Every output was sent, so the end can follow
+		if err := m.stream.Send(channelEnd(end, ch.id)); err != nil {
+			return status.Errorf(codes.Internal, "failed to send end of stream: %v", err)
+		}
+		m.remove(ch)
+		ch.session.close()
+		m.lastEnd = ch.session.endStatus()
+		if m.lastEnd == nil {
+			log.Printf("InteractStream: sent server_end signal for channel %q", id)
+		}
+	}
+	return nil
+}
+
+// sendChannelError reports an error of a single channel to the client
+func (m *streamMux) sendChannelError(channelID string, err error) error {
+	log.Printf("InteractStream: channel %q failed: %v", channelID, err)
+	errorResp := &busv1.InteractResponse{
+		ResponseType: &busv1.InteractResponse_Error{
+			Error: asStreamError(err).proto(channelID),
+		},
+	}
+	if sendErr := m.stream.Send(errorResp); sendErr != nil {
+		return status.Errorf(codes.Internal, "failed to send error response: %v", sendErr)
+	}
+	return nil
+}
+
+// remove stops a channel's goroutines and forgets it
+func (m *streamMux) remove(ch *streamChannel) {
+	close(ch.stop)
+	delete(m.channels, ch.id)
+}
+
+// detach leaves the sessions of all open channels waiting to be resumed
+func (m *streamMux) detach() {
+	for _, ch := range m.channels {
+		m.remove(ch)
+		ch.session.detach(ch.attachment)
+	}
+}
+
+// channelEnd addresses the end of a session to a channel
+func channelEnd(end *busv1.InteractResponse, channelID string) *busv1.InteractResponse {
+	if streamErr := end.GetError(); streamErr != nil {
+		channelErr := proto.Clone(streamErr).(*busv1.StreamError)
+		channelErr.ChannelId = channelID
+		return &busv1.InteractResponse{
+			ResponseType: &busv1.InteractResponse_Error{Error: channelErr},
+		}
+	}
+	return &busv1.InteractResponse{
+		ResponseType: &busv1.InteractResponse_ServerEnd{
+			ServerEnd: &busv1.StreamEnd{ChannelId: channelID},
+		},
+	}
+}
@@ -0,0 +1,487 @@
+package bus
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/segmentation"
+)
+
+const (
+	// How long a stream outlives its connection, waiting for the client to resume it
+	defaultStreamResumeGrace = 30 * time.Second
+
+	// Output chunks kept for a resume. Clients that do not acknowledge their
+	// output lose the oldest chunks beyond this.
+	streamOutputBufferSize = 256
+)
+
+// interactSession is a live InteractStream. The provider runs independently of
+// the gRPC stream, so that a client whose connection dropped can reattach and
+// receive the output it missed.
+type interactSession struct {
+	id           string
+	server       *Server
+	recorder     *streamRecorder
+	cancel       context.CancelFunc // Stops the provider
+	ctx          context.Context
+	eventType    string
+	providerName string
+	startedAt    time.Time
+	limits       policy.StreamLimits
+
+	// Input to the provider; inputMu serializes sends with closing the channel
+	input       chan []byte
+	inputMu     sync.Mutex
+	inputClosed bool
+
+	// Optional server-side segmentation of the input, guarded by inputMu
+	segmenter   *segmentation.Segmenter
+	idleTimeout time.Duration
+	idleTimer   *time.Timer
+	idleGen     uint64
+
+	// Control messages to the provider, and the attributes they maintain
+	control    chan providers.StreamControl
+	controlMu  sync.Mutex
+	attributes map[string]string
+
+	mu         sync.Mutex
+	lastInput  uint64
+	lastOutput uint64
+	output     []*busv1.StreamData     // Unacknowledged output, oldest first
+	end        *busv1.InteractResponse // server_end or error once the stream is over
+	endErr     error                   // Status returned to the client with the end
+	changed    chan struct{}           // Closed when output or the end arrives
+	attachment uint64                  // Attached connection, 0 while detached
+	attachSeq  uint64
+	taken      chan struct{} // Closed when another connection takes over
+	graceTimer *time.Timer
+	closed     bool
+
+	// Usage, guarded by mu and checked against the limits
+	inputChunks  uint64
+	inputBytes   uint64
+	outputBytes  uint64
+	lastActivity time.Time
+}
+
+// startInteractSession starts the provider of a new stream and registers the
+// stream for resumption
+func (s *Server) startInteractSession(streamID, providerName string, provider providers.StreamingComputeProvider, config *busv1.StreamConfig) *interactSession {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+	session := &interactSession{
+		id:           streamID,
+		server:       s,
+		recorder:     s.newStreamRecorder(streamID, providerName, config),
+		cancel:       cancel,
+		ctx:          ctx,
+		eventType:    config.EventType,
+		providerName: providerName,
+		startedAt:    now,
+		limits:       s.streamLimits(config.EventType),
+		lastActivity: now,
+		input:        make(chan []byte, 10),
+		changed:      make(chan struct{}),
+
+		control:    make(chan providers.StreamControl, 10),
+		attributes: make(map[string]string, len(config.Attributes)),
+	}
+	for key, value := range config.Attributes {
+		session.attributes[key] = value
+	}
+	if seg := config.GetSegmentation(); seg != nil && seg.Mode != busv1.SegmentationMode_SEGMENTATION_MODE_NONE {
+		session.segmenter = segmentation.New(segmentationMode(seg.Mode), int(seg.MaxChars))
+		session.idleTimeout = segmentation.DefaultIdleTimeout
+		if seg.IdleTimeoutMs > 0 {
+			session.idleTimeout = time.Duration(seg.IdleTimeoutMs) * time.Millisecond
+		}
+	}
+
+	s.streamSessionsMu.Lock()
+	if s.streamSessions == nil {
+		s.streamSessions = make(map[string]*interactSession)
+	}
+	s.streamSessions[streamID] = session
+	s.streamsStarted++
+	s.streamSessionsMu.Unlock()
+
+	go session.watchLimits()
+
+	go func() {
+		providerOutput := make(chan []byte, 10)
+		errChan := make(chan error, 1)
+		go func() {
+			defer close(providerOutput)
+			errChan <- provider.ExecuteStream(ctx, config.Attributes, session.input, session.control, providerOutput)
+		}()
+
+		for data := range providerOutput {
+			session.addOutput(data)
+		}
+		session.finish(<-errChan)
+	}()
+
+	return session
+}
+
+// segmentationMode maps the segmentation mode of a stream config
+func segmentationMode(mode busv1.SegmentationMode) segmentation.Mode {
+	switch mode {
+	case busv1.SegmentationMode_SEGMENTATION_MODE_SENTENCE:
+		return segmentation.ModeSentence
+	case busv1.SegmentationMode_SEGMENTATION_MODE_PUNCTUATION:
+		return segmentation.ModePunctuation
+	case busv1.SegmentationMode_SEGMENTATION_MODE_IDLE_TIMEOUT:
+		return segmentation.ModeIdleTimeout
+	default:
+		return segmentation.ModeNone
+	}
+}
+
+// lookupInteractSession returns a live stream by ID
+func (s *Server) lookupInteractSession(streamID string) *interactSession {
+	s.streamSessionsMu.Lock()
+	defer s.streamSessionsMu.Unlock()
+	return s.streamSessions[streamID]
+}
+
+// resumeGrace returns how long a detached stream waits to be resumed
+func (s *Server) resumeGrace() time.Duration {
+	if s.streamResumeGrace > 0 {
+		return s.streamResumeGrace
+	}
+	return defaultStreamResumeGrace
+}
+
+// attach connects a client that received output up to lastSequence. Another
+// connection attached to the stream is taken over. It returns the ID of the
+// attachment and a channel that is closed when it is taken over.
+func (sess *interactSession) attach(lastSequence uint64) (uint64, <-chan struct{}, error) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+
+	if sess.closed {
+		return 0, nil, newStreamError(codes.NotFound, busv1.StreamErrorReason_STREAM_ERROR_REASON_NOT_RESUMED,
+			"stream %s is no longer resumable", sess.id)
+	}
+	if lastSequence > sess.lastOutput {
+		return 0, nil, newStreamError(codes.InvalidArgument, busv1.StreamErrorReason_STREAM_ERROR_REASON_INVALID_REQUEST,
+			"last_sequence %d is beyond the last output %d of stream %s", lastSequence, sess.lastOutput, sess.id)
+	}
+	if lastSequence < sess.lastOutput && (len(sess.output) == 0 || sess.output[0].Sequence > lastSequence+1) {
+		return 0, nil, newStreamError(codes.OutOfRange, busv1.StreamErrorReason_STREAM_ERROR_REASON_NOT_RESUMED,
+			"output of stream %s after sequence %d is no longer buffered", sess.id, lastSequence)
+	}
+
+	if sess.graceTimer != nil {
+		sess.graceTimer.Stop()
+		sess.graceTimer = nil
+	}
+	if sess.attachment != 0 {
+		close(sess.taken)
+	}
+	sess.attachSeq++
+	sess.attachment = sess.attachSeq
+	sess.taken = make(chan struct{})
+	sess.release(lastSequence)
+	return sess.attachment, sess.taken, nil
+}
+
+// detach disconnects a client. Unless the stream is over or taken over, it
+// waits for the grace period to be resumed before it is abandoned.
+func (sess *interactSession) detach(attachment uint64) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if sess.attachment != attachment || sess.closed {
+		return
+	}
+	sess.attachment = 0
+	grace := sess.server.resumeGrace()
+	log.Printf("InteractStream: stream %s detached, resumable for %v", sess.id, grace)
+	sess.graceTimer = time.AfterFunc(grace, sess.abandon)
+}
+
+// abandon ends a stream that was not resumed in time
+func (sess *interactSession) abandon() {
+	log.Printf("InteractStream: stream %s was not resumed, abandoning it", sess.id)
+	sess.fail(newStreamError(codes.Canceled, busv1.StreamErrorReason_STREAM_ERROR_REASON_NOT_RESUMED,
+		"stream was not resumed within the grace period"))
+	sess.close()
+}
+
+// lastInputSequence returns the sequence number of the last input received
+func (sess *interactSession) lastInputSequence() uint64 {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.lastInput
+}
+
+// receive forwards an input chunk to the provider, re-cut into segments if the
+// stream asked for segmentation. Chunks with a sequence number that was
+// received already, e.g. resent after a resume, are dropped. A chunk beyond
+// the input limits of the stream fails it.
+func (sess *interactSession) receive(data *busv1.StreamData) {
+	sess.mu.Lock()
+	sequence := data.Sequence
+	if sequence == 0 {
+		sequence = sess.lastInput + 1
+	} else if sequence <= sess.lastInput {
+		sess.mu.Unlock()
+		log.Printf("InteractStream: dropping duplicate input %d of stream %s", sequence, sess.id)
+		return
+	}
+	sess.lastInput = sequence
+	limitErr := sess.checkInputLimits(len(data.Content))
+	sess.mu.Unlock()
+	if limitErr != nil {
+		sess.fail(limitErr)
+		return
+	}
+
+	sess.inputMu.Lock()
+	defer sess.inputMu.Unlock()
+	if sess.inputClosed {
+		return
+	}
+	if sess.segmenter == nil {
+		sess.forward(data.Content)
+		return
+	}
+	for _, segment := range sess.segmenter.Push(data.Content) {
+		sess.forward(segment)
+	}
+
+	// Flush an unfinished segment once the client pauses
+	sess.idleGen++
+	if sess.idleTimer != nil {
+		sess.idleTimer.Stop()
+		sess.idleTimer = nil
+	}
+	if sess.segmenter.Pending() {
+		gen := sess.idleGen
+		sess.idleTimer = time.AfterFunc(sess.idleTimeout, func() { sess.flushIdle(gen) })
+	}
+}
+
+// flushIdle forwards the buffered segment after the client paused, unless more
+// input arrived in the meantime
+func (sess *interactSession) flushIdle(gen uint64) {
+	sess.inputMu.Lock()
+	defer sess.inputMu.Unlock()
+	if sess.inputClosed || gen != sess.idleGen {
+		return
+	}
+	if segment := sess.segmenter.Flush(); segment != nil {
+		sess.forward(segment)
+	}
+}
+
+// forward records an input chunk and passes it to the provider. The caller
+// holds inputMu.
+func (sess *interactSession) forward(content []byte) {
+	sess.recorder.input(content)
+	select {
+	case sess.input <- content:
+	case <-sess.ctx.Done():
+	}
+}
+
+// closeInput forwards a buffered segment and signals the provider that no more
+// input follows
+func (sess *interactSession) closeInput() {
+	sess.inputMu.Lock()
+	defer sess.inputMu.Unlock()
+	if sess.inputClosed {
+		return
+	}
+	if sess.segmenter != nil {
+		if sess.idleTimer != nil {
+			sess.idleTimer.Stop()
+		}
+		if segment := sess.segmenter.Flush(); segment != nil {
+			sess.forward(segment)
+		}
+	}
+	sess.inputClosed = true
+	close(sess.input)
+}
+
+// cancelGeneration asks the provider to abort the output it is generating
+func (sess *interactSession) cancelGeneration(reason string) {
+	sess.controlMu.Lock()
+	defer sess.controlMu.Unlock()
+	sess.recorder.cancelled(reason)
+	sess.sendControl(providers.StreamControl{Type: providers.StreamControlCancel, Reason: reason})
+}
+
+// update applies attribute changes and passes the resulting attributes to the
+// provider. An empty value removes an attribute.
+func (sess *interactSession) update(changes map[string]string) {
+	sess.controlMu.Lock()
+	defer sess.controlMu.Unlock()
+	for key, value := range changes {
+		if value == "" {
+			delete(sess.attributes, key)
+		} else {
+			sess.attributes[key] = value
+		}
+	}
+	attributes := make(map[string]string, len(sess.attributes))
+	for key, value := range sess.attributes {
+		attributes[key] = value
+	}
+	sess.recorder.updated(changes)
+	sess.sendControl(providers.StreamControl{Type: providers.StreamControlUpdate, Attributes: attributes})
+}
+
+// sendControl passes a control message to the provider. The caller holds controlMu.
+func (sess *interactSession) sendControl(control providers.StreamControl) {
+	select {
+	case sess.control <- control:
+	case <-sess.ctx.Done():
+	}
+}
+
+// ack releases the output a client acknowledged
+func (sess *interactSession) ack(sequence uint64) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.release(sequence)
+}
+
+// release drops buffered output up to a sequence number. The caller holds the lock.
+func (sess *interactSession) release(sequence uint64) {
+	i := 0
+	for i < len(sess.output) && sess.output[i].Sequence <= sequence {
+		i++
+	}
+	sess.output = sess.output[i:]
+}
+
+// addOutput numbers and buffers a chunk of provider output
+func (sess *interactSession) addOutput(content []byte) {
+	sess.recorder.output(content)
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	sess.lastOutput++
+	sess.outputBytes += uint64(len(content))
+	sess.lastActivity = time.Now()
+	sess.output = append(sess.output, &busv1.StreamData{Content: content, Sequence: sess.lastOutput})
+	if len(sess.output) > streamOutputBufferSize {
+		sess.output = sess.output[len(sess.output)-streamOutputBufferSize:]
+	}
+	sess.notify()
+}
+
+// finish ends the stream once the provider returned
+func (sess *interactSession) finish(err error) {
+	if err != nil {
+		sess.fail(newProviderStreamError(err, sess.providerName))
+		return
+	}
+
+	sess.mu.Lock()
+	if sess.end != nil {
+		sess.mu.Unlock()
+		return
+	}
+	sess.end = &busv1.InteractResponse{
+		ResponseType: &busv1.InteractResponse_ServerEnd{
+			ServerEnd: &busv1.StreamEnd{},
+		},
+	}
+	sess.notify()
+	sess.mu.Unlock()
+
+	sess.recorder.ended()
+}
+
+// fail ends the stream with an error and stops the provider
+func (sess *interactSession) fail(err *streamError) {
+	sess.mu.Lock()
+	if sess.end != nil {
+		sess.mu.Unlock()
+		return
+	}
+	sess.end = &busv1.InteractResponse{
+		ResponseType: &busv1.InteractResponse_Error{
+			Error: err.proto(""),
+		},
+	}
+	sess.endErr = err.status("stream error: " + err.message).Err()
+	sess.notify()
+	sess.mu.Unlock()
+
+	log.Printf("InteractStream: stream %s failed (%s): %s", sess.id, err.reason, err.message)
+	sess.server.countStreamError(err.reason)
+	sess.recorder.failed(err)
+	sess.cancel()
+}
+
+// next returns the output after a sequence number, the end of the stream if it
+// is over, and a channel that is closed when more arrives
+func (sess *interactSession) next(after uint64) ([]*busv1.StreamData, *busv1.InteractResponse, <-chan struct{}) {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	var chunks []*busv1.StreamData
+	for _, chunk := range sess.output {
+		if chunk.Sequence > after {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks, sess.end, sess.changed
+}
+
+// changes returns a channel that is closed when output or the end arrives
+func (sess *interactSession) changes() <-chan struct{} {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.changed
+}
+
+// endStatus returns the status of a stream that is over, nil if it ended gracefully
+func (sess *interactSession) endStatus() error {
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	return sess.endErr
+}
+
+// notify wakes up the attached connection. The caller holds the lock.
+func (sess *interactSession) notify() {
+	close(sess.changed)
+	sess.changed = make(chan struct{})
+}
+
+// close unregisters a stream whose end was delivered or that was abandoned,
+// and waits until its events are stored
+func (sess *interactSession) close() {
+	sess.mu.Lock()
+	if sess.closed {
+		sess.mu.Unlock()
+		return
+	}
+	sess.closed = true
+	if sess.graceTimer != nil {
+		sess.graceTimer.Stop()
+		sess.graceTimer = nil
+	}
+	sess.mu.Unlock()
+
+	s := sess.server
+	s.streamSessionsMu.Lock()
+	delete(s.streamSessions, sess.id)
+	s.streamSessionsMu.Unlock()
+
+	sess.cancel()
+	sess.recorder.close()
+}
@@ -0,0 +1,328 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+const (
+	// Event type of the fact a session is compacted into
+	summaryEventType = "pcas.memory.summary.v1"
+
+	// summarizedByEdgeLabel links a session event to the summary that covers it
+	summarizedByEdgeLabel = "summarized_by"
+
+	// Summarization defaults for configurations that do not override them
+	defaultSummaryIdleAfter = 30 * time.Minute
+	defaultSummaryInterval  = 5 * time.Minute
+	defaultSummaryLookback  = 24 * time.Hour
+	defaultSummaryMinEvents = 4
+	defaultSummaryMaxEvents = 200
+	defaultSummaryTimeout   = 60 * time.Second
+
+	// Recent events scanned for idle sessions on every run
+	maxSummaryScanEvents = 5000
+
+	// Session turns longer than this are shortened in the summary prompt
+	maxSummaryTurnChars = 2000
+
+	// Summaries condense many turns, so ranking treats them as important
+	summaryImportance = "0.8"
+)
+
+// summaryPromptTemplate asks the provider for a summary that stands on its own as a memory
+const summaryPromptTemplate = `Summarize the following conversation for a personal memory store.
+Keep the facts, decisions, preferences and open tasks it mentions, with names, dates and numbers.
+Leave out greetings and small talk. Write a few short sentences in the third person about the user.
+
+Conversation:
+%s
+Summary:`
+
+// SetSummarization enables the compaction of idle sessions into summary facts
+func (s *Server) SetSummarization(cfg *policy.Summarization) {
+	s.summarization = cfg
+}
+
+// StartSummarization periodically summarizes idle sessions until the context is
+// cancelled. It does nothing unless summarization is configured.
+// WaitForBackground waits for it to stop.
+func (s *Server) StartSummarization(ctx context.Context) {
+	if s.summarization == nil {
+		return
+	}
+
+	interval := s.summarization.Interval
+	if interval <= 0 {
+		interval = defaultSummaryInterval
+	}
+	log.Printf("Session summarization enabled (provider: %s, every %v)", s.summarization.Provider, interval)
+	s.backgroundWG.Add(1)
+	go func() {
+		defer s.backgroundWG.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if summarized := s.summarizeIdleSessions(ctx, time.Now()); summarized > 0 {
+					log.Printf("Summarized %d idle sessions", summarized)
+				}
+			}
+		}
+	}()
+}
+
+// summarizeIdleSessions looks for sessions with recent activity that have been
+// quiet for the idle period and summarizes the events they gained since their
+// last summary. It returns the number of summaries written.
+func (s *Server) summarizeIdleSessions(ctx context.Context, now time.Time) int {
+	querier, ok := s.storage.(storage.EventQuerier)
+	if !ok || s.summarization == nil {
+		return 0
+	}
+
+	idleAfter := s.summarization.IdleAfter
+	if idleAfter <= 0 {
+		idleAfter = defaultSummaryIdleAfter
+	}
+	lookback := s.summarization.Lookback
+	if lookback <= 0 {
+		lookback = defaultSummaryLookback
+	}
+
+	since := now.Add(-lookback)
+	events, err := querier.QueryEvents(ctx, &storage.Filter{TimeFrom: &since, ExcludeEventTypes: bookkeepingEventTypes}, maxSummaryScanEvents)
+	if err != nil {
+		log.Printf("Failed to query recent events for summarization: %v", err)
+		return 0
+	}
+
+	// Events are most recent first, so the first turn and the first summary of
+	// a session are its latest
+	latest := make(map[string]*eventsv1.Event)
+	summaries := make(map[string]*eventsv1.Event)
+	var sessionIDs []string
+	for _, event := range events {
+		if event.SessionId == "" {
+			continue
+		}
+		if event.Type == summaryEventType {
+			if _, seen := summaries[event.SessionId]; !seen {
+				summaries[event.SessionId] = event
+			}
+			continue
+		}
+		if _, seen := latest[event.SessionId]; !seen {
+			latest[event.SessionId] = event
+			sessionIDs = append(sessionIDs, event.SessionId)
+		}
+	}
+	sort.Strings(sessionIDs)
+
+	summarized := 0
+	for _, sessionID := range sessionIDs {
+		last := latest[sessionID]
+		if now.Sub(last.GetTime().AsTime()) < idleAfter {
+			// Still active
+			continue
+		}
+		if summary := summaries[sessionID]; summary != nil && !last.GetTime().AsTime().After(summarizedUntil(summary)) {
+			// Summarized already
+			continue
+		}
+		if s.summarizeSession(ctx, querier, sessionID, last.UserId) {
+			summarized++
+		}
+	}
+	return summarized
+}
+
+// summarizeSession summarizes the events a session gained since its last summary,
+// stores the summary as a fact linked to them and vectorizes it. It reports
+// whether a summary was written.
+func (s *Server) summarizeSession(ctx context.Context, querier storage.EventQuerier, sessionID, userID string) bool {
+	cfg := s.summarization
+	provider, exists := s.providers[cfg.Provider]
+	if !exists {
+		log.Printf("Summarization provider %s not found", cfg.Provider)
+		return false
+	}
+
+	minEvents := cfg.MinEvents
+	if minEvents <= 0 {
+		minEvents = defaultSummaryMinEvents
+	}
+	maxEvents := cfg.MaxEvents
+	if maxEvents <= 0 {
+		maxEvents = defaultSummaryMaxEvents
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultSummaryTimeout
+	}
+
+	// The server's bookkeeping of the session is not part of the conversation
+	filter := &storage.Filter{SessionID: &sessionID, ExcludeEventTypes: bookkeepingEventTypes}
+	if userID != "" {
+		filter.UserID = &userID
+	}
+
+	// Only events after the newest turn of the last summary are new. The
+	// summary itself is stored later, and turns stored while it was written
+	// are still new.
+	summaryFilter := *filter
+	summaryFilter.EventTypes = []string{summaryEventType}
+	summaryFilter.ExcludeEventTypes = nil
+	previous, err := querier.QueryEvents(ctx, &summaryFilter, 1)
+	if err != nil {
+		log.Printf("Failed to query summaries of session %s: %v", sessionID, err)
+		return false
+	}
+	summarized := make(map[string]bool)
+	if len(previous) > 0 {
+		until := summarizedUntil(previous[0])
+		filter.TimeFrom = &until
+		// Times have second precision, so turns at the cut-off may be covered
+		if sourceIDs, ok := eventDataMap(previous[0])["source_event_ids"].([]interface{}); ok {
+			for _, id := range sourceIDs {
+				if id, ok := id.(string); ok {
+					summarized[id] = true
+				}
+			}
+		}
+	}
+
+	events, err := querier.QueryEvents(ctx, filter, maxEvents)
+	if err != nil {
+		log.Printf("Failed to query events of session %s: %v", sessionID, err)
+		return false
+	}
+	var turns []*eventsv1.Event
+	for _, event := range events {
+		if event.Type == summaryEventType || event.Type == consolidatedMemoryEventType {
+			continue
+		}
+		if summarized[event.Id] || (filter.TimeFrom != nil && event.GetTime().AsTime().Before(*filter.TimeFrom)) {
+			continue
+		}
+		turns = append(turns, event)
+	}
+	if len(turns) < minEvents {
+		return false
+	}
+
+	// turns are most recent first
+	newest, oldest := turns[0], turns[len(turns)-1]
+	requestData := map[string]interface{}{
+		"prompt": fmt.Sprintf(summaryPromptTemplate, renderTranscript(turns, maxSummaryTurnChars)),
+	}
+
+	summaryCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	response, err := s.executeProvider(summaryCtx, newest, cfg.Provider, provider, requestData, nil)
+	if err != nil {
+		log.Printf("Failed to summarize session %s with %s: %v", sessionID, cfg.Provider, err)
+		return false
+	}
+	text := strings.TrimSpace(response)
+	if text == "" {
+		log.Printf("Summarization provider %s returned an empty summary for session %s", cfg.Provider, sessionID)
+		return false
+	}
+
+	sourceIDs := make([]interface{}, len(turns))
+	for i, turn := range turns {
+		sourceIDs[i] = turn.Id
+	}
+	// The text doubles as the subject so that it is embedded and shown by RAG
+	summary := newServerEvent(summaryEventType, text, newest, map[string]interface{}{
+		"text":             text,
+		"source_event_ids": sourceIDs,
+		"events":           float64(len(turns)),
+		"from":             oldest.GetTime().AsTime().Format(time.RFC3339),
+		"to":               newest.GetTime().AsTime().Format(time.RFC3339),
+	})
+	summary.Attributes = map[string]string{importanceAttribute: summaryImportance}
+
+	if err := s.storage.StoreEvent(ctx, summary, nil); err != nil {
+		log.Printf("Failed to store summary of session %s: %v", sessionID, err)
+		return false
+	}
+	s.broadcastEvent(summary)
+
+	if graph, ok := s.storage.(storage.GraphStorage); ok {
+		for _, turn := range turns {
+			if err := graph.CreateEdge(ctx, turn.Id, summary.Id, summarizedByEdgeLabel); err != nil {
+				log.Printf("Failed to link event %s to summary %s: %v", turn.Id, summary.Id, err)
+			}
+		}
+	}
+
+	if s.embeddingProvider != nil {
+		s.vectorizeWG.Add(1)
+		s.vectorizeEvent(summary)
+	}
+
+	log.Printf("Summarized %d events of session %s into %s", len(turns), sessionID, summary.Id)
+	return true
+}
+
+// summarizedUntil returns the time of the newest turn a summary covers, or the
+// time of the summary if it does not record it
+func summarizedUntil(summary *eventsv1.Event) time.Time {
+	until := summary.GetTime().AsTime()
+	if to, ok := eventDataMap(summary)["to"].(string); ok {
+		if parsed, err := time.Parse(time.RFC3339, to); err == nil {
+			until = parsed
+		}
+	}
+	return until
+}
+
+// preferSummaries replaces session events that a summary covers with the summary,
+// keeping the best score, so that RAG shows the condensed fact rather than
+// individual turns. A summary that does not pass the filter of the retrieval
+// does not replace anything. The results must be sorted best first.
+func (s *Server) preferSummaries(ctx context.Context, results []storage.QueryResult, filter *storage.Filter) []storage.QueryResult {
+	graph, ok := s.storage.(storage.GraphStorage)
+	if !ok || len(results) == 0 {
+		return results
+	}
+
+	preferred := make([]storage.QueryResult, 0, len(results))
+	seen := make(map[string]bool)
+	allowed := make(map[string]bool) // Whether a summary passes the filter, by ID
+	for _, result := range results {
+		edges, err := graph.GetEdges(ctx, result.ID, summarizedByEdgeLabel)
+		if err == nil && len(edges) > 0 {
+			summaryID := edges[len(edges)-1].TargetID
+			passes, checked := allowed[summaryID]
+			if !checked {
+				summary, err := s.storage.GetEventByID(ctx, summaryID)
+				passes = err == nil && summary != nil && filter.Matches(summary)
+				allowed[summaryID] = passes
+			}
+			if passes {
+				log.Printf("RAG: Using summary %s instead of session event %s", summaryID, result.ID)
+				result = storage.QueryResult{ID: summaryID, Score: result.Score}
+			}
+		}
+		if seen[result.ID] {
+			continue
+		}
+		seen[result.ID] = true
+		preferred = append(preferred, result)
+	}
+	return preferred
+}
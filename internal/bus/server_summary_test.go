@@ -0,0 +1,232 @@
+package bus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+func TestSummarizeIdleSessions(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	summarizer := &recordingProvider{result: "The user is planning a team offsite in Kyoto in April."}
+	s := NewServer(nil, map[string]providers.ComputeProvider{"summarizer": summarizer}, store)
+	s.embeddingProvider = keywordEmbeddingProvider{}
+	s.SetSummarization(&policy.Summarization{Provider: "summarizer", IdleAfter: time.Hour, MinEvents: 3})
+
+	// An idle session about the offsite, an active session and a short idle
+	// session. The server's bookkeeping is not part of the conversation, so it
+	// neither reaches the summary nor makes the short session long enough.
+	ctx := context.Background()
+	now := time.Now()
+	turns := []struct {
+		id      string
+		session string
+		typ     string
+		text    string
+		age     time.Duration
+	}{
+		{"idle-1", "session-idle", "user.note.v1", "Let's plan the offsite", 3 * time.Hour},
+		{"idle-2", "session-idle", "pcas.response.v1", "Sure, where should it be?", 3 * time.Hour},
+		{"idle-3", "session-idle", "user.note.v1", "Kyoto, in April", 2 * time.Hour},
+		{"idle-trace", "session-idle", ragTraceEventType, "Earlier retrieval query", 2 * time.Hour},
+		{"active-1", "session-active", "user.note.v1", "Hello", 10 * time.Minute},
+		{"active-2", "session-active", "user.note.v1", "Still typing", 5 * time.Minute},
+		{"active-3", "session-active", "user.note.v1", "One more thing", time.Minute},
+		{"short-1", "session-short", "user.note.v1", "Hi", 5 * time.Hour},
+		{"short-2", "session-short", streamInputEventType, "chunk", 5 * time.Hour},
+		{"short-3", "session-short", streamOutputEventType, "CHUNK", 5 * time.Hour},
+	}
+	for _, turn := range turns {
+		data, _ := structpb.NewValue(map[string]interface{}{"text": turn.text})
+		anyData, _ := anypb.New(data)
+		event := &eventsv1.Event{
+			Id:          turn.id,
+			Type:        turn.typ,
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      "user-1",
+			SessionId:   turn.session,
+			Data:        anyData,
+			Time:        timestamppb.New(now.Add(-turn.age)),
+		}
+		if err := store.StoreEvent(ctx, event, nil); err != nil {
+			t.Fatalf("Failed to store event %s: %v", turn.id, err)
+		}
+		if IsFactEvent(event.Type) {
+			s.vectorizeWG.Add(1)
+			s.vectorizeEvent(event)
+		}
+	}
+
+	if summarized := s.summarizeIdleSessions(ctx, now); summarized != 1 {
+		t.Fatalf("Expected 1 summarized session, got %d", summarized)
+	}
+	if !strings.Contains(summarizer.prompt, "User: Kyoto, in April") || !strings.Contains(summarizer.prompt, "Assistant: Sure, where should it be?") {
+		t.Errorf("Expected the session transcript in the prompt, got:\n%s", summarizer.prompt)
+	}
+	if strings.Contains(summarizer.prompt, "Earlier retrieval query") {
+		t.Errorf("Expected bookkeeping events to be left out of the prompt, got:\n%s", summarizer.prompt)
+	}
+
+	// The summary is a fact of the session linked to every summarized event
+	graph := store.(storage.GraphStorage)
+	edges, err := graph.GetEdges(ctx, "idle-3", summarizedByEdgeLabel)
+	if err != nil || len(edges) != 1 {
+		t.Fatalf("Expected idle-3 to be linked to a summary, got %v (%v)", edges, err)
+	}
+	summary, err := store.GetEventByID(ctx, edges[0].TargetID)
+	if err != nil {
+		t.Fatalf("Failed to load summary: %v", err)
+	}
+	if summary.Type != summaryEventType || summary.SessionId != "session-idle" || summary.UserId != "user-1" {
+		t.Errorf("Expected a summary of session-idle for user-1, got %s of %s for %s", summary.Type, summary.SessionId, summary.UserId)
+	}
+
+	// Sessions without new events are not summarized again
+	if summarized := s.summarizeIdleSessions(ctx, now.Add(2*time.Hour)); summarized != 1 {
+		t.Errorf("Expected only the formerly active session to be summarized, got %d", summarized)
+	}
+	if summarized := s.summarizeIdleSessions(ctx, now.Add(3*time.Hour)); summarized != 0 {
+		t.Errorf("Expected no further summaries, got %d", summarized)
+	}
+
+	// RAG shows the summary instead of the raw turns
+	prompt := &eventsv1.Event{Id: "prompt-1", Type: "pcas.user.prompt.v1", UserId: "user-1"}
+	requestData := map[string]interface{}{"prompt": "Where is the offsite? Kyoto?"}
+	s.applyRAGEnhancement(ctx, prompt, requestData, &policy.RAG{}, nil)
+	messages, ok := requestData["messages"].([]map[string]string)
+	if !ok {
+		t.Fatalf("Expected RAG messages, got %v", requestData)
+	}
+	system := messages[0]["content"]
+	if !strings.Contains(system, "offsite in Kyoto in April") {
+		t.Errorf("Expected the summary in the system message:\n%s", system)
+	}
+	if strings.Contains(system, "Kyoto, in April") {
+		t.Errorf("Expected the summarized turn to be replaced by its summary:\n%s", system)
+	}
+
+	// A summary the retrieval filters out does not replace the turns it covers
+	requestData = map[string]interface{}{"prompt": "Where is the offsite? Kyoto?"}
+	s.applyRAGEnhancement(ctx, prompt, requestData, &policy.RAG{Filters: policy.RAGFilters{EventTypes: []string{"user.note.v1"}}}, nil)
+	messages, ok = requestData["messages"].([]map[string]string)
+	if !ok {
+		t.Fatalf("Expected RAG messages, got %v", requestData)
+	}
+	system = messages[0]["content"]
+	if strings.Contains(system, "offsite in Kyoto in April") {
+		t.Errorf("Expected the summary to be filtered out:\n%s", system)
+	}
+	if !strings.Contains(system, "Kyoto, in April") {
+		t.Errorf("Expected the turn to be kept when its summary is filtered out:\n%s", system)
+	}
+}
+
+func TestStartSummarization_WaitForBackground(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	s := NewServer(nil, nil, store)
+	s.SetSummarization(&policy.Summarization{Provider: "summarizer", Interval: time.Millisecond})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.StartSummarization(ctx)
+	time.Sleep(10 * time.Millisecond) // Let a few passes run
+	cancel()
+
+	// A pass may start vectorization, so it has to finish before shutdown waits for it
+	stopped := make(chan struct{})
+	go func() {
+		s.WaitForBackground()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the summarization loop to stop after cancellation")
+	}
+}
+
+// turnStoringProvider stores a turn in the session while it writes the first
+// summary, as a client that keeps talking would
+type turnStoringProvider struct {
+	store storage.Storage
+	turn  *eventsv1.Event
+	calls int
+}
+
+func (p *turnStoringProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	p.calls++
+	if p.calls == 1 {
+		if err := p.store.StoreEvent(ctx, p.turn, nil); err != nil {
+			return "", err
+		}
+	}
+	return "Summary", nil
+}
+
+func TestSummarizeIdleSessions_TurnsDuringSummary(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	now := time.Now()
+	event := func(id string, at time.Time) *eventsv1.Event {
+		return &eventsv1.Event{
+			Id:          id,
+			Type:        "user.note.v1",
+			Source:      "test",
+			Specversion: "1.0",
+			UserId:      "user-1",
+			SessionId:   "session-1",
+			Subject:     id,
+			Time:        timestamppb.New(at),
+		}
+	}
+	for _, id := range []string{"turn-1", "turn-2"} {
+		if err := store.StoreEvent(ctx, event(id, now.Add(-2*time.Hour)), nil); err != nil {
+			t.Fatalf("Failed to store event %s: %v", id, err)
+		}
+	}
+
+	// The late turn is newer than the summarized turns but older than the summary
+	summarizer := &turnStoringProvider{store: store, turn: event("late-turn", now.Add(-time.Hour))}
+	s := NewServer(nil, map[string]providers.ComputeProvider{"summarizer": summarizer}, store)
+	s.SetSummarization(&policy.Summarization{Provider: "summarizer", IdleAfter: time.Minute, MinEvents: 1})
+
+	if summarized := s.summarizeIdleSessions(ctx, now); summarized != 1 {
+		t.Fatalf("Expected the session to be summarized, got %d", summarized)
+	}
+	if summarized := s.summarizeIdleSessions(ctx, now.Add(time.Hour)); summarized != 1 {
+		t.Fatalf("Expected the late turn to be summarized, got %d", summarized)
+	}
+	edges, err := store.(storage.GraphStorage).GetEdges(ctx, "late-turn", summarizedByEdgeLabel)
+	if err != nil || len(edges) != 1 {
+		t.Errorf("Expected late-turn to be linked to a summary, got %v (%v)", edges, err)
+	}
+	if summarized := s.summarizeIdleSessions(ctx, now.Add(2*time.Hour)); summarized != 0 {
+		t.Errorf("Expected no further summaries, got %d", summarized)
+	}
+}
@@ -0,0 +1,90 @@
+package bus
+
+import (
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/tokenizer"
+)
+
+const (
+	// Room left for the answer when fitting context into a provider's window
+	completionReserveTokens = 2000
+	
+	// Model the OpenAI provider sends requests to
+	openAIDefaultModel = "gpt-4o"
+)
+
+// tokenizerFor returns the tokenizer matching the model behind the provider
+func (s *Server) tokenizerFor(providerName string) tokenizer.Tokenizer {
+	if s.policyEngine == nil {
+		return tokenizer.ForModel("")
+	}
+	return tokenizer.ForModel(providerModel(s.policyEngine.ProviderConfig(providerName)))
+}
+
+// providerModel returns the model or encoding used to count tokens for a provider
+func providerModel(cfg *policy.ProviderConfig) string {
+	if cfg == nil {
+		return ""
+	}
+	if cfg.Tokenizer != "" {
+		return cfg.Tokenizer
+	}
+	if model, ok := cfg.Config["model"].(string); ok && model != "" {
+		return model
+	}
+	if cfg.Type == "openai" {
+		return openAIDefaultModel
+	}
+	return ""
+}
+
+// contextBudget caps the configured context budget so that the context, the prompt
+// and the answer fit into the smallest context window of the given providers
+func (s *Server) contextBudget(providerNames []string, configured, promptTokens int) int {
+	budget := configured
+	if s.policyEngine == nil {
+		return budget
+	}
+	
+	for _, name := range providerNames {
+		cfg := s.policyEngine.ProviderConfig(name)
+		if cfg == nil || cfg.ContextWindow <= 0 {
+			continue
+		}
+		available := cfg.ContextWindow - promptTokens - completionReserveTokens
+		if available < 0 {
+			available = 0
+		}
+		if available < budget {
+			budget = available
+		}
+	}
+	return budget
+}
+
+// countRequestTokens counts the prompt tokens of a request with the given tokenizer
+func countRequestTokens(tok tokenizer.Tokenizer, requestData map[string]interface{}) int {
+	if messages, ok, err := providers.MessagesFromRequest(requestData); ok && err == nil {
+		total := 0
+		for _, msg := range messages {
+			total += tok.CountTokens(msg.Content)
+		}
+		return total
+	}
+	
+	prompt, _ := requestData["prompt"].(string)
+	return tok.CountTokens(prompt)
+}
+
+// actionProviders returns every provider an action may send the request to
+func actionProviders(action *policy.Action) []string {
+	if action.FanOut == nil {
+		return action.ProviderChain()
+	}
+	names := make([]string, 0, len(action.FanOut.Targets))
+	for _, target := range action.FanOut.Targets {
+		names = append(names, target.Provider)
+	}
+	return names
+}
@@ -0,0 +1,48 @@
+package bus
+
+import (
+	"testing"
+
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/tokenizer"
+)
+
+func TestContextBudget(t *testing.T) {
+	s := &Server{policyEngine: policy.NewEngine(&policy.Policy{
+		Providers: []policy.ProviderConfig{
+			{Name: "openai-gpt4", Type: "openai", ContextWindow: 128000},
+			{Name: "ollama-llama3", Type: "ollama", ContextWindow: 8192, Config: map[string]interface{}{"model": "llama3:8b"}},
+			{Name: "mock-provider", Type: "mock"},
+		},
+	})}
+
+	testCases := []struct {
+		name         string
+		providers    []string
+		configured   int
+		promptTokens int
+		expected     int
+	}{
+		{name: "large window keeps configured budget", providers: []string{"openai-gpt4"}, configured: 4000, promptTokens: 100, expected: 4000},
+		{name: "small window caps budget", providers: []string{"ollama-llama3"}, configured: 8000, promptTokens: 192, expected: 8192 - 192 - completionReserveTokens},
+		{name: "smallest window of the chain wins", providers: []string{"openai-gpt4", "ollama-llama3"}, configured: 8000, promptTokens: 0, expected: 8192 - completionReserveTokens},
+		{name: "unknown window is ignored", providers: []string{"mock-provider"}, configured: 4000, expected: 4000},
+		{name: "prompt fills the window", providers: []string{"ollama-llama3"}, configured: 4000, promptTokens: 9000, expected: 0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if budget := s.contextBudget(tc.providers, tc.configured, tc.promptTokens); budget != tc.expected {
+				t.Errorf("Expected budget %d, got %d", tc.expected, budget)
+			}
+		})
+	}
+
+	// Tokenizers follow the model behind each provider
+	if name := s.tokenizerFor("openai-gpt4").Name(); name != tokenizer.EncodingO200K {
+		t.Errorf("Expected o200k_base for openai-gpt4, got %s", name)
+	}
+	if name := s.tokenizerFor("ollama-llama3").Name(); name != tokenizer.DefaultEncoding {
+		t.Errorf("Expected default encoding for ollama-llama3, got %s", name)
+	}
+}
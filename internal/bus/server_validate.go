@@ -0,0 +1,127 @@
+package bus
+
+import (
+	"fmt"
+	"regexp"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+)
+
+const (
+	// Largest encoded data payload of a published event
+	defaultMaxEventDataBytes = 1 << 20
+
+	// Most attributes a published event may carry
+	defaultMaxEventAttributes = 64
+
+	// Limits of the envelope fields
+	maxEventIDLength        = 256
+	maxEventTypeLength      = 256
+	maxEventSourceLength    = 1024
+	maxAttributeKeyLength   = 128
+	maxAttributeValueLength = 4096
+)
+
+// The CloudEvents version the bus accepts
+const eventSpecVersion = "1.0"
+
+// ErrorInfo reason of a publish rejected because the event is malformed
+const errorReasonInvalidEvent = "INVALID_EVENT"
+
+// eventTypePattern matches reverse-DNS event types such as
+// "pcas.user.prompt.v1": dot-separated lowercase segments of letters, digits,
+// '_' and '-'
+var eventTypePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*(\.[a-z0-9][a-z0-9_-]*)+$`)
+
+// normalizeEvent checks the envelope of a published event and fills in the
+// time if it is missing. It returns an INVALID_ARGUMENT status with a
+// BadRequest detail listing every invalid field.
+func (s *Server) normalizeEvent(event *eventsv1.Event) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+	violate := func(field, format string, args ...interface{}) {
+		violations = append(violations, &errdetails.BadRequest_FieldViolation{
+			Field:       field,
+			Description: fmt.Sprintf(format, args...),
+		})
+	}
+
+	switch {
+	case event.Id == "":
+		violate("id", "id is required")
+	case len(event.Id) > maxEventIDLength:
+		violate("id", "id must not be longer than %d bytes", maxEventIDLength)
+	}
+
+	switch {
+	case event.Type == "":
+		violate("type", "type is required")
+	case len(event.Type) > maxEventTypeLength:
+		violate("type", "type must not be longer than %d bytes", maxEventTypeLength)
+	case !eventTypePattern.MatchString(event.Type):
+		violate("type", "type %q must be dot-separated lowercase segments, e.g. \"pcas.user.prompt.v1\"", event.Type)
+	}
+
+	switch {
+	case event.Source == "":
+		violate("source", "source is required")
+	case len(event.Source) > maxEventSourceLength:
+		violate("source", "source must not be longer than %d bytes", maxEventSourceLength)
+	}
+
+	if event.Specversion != eventSpecVersion {
+		violate("specversion", "specversion must be %q, got %q", eventSpecVersion, event.Specversion)
+	}
+
+	maxDataBytes, maxAttributes := s.eventSizeLimits()
+	if size := proto.Size(event.Data); size > maxDataBytes {
+		violate("data", "data is %d bytes, more than the limit of %d", size, maxDataBytes)
+	}
+	if len(event.Attributes) > maxAttributes {
+		violate("attributes", "%d attributes, more than the limit of %d", len(event.Attributes), maxAttributes)
+	}
+	for key, value := range event.Attributes {
+		switch {
+		case key == "":
+			violate("attributes", "attribute keys must not be empty")
+		case len(key) > maxAttributeKeyLength:
+			violate("attributes", "attribute key %.32q... is longer than %d bytes", key, maxAttributeKeyLength)
+		case len(value) > maxAttributeValueLength:
+			violate("attributes", "attribute %q is longer than %d bytes", key, maxAttributeValueLength)
+		}
+	}
+
+	if len(violations) > 0 {
+		st := status.Newf(codes.InvalidArgument, "invalid event %q: %s", event.Id, violations[0].Description)
+		if withViolations, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+			st = withViolations
+		}
+		class := errorClass{code: codes.InvalidArgument, reason: errorReasonInvalidEvent}
+		return withErrorDetails(st, class, "", 0).Err()
+	}
+
+	// The bus fills in a missing time
+	if event.Time == nil {
+		event.Time = timestamppb.Now()
+	}
+	return nil
+}
+
+// eventSizeLimits returns the configured size limits of published events
+func (s *Server) eventSizeLimits() (maxDataBytes, maxAttributes int) {
+	s.publishedMu.Lock()
+	defer s.publishedMu.Unlock()
+	maxDataBytes, maxAttributes = s.maxEventDataBytes, s.maxEventAttributes
+	if maxDataBytes <= 0 {
+		maxDataBytes = defaultMaxEventDataBytes
+	}
+	if maxAttributes <= 0 {
+		maxAttributes = defaultMaxEventAttributes
+	}
+	return maxDataBytes, maxAttributes
+}
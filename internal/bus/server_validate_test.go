@@ -0,0 +1,121 @@
+package bus
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
+)
+
+func TestPublish_Validation(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	s := NewServer(policy.NewEngine(&policy.Policy{}), nil, store)
+	s.SetPublishing(&policy.Publish{MaxDataBytes: 64, MaxAttributes: 2})
+
+	largeData, _ := anypb.New(wrapperspb.String(strings.Repeat("x", 100)))
+
+	testCases := []struct {
+		name         string
+		modify       func(event *eventsv1.Event)
+		expectFields []string
+	}{
+		{
+			name:   "valid",
+			modify: func(event *eventsv1.Event) {},
+		},
+		{
+			name: "empty envelope",
+			modify: func(event *eventsv1.Event) {
+				*event = eventsv1.Event{}
+			},
+			expectFields: []string{"id", "type", "source", "specversion"},
+		},
+		{
+			name:         "wrong specversion",
+			modify:       func(event *eventsv1.Event) { event.Specversion = "0.3" },
+			expectFields: []string{"specversion"},
+		},
+		{
+			name:         "uppercase type",
+			modify:       func(event *eventsv1.Event) { event.Type = "PCAS.User.Prompt" },
+			expectFields: []string{"type"},
+		},
+		{
+			name:         "type without namespace",
+			modify:       func(event *eventsv1.Event) { event.Type = "prompt" },
+			expectFields: []string{"type"},
+		},
+		{
+			name:         "empty type segment",
+			modify:       func(event *eventsv1.Event) { event.Type = "pcas..prompt.v1" },
+			expectFields: []string{"type"},
+		},
+		{
+			name:         "data too large",
+			modify:       func(event *eventsv1.Event) { event.Data = largeData },
+			expectFields: []string{"data"},
+		},
+		{
+			name: "too many attributes",
+			modify: func(event *eventsv1.Event) {
+				event.Attributes = map[string]string{"a": "1", "b": "2", "c": "3"}
+			},
+			expectFields: []string{"attributes"},
+		},
+		{
+			name: "attribute value too long",
+			modify: func(event *eventsv1.Event) {
+				event.Attributes = map[string]string{"a": strings.Repeat("x", maxAttributeValueLength+1)}
+			},
+			expectFields: []string{"attributes"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			event := &eventsv1.Event{Id: "event-1", Type: "test.valid.v1", Source: "test", Specversion: "1.0"}
+			tc.modify(event)
+
+			_, err := s.Publish(context.Background(), event)
+			if len(tc.expectFields) == 0 {
+				if err != nil {
+					t.Fatalf("Expected the event to be accepted, got %v", err)
+				}
+				if event.Time == nil {
+					t.Error("Expected the missing time to be filled in")
+				}
+				return
+			}
+
+			st := status.Convert(err)
+			if st.Code() != codes.InvalidArgument {
+				t.Fatalf("Expected InvalidArgument, got %v", err)
+			}
+			var fields []string
+			for _, detail := range st.Details() {
+				if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+					for _, violation := range badRequest.FieldViolations {
+						fields = append(fields, violation.Field)
+					}
+				}
+			}
+			if strings.Join(fields, ",") != strings.Join(tc.expectFields, ",") {
+				t.Errorf("Expected violations of %v, got %v", tc.expectFields, fields)
+			}
+		})
+	}
+}
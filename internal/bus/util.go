@@ -11,6 +11,8 @@ func IsFactEvent(eventType string) bool {
 		"user.reminder.v1",
 		"user.task.v1",
 		"user.memory.v1",
+		"pcas.memory.summary.v1",
+		"pcas.stream.transcript.v1",
 	}
 	
 	for _, factType := range factEventTypes {
@@ -20,4 +22,20 @@ func IsFactEvent(eventType string) bool {
 	}
 	
 	return false
-}
\ No newline at end of file
+}
+// bookkeepingEventTypes are the events the server records about its own work:
+// RAG traces, budget notices and the progress of streams. They share the session
+// of the event they describe, but they are not part of its conversation, so
+// session context and summaries leave them out. Stream transcripts are facts and
+// stay in.
+var bookkeepingEventTypes = []string{
+	ragTraceEventType,
+	budgetExceededEventType,
+	streamStartedEventType,
+	streamInputEventType,
+	streamOutputEventType,
+	streamCancelledEventType,
+	streamUpdatedEventType,
+	streamEndedEventType,
+	streamErrorEventType,
+}
@@ -11,6 +11,17 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 	
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/chunking"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+const (
+	// How long one embedding request, and storing the result, may take
+	defaultVectorizeTimeout = 30 * time.Second
+
+	// Chunks sent in one request to providers that embed several texts at once
+	embeddingBatchSize = 32
 )
 
 // vectorizeEvent extracts text content from an event and stores its embedding
@@ -18,10 +29,6 @@ func (s *Server) vectorizeEvent(event *eventsv1.Event) {
 	// Ensure we decrement the wait group counter when done
 	defer s.vectorizeWG.Done()
 	
-	// Create a context with timeout for vectorization
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
-
 	// Extract text content to vectorize
 	textContent := s.extractTextContent(event)
 	if textContent == "" {
@@ -29,6 +36,21 @@ func (s *Server) vectorizeEvent(event *eventsv1.Event) {
 		return
 	}
 	
+	// Long texts such as meeting transcripts are embedded chunk by chunk, so that
+	// each passage can be found on its own
+	chunks := chunking.Split(textContent, chunking.DefaultSize, chunking.DefaultOverlap)
+	if len(chunks) > 1 {
+		if chunkStore, ok := s.storage.(storage.ChunkStorage); ok {
+			s.vectorizeChunks(event, chunks, chunkStore)
+			return
+		}
+		log.Printf("Storage does not support chunk embeddings, embedding event %s as a whole", event.Id)
+	}
+	
+	// Create a context with timeout for vectorization
+	ctx, cancel := context.WithTimeout(context.Background(), s.embeddingTimeout())
+	defer cancel()
+	
 	log.Printf("Vectorizing content for event %s (type: %s): \"%s\"", event.Id, event.Type, textContent)
 
 	// Create embedding
@@ -68,6 +90,9 @@ func (s *Server) vectorizeEvent(event *eventsv1.Event) {
 		metadata["correlation_id"] = event.CorrelationId
 	}
 
+	// Link near-duplicates of the user's existing memories to their cluster
+	s.linkDuplicate(ctx, event, embedding)
+	
 	// Store the vector as a separate node and link it to the event
 	// Note: The event has already been stored, so we just need to add the embedding
 	err = s.storage.AddEmbeddingToEvent(ctx, event.Id, embedding)
@@ -79,7 +104,107 @@ func (s *Server) vectorizeEvent(event *eventsv1.Event) {
 	log.Printf("Successfully vectorized event %s (type: %s)", event.Id, event.Type)
 }
 
-// extractTextContent extracts meaningful text from event data
+// vectorizeChunks embeds every chunk of a long event and links the chunk vectors
+// to the event. The event is left without embeddings if any chunk fails.
+func (s *Server) vectorizeChunks(event *eventsv1.Event, chunks []chunking.Chunk, chunkStore storage.ChunkStorage) {
+	log.Printf("Vectorizing %d chunks for event %s (type: %s)", len(chunks), event.Id, event.Type)
+	
+	texts := make([]string, len(chunks))
+	for i, chunk := range chunks {
+		texts[i] = chunk.Text
+	}
+	vectors, err := s.embedTexts(texts)
+	if err != nil {
+		log.Printf("Failed to create chunk embeddings for event %s: %v", event.Id, err)
+		return
+	}
+	
+	embeddings := make([]storage.ChunkEmbedding, len(chunks))
+	for i, chunk := range chunks {
+		embeddings[i] = storage.ChunkEmbedding{
+			ChunkRef:  storage.ChunkRef{Index: chunk.Index, Start: chunk.Start, End: chunk.End},
+			Embedding: vectors[i],
+		}
+	}
+	
+	ctx, cancel := context.WithTimeout(context.Background(), s.embeddingTimeout())
+	defer cancel()
+	
+	// Link near-duplicates by the mean chunk vector, which stands for the whole text
+	s.linkDuplicate(ctx, event, meanEmbedding(embeddings))
+	
+	if err := chunkStore.AddChunkEmbeddings(ctx, event.Id, embeddings); err != nil {
+		log.Printf("Failed to add chunk embeddings to event %s: %v", event.Id, err)
+		return
+	}
+	
+	log.Printf("Successfully vectorized event %s in %d chunks (type: %s)", event.Id, len(chunks), event.Type)
+}
+
+// embedTexts embeds texts in order. Every request has its own timeout, so that a
+// long transcript is not cut off by a deadline for the whole event. Providers
+// that embed several texts at once get them in batches.
+func (s *Server) embedTexts(texts []string) ([][]float32, error) {
+	batcher, batched := s.embeddingProvider.(providers.BatchEmbeddingProvider)
+	size := 1
+	if batched {
+		size = embeddingBatchSize
+	}
+	
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += size {
+		end := min(start+size, len(texts))
+		ctx, cancel := context.WithTimeout(context.Background(), s.embeddingTimeout())
+		var batch [][]float32
+		var err error
+		if batched {
+			batch, err = batcher.CreateEmbeddings(ctx, texts[start:end])
+		} else {
+			var embedding []float32
+			embedding, err = s.embeddingProvider.CreateEmbedding(ctx, texts[start])
+			batch = [][]float32{embedding}
+		}
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed chunks %d to %d: %w", start, end-1, err)
+		}
+		if len(batch) != end-start {
+			return nil, fmt.Errorf("expected %d embeddings for chunks %d to %d, got %d", end-start, start, end-1, len(batch))
+		}
+		embeddings = append(embeddings, batch...)
+	}
+	return embeddings, nil
+}
+
+// embeddingTimeout returns how long one embedding request may take
+func (s *Server) embeddingTimeout() time.Duration {
+	if s.vectorizeTimeout <= 0 {
+		return defaultVectorizeTimeout
+	}
+	return s.vectorizeTimeout
+}
+
+// meanEmbedding averages the vectors of an event's chunks
+func meanEmbedding(chunks []storage.ChunkEmbedding) []float32 {
+	if len(chunks) == 0 {
+		return nil
+	}
+	mean := make([]float32, len(chunks[0].Embedding))
+	for _, chunk := range chunks {
+		for i := range mean {
+			if i < len(chunk.Embedding) {
+				mean[i] += chunk.Embedding[i]
+			}
+		}
+	}
+	for i := range mean {
+		mean[i] /= float32(len(chunks))
+	}
+	return mean
+}
+
+// extractTextContent extracts meaningful text from event data.
+// Chunk offsets stored for long events refer to this text.
 func (s *Server) extractTextContent(event *eventsv1.Event) string {
 	// First priority: Check event.Subject
 	if event.Subject != "" {
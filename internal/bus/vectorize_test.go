@@ -1,12 +1,21 @@
 package bus
 
 import (
+	"context"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"google.golang.org/protobuf/types/known/anypb"
 	"google.golang.org/protobuf/types/known/structpb"
 	
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/chunking"
+	"github.com/soaringjerry/pcas/internal/policy"
+	"github.com/soaringjerry/pcas/internal/providers"
+	"github.com/soaringjerry/pcas/internal/storage"
+	"github.com/soaringjerry/pcas/internal/storage/sqlite"
 )
 
 func TestExtractTextContent(t *testing.T) {
@@ -175,4 +184,152 @@ func TestExtractTextContent_InvalidData(t *testing.T) {
 	if result != "" {
 		t.Errorf("expected empty string for invalid data, got %q", result)
 	}
-}
\ No newline at end of file
+}
+func TestVectorizeEvent_Chunks(t *testing.T) {
+	store, err := sqlite.NewProvider(":memory:")
+	if err != nil {
+		t.Fatalf("Failed to create storage: %v", err)
+	}
+	defer store.Close()
+
+	// A transcript with a single passage about Kyoto in the middle
+	transcript := strings.Repeat("We reviewed the quarterly numbers. ", 80) +
+		"The offsite will be in Kyoto in April. " +
+		strings.Repeat("Action items were assigned to the team. ", 80)
+	data, _ := structpb.NewValue(map[string]interface{}{"text": transcript})
+	anyData, _ := anypb.New(data)
+	event := &eventsv1.Event{
+		Id:          "meeting-1",
+		Type:        "dreamtrans.transcript.v1",
+		Source:      "test",
+		Specversion: "1.0",
+		UserId:      "user-1",
+		Data:        anyData,
+	}
+
+	ctx := context.Background()
+	if err := store.StoreEvent(ctx, event, nil); err != nil {
+		t.Fatalf("Failed to store event: %v", err)
+	}
+
+	s := NewServer(nil, nil, store)
+	s.embeddingProvider = keywordEmbeddingProvider{}
+	s.vectorizeWG.Add(1)
+	s.vectorizeEvent(event)
+
+	results, err := store.QuerySimilar(ctx, []float32{1, 0}, 5, nil)
+	if err != nil {
+		t.Fatalf("QuerySimilar failed: %v", err)
+	}
+	if len(results) != 1 || results[0].ID != "meeting-1" || results[0].Chunk == nil {
+		t.Fatalf("Expected one chunk hit aggregated to meeting-1, got %+v", results)
+	}
+	if passage := transcript[results[0].Chunk.Start:results[0].Chunk.End]; !strings.Contains(passage, "Kyoto") {
+		t.Errorf("Expected the best chunk to contain the Kyoto passage, got %q", passage)
+	}
+
+	// RAG renders the matching passage instead of the truncated transcript
+	prompt := &eventsv1.Event{Id: "prompt-1", Type: "pcas.user.prompt.v1", UserId: "user-1"}
+	requestData := map[string]interface{}{"prompt": "Where is the Kyoto offsite?"}
+	s.applyRAGEnhancement(ctx, prompt, requestData, &policy.RAG{}, nil)
+	messages, ok := requestData["messages"].([]map[string]string)
+	if !ok {
+		t.Fatalf("Expected RAG messages, got %v", requestData)
+	}
+	if system := messages[0]["content"]; !strings.Contains(system, "The offsite will be in Kyoto in April.") || !strings.Contains(system, "(excerpt") {
+		t.Errorf("Expected the Kyoto excerpt in the system message:\n%s", system)
+	}
+}
+
+// slowEmbeddingProvider takes delay for every request and counts the requests
+type slowEmbeddingProvider struct {
+	delay    time.Duration
+	requests atomic.Int32
+}
+
+func (p *slowEmbeddingProvider) CreateEmbedding(ctx context.Context, text string) ([]float32, error) {
+	p.requests.Add(1)
+	select {
+	case <-time.After(p.delay):
+		return keywordEmbeddingProvider{}.CreateEmbedding(ctx, text)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// slowBatchEmbeddingProvider embeds several texts per request
+type slowBatchEmbeddingProvider struct {
+	slowEmbeddingProvider
+}
+
+func (p *slowBatchEmbeddingProvider) CreateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	p.requests.Add(1)
+	select {
+	case <-time.After(p.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, text := range texts {
+		embeddings[i], _ = keywordEmbeddingProvider{}.CreateEmbedding(ctx, text)
+	}
+	return embeddings, nil
+}
+
+func TestVectorizeEvent_ChunksTimeout(t *testing.T) {
+	// More chunks than can be embedded one after another within one timeout
+	transcript := strings.Repeat("We reviewed the quarterly numbers for Kyoto. ", 300)
+	chunks := len(chunking.Split(transcript, chunking.DefaultSize, chunking.DefaultOverlap))
+	const delay = 20 * time.Millisecond
+
+	single := &slowEmbeddingProvider{delay: delay}
+	batch := &slowBatchEmbeddingProvider{slowEmbeddingProvider{delay: delay}}
+	testCases := []struct {
+		name             string
+		provider         providers.EmbeddingProvider
+		requests         *atomic.Int32
+		expectedRequests int32
+	}{
+		{name: "one chunk per request", provider: single, requests: &single.requests, expectedRequests: int32(chunks)},
+		{name: "batched", provider: batch, requests: &batch.requests, expectedRequests: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			store, err := sqlite.NewProvider(":memory:")
+			if err != nil {
+				t.Fatalf("Failed to create storage: %v", err)
+			}
+			defer store.Close()
+
+			event := &eventsv1.Event{
+				Id:          "meeting-1",
+				Type:        "dreamtrans.transcript.v1",
+				Source:      "test",
+				Specversion: "1.0",
+				Subject:     transcript,
+			}
+			ctx := context.Background()
+			if err := store.StoreEvent(ctx, event, nil); err != nil {
+				t.Fatalf("Failed to store event: %v", err)
+			}
+
+			s := NewServer(nil, nil, store)
+			s.embeddingProvider = tc.provider
+			s.vectorizeTimeout = time.Duration(chunks) * delay / 2
+			s.vectorizeWG.Add(1)
+			s.vectorizeEvent(event)
+
+			if requests := tc.requests.Load(); requests != tc.expectedRequests {
+				t.Errorf("Expected %d embedding requests, got %d", tc.expectedRequests, requests)
+			}
+			edges, err := store.(storage.EdgeLister).ListEdges(ctx, "chunk_of")
+			if err != nil {
+				t.Fatalf("ListEdges failed: %v", err)
+			}
+			if len(edges) != chunks {
+				t.Errorf("Expected all %d chunks to be embedded, got %d", chunks, len(edges))
+			}
+		})
+	}
+}
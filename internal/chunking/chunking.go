@@ -0,0 +1,124 @@
+// Package chunking splits long texts into overlapping chunks before embedding.
+// A long meeting transcript embedded as a single vector blurs every topic it
+// covers; embedding its chunks separately lets a search match the passage that
+// is actually relevant.
+package chunking
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultSize is the maximum chunk length in bytes. Texts up to this length
+	// are embedded as a whole.
+	DefaultSize = 2000
+
+	// DefaultOverlap is how many bytes consecutive chunks share, so that a
+	// sentence cut at a boundary is still complete in one of them
+	DefaultOverlap = 200
+)
+
+// Chunk is a piece of a text. Start and End are byte offsets into the text.
+type Chunk struct {
+	Index int
+	Start int
+	End   int
+	Text  string
+}
+
+// Split cuts text into chunks of at most size bytes that overlap by about
+// overlap bytes. Boundaries are moved back to the end of a sentence or word
+// where possible and never split a UTF-8 character. A text that fits into one
+// chunk is returned as a single chunk; an empty text returns no chunks.
+func Split(text string, size, overlap int) []Chunk {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	if overlap < 0 || overlap >= size/2 {
+		overlap = size / 10
+	}
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	if len(text) <= size {
+		return []Chunk{{Index: 0, Start: 0, End: len(text), Text: text}}
+	}
+
+	var chunks []Chunk
+	start := 0
+	for start < len(text) {
+		end := start + size
+		if end >= len(text) {
+			end = len(text)
+		} else {
+			end = boundary(text, start+size/2, end)
+		}
+
+		chunks = append(chunks, Chunk{
+			Index: len(chunks),
+			Start: start,
+			End:   end,
+			Text:  text[start:end],
+		})
+		if end == len(text) {
+			break
+		}
+
+		// Step back by the overlap, starting the next chunk at a word
+		next := end - overlap
+		if next <= start {
+			next = end
+		}
+		start = wordStart(text, next, end)
+	}
+
+	return chunks
+}
+
+// boundary returns the best cut position in text[min:max]: after the last
+// sentence end, else after the last whitespace, else the last rune boundary
+func boundary(text string, min, max int) int {
+	window := text[min:max]
+	if i := strings.LastIndexAny(window, ".!?。！？\n"); i >= 0 {
+		_, width := utf8.DecodeRuneInString(window[i:])
+		return min + i + width
+	}
+	if i := strings.LastIndexFunc(window, unicode.IsSpace); i >= 0 {
+		_, width := utf8.DecodeRuneInString(window[i:])
+		return min + i + width
+	}
+	for max > min && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return max
+}
+
+// wordStart moves pos forward to the start of the next word, without passing
+// limit, and never splits a UTF-8 character
+func wordStart(text string, pos, limit int) int {
+	for pos < limit && !utf8.RuneStart(text[pos]) {
+		pos++
+	}
+	for i := pos; i < limit; {
+		r, width := utf8.DecodeRuneInString(text[i:])
+		if unicode.IsSpace(r) {
+			// Skip the whitespace run and start at the following word
+			j := i + width
+			for j < limit {
+				r, w := utf8.DecodeRuneInString(text[j:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				j += w
+			}
+			if j < limit {
+				return j
+			}
+			break
+		}
+		i += width
+	}
+	return pos
+}
@@ -0,0 +1,74 @@
+package chunking
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplit(t *testing.T) {
+	sentence := "The team agreed to ship the release on Friday. "
+	transcript := strings.Repeat(sentence, 100)
+	cjk := strings.Repeat("今天的会议讨论了发布计划。", 100)
+
+	testCases := []struct {
+		name         string
+		text         string
+		size         int
+		overlap      int
+		expectChunks int // Zero means more than one
+	}{
+		{name: "empty", text: "  ", size: 100, overlap: 10, expectChunks: -1},
+		{name: "short text", text: "hello world", size: 100, overlap: 10, expectChunks: 1},
+		{name: "long transcript", text: transcript, size: 500, overlap: 50},
+		{name: "cjk text", text: cjk, size: 300, overlap: 30},
+		{name: "no spaces", text: strings.Repeat("x", 1000), size: 300, overlap: 30},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chunks := Split(tc.text, tc.size, tc.overlap)
+			switch {
+			case tc.expectChunks == -1:
+				if len(chunks) != 0 {
+					t.Fatalf("Expected no chunks, got %d", len(chunks))
+				}
+				return
+			case tc.expectChunks > 0 && len(chunks) != tc.expectChunks:
+				t.Fatalf("Expected %d chunks, got %d", tc.expectChunks, len(chunks))
+			case tc.expectChunks == 0 && len(chunks) < 2:
+				t.Fatalf("Expected several chunks, got %d", len(chunks))
+			}
+
+			if chunks[0].Start != 0 || chunks[len(chunks)-1].End != len(tc.text) {
+				t.Errorf("Expected chunks to cover the whole text, got %d..%d", chunks[0].Start, chunks[len(chunks)-1].End)
+			}
+			for i, chunk := range chunks {
+				if chunk.Index != i {
+					t.Errorf("Expected index %d, got %d", i, chunk.Index)
+				}
+				if chunk.End-chunk.Start > tc.size {
+					t.Errorf("Chunk %d is %d bytes, above the size of %d", i, chunk.End-chunk.Start, tc.size)
+				}
+				if chunk.Text != tc.text[chunk.Start:chunk.End] {
+					t.Errorf("Chunk %d text does not match its offsets", i)
+				}
+				if !utf8.ValidString(chunk.Text) {
+					t.Errorf("Chunk %d splits a UTF-8 character", i)
+				}
+				if i > 0 && chunk.Start >= chunks[i-1].End {
+					t.Errorf("Chunk %d does not overlap the previous chunk", i)
+				}
+			}
+		})
+	}
+}
+
+func TestSplit_SentenceBoundaries(t *testing.T) {
+	text := strings.Repeat("Alpha beta gamma delta. ", 50)
+	for _, chunk := range Split(text, 200, 20)[:2] {
+		if !strings.HasSuffix(strings.TrimSpace(chunk.Text), ".") {
+			t.Errorf("Expected chunk to end at a sentence, got %q", chunk.Text)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package policy
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"gopkg.in/yaml.v3"
 	
@@ -14,13 +15,179 @@ type Policy struct {
 	Version   string           `yaml:"version"`
 	Providers []ProviderConfig `yaml:"providers"`
 	Rules     []Rule          `yaml:"rules"`
+	Budgets   []Budget         `yaml:"budgets,omitempty"`
+	Memory    *Memory          `yaml:"memory,omitempty"`
+	
+	// Limits of every InteractStream; rules can override them
+	StreamLimits *StreamLimits `yaml:"stream_limits,omitempty"`
+	
+	// How published events are accepted
+	Publish *Publish `yaml:"publish,omitempty"`
+}
+
+// Memory configures how the server maintains the user's long-term memory
+type Memory struct {
+	Deduplication *Deduplication `yaml:"deduplication,omitempty"` // Detect and collapse repeated facts
+	Summarization *Summarization `yaml:"summarization,omitempty"` // Summarize idle sessions into memory facts
+}
+
+// Validate checks the memory configuration for invalid values
+func (m *Memory) Validate() error {
+	if m.Deduplication != nil {
+		if err := m.Deduplication.Validate(); err != nil {
+			return err
+		}
+	}
+	if m.Summarization != nil {
+		if err := m.Summarization.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Deduplication configures near-duplicate detection at vectorization time. A new
+// event whose embedding is at least as similar as the threshold to one of the
+// user's existing vectors is linked to it with a duplicate_of edge, and search and
+// RAG results show each cluster once.
+type Deduplication struct {
+	Threshold             float32       `yaml:"threshold,omitempty"`              // Minimum similarity of a duplicate (default 0.95)
+	ConsolidationInterval time.Duration `yaml:"consolidation_interval,omitempty"` // How often clusters are merged into a canonical memory (zero disables)
+	MinClusterSize        int           `yaml:"min_cluster_size,omitempty"`       // Events a cluster needs before it is merged (default 3)
+}
+
+// Validate checks the deduplication configuration for invalid values
+func (d *Deduplication) Validate() error {
+	if d.Threshold < 0 || d.Threshold > 1 {
+		return fmt.Errorf("deduplication threshold must be between 0 and 1, got %v", d.Threshold)
+	}
+	if d.ConsolidationInterval < 0 {
+		return fmt.Errorf("deduplication consolidation_interval must not be negative, got %v", d.ConsolidationInterval)
+	}
+	if d.MinClusterSize < 0 || d.MinClusterSize == 1 {
+		return fmt.Errorf("deduplication min_cluster_size must be at least 2, got %d", d.MinClusterSize)
+	}
+	return nil
+}
+
+// Summarization configures the background job that compacts idle sessions. Once
+// a session has been quiet for idle_after, a compute provider summarizes its new
+// events into a pcas.memory.summary.v1 fact, which RAG then prefers over the raw
+// turns.
+type Summarization struct {
+	Provider  string        `yaml:"provider"`             // Compute provider that writes the summaries
+	IdleAfter time.Duration `yaml:"idle_after,omitempty"` // Inactivity after which a session is summarized (default 30m)
+	Interval  time.Duration `yaml:"interval,omitempty"`   // How often idle sessions are looked for (default 5m)
+	Lookback  time.Duration `yaml:"lookback,omitempty"`   // How far back sessions are looked for (default 24h)
+	MinEvents int           `yaml:"min_events,omitempty"` // New events a session needs before it is summarized (default 4)
+	MaxEvents int           `yaml:"max_events,omitempty"` // Most recent events included in a summary (default 200)
+	Timeout   time.Duration `yaml:"timeout,omitempty"`    // Time limit of a summary request (default 60s)
+}
+
+// Validate checks the summarization configuration for invalid values
+func (s *Summarization) Validate() error {
+	if s.Provider == "" {
+		return fmt.Errorf("memory summarization requires a provider")
+	}
+	if s.IdleAfter < 0 || s.Interval < 0 || s.Lookback < 0 || s.Timeout < 0 {
+		return fmt.Errorf("memory summarization durations must not be negative")
+	}
+	if s.MinEvents < 0 || s.MaxEvents < 0 {
+		return fmt.Errorf("memory summarization event counts must not be negative")
+	}
+	if s.MaxEvents > 0 && s.MaxEvents < s.MinEvents {
+		return fmt.Errorf("memory summarization max_events (%d) must not be below min_events (%d)", s.MaxEvents, s.MinEvents)
+	}
+	return nil
+}
+
+// StreamLimits bounds the resources a single InteractStream can hold, so that a
+// misbehaving client cannot keep a provider stream open forever. Zero fields of
+// a rule's limits keep the global value.
+type StreamLimits struct {
+	IdleTimeout    time.Duration `yaml:"idle_timeout,omitempty"`     // Ends a stream without input or output for this long (default 5m)
+	MaxDuration    time.Duration `yaml:"max_duration,omitempty"`     // Ends a stream that has run this long (default 1h)
+	MaxInputBytes  int64         `yaml:"max_input_bytes,omitempty"`  // Input bytes a stream may receive (zero means no limit)
+	MaxInputChunks int64         `yaml:"max_input_chunks,omitempty"` // Input chunks a stream may receive (zero means no limit)
+}
+
+// Validate checks the stream limits for invalid values
+func (l *StreamLimits) Validate() error {
+	if l.IdleTimeout < 0 || l.MaxDuration < 0 {
+		return fmt.Errorf("stream limit durations must not be negative")
+	}
+	if l.MaxInputBytes < 0 || l.MaxInputChunks < 0 {
+		return fmt.Errorf("stream input limits must not be negative")
+	}
+	return nil
+}
+
+// merge returns the limits with the non-zero fields of override applied
+func (l StreamLimits) merge(override *StreamLimits) StreamLimits {
+	if override == nil {
+		return l
+	}
+	if override.IdleTimeout > 0 {
+		l.IdleTimeout = override.IdleTimeout
+	}
+	if override.MaxDuration > 0 {
+		l.MaxDuration = override.MaxDuration
+	}
+	if override.MaxInputBytes > 0 {
+		l.MaxInputBytes = override.MaxInputBytes
+	}
+	if override.MaxInputChunks > 0 {
+		l.MaxInputChunks = override.MaxInputChunks
+	}
+	return l
+}
+
+// Publish configures how the server accepts published events
+type Publish struct {
+	IdempotencyWindow time.Duration `yaml:"idempotency_window,omitempty"` // How long a published event ID is remembered in memory to detect retries (default 10m)
+	ProcessingTimeout time.Duration `yaml:"processing_timeout,omitempty"` // How long processing one event may take before its providers are cancelled (default 2m)
+	MaxDataBytes      int           `yaml:"max_data_bytes,omitempty"`     // Largest encoded data payload of an event (default 1MiB)
+	MaxAttributes     int           `yaml:"max_attributes,omitempty"`     // Most attributes an event may carry (default 64)
+}
+
+// Validate checks the publish configuration for invalid values
+func (p *Publish) Validate() error {
+	if p.IdempotencyWindow < 0 {
+		return fmt.Errorf("publish idempotency_window must not be negative")
+	}
+	if p.ProcessingTimeout < 0 {
+		return fmt.Errorf("publish processing_timeout must not be negative")
+	}
+	if p.MaxDataBytes < 0 || p.MaxAttributes < 0 {
+		return fmt.Errorf("publish size limits must not be negative")
+	}
+	return nil
 }
 
 // ProviderConfig represents a provider configuration
 type ProviderConfig struct {
-	Name string                 `yaml:"name"`
-	Type string                 `yaml:"type"`
-	Config map[string]interface{} `yaml:",inline"`
+	Name           string                 `yaml:"name"`
+	Type           string                 `yaml:"type"`
+	CircuitBreaker *CircuitBreakerConfig  `yaml:"circuit_breaker,omitempty"`
+	Pricing        *Pricing               `yaml:"pricing,omitempty"`
+	ContextWindow  int                    `yaml:"context_window,omitempty"` // Maximum tokens of prompt and completion (zero means unknown)
+	Tokenizer      string                 `yaml:"tokenizer,omitempty"`      // Model or encoding used to count tokens, e.g. "gpt-4o" or "cl100k_base"
+	Config         map[string]interface{} `yaml:",inline"`
+}
+
+// Pricing is the cost of a provider per 1000 tokens
+type Pricing struct {
+	PromptPer1K     float64 `yaml:"prompt_per_1k"`
+	CompletionPer1K float64 `yaml:"completion_per_1k"`
+}
+
+// CircuitBreakerConfig overrides the circuit breaker defaults for a provider
+type CircuitBreakerConfig struct {
+	Disabled            bool          `yaml:"disabled,omitempty"`
+	FailureThreshold    int           `yaml:"failure_threshold,omitempty"`
+	OpenTimeout         time.Duration `yaml:"open_timeout,omitempty"`
+	HalfOpenMaxRequests int           `yaml:"half_open_max_requests,omitempty"`
+	ErrorRateThreshold  float64       `yaml:"error_rate_threshold,omitempty"`
 }
 
 // Rule represents a single policy rule
@@ -30,6 +197,64 @@ type Rule struct {
 	Then Action    `yaml:"then"`
 }
 
+// Budget scopes
+const (
+	BudgetScopeUser   = "user"   // Usage is accounted per user_id
+	BudgetScopeSource = "source" // Usage is accounted per event source (dApp)
+)
+
+// Budget periods
+const (
+	BudgetPeriodDaily   = "daily"
+	BudgetPeriodMonthly = "monthly"
+)
+
+// Budget actions
+const (
+	BudgetActionReject    = "reject"    // Reject the event
+	BudgetActionDowngrade = "downgrade" // Use DowngradeTo instead of the exhausted provider
+)
+
+// Budget caps the token usage or cost of a user or dApp over a period
+type Budget struct {
+	Name        string   `yaml:"name"`
+	Scope       string   `yaml:"scope"`                  // user or source
+	Match       string   `yaml:"match,omitempty"`        // Only apply to this user_id/source (empty means each one separately)
+	Providers   []string `yaml:"providers,omitempty"`    // Providers counted by this budget (empty means all)
+	Period      string   `yaml:"period"`                 // daily or monthly
+	MaxTokens   int64    `yaml:"max_tokens,omitempty"`   // Zero means no token limit
+	MaxCost     float64  `yaml:"max_cost,omitempty"`     // Zero means no cost limit
+	OnExceeded  string   `yaml:"on_exceeded,omitempty"`  // reject (default) or downgrade
+	DowngradeTo string   `yaml:"downgrade_to,omitempty"` // Provider used when downgrading
+}
+
+// Validate checks the budget configuration for consistency
+func (b *Budget) Validate() error {
+	switch b.Scope {
+	case BudgetScopeUser, BudgetScopeSource:
+	default:
+		return fmt.Errorf("unknown budget scope: %q", b.Scope)
+	}
+	switch b.Period {
+	case BudgetPeriodDaily, BudgetPeriodMonthly:
+	default:
+		return fmt.Errorf("unknown budget period: %q", b.Period)
+	}
+	if b.MaxTokens <= 0 && b.MaxCost <= 0 {
+		return fmt.Errorf("budget needs max_tokens or max_cost")
+	}
+	switch b.OnExceeded {
+	case "", BudgetActionReject:
+	case BudgetActionDowngrade:
+		if b.DowngradeTo == "" {
+			return fmt.Errorf("downgrade budget needs downgrade_to")
+		}
+	default:
+		return fmt.Errorf("unknown budget action: %q", b.OnExceeded)
+	}
+	return nil
+}
+
 // Condition represents the condition part of a rule
 type Condition struct {
 	EventType string      `yaml:"event_type"`
@@ -38,13 +263,223 @@ type Condition struct {
 
 // Action represents the action part of a rule
 type Action struct {
-	Provider       string `yaml:"provider"`
-	PromptTemplate string `yaml:"prompt_template,omitempty"`
+	Provider       string        `yaml:"provider"`
+	Fallbacks      []string      `yaml:"fallbacks,omitempty"` // Ordered providers to try when the primary fails
+	PromptTemplate string        `yaml:"prompt_template,omitempty"`
+	FanOut         *FanOut       `yaml:"fan_out,omitempty"`       // Send the event to several providers in parallel
+	Cache          *Cache        `yaml:"cache,omitempty"`         // Opt-in response cache for this rule
+	RAG            *RAG          `yaml:"rag,omitempty"`           // Retrieval-augmented generation settings for this rule
+	StreamLimits   *StreamLimits `yaml:"stream_limits,omitempty"` // Overrides the global stream limits for this rule
+}
+
+// RAG configures how a rule enriches requests with relevant historical events
+type RAG struct {
+	Enabled        *bool         `yaml:"enabled,omitempty"`         // Defaults to true when the block is present
+	TopK           int           `yaml:"top_k,omitempty"`           // Number of similar events to retrieve (default 5)
+	ScoreThreshold float32       `yaml:"score_threshold,omitempty"` // Minimum similarity score (default 0.4)
+	TimeWindow     time.Duration `yaml:"time_window,omitempty"`     // Only consider events this recent (zero means no limit)
+	MaxTokens      int           `yaml:"max_tokens,omitempty"`      // Token budget for the injected context (default 4000)
+	SessionEvents  int           `yaml:"session_events,omitempty"`  // Recent events of the current session to include (default 10, -1 disables)
+	Preferences    *bool         `yaml:"preferences,omitempty"`     // Include stored user preferences (default true)
+	Filters        RAGFilters    `yaml:"filters,omitempty"`
+	Ranking        *Ranking      `yaml:"ranking,omitempty"` // Re-rank memories by recency, importance and diversity
+	Rewrite        *QueryRewrite `yaml:"rewrite,omitempty"` // Rewrite follow-ups into standalone search queries
+}
+
+// QueryRewrite configures the optional query rewriting step of RAG. A compute
+// provider turns the latest message and the recent session into standalone
+// search queries, whose results are merged. If the provider fails, the verbatim
+// query is used.
+type QueryRewrite struct {
+	Provider      string        `yaml:"provider"`                 // Compute provider that writes the queries
+	MaxQueries    int           `yaml:"max_queries,omitempty"`    // Standalone queries to search for (default 3)
+	SessionEvents int           `yaml:"session_events,omitempty"` // Recent session events shown to the provider (default 6)
+	Timeout       time.Duration `yaml:"timeout,omitempty"`        // Time allowed for the rewrite (default 5s)
+}
+
+// Ranking configures how retrieved memories are re-ranked after the similarity
+// search. The blended score is (1 - recency_weight - importance_weight) * similarity
+// + recency_weight * recency + importance_weight * importance.
+type Ranking struct {
+	RecencyWeight    float64       `yaml:"recency_weight,omitempty"`    // Weight of the time decay (0 to 1)
+	HalfLife         time.Duration `yaml:"half_life,omitempty"`         // Age at which recency has halved (default 168h)
+	ImportanceWeight float64       `yaml:"importance_weight,omitempty"` // Weight of the importance attribute and user feedback (0 to 1)
+	MMRLambda        float64       `yaml:"mmr_lambda,omitempty"`        // Relevance vs. diversity trade-off (0 disables MMR)
+}
+
+// RAGFilters restricts which events may be retrieved as context
+type RAGFilters struct {
+	EventTypes  []string          `yaml:"event_types,omitempty"`  // Only retrieve these event types
+	Attributes  map[string]string `yaml:"attributes,omitempty"`   // Exact attribute matches (AND logic)
+	SameSession bool              `yaml:"same_session,omitempty"` // Only retrieve events from the event's session
+	AllUsers    bool              `yaml:"all_users,omitempty"`    // Search beyond the event's user
+}
+
+// IsEnabled reports whether retrieval should run for the rule
+func (r *RAG) IsEnabled() bool {
+	return r != nil && (r.Enabled == nil || *r.Enabled)
+}
+
+// IncludePreferences reports whether stored user preferences are added to the prompt
+func (r *RAG) IncludePreferences() bool {
+	return r.Preferences == nil || *r.Preferences
+}
+
+// Validate checks the RAG configuration for invalid values
+func (r *RAG) Validate() error {
+	if r.TopK < 0 {
+		return fmt.Errorf("rag top_k must not be negative, got %d", r.TopK)
+	}
+	if r.ScoreThreshold < 0 || r.ScoreThreshold > 1 {
+		return fmt.Errorf("rag score_threshold must be between 0 and 1, got %v", r.ScoreThreshold)
+	}
+	if r.TimeWindow < 0 {
+		return fmt.Errorf("rag time_window must not be negative, got %v", r.TimeWindow)
+	}
+	if r.MaxTokens < 0 {
+		return fmt.Errorf("rag max_tokens must not be negative, got %d", r.MaxTokens)
+	}
+	if r.SessionEvents < -1 {
+		return fmt.Errorf("rag session_events must be -1 or more, got %d", r.SessionEvents)
+	}
+	if r.Ranking != nil {
+		if err := r.Ranking.Validate(); err != nil {
+			return err
+		}
+	}
+	if r.Rewrite != nil {
+		if err := r.Rewrite.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Validate checks the query rewrite configuration for invalid values
+func (q *QueryRewrite) Validate() error {
+	if q.Provider == "" {
+		return fmt.Errorf("rag rewrite requires a provider")
+	}
+	if q.MaxQueries < 0 {
+		return fmt.Errorf("rag rewrite max_queries must not be negative, got %d", q.MaxQueries)
+	}
+	if q.SessionEvents < 0 {
+		return fmt.Errorf("rag rewrite session_events must not be negative, got %d", q.SessionEvents)
+	}
+	if q.Timeout < 0 {
+		return fmt.Errorf("rag rewrite timeout must not be negative, got %v", q.Timeout)
+	}
+	return nil
+}
+
+// Validate checks the ranking weights for invalid values
+func (r *Ranking) Validate() error {
+	if r.RecencyWeight < 0 || r.RecencyWeight > 1 {
+		return fmt.Errorf("rag ranking recency_weight must be between 0 and 1, got %v", r.RecencyWeight)
+	}
+	if r.ImportanceWeight < 0 || r.ImportanceWeight > 1 {
+		return fmt.Errorf("rag ranking importance_weight must be between 0 and 1, got %v", r.ImportanceWeight)
+	}
+	if r.RecencyWeight+r.ImportanceWeight > 1 {
+		return fmt.Errorf("rag ranking recency_weight and importance_weight must not add up to more than 1")
+	}
+	if r.HalfLife < 0 {
+		return fmt.Errorf("rag ranking half_life must not be negative, got %v", r.HalfLife)
+	}
+	if r.MMRLambda < 0 || r.MMRLambda > 1 {
+		return fmt.Errorf("rag ranking mmr_lambda must be between 0 and 1, got %v", r.MMRLambda)
+	}
+	return nil
+}
+
+// Cache configures the provider response cache of a rule
+type Cache struct {
+	TTL           time.Duration `yaml:"ttl,omitempty"`             // How long a response is served from cache (default 1h)
+	MaxEntries    int           `yaml:"max_entries,omitempty"`     // Entries kept per provider (default 1000)
+	MaxEntryBytes int           `yaml:"max_entry_bytes,omitempty"` // Larger responses are not cached (zero means no limit)
+}
+
+// Fan-out strategies
+const (
+	FanOutStrategyAll          = "all"           // Wait for every provider to finish
+	FanOutStrategyFirstSuccess = "first_success" // Stop at the first successful provider
+	FanOutStrategyQuorum       = "quorum"        // Stop once Quorum providers succeeded
+)
+
+// Fan-out emit modes
+const (
+	FanOutEmitPerProvider = "per_provider" // One pcas.response.v1 per successful provider
+	FanOutEmitAggregate   = "aggregate"    // A single pcas.response.v1 listing every answer
+)
+
+// FanOut configures parallel execution of one event across several providers
+type FanOut struct {
+	Targets  []FanOutTarget `yaml:"targets"`
+	Strategy string         `yaml:"strategy,omitempty"` // all (default), first_success or quorum
+	Quorum   int            `yaml:"quorum,omitempty"`   // Required successes for the quorum strategy
+	Emit     string         `yaml:"emit,omitempty"`     // per_provider (default) or aggregate
+	Timeout  time.Duration  `yaml:"timeout,omitempty"`  // Default per-provider timeout
+}
+
+// FanOutTarget is a single provider in a fan-out
+type FanOutTarget struct {
+	Provider string        `yaml:"provider"`
+	Timeout  time.Duration `yaml:"timeout,omitempty"` // Overrides FanOut.Timeout for this provider
+}
+
+// Validate checks the fan-out configuration for consistency
+func (f *FanOut) Validate() error {
+	if len(f.Targets) == 0 {
+		return fmt.Errorf("fan_out requires at least one target")
+	}
+	for _, target := range f.Targets {
+		if target.Provider == "" {
+			return fmt.Errorf("fan_out target is missing a provider")
+		}
+	}
+	
+	switch f.Strategy {
+	case "", FanOutStrategyAll, FanOutStrategyFirstSuccess:
+	case FanOutStrategyQuorum:
+		if f.Quorum <= 0 || f.Quorum > len(f.Targets) {
+			return fmt.Errorf("fan_out quorum must be between 1 and %d, got %d", len(f.Targets), f.Quorum)
+		}
+	default:
+		return fmt.Errorf("unknown fan_out strategy: %s", f.Strategy)
+	}
+	
+	switch f.Emit {
+	case "", FanOutEmitPerProvider, FanOutEmitAggregate:
+	default:
+		return fmt.Errorf("unknown fan_out emit mode: %s", f.Emit)
+	}
+	
+	return nil
+}
+
+// ProviderChain returns the primary provider followed by its fallbacks, in order
+func (a *Action) ProviderChain() []string {
+	chain := make([]string, 0, 1+len(a.Fallbacks))
+	seen := make(map[string]bool)
+	for _, name := range append([]string{a.Provider}, a.Fallbacks...) {
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		chain = append(chain, name)
+	}
+	return chain
+}
+
+// HealthChecker reports whether a provider is currently able to serve requests
+type HealthChecker interface {
+	IsHealthy(provider string) bool
 }
 
 // Engine is the policy evaluation engine
 type Engine struct {
 	policy *Policy
+	health HealthChecker
 }
 
 // NewEngine creates a new policy engine with the given policy
@@ -54,6 +489,31 @@ func NewEngine(policy *Policy) *Engine {
 	}
 }
 
+// SetHealthChecker sets the health checker used to skip unhealthy providers
+func (e *Engine) SetHealthChecker(health HealthChecker) {
+	e.health = health
+}
+
+// HealthyProviders filters the chain down to the providers that are currently healthy,
+// preserving order. If every provider is unhealthy the full chain is returned so that
+// the request still gets a chance to probe a recovering provider.
+func (e *Engine) HealthyProviders(chain []string) []string {
+	if e.health == nil {
+		return chain
+	}
+	
+	healthy := make([]string, 0, len(chain))
+	for _, name := range chain {
+		if e.health.IsHealthy(name) {
+			healthy = append(healthy, name)
+		}
+	}
+	if len(healthy) == 0 {
+		return chain
+	}
+	return healthy
+}
+
 // LoadPolicy loads a policy from a YAML file
 func LoadPolicy(path string) (*Policy, error) {
 	data, err := os.ReadFile(path)
@@ -65,52 +525,112 @@ func LoadPolicy(path string) (*Policy, error) {
 	if err := yaml.Unmarshal(data, &policy); err != nil {
 		return nil, fmt.Errorf("failed to parse policy file: %w", err)
 	}
+	
+	for _, budget := range policy.Budgets {
+		if err := budget.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid budget %q: %w", budget.Name, err)
+		}
+	}
+	
+	if policy.Memory != nil {
+		if err := policy.Memory.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid memory configuration: %w", err)
+		}
+	}
+	
+	if policy.StreamLimits != nil {
+		if err := policy.StreamLimits.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid stream limits: %w", err)
+		}
+	}
+	
+	if policy.Publish != nil {
+		if err := policy.Publish.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid publish configuration: %w", err)
+		}
+	}
+	
+	for _, rule := range policy.Rules {
+		if rule.Then.StreamLimits != nil {
+			if err := rule.Then.StreamLimits.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule %q: %w", rule.Name, err)
+			}
+		}
+		if rule.Then.FanOut != nil {
+			if err := rule.Then.FanOut.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule %q: %w", rule.Name, err)
+			}
+		}
+		if rule.Then.RAG != nil {
+			if err := rule.Then.RAG.Validate(); err != nil {
+				return nil, fmt.Errorf("invalid rule %q: %w", rule.Name, err)
+			}
+		}
+	}
 
 	return &policy, nil
 }
 
+// ProviderConfig returns the configuration of the named provider, or nil if unknown
+func (e *Engine) ProviderConfig(name string) *ProviderConfig {
+	for i := range e.policy.Providers {
+		if e.policy.Providers[i].Name == name {
+			return &e.policy.Providers[i]
+		}
+	}
+	return nil
+}
+
 // SelectProvider selects a provider based on the event type
 func (e *Engine) SelectProvider(event *eventsv1.Event) (string, string) {
-	for _, rule := range e.policy.Rules {
-		// Step 1: Check direct event_type match (backward compatibility)
-		if rule.If.EventType != "" && rule.If.EventType == event.Type {
-			return rule.Then.Provider, rule.Then.PromptTemplate
-		}
-		
-		// Step 2: Check any_of conditions
-		if len(rule.If.AnyOf) > 0 {
-			for _, condition := range rule.If.AnyOf {
-				if condition.EventType == event.Type {
-					return rule.Then.Provider, rule.Then.PromptTemplate
-				}
-			}
-		}
+	action := e.SelectAction(event.Type)
+	if action == nil {
+		return "", ""
 	}
-	
-	// Return empty string if no matching rule found
-	return "", ""
+	return action.Provider, action.PromptTemplate
 }
 
 // SelectProviderForStream selects a provider for streaming based on the event type
 func (e *Engine) SelectProviderForStream(eventType string) (string, string) {
 	// For now, use the same logic as SelectProvider
 	// In the future, we might want to add specific streaming provider configuration
-	for _, rule := range e.policy.Rules {
+	action := e.SelectAction(eventType)
+	if action == nil {
+		return "", ""
+	}
+	return action.Provider, action.PromptTemplate
+}
+
+// StreamLimits returns the limits of streams with the event type: the global
+// limits, overridden by those of the matching rule. Unset limits are zero.
+func (e *Engine) StreamLimits(eventType string) StreamLimits {
+	var limits StreamLimits
+	limits = limits.merge(e.policy.StreamLimits)
+	if action := e.SelectAction(eventType); action != nil {
+		limits = limits.merge(action.StreamLimits)
+	}
+	return limits
+}
+
+// SelectAction returns the action of the first rule matching the event type,
+// or nil if no rule matches
+func (e *Engine) SelectAction(eventType string) *Action {
+	for i := range e.policy.Rules {
+		rule := &e.policy.Rules[i]
+		
 		// Step 1: Check direct event_type match (backward compatibility)
 		if rule.If.EventType != "" && rule.If.EventType == eventType {
-			return rule.Then.Provider, rule.Then.PromptTemplate
+			return &rule.Then
 		}
 		
 		// Step 2: Check any_of conditions
-		if len(rule.If.AnyOf) > 0 {
-			for _, condition := range rule.If.AnyOf {
-				if condition.EventType == eventType {
-					return rule.Then.Provider, rule.Then.PromptTemplate
-				}
+		for _, condition := range rule.If.AnyOf {
+			if condition.EventType == eventType {
+				return &rule.Then
 			}
 		}
 	}
 	
-	// Return empty string if no matching rule found
-	return "", ""
-}
\ No newline at end of file
+	// No matching rule found
+	return nil
+}
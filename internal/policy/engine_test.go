@@ -2,6 +2,7 @@ package policy
 
 import (
 	"testing"
+	"time"
 
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
 )
@@ -124,3 +125,204 @@ func TestSelectProvider_AnyOf(t *testing.T) {
 	}
 }
 
+
+func TestSelectAction_Fallbacks(t *testing.T) {
+	engine := NewEngine(&Policy{
+		Rules: []Rule{
+			{
+				Name: "Rule with fallbacks",
+				If:   Condition{EventType: "pcas.user.prompt.v1"},
+				Then: Action{
+					Provider:  "openai-gpt4",
+					Fallbacks: []string{"ollama-llama3", "mock-provider", "openai-gpt4"},
+				},
+			},
+		},
+	})
+
+	action := engine.SelectAction("pcas.user.prompt.v1")
+	if action == nil {
+		t.Fatal("expected a matching action")
+	}
+
+	// Duplicates are dropped while preserving order
+	expected := []string{"openai-gpt4", "ollama-llama3", "mock-provider"}
+	chain := action.ProviderChain()
+	if len(chain) != len(expected) {
+		t.Fatalf("expected chain %v, got %v", expected, chain)
+	}
+	for i := range expected {
+		if chain[i] != expected[i] {
+			t.Errorf("expected chain %v, got %v", expected, chain)
+			break
+		}
+	}
+
+	if engine.SelectAction("unknown.event.type") != nil {
+		t.Error("expected nil action for unmatched event type")
+	}
+}
+
+func TestRAG_Validate(t *testing.T) {
+	disabled := false
+
+	testCases := []struct {
+		name          string
+		rag           *RAG
+		expectEnabled bool
+		expectError   bool
+	}{
+		{
+			name:          "empty block uses defaults",
+			rag:           &RAG{},
+			expectEnabled: true,
+		},
+		{
+			name:          "explicitly disabled",
+			rag:           &RAG{Enabled: &disabled},
+			expectEnabled: false,
+		},
+		{
+			name:          "score threshold out of range",
+			rag:           &RAG{ScoreThreshold: 1.5},
+			expectEnabled: true,
+			expectError:   true,
+		},
+		{
+			name:          "negative top_k",
+			rag:           &RAG{TopK: -1},
+			expectEnabled: true,
+			expectError:   true,
+		},
+		{
+			name:          "ranking weights",
+			rag:           &RAG{Ranking: &Ranking{RecencyWeight: 0.3, ImportanceWeight: 0.2, HalfLife: 72 * time.Hour, MMRLambda: 0.7}},
+			expectEnabled: true,
+		},
+		{
+			name:          "rewrite without provider",
+			rag:           &RAG{Rewrite: &QueryRewrite{MaxQueries: 2}},
+			expectEnabled: true,
+			expectError:   true,
+		},
+		{
+			name:          "ranking weights above one",
+			rag:           &RAG{Ranking: &Ranking{RecencyWeight: 0.7, ImportanceWeight: 0.5}},
+			expectEnabled: true,
+			expectError:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if enabled := tc.rag.IsEnabled(); enabled != tc.expectEnabled {
+				t.Errorf("expected enabled = %v, got %v", tc.expectEnabled, enabled)
+			}
+			err := tc.rag.Validate()
+			if tc.expectError && err == nil {
+				t.Error("expected validation error, got nil")
+			} else if !tc.expectError && err != nil {
+				t.Errorf("unexpected validation error: %v", err)
+			}
+		})
+	}
+
+	var missing *RAG
+	if missing.IsEnabled() {
+		t.Error("expected nil RAG config to be disabled")
+	}
+}
+
+func TestMemory_Validate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		memory      *Memory
+		expectError bool
+	}{
+		{
+			name:   "deduplication and summarization",
+			memory: &Memory{Deduplication: &Deduplication{Threshold: 0.9, ConsolidationInterval: time.Hour}, Summarization: &Summarization{Provider: "ollama"}},
+		},
+		{
+			name:        "threshold above one",
+			memory:      &Memory{Deduplication: &Deduplication{Threshold: 1.2}},
+			expectError: true,
+		},
+		{
+			name:        "summarization without provider",
+			memory:      &Memory{Summarization: &Summarization{IdleAfter: time.Hour}},
+			expectError: true,
+		},
+		{
+			name:        "max events below min events",
+			memory:      &Memory{Summarization: &Summarization{Provider: "ollama", MinEvents: 10, MaxEvents: 5}},
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.memory.Validate()
+			if tc.expectError && err == nil {
+				t.Error("expected validation error, got nil")
+			} else if !tc.expectError && err != nil {
+				t.Errorf("unexpected validation error: %v", err)
+			}
+		})
+	}
+}
+
+func TestEngine_StreamLimits(t *testing.T) {
+	engine := NewEngine(&Policy{
+		StreamLimits: &StreamLimits{IdleTimeout: time.Minute, MaxDuration: time.Hour, MaxInputBytes: 1 << 20},
+		Rules: []Rule{
+			{
+				Name: "long dictation",
+				If:   Condition{EventType: "dapp.dictation.stream.v1"},
+				Then: Action{Provider: "whisper", StreamLimits: &StreamLimits{MaxDuration: 4 * time.Hour, MaxInputChunks: 10000}},
+			},
+			{
+				Name: "translation",
+				If:   Condition{EventType: "dapp.translate.stream.v1"},
+				Then: Action{Provider: "openai"},
+			},
+		},
+	})
+
+	testCases := []struct {
+		name      string
+		eventType string
+		expected  StreamLimits
+	}{
+		{
+			name:      "rule overrides global limits",
+			eventType: "dapp.dictation.stream.v1",
+			expected:  StreamLimits{IdleTimeout: time.Minute, MaxDuration: 4 * time.Hour, MaxInputBytes: 1 << 20, MaxInputChunks: 10000},
+		},
+		{
+			name:      "rule without limits",
+			eventType: "dapp.translate.stream.v1",
+			expected:  StreamLimits{IdleTimeout: time.Minute, MaxDuration: time.Hour, MaxInputBytes: 1 << 20},
+		},
+		{
+			name:      "no matching rule",
+			eventType: "dapp.unknown.v1",
+			expected:  StreamLimits{IdleTimeout: time.Minute, MaxDuration: time.Hour, MaxInputBytes: 1 << 20},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if limits := engine.StreamLimits(tc.eventType); limits != tc.expected {
+				t.Errorf("expected %+v, got %+v", tc.expected, limits)
+			}
+		})
+	}
+
+	if err := (&StreamLimits{IdleTimeout: -time.Second}).Validate(); err == nil {
+		t.Error("expected a negative idle timeout to be invalid")
+	}
+	if err := (&StreamLimits{MaxInputChunks: -1}).Validate(); err == nil {
+		t.Error("expected a negative chunk limit to be invalid")
+	}
+}
@@ -8,4 +8,12 @@ import (
 type EmbeddingProvider interface {
 	// CreateEmbedding converts text into a vector embedding
 	CreateEmbedding(ctx context.Context, text string) ([]float32, error)
-}
\ No newline at end of file
+}
+
+// BatchEmbeddingProvider is implemented by embedding providers that convert
+// several texts in one request
+type BatchEmbeddingProvider interface {
+	EmbeddingProvider
+	// CreateEmbeddings converts texts into vector embeddings, in input order
+	CreateEmbeddings(ctx context.Context, texts []string) ([][]float32, error)
+}
@@ -3,25 +3,29 @@ package providers
 import (
 	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // Standard provider errors
 var (
 	// ErrProviderUnavailable indicates the provider service is unreachable or down
 	ErrProviderUnavailable = errors.New("provider service is unavailable")
-	
+
 	// ErrInvalidInput indicates the input provided to the provider is invalid
 	ErrInvalidInput = errors.New("invalid input provided to provider")
-	
+
 	// ErrTimeout indicates the provider operation timed out
 	ErrTimeout = errors.New("provider operation timed out")
-	
+
 	// ErrRateLimited indicates the provider is rate limiting requests
 	ErrRateLimited = errors.New("provider rate limit exceeded")
-	
+
 	// ErrUnauthorized indicates authentication/authorization failed
 	ErrUnauthorized = errors.New("provider authentication failed")
-	
+
 	// ErrInternalError indicates an internal error in the provider
 	ErrInternalError = errors.New("provider internal error")
 )
@@ -32,4 +36,75 @@ func WrapProviderError(standardErr error, providerErr error) error {
 		return standardErr
 	}
 	return fmt.Errorf("%w: %v", standardErr, providerErr)
-}
\ No newline at end of file
+}
+
+// IsFailoverError reports whether err indicates that the request may succeed on
+// a different provider. Besides outages, timeouts and rate limits, this covers
+// authentication and internal errors: both are faults of the provider (its
+// credentials or its service), not of the request, so another provider can
+// still serve it. Invalid input and unclassified errors are not failed over,
+// since another provider would most likely reject the same request.
+func IsFailoverError(err error) bool {
+	return errors.Is(err, ErrProviderUnavailable) ||
+		errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrUnauthorized) ||
+		errors.Is(err, ErrInternalError)
+}
+
+// IsRetryable reports whether the same request may succeed when it is sent
+// again later, as opposed to errors that need a change of input or configuration
+func IsRetryable(err error) bool {
+	return errors.Is(err, ErrProviderUnavailable) ||
+		errors.Is(err, ErrTimeout) ||
+		errors.Is(err, ErrRateLimited)
+}
+
+// retryAfterError carries the delay a provider asked for before the next attempt
+type retryAfterError struct {
+	err   error
+	after time.Duration
+}
+
+func (e *retryAfterError) Error() string {
+	return e.err.Error()
+}
+
+func (e *retryAfterError) Unwrap() error {
+	return e.err
+}
+
+// WithRetryAfter annotates err with the time to wait before retrying. A
+// non-positive delay leaves err as it is.
+func WithRetryAfter(err error, after time.Duration) error {
+	if err == nil || after <= 0 {
+		return err
+	}
+	return &retryAfterError{err: err, after: after}
+}
+
+// RetryAfter returns the time to wait before retrying a failed request, or zero
+// if the provider did not say
+func RetryAfter(err error) time.Duration {
+	var retryErr *retryAfterError
+	if errors.As(err, &retryErr) {
+		return retryErr.after
+	}
+	return 0
+}
+
+// ParseRetryAfter parses the value of an HTTP Retry-After header, given either
+// in seconds or as a date. It returns zero for missing or invalid values.
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Chat message roles used in the provider-neutral "messages" request field
+const (
+	RoleSystem    = "system"
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// Message is a single chat message in the provider-neutral request format.
+// Requests carry messages in the "messages" field, either as built by the bus
+// ([]map[string]string) or as decoded from event data ([]interface{}).
+type Message struct {
+	Role    string
+	Content string
+}
+
+// MessagesFromRequest extracts the "messages" field from the request data.
+// The boolean result reports whether the field was present.
+func MessagesFromRequest(requestData map[string]interface{}) ([]Message, bool, error) {
+	raw, exists := requestData["messages"]
+	if !exists {
+		return nil, false, nil
+	}
+
+	var messages []Message
+	switch v := raw.(type) {
+	case []map[string]string:
+		for _, msg := range v {
+			messages = append(messages, Message{Role: msg["role"], Content: msg["content"]})
+		}
+	case []Message:
+		messages = append(messages, v...)
+	case []interface{}:
+		for i, item := range v {
+			msg, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, true, WrapProviderError(ErrInvalidInput, fmt.Errorf("message %d is not an object", i))
+			}
+			role, _ := msg["role"].(string)
+			content, ok := msg["content"].(string)
+			if !ok {
+				return nil, true, WrapProviderError(ErrInvalidInput, fmt.Errorf("message %d has no string content", i))
+			}
+			messages = append(messages, Message{Role: role, Content: content})
+		}
+	default:
+		return nil, true, WrapProviderError(ErrInvalidInput, fmt.Errorf("'messages' field has invalid format"))
+	}
+
+	if len(messages) == 0 {
+		return nil, true, WrapProviderError(ErrInvalidInput, fmt.Errorf("'messages' field is empty"))
+	}
+
+	// Unknown roles are treated as user input
+	for i := range messages {
+		switch messages[i].Role {
+		case RoleSystem, RoleUser, RoleAssistant:
+		default:
+			messages[i].Role = RoleUser
+		}
+	}
+
+	return messages, true, nil
+}
+
+// FlattenMessages converts chat messages for completion-style APIs that take a
+// single prompt. System messages are joined into the system prompt. A lone user
+// message becomes the prompt as-is, longer conversations are rendered as a
+// role-prefixed transcript.
+func FlattenMessages(messages []Message) (system string, prompt string) {
+	var systemParts []string
+	var conversation []Message
+	for _, msg := range messages {
+		if msg.Role == RoleSystem {
+			systemParts = append(systemParts, msg.Content)
+		} else {
+			conversation = append(conversation, msg)
+		}
+	}
+
+	system = strings.Join(systemParts, "\n\n")
+
+	if len(conversation) == 1 && conversation[0].Role == RoleUser {
+		return system, conversation[0].Content
+	}
+
+	var buf strings.Builder
+	for _, msg := range conversation {
+		role := "User"
+		if msg.Role == RoleAssistant {
+			role = "Assistant"
+		}
+		buf.WriteString(fmt.Sprintf("%s: %s\n\n", role, msg.Content))
+	}
+	if len(conversation) > 0 {
+		buf.WriteString("Assistant:")
+	}
+	return system, buf.String()
+}
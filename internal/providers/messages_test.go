@@ -0,0 +1,117 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestMessagesFromRequest(t *testing.T) {
+	testCases := []struct {
+		name          string
+		requestData   map[string]interface{}
+		expectPresent bool
+		expectRoles   []string
+		expectErr     bool
+	}{
+		{
+			name:        "no messages",
+			requestData: map[string]interface{}{"prompt": "hi"},
+		},
+		{
+			name: "bus format",
+			requestData: map[string]interface{}{"messages": []map[string]string{
+				{"role": "system", "content": "context"},
+				{"role": "user", "content": "question"},
+			}},
+			expectPresent: true,
+			expectRoles:   []string{RoleSystem, RoleUser},
+		},
+		{
+			name: "decoded event data with unknown role",
+			requestData: map[string]interface{}{"messages": []interface{}{
+				map[string]interface{}{"role": "assistant", "content": "earlier answer"},
+				map[string]interface{}{"role": "tool", "content": "output"},
+			}},
+			expectPresent: true,
+			expectRoles:   []string{RoleAssistant, RoleUser},
+		},
+		{
+			name:          "invalid format",
+			requestData:   map[string]interface{}{"messages": "not a list"},
+			expectPresent: true,
+			expectErr:     true,
+		},
+		{
+			name:          "missing content",
+			requestData:   map[string]interface{}{"messages": []interface{}{map[string]interface{}{"role": "user"}}},
+			expectPresent: true,
+			expectErr:     true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			messages, present, err := MessagesFromRequest(tc.requestData)
+			if present != tc.expectPresent {
+				t.Errorf("Expected present = %v, got %v", tc.expectPresent, present)
+			}
+			if tc.expectErr {
+				if !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("Expected ErrInvalidInput, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(messages) != len(tc.expectRoles) {
+				t.Fatalf("Expected %d messages, got %d", len(tc.expectRoles), len(messages))
+			}
+			for i, role := range tc.expectRoles {
+				if messages[i].Role != role {
+					t.Errorf("Message %d: expected role %q, got %q", i, role, messages[i].Role)
+				}
+			}
+		})
+	}
+}
+
+func TestFlattenMessages(t *testing.T) {
+	testCases := []struct {
+		name         string
+		messages     []Message
+		expectSystem string
+		expectPrompt string
+	}{
+		{
+			name: "system and single user message",
+			messages: []Message{
+				{Role: RoleSystem, Content: "Use the context."},
+				{Role: RoleUser, Content: "What did I do yesterday?"},
+			},
+			expectSystem: "Use the context.",
+			expectPrompt: "What did I do yesterday?",
+		},
+		{
+			name: "conversation becomes a transcript",
+			messages: []Message{
+				{Role: RoleUser, Content: "Hi"},
+				{Role: RoleAssistant, Content: "Hello"},
+				{Role: RoleUser, Content: "Bye"},
+			},
+			expectPrompt: "User: Hi\n\nAssistant: Hello\n\nUser: Bye\n\nAssistant:",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			system, prompt := FlattenMessages(tc.messages)
+			if system != tc.expectSystem {
+				t.Errorf("Expected system %q, got %q", tc.expectSystem, system)
+			}
+			if prompt != tc.expectPrompt {
+				t.Errorf("Expected prompt %q, got %q", tc.expectPrompt, prompt)
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+// State is the state of a circuit breaker
+type State int
+
+const (
+	// StateClosed lets every request through
+	StateClosed State = iota
+	// StateOpen rejects every request until the open timeout elapses
+	StateOpen
+	// StateHalfOpen lets a limited number of probe requests through
+	StateHalfOpen
+)
+
+// String returns the name of the state
+func (s State) String() string {
+	switch s {
+	case StateClosed:
+		return "closed"
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrCircuitOpen indicates the request was rejected without calling the provider
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// errRejected wraps both ErrProviderUnavailable, so that callers fail over, and ErrCircuitOpen
+var errRejected = fmt.Errorf("%w: %w", providers.ErrProviderUnavailable, ErrCircuitOpen)
+
+// Config configures a circuit breaker
+type Config struct {
+	FailureThreshold    int           // Consecutive unavailable/timeout failures before the circuit opens
+	OpenTimeout         time.Duration // Time the circuit stays open before probing
+	HalfOpenMaxRequests int           // Concurrent probe requests allowed while half-open
+	WindowSize          int           // Number of recent calls used to compute the error rate
+	ErrorRateThreshold  float64       // Failure ratio over a full window that opens the circuit
+}
+
+// DefaultConfig returns the default circuit breaker configuration
+func DefaultConfig() Config {
+	return Config{
+		FailureThreshold:    5,
+		OpenTimeout:         30 * time.Second,
+		HalfOpenMaxRequests: 1,
+		WindowSize:          20,
+		ErrorRateThreshold:  0.5,
+	}
+}
+
+// CircuitBreaker wraps a ComputeProvider and stops calling it after repeated failures
+type CircuitBreaker struct {
+	name     string
+	provider providers.ComputeProvider
+	config   Config
+	
+	mu                  sync.Mutex
+	state               State
+	consecutiveFailures int
+	openedAt            time.Time
+	halfOpenInFlight    int
+	
+	// Sliding window of recent outcomes (true means failure)
+	window      []bool
+	windowNext  int
+	windowCount int
+	
+	totalRequests int64
+	totalFailures int64
+	lastError     string
+	lastFailure   time.Time
+	
+	now func() time.Time // Replaceable clock for tests
+}
+
+// streamingCircuitBreaker also forwards streaming calls
+type streamingCircuitBreaker struct {
+	*CircuitBreaker
+	streaming providers.StreamingComputeProvider
+}
+
+// NewCircuitBreaker creates a circuit breaker around the provider
+func NewCircuitBreaker(name string, provider providers.ComputeProvider, config Config) *CircuitBreaker {
+	defaults := DefaultConfig()
+	if config.FailureThreshold <= 0 {
+		config.FailureThreshold = defaults.FailureThreshold
+	}
+	if config.OpenTimeout <= 0 {
+		config.OpenTimeout = defaults.OpenTimeout
+	}
+	if config.HalfOpenMaxRequests <= 0 {
+		config.HalfOpenMaxRequests = defaults.HalfOpenMaxRequests
+	}
+	if config.WindowSize <= 0 {
+		config.WindowSize = defaults.WindowSize
+	}
+	if config.ErrorRateThreshold <= 0 {
+		config.ErrorRateThreshold = defaults.ErrorRateThreshold
+	}
+	
+	return &CircuitBreaker{
+		name:     name,
+		provider: provider,
+		config:   config,
+		window:   make([]bool, config.WindowSize),
+		now:      time.Now,
+	}
+}
+
+// WrapWithCircuitBreaker wraps the provider in a circuit breaker and registers it
+// with the health registry. Streaming providers stay streaming providers.
+func WrapWithCircuitBreaker(name string, provider providers.ComputeProvider, registry *HealthRegistry, config Config) providers.ComputeProvider {
+	breaker := NewCircuitBreaker(name, provider, config)
+	if registry != nil {
+		registry.Register(name, breaker)
+	}
+	
+	if streaming, ok := provider.(providers.StreamingComputeProvider); ok {
+		return &streamingCircuitBreaker{CircuitBreaker: breaker, streaming: streaming}
+	}
+	return breaker
+}
+
+// Execute implements the ComputeProvider interface
+func (cb *CircuitBreaker) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	if err := cb.allow(); err != nil {
+		return "", err
+	}
+	
+	result, err := cb.provider.Execute(ctx, requestData)
+	cb.record(ctx, err)
+	return result, err
+}
+
+// ExecuteStream implements the StreamingComputeProvider interface
+func (scb *streamingCircuitBreaker) ExecuteStream(ctx context.Context, attributes map[string]string, input <-chan []byte, control <-chan providers.StreamControl, output chan<- []byte) error {
+	if err := scb.allow(); err != nil {
+		return err
+	}
+	
+	err := scb.streaming.ExecuteStream(ctx, attributes, input, control, output)
+	scb.record(ctx, err)
+	return err
+}
+
+// State returns the current state, moving from open to half-open if the open timeout elapsed
+func (cb *CircuitBreaker) State() State {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	
+	cb.advanceLocked()
+	return cb.state
+}
+
+// Health returns a snapshot of the provider's health
+func (cb *CircuitBreaker) Health() Health {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	
+	cb.advanceLocked()
+	
+	return Health{
+		Provider:            cb.name,
+		State:               cb.state,
+		Healthy:             cb.state != StateOpen,
+		ConsecutiveFailures: cb.consecutiveFailures,
+		ErrorRate:           cb.errorRateLocked(),
+		TotalRequests:       cb.totalRequests,
+		TotalFailures:       cb.totalFailures,
+		LastError:           cb.lastError,
+		LastFailure:         cb.lastFailure,
+	}
+}
+
+// allow decides whether a request may reach the provider
+func (cb *CircuitBreaker) allow() error {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	
+	cb.advanceLocked()
+	
+	switch cb.state {
+	case StateOpen:
+		// Callers may retry once the circuit lets a probe through
+		return providers.WithRetryAfter(errRejected, cb.config.OpenTimeout-cb.now().Sub(cb.openedAt))
+	case StateHalfOpen:
+		if cb.halfOpenInFlight >= cb.config.HalfOpenMaxRequests {
+			return errRejected
+		}
+		cb.halfOpenInFlight++
+	}
+	return nil
+}
+
+// isProviderFailure reports whether a call failed because the provider itself
+// is unhealthy. Other errors, such as invalid input or a rejected key, mean the
+// provider answered. Calls the caller cancelled, including fan-out providers
+// stopped once enough others answered, say nothing about the provider, even if
+// it reports the aborted request as unavailable.
+func isProviderFailure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	return errors.Is(err, providers.ErrProviderUnavailable) || errors.Is(err, providers.ErrTimeout)
+}
+
+// record updates the breaker with the outcome of a call
+func (cb *CircuitBreaker) record(ctx context.Context, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	
+	wasHalfOpen := cb.state == StateHalfOpen
+	if wasHalfOpen && cb.halfOpenInFlight > 0 {
+		cb.halfOpenInFlight--
+	}
+	
+	// A cancelled call is not an outcome; a cancelled probe leaves the circuit half-open
+	if err != nil && ctx.Err() != nil {
+		return
+	}
+	
+	failed := isProviderFailure(ctx, err)
+	cb.totalRequests++
+	cb.window[cb.windowNext] = failed
+	cb.windowNext = (cb.windowNext + 1) % len(cb.window)
+	if cb.windowCount < len(cb.window) {
+		cb.windowCount++
+	}
+	
+	if !failed {
+		cb.consecutiveFailures = 0
+		if wasHalfOpen {
+			cb.transitionLocked(StateClosed)
+		}
+		return
+	}
+	
+	cb.totalFailures++
+	cb.lastError = err.Error()
+	cb.lastFailure = cb.now()
+	
+	cb.consecutiveFailures++
+	if wasHalfOpen || cb.consecutiveFailures >= cb.config.FailureThreshold {
+		cb.transitionLocked(StateOpen)
+		return
+	}
+	
+	// Intermittent failures open the circuit once they make up too much of a full window
+	if cb.windowCount == len(cb.window) && cb.errorRateLocked() >= cb.config.ErrorRateThreshold {
+		cb.transitionLocked(StateOpen)
+	}
+}
+
+// errorRateLocked returns the failure ratio over the recent window
+func (cb *CircuitBreaker) errorRateLocked() float64 {
+	if cb.windowCount == 0 {
+		return 0
+	}
+	failures := 0
+	for i := 0; i < cb.windowCount; i++ {
+		if cb.window[i] {
+			failures++
+		}
+	}
+	return float64(failures) / float64(cb.windowCount)
+}
+
+// advanceLocked moves an open circuit to half-open once the open timeout has elapsed
+func (cb *CircuitBreaker) advanceLocked() {
+	if cb.state == StateOpen && cb.now().Sub(cb.openedAt) >= cb.config.OpenTimeout {
+		cb.transitionLocked(StateHalfOpen)
+	}
+}
+
+// transitionLocked changes the state of the circuit
+func (cb *CircuitBreaker) transitionLocked(state State) {
+	if cb.state == state {
+		return
+	}
+	
+	log.Printf("CircuitBreaker: provider %s %s -> %s (consecutive failures: %d)", cb.name, cb.state, state, cb.consecutiveFailures)
+	cb.state = state
+	cb.halfOpenInFlight = 0
+	if state == StateOpen {
+		cb.openedAt = cb.now()
+	}
+	if state == StateClosed {
+		// A recovered provider starts with a clean window
+		cb.consecutiveFailures = 0
+		cb.windowNext, cb.windowCount = 0, 0
+	}
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/soaringjerry/pcas/internal/providers"
+)
+
+// scriptedProvider returns the configured error and counts calls
+type scriptedProvider struct {
+	err   error
+	calls int
+}
+
+func (p *scriptedProvider) Execute(ctx context.Context, requestData map[string]interface{}) (string, error) {
+	p.calls++
+	if p.err != nil {
+		return "", p.err
+	}
+	return "ok", nil
+}
+
+func TestCircuitBreaker_OpensAndRecovers(t *testing.T) {
+	provider := &scriptedProvider{err: providers.WrapProviderError(providers.ErrProviderUnavailable, errors.New("connection refused"))}
+	registry := NewHealthRegistry()
+	breaker := NewCircuitBreaker("ollama", provider, Config{FailureThreshold: 3, OpenTimeout: time.Minute})
+	registry.Register("ollama", breaker)
+
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Failures below the threshold keep the circuit closed
+	for i := 0; i < 3; i++ {
+		breaker.Execute(ctx, nil)
+	}
+	if breaker.State() != StateOpen {
+		t.Fatalf("Expected circuit to be open after 3 failures, got %s", breaker.State())
+	}
+	if registry.IsHealthy("ollama") {
+		t.Error("Expected provider to be reported unhealthy while open")
+	}
+
+	// Open circuit rejects without calling the provider
+	_, err := breaker.Execute(ctx, nil)
+	if !errors.Is(err, providers.ErrProviderUnavailable) || !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("Expected circuit open error, got %v", err)
+	}
+	if after := providers.RetryAfter(err); after != time.Minute {
+		t.Errorf("Expected retry after the open timeout, got %v", after)
+	}
+	if provider.calls != 3 {
+		t.Errorf("Expected provider to be called 3 times, got %d", provider.calls)
+	}
+
+	// After the open timeout a failed probe re-opens the circuit
+	now = now.Add(time.Minute)
+	if breaker.State() != StateHalfOpen {
+		t.Fatalf("Expected half-open after timeout, got %s", breaker.State())
+	}
+	breaker.Execute(ctx, nil)
+	if breaker.State() != StateOpen {
+		t.Fatalf("Expected failed probe to re-open the circuit, got %s", breaker.State())
+	}
+
+	// A successful probe closes it again
+	now = now.Add(time.Minute)
+	provider.err = nil
+	if _, err := breaker.Execute(ctx, nil); err != nil {
+		t.Fatalf("Expected probe to succeed, got %v", err)
+	}
+	if breaker.State() != StateClosed {
+		t.Fatalf("Expected circuit to close after successful probe, got %s", breaker.State())
+	}
+
+	health := registry.Snapshot()
+	if len(health) != 1 || !health[0].Healthy || health[0].TotalRequests != 5 || health[0].TotalFailures != 4 {
+		t.Errorf("Unexpected health snapshot: %+v", health)
+	}
+}
+
+func TestCircuitBreaker_IgnoresNonTrippingErrors(t *testing.T) {
+	provider := &scriptedProvider{err: providers.WrapProviderError(providers.ErrInvalidInput, errors.New("missing prompt"))}
+	breaker := NewCircuitBreaker("openai", provider, Config{FailureThreshold: 2})
+
+	for i := 0; i < 5; i++ {
+		breaker.Execute(context.Background(), nil)
+	}
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected invalid input errors not to open the circuit, got %s", breaker.State())
+	}
+	if health := breaker.Health(); health.ErrorRate != 0 || health.TotalFailures != 0 {
+		t.Errorf("Expected invalid input not to count as a failure, got %+v", health)
+	}
+}
+
+func TestCircuitBreaker_IgnoresCancelledCalls(t *testing.T) {
+	// Providers may report an aborted request as unavailable
+	provider := &scriptedProvider{err: providers.WrapProviderError(providers.ErrProviderUnavailable, context.Canceled)}
+	breaker := NewCircuitBreaker("ollama", provider, Config{FailureThreshold: 2})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for i := 0; i < 5; i++ {
+		breaker.Execute(ctx, nil)
+	}
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected cancelled calls not to open the circuit, got %s", breaker.State())
+	}
+	if health := breaker.Health(); health.ConsecutiveFailures != 0 || health.TotalFailures != 0 {
+		t.Errorf("Expected cancelled calls not to count as failures, got %+v", health)
+	}
+
+	// Errors that do not mean the provider is down reset the consecutive failures
+	provider.err = providers.ErrProviderUnavailable
+	breaker.Execute(context.Background(), nil)
+	provider.err = providers.ErrUnauthorized
+	breaker.Execute(context.Background(), nil)
+	provider.err = providers.ErrProviderUnavailable
+	breaker.Execute(context.Background(), nil)
+	if breaker.State() != StateClosed {
+		t.Errorf("Expected non-consecutive failures to keep the circuit closed, got %s", breaker.State())
+	}
+}
+
+func TestCircuitBreaker_OpensOnErrorRate(t *testing.T) {
+	provider := &scriptedProvider{}
+	breaker := NewCircuitBreaker("ollama", provider, Config{FailureThreshold: 3, WindowSize: 10, ErrorRateThreshold: 0.5})
+	ctx := context.Background()
+
+	// Every other call fails, so consecutive failures never reach the threshold
+	for i := 0; i < 9; i++ {
+		provider.err = nil
+		if i%2 == 1 {
+			provider.err = providers.ErrProviderUnavailable
+		}
+		breaker.Execute(ctx, nil)
+	}
+	if breaker.State() != StateClosed {
+		t.Fatalf("Expected circuit to stay closed before the window is full, got %s", breaker.State())
+	}
+
+	// The tenth call fills the window at an error rate of 0.5
+	provider.err = providers.ErrProviderUnavailable
+	breaker.Execute(ctx, nil)
+	if breaker.State() != StateOpen {
+		t.Errorf("Expected circuit to open at the error rate threshold, got %s", breaker.State())
+	}
+	if health := breaker.Health(); health.ErrorRate != 0.5 || health.ConsecutiveFailures != 1 {
+		t.Errorf("Expected an error rate of 0.5 with 1 consecutive failure, got %+v", health)
+	}
+}
@@ -0,0 +1,17 @@
+// Package middleware provides wrappers around providers.ComputeProvider that add
+// cross-cutting behaviour without changing the providers themselves.
+//
+// The circuit breaker tracks the outcome of every call to a provider. After a
+// number of consecutive ErrProviderUnavailable or ErrTimeout failures the circuit
+// opens and further calls fail immediately with ErrProviderUnavailable, which
+// lets the bus fail over to the next provider without paying for timeouts and
+// retries. Once the open timeout has elapsed the circuit becomes half-open and a
+// limited number of probe requests are let through; a successful probe closes
+// the circuit again, a failed one re-opens it.
+//
+// Usage:
+//
+//	registry := middleware.NewHealthRegistry()
+//	provider = middleware.WrapWithCircuitBreaker("ollama-llama3", provider, registry, middleware.DefaultConfig())
+//	policyEngine.SetHealthChecker(registry)
+package middleware
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Health is a point-in-time snapshot of a provider's health
+type Health struct {
+	Provider            string
+	State               State
+	Healthy             bool // False while the circuit is open
+	ConsecutiveFailures int
+	ErrorRate           float64 // Failure ratio over the recent window
+	TotalRequests       int64
+	TotalFailures       int64
+	LastError           string
+	LastFailure         time.Time
+}
+
+// HealthRegistry tracks the circuit breakers of all providers.
+// It implements policy.HealthChecker so that the policy engine can skip unhealthy providers.
+type HealthRegistry struct {
+	mu       sync.RWMutex
+	breakers map[string]*CircuitBreaker
+}
+
+// NewHealthRegistry creates an empty health registry
+func NewHealthRegistry() *HealthRegistry {
+	return &HealthRegistry{
+		breakers: make(map[string]*CircuitBreaker),
+	}
+}
+
+// Register adds a provider's circuit breaker to the registry
+func (r *HealthRegistry) Register(name string, breaker *CircuitBreaker) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.breakers[name] = breaker
+}
+
+// IsHealthy reports whether requests to the provider are currently allowed.
+// Providers without a circuit breaker are always considered healthy.
+func (r *HealthRegistry) IsHealthy(name string) bool {
+	r.mu.RLock()
+	breaker, ok := r.breakers[name]
+	r.mu.RUnlock()
+	
+	if !ok {
+		return true
+	}
+	return breaker.State() != StateOpen
+}
+
+// Snapshot returns the health of every registered provider, sorted by name
+func (r *HealthRegistry) Snapshot() []Health {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	
+	snapshot := make([]Health, 0, len(r.breakers))
+	for _, breaker := range r.breakers {
+		snapshot = append(snapshot, breaker.Health())
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		return snapshot[i].Provider < snapshot[j].Provider
+	})
+	return snapshot
+}
@@ -21,8 +21,9 @@ const (
 
 // Provider implements the ComputeProvider interface for Ollama
 type Provider struct {
-	httpClient *http.Client
-	baseURL    string
+	httpClient   *http.Client
+	baseURL      string
+	defaultModel string // Used when the request does not specify a model
 }
 
 // NewProvider creates a new Ollama provider instance
@@ -39,9 +40,17 @@ func NewProvider(httpClient *http.Client, baseURL string) *Provider {
 	}
 }
 
+// SetDefaultModel sets the model used for requests that do not specify one.
+// This allows the provider to serve as a fallback for requests routed from
+// providers that do not need an explicit model.
+func (p *Provider) SetDefaultModel(model string) {
+	p.defaultModel = model
+}
+
 // GenerateRequest represents the request payload for Ollama's generate API
 type GenerateRequest struct {
 	Model  string `json:"model"`
+	System string `json:"system,omitempty"`
 	Prompt string `json:"prompt"`
 	Stream bool   `json:"stream"`
 }
@@ -66,7 +75,7 @@ func (p *Provider) Execute(ctx context.Context, requestData map[string]interface
 	startTime := time.Now()
 	
 	// Extract and validate parameters
-	model, prompt, err := p.extractParameters(requestData)
+	model, system, prompt, err := p.extractParameters(requestData)
 	if err != nil {
 		return "", err
 	}
@@ -76,6 +85,7 @@ func (p *Provider) Execute(ctx context.Context, requestData map[string]interface
 	// Prepare the request
 	req := GenerateRequest{
 		Model:  model,
+		System: system,
 		Prompt: prompt,
 		Stream: false, // PoC doesn't support streaming
 	}
@@ -111,12 +121,16 @@ func (p *Provider) Execute(ctx context.Context, requestData map[string]interface
 	return "", lastErr
 }
 
-// extractParameters validates and extracts parameters from request data
-func (p *Provider) extractParameters(requestData map[string]interface{}) (model string, prompt string, err error) {
+// extractParameters validates and extracts parameters from request data.
+// Chat-style "messages" are flattened into Ollama's system and prompt fields.
+func (p *Provider) extractParameters(requestData map[string]interface{}) (model string, system string, prompt string, err error) {
 	// Extract model (required)
 	modelVal, ok := requestData["model"]
+	if !ok && p.defaultModel != "" {
+		modelVal, ok = p.defaultModel, true
+	}
 	if !ok {
-		return "", "", providers.WrapProviderError(
+		return "", "", "", providers.WrapProviderError(
 			providers.ErrInvalidInput,
 			fmt.Errorf("missing required field: model"),
 		)
@@ -124,7 +138,7 @@ func (p *Provider) extractParameters(requestData map[string]interface{}) (model
 	
 	model, ok = modelVal.(string)
 	if !ok || model == "" {
-		return "", "", providers.WrapProviderError(
+		return "", "", "", providers.WrapProviderError(
 			providers.ErrInvalidInput,
 			fmt.Errorf("model must be a non-empty string"),
 		)
@@ -133,17 +147,33 @@ func (p *Provider) extractParameters(requestData map[string]interface{}) (model
 	// Check for unsupported streaming
 	if streamVal, ok := requestData["stream"]; ok {
 		if stream, _ := streamVal.(bool); stream {
-			return "", "", providers.WrapProviderError(
+			return "", "", "", providers.WrapProviderError(
 				providers.ErrInvalidInput,
 				fmt.Errorf("streaming responses are not supported yet"),
 			)
 		}
 	}
 	
+	// Messages take precedence over the plain prompt, e.g. after RAG enhancement
+	messages, hasMessages, err := providers.MessagesFromRequest(requestData)
+	if err != nil {
+		return "", "", "", err
+	}
+	if hasMessages {
+		system, prompt = providers.FlattenMessages(messages)
+		if prompt == "" {
+			return "", "", "", providers.WrapProviderError(
+				providers.ErrInvalidInput,
+				fmt.Errorf("messages contain no user input"),
+			)
+		}
+		return model, system, prompt, nil
+	}
+	
 	// Extract prompt (required)
 	promptVal, ok := requestData["prompt"]
 	if !ok {
-		return "", "", providers.WrapProviderError(
+		return "", "", "", providers.WrapProviderError(
 			providers.ErrInvalidInput,
 			fmt.Errorf("missing required field: prompt"),
 		)
@@ -151,13 +181,13 @@ func (p *Provider) extractParameters(requestData map[string]interface{}) (model
 	
 	prompt, ok = promptVal.(string)
 	if !ok || prompt == "" {
-		return "", "", providers.WrapProviderError(
+		return "", "", "", providers.WrapProviderError(
 			providers.ErrInvalidInput,
 			fmt.Errorf("prompt must be a non-empty string"),
 		)
 	}
 	
-	return model, prompt, nil
+	return model, "", prompt, nil
 }
 
 // doRequest performs a single HTTP request to Ollama
@@ -200,10 +230,10 @@ func (p *Provider) doRequest(ctx context.Context, req GenerateRequest) (string,
 				fmt.Errorf("status %d: %s", resp.StatusCode, string(body)),
 			)
 		case http.StatusTooManyRequests:
-			return "", providers.WrapProviderError(
+			return "", providers.WithRetryAfter(providers.WrapProviderError(
 				providers.ErrRateLimited,
 				fmt.Errorf("status %d: %s", resp.StatusCode, string(body)),
-			)
+			), providers.ParseRetryAfter(resp.Header.Get("Retry-After")))
 		case http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable:
 			// These are retryable
 			return "", providers.WrapProviderError(
@@ -234,6 +264,13 @@ func (p *Provider) doRequest(ctx context.Context, req GenerateRequest) (string,
 		)
 	}
 	
+	// Report token usage for cost accounting
+	providers.ReportUsage(ctx, providers.Usage{
+		Model:            genResp.Model,
+		PromptTokens:     genResp.PromptEvalCount,
+		CompletionTokens: genResp.EvalCount,
+	})
+	
 	return genResp.Response, nil
 }
 
@@ -2,6 +2,7 @@ package ollama
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -94,6 +95,40 @@ func TestProvider_Execute_WithModel(t *testing.T) {
 	}
 }
 
+func TestProvider_Execute_WithMessages(t *testing.T) {
+	var received GenerateRequest
+	
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		w.Write([]byte(`{"model": "llama3:8b", "response": "Answer with context", "done": true}`))
+	}))
+	defer server.Close()
+	
+	provider := NewProvider(nil, server.URL)
+	
+	// RAG-enhanced requests carry messages instead of a prompt
+	requestData := map[string]interface{}{
+		"model": "llama3:8b",
+		"messages": []map[string]string{
+			{"role": "system", "content": "Relevant context"},
+			{"role": "user", "content": "What did I note?"},
+		},
+	}
+	
+	if _, err := provider.Execute(context.Background(), requestData); err != nil {
+		t.Fatalf("Execute returned unexpected error: %v", err)
+	}
+	
+	if received.System != "Relevant context" {
+		t.Errorf("Expected system prompt %q, got %q", "Relevant context", received.System)
+	}
+	if received.Prompt != "What did I note?" {
+		t.Errorf("Expected prompt %q, got %q", "What did I note?", received.Prompt)
+	}
+}
+
 func TestProvider_Execute_MissingPrompt(t *testing.T) {
 	provider := NewProvider(nil, "http://localhost:11434")
 	
@@ -259,6 +294,7 @@ func TestProvider_Execute_Timeout(t *testing.T) {
 
 func TestProvider_Execute_RateLimited(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
 		w.WriteHeader(http.StatusTooManyRequests)
 		w.Write([]byte("Rate limit exceeded"))
 	}))
@@ -280,6 +316,10 @@ func TestProvider_Execute_RateLimited(t *testing.T) {
 	if !strings.Contains(err.Error(), providers.ErrRateLimited.Error()) {
 		t.Errorf("Expected rate limited error, got: %v", err)
 	}
+	
+	if after := providers.RetryAfter(err); after != 7*time.Second {
+		t.Errorf("Expected retry after 7s, got %v", after)
+	}
 }
 
 func TestProvider_Execute_IncompleteResponse(t *testing.T) {
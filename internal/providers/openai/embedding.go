@@ -9,6 +9,10 @@ import (
 	"github.com/soaringjerry/pcas/internal/providers"
 )
 
+// Texts sent in one embeddings request, well below the API's input limits for
+// chunks of a few thousand characters
+const maxEmbeddingBatch = 64
+
 // EmbeddingProvider is an OpenAI implementation of the EmbeddingProvider interface
 type EmbeddingProvider struct {
 	client *openai.Client
@@ -45,4 +49,36 @@ func (p *EmbeddingProvider) CreateEmbedding(ctx context.Context, text string) ([
 	embedding := resp.Data[0].Embedding
 
 	return embedding, nil
-}
\ No newline at end of file
+}
+
+// CreateEmbeddings converts texts into vector embeddings with one API request
+// per batch of maxEmbeddingBatch texts
+func (p *EmbeddingProvider) CreateEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings := make([][]float32, 0, len(texts))
+	for start := 0; start < len(texts); start += maxEmbeddingBatch {
+		batch := texts[start:min(start+maxEmbeddingBatch, len(texts))]
+		req := openai.EmbeddingRequest{
+			Input: batch,
+			Model: openai.LargeEmbedding3,
+		}
+
+		resp, err := p.client.CreateEmbeddings(ctx, req)
+		if err != nil {
+			return nil, fmt.Errorf("OpenAI embedding error: %w", err)
+		}
+		if len(resp.Data) != len(batch) {
+			return nil, fmt.Errorf("OpenAI returned %d embeddings for %d texts", len(resp.Data), len(batch))
+		}
+
+		// The data carries the index of its input, which need not be in order
+		vectors := make([][]float32, len(batch))
+		for _, data := range resp.Data {
+			if data.Index < 0 || data.Index >= len(batch) {
+				return nil, fmt.Errorf("OpenAI returned an embedding for unknown input %d", data.Index)
+			}
+			vectors[data.Index] = data.Embedding
+		}
+		embeddings = append(embeddings, vectors...)
+	}
+	return embeddings, nil
+}
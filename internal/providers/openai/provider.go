@@ -2,9 +2,13 @@ package openai
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
 
 	"github.com/sashabaranov/go-openai"
+	
+	"github.com/soaringjerry/pcas/internal/providers"
 )
 
 // Provider is an OpenAI implementation of ComputeProvider
@@ -30,34 +34,28 @@ func (p *Provider) Execute(ctx context.Context, requestData map[string]interface
 	}
 	
 	// Check if messages are already provided (RAG enhanced)
-	if messagesInterface, exists := requestData["messages"]; exists {
-		// Convert messages from interface{} to proper format
-		if msgSlice, ok := messagesInterface.([]map[string]string); ok {
-			for _, msg := range msgSlice {
-				role := msg["role"]
-				content := msg["content"]
-				
-				var openaiRole string
-				switch role {
-				case "system":
-					openaiRole = openai.ChatMessageRoleSystem
-				case "user":
-					openaiRole = openai.ChatMessageRoleUser
-				case "assistant":
-					openaiRole = openai.ChatMessageRoleAssistant
-				default:
-					openaiRole = openai.ChatMessageRoleUser
-				}
-				
-				messages = append(messages, openai.ChatCompletionMessage{
-					Role:    openaiRole,
-					Content: content,
-				})
+	chatMessages, hasMessages, err := providers.MessagesFromRequest(requestData)
+	if err != nil {
+		return "", err
+	}
+	if hasMessages {
+		for _, msg := range chatMessages {
+			var openaiRole string
+			switch msg.Role {
+			case providers.RoleSystem:
+				openaiRole = openai.ChatMessageRoleSystem
+			case providers.RoleAssistant:
+				openaiRole = openai.ChatMessageRoleAssistant
+			default:
+				openaiRole = openai.ChatMessageRoleUser
 			}
-			fmt.Printf("OpenAI: Using RAG-enhanced messages with %d messages\n", len(messages))
-		} else {
-			return "", fmt.Errorf("'messages' field has invalid format")
+			
+			messages = append(messages, openai.ChatCompletionMessage{
+				Role:    openaiRole,
+				Content: msg.Content,
+			})
 		}
+		fmt.Printf("OpenAI: Using RAG-enhanced messages with %d messages\n", len(messages))
 	} else {
 		// Fall back to simple prompt format
 		promptInterface, exists := requestData["prompt"]
@@ -89,13 +87,57 @@ func (p *Provider) Execute(ctx context.Context, requestData map[string]interface
 	// Call OpenAI API
 	resp, err := p.client.CreateChatCompletion(ctx, req)
 	if err != nil {
-		return "", fmt.Errorf("OpenAI API error: %w", err)
+		return "", classifyError(ctx, err)
 	}
 	
+	// Report token usage for cost accounting
+	providers.ReportUsage(ctx, providers.Usage{
+		Model:            resp.Model,
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+	})
+	
 	// Extract response content
 	if len(resp.Choices) == 0 {
 		return "", fmt.Errorf("no response choices from OpenAI")
 	}
 	
 	return resp.Choices[0].Message.Content, nil
+}
+
+// classifyError maps OpenAI client errors onto the standard provider errors so
+// that callers can react to them with errors.Is
+func classifyError(ctx context.Context, err error) error {
+	wrapped := fmt.Errorf("OpenAI API error: %w", err)
+	
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		return providers.WrapProviderError(providers.ErrTimeout, wrapped)
+	}
+	
+	statusCode := 0
+	var apiErr *openai.APIError
+	var reqErr *openai.RequestError
+	if errors.As(err, &apiErr) {
+		statusCode = apiErr.HTTPStatusCode
+	} else if errors.As(err, &reqErr) {
+		statusCode = reqErr.HTTPStatusCode
+	} else {
+		// No HTTP response at all, e.g. DNS or connection failures
+		return providers.WrapProviderError(providers.ErrProviderUnavailable, wrapped)
+	}
+	
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return providers.WrapProviderError(providers.ErrUnauthorized, wrapped)
+	case statusCode == http.StatusTooManyRequests:
+		return providers.WrapProviderError(providers.ErrRateLimited, wrapped)
+	case statusCode == http.StatusBadRequest || statusCode == http.StatusNotFound || statusCode == http.StatusUnprocessableEntity:
+		return providers.WrapProviderError(providers.ErrInvalidInput, wrapped)
+	case statusCode == http.StatusGatewayTimeout || statusCode == http.StatusRequestTimeout:
+		return providers.WrapProviderError(providers.ErrTimeout, wrapped)
+	case statusCode >= 500:
+		return providers.WrapProviderError(providers.ErrProviderUnavailable, wrapped)
+	default:
+		return providers.WrapProviderError(providers.ErrInternalError, wrapped)
+	}
 }
\ No newline at end of file
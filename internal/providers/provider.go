@@ -13,6 +13,28 @@ type ComputeProvider interface {
 // StreamingComputeProvider is the interface for providers that support streaming
 type StreamingComputeProvider interface {
 	ComputeProvider
-	// ExecuteStream handles bidirectional streaming
-	ExecuteStream(ctx context.Context, attributes map[string]string, input <-chan []byte, output chan<- []byte) error
-}
\ No newline at end of file
+	// ExecuteStream handles bidirectional streaming. The stream ends when input is
+	// closed. Control messages from the client arrive on control, which is never
+	// closed; they are ordered among themselves but not with the input.
+	ExecuteStream(ctx context.Context, attributes map[string]string, input <-chan []byte, control <-chan StreamControl, output chan<- []byte) error
+}
+
+// StreamControlType identifies a control message of a stream
+type StreamControlType int
+
+const (
+	// StreamControlCancel aborts the current generation. The stream stays open
+	// and the provider continues with the next input.
+	StreamControlCancel StreamControlType = iota + 1
+	// StreamControlUpdate changes attributes of the stream, e.g. the target language
+	StreamControlUpdate
+)
+
+// StreamControl is a control message sent by the client of a stream
+type StreamControl struct {
+	Type StreamControlType
+	// Attributes of the stream after an update, with the changes applied
+	Attributes map[string]string
+	// Optional reason given for a cancel
+	Reason string
+}
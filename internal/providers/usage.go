@@ -0,0 +1,63 @@
+package providers
+
+import (
+	"context"
+	"sync"
+)
+
+// Usage describes the tokens consumed by a single provider call
+type Usage struct {
+	Model            string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// TotalTokens returns the sum of prompt and completion tokens
+func (u Usage) TotalTokens() int {
+	return u.PromptTokens + u.CompletionTokens
+}
+
+// UsageRecorder collects the usage reported by providers during a call
+type UsageRecorder struct {
+	mu     sync.Mutex
+	usages []Usage
+}
+
+// usageRecorderKey is the context key for the usage recorder
+type usageRecorderKey struct{}
+
+// WithUsageRecorder returns a context that collects usage reported by providers.
+// Providers report usage with ReportUsage, so the ComputeProvider interface does
+// not need to change to carry token counts.
+func WithUsageRecorder(ctx context.Context) (context.Context, *UsageRecorder) {
+	recorder := &UsageRecorder{}
+	return context.WithValue(ctx, usageRecorderKey{}, recorder), recorder
+}
+
+// ReportUsage records usage on the recorder attached to the context, if any
+func ReportUsage(ctx context.Context, usage Usage) {
+	recorder, ok := ctx.Value(usageRecorderKey{}).(*UsageRecorder)
+	if !ok {
+		return
+	}
+	
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	recorder.usages = append(recorder.usages, usage)
+}
+
+// Total returns the combined usage of all reports. The model is taken from the last report.
+func (r *UsageRecorder) Total() (Usage, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	
+	var total Usage
+	for _, usage := range r.usages {
+		total.PromptTokens += usage.PromptTokens
+		total.CompletionTokens += usage.CompletionTokens
+		if usage.Model != "" {
+			total.Model = usage.Model
+		}
+	}
+	return total, len(r.usages) > 0
+}
@@ -0,0 +1,163 @@
+// Package ranking re-ranks similarity search results. Cosine similarity alone
+// prefers a two-year-old note over yesterday's nearly identical one, so the final
+// score blends similarity with a time decay and an importance signal, and an
+// optional MMR pass trades some relevance for diversity.
+package ranking
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	// DefaultHalfLife is the age at which the recency signal has halved
+	DefaultHalfLife = 7 * 24 * time.Hour
+
+	// DefaultImportance is used for events without an importance signal
+	DefaultImportance = 0.5
+
+	// CandidateFactor is how many more candidates than requested results should be
+	// retrieved when re-ranking, so that older or redundant hits can be replaced
+	CandidateFactor = 4
+)
+
+// Weights configures the scoring stage. The zero value ranks by similarity only.
+type Weights struct {
+	Recency    float64       // Weight of the time decay signal (0 to 1)
+	HalfLife   time.Duration // Age at which recency has halved (default one week)
+	Importance float64       // Weight of the importance signal (0 to 1)
+	MMRLambda  float64       // Relevance vs. diversity trade-off (0 disables MMR, 1 is relevance only)
+}
+
+// IsZero reports whether the weights leave the similarity order unchanged
+func (w Weights) IsZero() bool {
+	return w.Recency == 0 && w.Importance == 0 && w.MMRLambda == 0
+}
+
+// Validate checks the weights for invalid values
+func (w Weights) Validate() error {
+	if w.Recency < 0 || w.Recency > 1 {
+		return fmt.Errorf("recency weight must be between 0 and 1, got %v", w.Recency)
+	}
+	if w.Importance < 0 || w.Importance > 1 {
+		return fmt.Errorf("importance weight must be between 0 and 1, got %v", w.Importance)
+	}
+	if w.Recency+w.Importance > 1 {
+		return fmt.Errorf("recency and importance weights must not add up to more than 1, got %v", w.Recency+w.Importance)
+	}
+	if w.HalfLife < 0 {
+		return fmt.Errorf("half-life must not be negative, got %v", w.HalfLife)
+	}
+	if w.MMRLambda < 0 || w.MMRLambda > 1 {
+		return fmt.Errorf("MMR lambda must be between 0 and 1, got %v", w.MMRLambda)
+	}
+	return nil
+}
+
+// Candidate is a search hit with the signals used for ranking
+type Candidate struct {
+	ID         string
+	Similarity float64   // Cosine similarity to the query
+	Time       time.Time // Event time, zero if unknown
+	Importance float64   // Importance between 0 and 1
+	Embedding  []float32 // Used by MMR to detect redundant hits, may be nil
+}
+
+// Result is a ranked candidate
+type Result struct {
+	Candidate
+	Recency float64 // Time decay between 0 and 1
+	Score   float64 // Blended relevance score
+}
+
+// Recency returns the exponential time decay 0.5^(age/halfLife). Events without a
+// time, or from the future, count as fresh.
+func Recency(t, now time.Time, halfLife time.Duration) float64 {
+	if t.IsZero() || !t.Before(now) {
+		return 1
+	}
+	if halfLife <= 0 {
+		halfLife = DefaultHalfLife
+	}
+	return math.Pow(0.5, float64(now.Sub(t))/float64(halfLife))
+}
+
+// Rank scores the candidates and returns up to topK of them, best first. A topK of
+// zero or less returns all candidates.
+func Rank(candidates []Candidate, weights Weights, now time.Time, topK int) []Result {
+	similarityWeight := 1 - weights.Recency - weights.Importance
+
+	results := make([]Result, len(candidates))
+	for i, candidate := range candidates {
+		recency := Recency(candidate.Time, now, weights.HalfLife)
+		results[i] = Result{
+			Candidate: candidate,
+			Recency:   recency,
+			Score: similarityWeight*candidate.Similarity +
+				weights.Recency*recency +
+				weights.Importance*candidate.Importance,
+		}
+	}
+
+	// Stable so that equal scores keep the similarity order of the search
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if topK <= 0 || topK > len(results) {
+		topK = len(results)
+	}
+	if weights.MMRLambda <= 0 || weights.MMRLambda >= 1 {
+		return results[:topK]
+	}
+	return mmr(results, weights.MMRLambda, topK)
+}
+
+// mmr selects results by maximal marginal relevance: each step picks the result
+// with the best trade-off between its score and its similarity to the results
+// already selected. The input must be sorted by score.
+func mmr(results []Result, lambda float64, topK int) []Result {
+	selected := make([]Result, 0, topK)
+	remaining := append([]Result(nil), results...)
+
+	for len(selected) < topK && len(remaining) > 0 {
+		best := 0
+		bestValue := math.Inf(-1)
+		for i, result := range remaining {
+			redundancy := 0.0
+			for _, chosen := range selected {
+				if sim := cosine(result.Embedding, chosen.Embedding); sim > redundancy {
+					redundancy = sim
+				}
+			}
+			value := lambda*result.Score - (1-lambda)*redundancy
+			if value > bestValue {
+				best = i
+				bestValue = value
+			}
+		}
+		selected = append(selected, remaining[best])
+		remaining = append(remaining[:best], remaining[best+1:]...)
+	}
+
+	return selected
+}
+
+// cosine returns the cosine similarity of two vectors, or 0 if either is missing
+func cosine(a, b []float32) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,128 @@
+package ranking
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestRecency(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	halfLife := 24 * time.Hour
+
+	testCases := []struct {
+		name     string
+		time     time.Time
+		expected float64
+	}{
+		{name: "unknown time", time: time.Time{}, expected: 1},
+		{name: "now", time: now, expected: 1},
+		{name: "future", time: now.Add(time.Hour), expected: 1},
+		{name: "one half-life", time: now.Add(-24 * time.Hour), expected: 0.5},
+		{name: "two half-lives", time: now.Add(-48 * time.Hour), expected: 0.25},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Recency(tc.time, now, halfLife); math.Abs(got-tc.expected) > 1e-9 {
+				t.Errorf("Expected recency %v, got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestRank(t *testing.T) {
+	now := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	old := Candidate{ID: "old", Similarity: 0.92, Time: now.Add(-2 * 365 * 24 * time.Hour), Importance: DefaultImportance}
+	recent := Candidate{ID: "recent", Similarity: 0.88, Time: now.Add(-24 * time.Hour), Importance: DefaultImportance}
+	pinned := Candidate{ID: "pinned", Similarity: 0.80, Time: now.Add(-24 * time.Hour), Importance: 1}
+
+	testCases := []struct {
+		name     string
+		weights  Weights
+		topK     int
+		expected []string
+	}{
+		{
+			name:     "similarity only",
+			weights:  Weights{},
+			expected: []string{"old", "recent", "pinned"},
+		},
+		{
+			name:     "recency prefers yesterday's note",
+			weights:  Weights{Recency: 0.3},
+			expected: []string{"recent", "pinned", "old"},
+		},
+		{
+			name:     "importance boosts pinned memory",
+			weights:  Weights{Importance: 0.4},
+			expected: []string{"pinned", "old", "recent"},
+		},
+		{
+			name:     "topK truncates",
+			weights:  Weights{Recency: 0.3},
+			topK:     1,
+			expected: []string{"recent"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			results := Rank([]Candidate{old, recent, pinned}, tc.weights, now, tc.topK)
+			if len(results) != len(tc.expected) {
+				t.Fatalf("Expected %d results, got %d", len(tc.expected), len(results))
+			}
+			for i, id := range tc.expected {
+				if results[i].ID != id {
+					t.Errorf("Expected %s at position %d, got %s", id, i, results[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestRank_MMR(t *testing.T) {
+	now := time.Now()
+	candidates := []Candidate{
+		{ID: "a", Similarity: 0.95, Embedding: []float32{1, 0, 0}},
+		{ID: "a-duplicate", Similarity: 0.94, Embedding: []float32{1, 0.01, 0}},
+		{ID: "b", Similarity: 0.80, Embedding: []float32{0, 1, 0}},
+	}
+
+	// Without diversity the near-duplicate takes the second slot
+	results := Rank(candidates, Weights{}, now, 2)
+	if results[1].ID != "a-duplicate" {
+		t.Errorf("Expected a-duplicate second without MMR, got %s", results[1].ID)
+	}
+
+	results = Rank(candidates, Weights{MMRLambda: 0.5}, now, 2)
+	if results[0].ID != "a" || results[1].ID != "b" {
+		t.Errorf("Expected [a b] with MMR, got [%s %s]", results[0].ID, results[1].ID)
+	}
+}
+
+func TestWeights_Validate(t *testing.T) {
+	testCases := []struct {
+		name        string
+		weights     Weights
+		expectError bool
+	}{
+		{name: "zero", weights: Weights{}},
+		{name: "valid", weights: Weights{Recency: 0.3, Importance: 0.2, HalfLife: time.Hour, MMRLambda: 0.7}},
+		{name: "recency out of range", weights: Weights{Recency: 1.5}, expectError: true},
+		{name: "weights above one", weights: Weights{Recency: 0.6, Importance: 0.6}, expectError: true},
+		{name: "negative half-life", weights: Weights{HalfLife: -time.Hour}, expectError: true},
+		{name: "lambda out of range", weights: Weights{MMRLambda: 2}, expectError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.weights.Validate()
+			if tc.expectError && err == nil {
+				t.Error("Expected validation error, got nil")
+			} else if !tc.expectError && err != nil {
+				t.Errorf("Unexpected validation error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,238 @@
+// Package segmentation re-cuts streamed text into units a provider can process
+// on their own. Speech recognizers and keyboards deliver text in arbitrary
+// pieces; a translation provider needs whole sentences or clauses. A Segmenter
+// buffers the pieces and returns a segment whenever a boundary is complete.
+//
+// Boundaries are language aware. Latin sentences end with . ! ? or … followed
+// by whitespace, so that decimals, URLs and common abbreviations are not cut.
+// CJK sentences end with 。！？ (or ASCII punctuation after a CJK character)
+// without a following space. Closing quotes and brackets stay with the
+// sentence they close.
+package segmentation
+
+import (
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Mode selects where streamed text is cut
+type Mode int
+
+const (
+	// ModeNone forwards every chunk as is
+	ModeNone Mode = iota
+	// ModeSentence cuts after the end of each sentence
+	ModeSentence
+	// ModePunctuation cuts after sentence ends and clause punctuation such as
+	// commas, semicolons and colons
+	ModePunctuation
+	// ModeIdleTimeout cuts only when no input arrived for the idle timeout
+	ModeIdleTimeout
+)
+
+const (
+	// DefaultIdleTimeout is how long buffered text waits for more input before
+	// it is flushed as a segment
+	DefaultIdleTimeout = time.Second
+
+	// DefaultMaxChars is the maximum segment length in characters. Longer text
+	// is cut at the last word boundary.
+	DefaultMaxChars = 500
+)
+
+// Latin abbreviations whose period does not end a sentence
+var abbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true,
+	"sr": true, "jr": true, "st": true, "vs": true, "e.g": true, "i.e": true,
+}
+
+// Segmenter buffers streamed text and cuts it into segments. It is not safe
+// for concurrent use.
+type Segmenter struct {
+	mode     Mode
+	maxChars int
+	buf      []byte
+}
+
+// New returns a segmenter for mode. A maxChars of zero or less means DefaultMaxChars.
+func New(mode Mode, maxChars int) *Segmenter {
+	if maxChars <= 0 {
+		maxChars = DefaultMaxChars
+	}
+	return &Segmenter{mode: mode, maxChars: maxChars}
+}
+
+// Push adds a chunk of text and returns the segments it completed, oldest
+// first. Segments are trimmed of surrounding whitespace and never empty. In
+// ModeNone the chunk is returned as is.
+func (s *Segmenter) Push(chunk []byte) [][]byte {
+	if s.mode == ModeNone {
+		return [][]byte{chunk}
+	}
+
+	s.buf = append(s.buf, chunk...)
+	var segments [][]byte
+	for {
+		cut := -1
+		if s.mode != ModeIdleTimeout {
+			cut = s.boundary()
+		}
+		if cut < 0 && utf8.RuneCount(s.buf) > s.maxChars {
+			cut = s.forcedCut()
+		}
+		if cut < 0 {
+			break
+		}
+		segments = appendSegment(segments, s.buf[:cut])
+		s.buf = s.buf[cut:]
+	}
+
+	// Whitespace between segments is not worth keeping
+	if len(strings.TrimSpace(string(s.buf))) == 0 {
+		s.buf = s.buf[:0]
+	}
+	return segments
+}
+
+// Flush returns the buffered text as a final segment, or nil if there is none
+func (s *Segmenter) Flush() []byte {
+	segments := appendSegment(nil, s.buf)
+	s.buf = nil
+	if len(segments) == 0 {
+		return nil
+	}
+	return segments[0]
+}
+
+// Pending reports whether text is buffered
+func (s *Segmenter) Pending() bool {
+	return len(s.buf) > 0
+}
+
+// boundary returns the byte offset after the first complete segment in the
+// buffer, or -1 if there is none yet
+func (s *Segmenter) boundary() int {
+	buf := s.buf
+	var prev rune
+	for i := 0; i < len(buf); {
+		if !utf8.FullRune(buf[i:]) {
+			// A character split across chunks
+			return -1
+		}
+		r, width := utf8.DecodeRune(buf[i:])
+		next := i + width
+
+		switch {
+		case r == '\n':
+			return next
+
+		case isCJKSentenceEnd(r) || (s.mode == ModePunctuation && isCJKClauseEnd(r)):
+			return skipClosing(buf, next)
+
+		case isLatinSentenceEnd(r) || (s.mode == ModePunctuation && isLatinClauseEnd(r)):
+			end := skipClosing(buf, skipRepeated(buf, next))
+			if isCJK(prev) && r != ',' && r != ':' {
+				// CJK text with ASCII punctuation needs no space after it
+				return end
+			}
+			if end == len(buf) {
+				// Whether whitespace follows is not known yet
+				return -1
+			}
+			following, _ := utf8.DecodeRune(buf[end:])
+			if unicode.IsSpace(following) && !(r == '.' && isAbbreviation(buf[:i])) {
+				return end
+			}
+			next = end
+		}
+
+		prev = r
+		i = next
+	}
+	return -1
+}
+
+// forcedCut returns where to cut a buffer that exceeds maxChars: after the last
+// whitespace within the limit, else at the limit
+func (s *Segmenter) forcedCut() int {
+	limit := 0
+	for n := 0; n < s.maxChars && limit < len(s.buf); n++ {
+		_, width := utf8.DecodeRune(s.buf[limit:])
+		limit += width
+	}
+	if i := strings.LastIndexFunc(string(s.buf[:limit]), unicode.IsSpace); i > 0 {
+		return i + 1
+	}
+	return limit
+}
+
+// appendSegment appends text trimmed of whitespace, unless nothing is left
+func appendSegment(segments [][]byte, text []byte) [][]byte {
+	trimmed := strings.TrimSpace(string(text))
+	if trimmed == "" {
+		return segments
+	}
+	return append(segments, []byte(trimmed))
+}
+
+// skipRepeated moves past repeated sentence punctuation such as "?!" or "..."
+func skipRepeated(buf []byte, pos int) int {
+	for pos < len(buf) {
+		r, width := utf8.DecodeRune(buf[pos:])
+		if !isLatinSentenceEnd(r) {
+			break
+		}
+		pos += width
+	}
+	return pos
+}
+
+// skipClosing moves past closing quotes and brackets that end with the sentence
+func skipClosing(buf []byte, pos int) int {
+	for pos < len(buf) {
+		r, width := utf8.DecodeRune(buf[pos:])
+		if !strings.ContainsRune(`"')]”’」』）》】`, r) {
+			break
+		}
+		pos += width
+	}
+	return pos
+}
+
+// isAbbreviation reports whether the word before a period is an abbreviation
+// or an initial, such as "Dr" or the "J" of "J. Smith"
+func isAbbreviation(before []byte) bool {
+	word := string(before)
+	if i := strings.LastIndexFunc(word, unicode.IsSpace); i >= 0 {
+		word = word[i+1:]
+	}
+	word = strings.TrimLeft(word, `"'(`)
+	if utf8.RuneCountInString(word) == 1 {
+		r, _ := utf8.DecodeRuneInString(word)
+		return unicode.IsUpper(r)
+	}
+	return abbreviations[strings.ToLower(word)]
+}
+
+func isLatinSentenceEnd(r rune) bool {
+	return r == '.' || r == '!' || r == '?' || r == '…'
+}
+
+func isLatinClauseEnd(r rune) bool {
+	return r == ',' || r == ';' || r == ':'
+}
+
+func isCJKSentenceEnd(r rune) bool {
+	return r == '。' || r == '！' || r == '？' || r == '｡'
+}
+
+func isCJKClauseEnd(r rune) bool {
+	return r == '，' || r == '、' || r == '；' || r == '：'
+}
+
+// isCJK reports whether r is a Chinese, Japanese or Korean character
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
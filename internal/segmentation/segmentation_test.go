@@ -0,0 +1,128 @@
+package segmentation
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSegmenter(t *testing.T) {
+	testCases := []struct {
+		name     string
+		mode     Mode
+		maxChars int
+		chunks   []string
+		expected []string // Segments returned by Push, then Flush
+	}{
+		{
+			name:     "none passes chunks through",
+			mode:     ModeNone,
+			chunks:   []string{"Hel", "lo. Wor", "ld"},
+			expected: []string{"Hel", "lo. Wor", "ld"},
+		},
+		{
+			name:     "sentences across chunks",
+			mode:     ModeSentence,
+			chunks:   []string{"Hello wor", "ld. How are", " you? Fine"},
+			expected: []string{"Hello world.", "How are you?", "Fine"},
+		},
+		{
+			name:     "sentence end waits for the next character",
+			mode:     ModeSentence,
+			chunks:   []string{"Pi is 3.", "14 roughly. Yes"},
+			expected: []string{"Pi is 3.14 roughly.", "Yes"},
+		},
+		{
+			name:     "abbreviations and initials",
+			mode:     ModeSentence,
+			chunks:   []string{"Dr. Smith met J. Doe, e.g. at noon. Then left"},
+			expected: []string{"Dr. Smith met J. Doe, e.g. at noon.", "Then left"},
+		},
+		{
+			name:     "closing quotes and repeated punctuation",
+			mode:     ModeSentence,
+			chunks:   []string{`He said "really?!" Then "no..." OK`},
+			expected: []string{`He said "really?!"`, `Then "no..."`, "OK"},
+		},
+		{
+			name:     "cjk sentences need no space",
+			mode:     ModeSentence,
+			chunks:   []string{"今天天气很好。我们去公", "园吧！好「的」？", "嗯"},
+			expected: []string{"今天天气很好。", "我们去公园吧！", "好「的」？", "嗯"},
+		},
+		{
+			name:     "ascii punctuation after cjk",
+			mode:     ModeSentence,
+			chunks:   []string{"你好!今天见?好"},
+			expected: []string{"你好!", "今天见?", "好"},
+		},
+		{
+			name:     "cjk character split across chunks",
+			mode:     ModeSentence,
+			chunks:   []string{"好\xe3\x80", "\x82再见"},
+			expected: []string{"好。", "再见"},
+		},
+		{
+			name:     "punctuation cuts clauses",
+			mode:     ModePunctuation,
+			chunks:   []string{"First, second; third: 1,000 items. 你好，世界、再见"},
+			expected: []string{"First,", "second;", "third:", "1,000 items.", "你好，", "世界、", "再见"},
+		},
+		{
+			name:     "sentence mode keeps clauses together",
+			mode:     ModeSentence,
+			chunks:   []string{"First, second; third.\nNext line"},
+			expected: []string{"First, second; third.", "Next line"},
+		},
+		{
+			name:     "idle timeout only cuts on flush",
+			mode:     ModeIdleTimeout,
+			chunks:   []string{"Hello. ", "World. "},
+			expected: []string{"Hello. World."},
+		},
+		{
+			name:     "long text is cut at a word",
+			mode:     ModeSentence,
+			maxChars: 12,
+			chunks:   []string{"one two three four five"},
+			expected: []string{"one two", "three four", "five"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := New(tc.mode, tc.maxChars)
+			var segments []string
+			for _, chunk := range tc.chunks {
+				for _, segment := range s.Push([]byte(chunk)) {
+					segments = append(segments, string(segment))
+				}
+			}
+			if tail := s.Flush(); tail != nil {
+				segments = append(segments, string(tail))
+			}
+			if s.Pending() {
+				t.Error("Expected nothing pending after Flush")
+			}
+			if !reflect.DeepEqual(segments, tc.expected) {
+				t.Errorf("Expected segments\n%q\ngot\n%q", tc.expected, segments)
+			}
+		})
+	}
+}
+
+func TestSegmenter_MaxCharsWithoutSpaces(t *testing.T) {
+	s := New(ModeSentence, 10)
+	segments := s.Push([]byte(strings.Repeat("字", 25)))
+	if len(segments) != 2 {
+		t.Fatalf("Expected 2 forced segments, got %d", len(segments))
+	}
+	for _, segment := range segments {
+		if string(segment) != strings.Repeat("字", 10) {
+			t.Errorf("Expected 10 characters, got %q", segment)
+		}
+	}
+	if tail := s.Flush(); string(tail) != strings.Repeat("字", 5) {
+		t.Errorf("Expected the rest on flush, got %q", tail)
+	}
+}
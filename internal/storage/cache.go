@@ -0,0 +1,27 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// ResponseCacheStore stores provider responses by request key until they expire
+type ResponseCacheStore interface {
+	// GetCachedResponse returns the cached response for the key, or nil if there is
+	// no entry or the entry has expired
+	GetCachedResponse(ctx context.Context, key string) (*CachedResponse, error)
+	
+	// PutCachedResponse stores a response and evicts the oldest entries of the same
+	// provider beyond maxEntries (zero means no limit)
+	PutCachedResponse(ctx context.Context, entry CachedResponse, maxEntries int) error
+}
+
+// CachedResponse is a provider response stored in the response cache
+type CachedResponse struct {
+	Key       string    // Hash of provider, model and normalized request data
+	Provider  string    // Provider that produced the response
+	Model     string    // Model requested, if any
+	Response  string    // The provider's response
+	CreatedAt time.Time // When the response was cached
+	ExpiresAt time.Time // When the entry stops being served
+}
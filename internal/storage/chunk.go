@@ -0,0 +1,25 @@
+package storage
+
+import "context"
+
+// ChunkStorage stores an embedding per chunk of a long event, linked to the event
+// by a chunk_of edge with the chunk's offsets. QuerySimilar aggregates chunk hits
+// back to the event.
+type ChunkStorage interface {
+	// AddChunkEmbeddings adds the chunk embeddings of an existing event
+	AddChunkEmbeddings(ctx context.Context, eventID string, chunks []ChunkEmbedding) error
+}
+
+// ChunkEmbedding is the embedding of a chunk of an event's text
+type ChunkEmbedding struct {
+	ChunkRef
+	Embedding []float32
+}
+
+// ChunkRef locates a chunk in the text that was embedded for an event.
+// Start and End are byte offsets.
+type ChunkRef struct {
+	Index int
+	Start int
+	End   int
+}
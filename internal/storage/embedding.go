@@ -0,0 +1,10 @@
+package storage
+
+import "context"
+
+// EmbeddingGetter reads back the stored embeddings of events, e.g. for diversity
+// re-ranking of search results
+type EmbeddingGetter interface {
+	// GetEmbeddings returns the embedding of each event that has one, keyed by event ID
+	GetEmbeddings(ctx context.Context, eventIDs []string) (map[string][]float32, error)
+}
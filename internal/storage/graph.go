@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// GraphStorage stores labelled edges between nodes, e.g. from a response event to
+// the events it was derived from
+type GraphStorage interface {
+	// CreateEdge links the source node to the target node with the given label
+	CreateEdge(ctx context.Context, sourceID, targetID, label string) error
+	
+	// GetEdges returns the outgoing edges of a node, optionally restricted to a label
+	GetEdges(ctx context.Context, sourceID, label string) ([]Edge, error)
+}
+
+// EdgeLister lists all stored edges of a label, e.g. to find clusters of
+// duplicate memories
+type EdgeLister interface {
+	// ListEdges returns every edge with the given label, oldest first
+	ListEdges(ctx context.Context, label string) ([]Edge, error)
+}
+
+// Edge is a labelled relationship between two nodes
+type Edge struct {
+	ID         string
+	SourceID   string
+	TargetID   string
+	Label      string
+	Properties map[string]string // Optional edge data, e.g. the offsets of a chunk_of edge
+	CreatedAt  time.Time
+}
@@ -34,16 +34,65 @@ type Storage interface {
 
 // Filter represents query filter parameters for advanced queries
 type Filter struct {
-	UserID           *string           // Filter by user ID (nil means no filter)
-	SessionID        *string           // Filter by session ID (nil means no filter)
-	EventTypes       []string          // Filter by event types (empty slice means no filter)
-	TimeFrom         *time.Time        // Filter events after this time (nil means no filter)
-	TimeTo           *time.Time        // Filter events before this time (nil means no filter)
-	AttributeFilters map[string]string // Filter by event attributes with exact match (AND logic)
+	UserID            *string           // Filter by user ID (nil means no filter)
+	SessionID         *string           // Filter by session ID (nil means no filter)
+	EventTypes        []string          // Filter by event types (empty slice means no filter)
+	ExcludeEventTypes []string          // Leave out events of these types
+	TimeFrom          *time.Time        // Filter events after this time (nil means no filter)
+	TimeTo            *time.Time        // Filter events before this time (nil means no filter)
+	AttributeFilters  map[string]string // Filter by event attributes with exact match (AND logic)
+}
+
+// Matches reports whether the event passes the filter, as a query with the
+// filter would decide
+func (f *Filter) Matches(event *eventsv1.Event) bool {
+	if f == nil {
+		return true
+	}
+	if f.UserID != nil && event.UserId != *f.UserID {
+		return false
+	}
+	if f.SessionID != nil && event.SessionId != *f.SessionID {
+		return false
+	}
+	if len(f.EventTypes) > 0 && !containsString(f.EventTypes, event.Type) {
+		return false
+	}
+	if containsString(f.ExcludeEventTypes, event.Type) {
+		return false
+	}
+	if f.TimeFrom != nil || f.TimeTo != nil {
+		if event.Time == nil {
+			return false
+		}
+		eventTime := event.Time.AsTime()
+		if f.TimeFrom != nil && eventTime.Before(*f.TimeFrom) {
+			return false
+		}
+		if f.TimeTo != nil && eventTime.After(*f.TimeTo) {
+			return false
+		}
+	}
+	for key, value := range f.AttributeFilters {
+		if event.Attributes[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
 }
 
 // QueryResult represents a single result from a vector similarity query
 type QueryResult struct {
-	ID    string  // Event ID
-	Score float32 // Similarity score (higher is more similar)
+	ID    string    // Event ID
+	Score float32   // Similarity score (higher is more similar)
+	Chunk *ChunkRef // Best matching chunk of a chunked event, nil if the whole event matched
 }
\ No newline at end of file
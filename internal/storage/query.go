@@ -0,0 +1,13 @@
+package storage
+
+import (
+	"context"
+	
+	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+)
+
+// EventQuerier lists stored events by metadata alone, without a vector search
+type EventQuerier interface {
+	// QueryEvents returns up to limit events matching the filter, most recent first
+	QueryEvents(ctx context.Context, filter *Filter, limit int) ([]*eventsv1.Event, error)
+}
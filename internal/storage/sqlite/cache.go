@@ -0,0 +1,105 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+// initCacheSchema creates the table backing the provider response cache
+func (p *Provider) initCacheSchema() error {
+	createCacheTableSQL := `
+	CREATE TABLE IF NOT EXISTS response_cache (
+		key TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		model TEXT,
+		response TEXT NOT NULL,
+		created_at INTEGER NOT NULL, -- Unix nanoseconds, used for eviction order
+		expires_at INTEGER NOT NULL  -- Unix nanoseconds
+	);
+	CREATE INDEX IF NOT EXISTS idx_response_cache_provider_created ON response_cache(provider, created_at);
+	CREATE INDEX IF NOT EXISTS idx_response_cache_expires ON response_cache(expires_at);
+	`
+	
+	if _, err := p.db.Exec(createCacheTableSQL); err != nil {
+		return fmt.Errorf("failed to create response cache table: %w", err)
+	}
+	
+	return nil
+}
+
+// GetCachedResponse returns the cached response for the key, or nil if there is
+// no entry or the entry has expired
+func (p *Provider) GetCachedResponse(ctx context.Context, key string) (*storage.CachedResponse, error) {
+	query := `
+		SELECT provider, model, response, created_at, expires_at
+		FROM response_cache
+		WHERE key = ?
+	`
+	
+	var entry storage.CachedResponse
+	var model sql.NullString
+	var createdAt, expiresAt int64
+	err := p.db.QueryRowContext(ctx, query, key).Scan(&entry.Provider, &model, &entry.Response, &createdAt, &expiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read cached response: %w", err)
+	}
+	
+	entry.Key = key
+	entry.Model = model.String
+	entry.CreatedAt = time.Unix(0, createdAt)
+	entry.ExpiresAt = time.Unix(0, expiresAt)
+	
+	if !time.Now().Before(entry.ExpiresAt) {
+		// Expired entries are removed lazily
+		if _, err := p.db.ExecContext(ctx, "DELETE FROM response_cache WHERE key = ?", key); err != nil {
+			return nil, fmt.Errorf("failed to delete expired cache entry: %w", err)
+		}
+		return nil, nil
+	}
+	
+	return &entry, nil
+}
+
+// PutCachedResponse stores a response and evicts the oldest entries of the same
+// provider beyond maxEntries (zero means no limit)
+func (p *Provider) PutCachedResponse(ctx context.Context, entry storage.CachedResponse, maxEntries int) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+	
+	query := `INSERT OR REPLACE INTO response_cache (key, provider, model, response, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)`
+	_, err := p.db.ExecContext(ctx, query, entry.Key, entry.Provider, entry.Model, entry.Response,
+		entry.CreatedAt.UnixNano(), entry.ExpiresAt.UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to store cached response: %w", err)
+	}
+	
+	// Drop expired entries
+	if _, err := p.db.ExecContext(ctx, "DELETE FROM response_cache WHERE expires_at <= ?", time.Now().UnixNano()); err != nil {
+		return fmt.Errorf("failed to purge expired cache entries: %w", err)
+	}
+	
+	// Enforce the size limit by evicting the oldest entries of this provider
+	if maxEntries > 0 {
+		evictSQL := `
+			DELETE FROM response_cache
+			WHERE provider = ? AND key NOT IN (
+				SELECT key FROM response_cache
+				WHERE provider = ?
+				ORDER BY created_at DESC
+				LIMIT ?
+			)
+		`
+		if _, err := p.db.ExecContext(ctx, evictSQL, entry.Provider, entry.Provider, maxEntries); err != nil {
+			return fmt.Errorf("failed to evict cache entries: %w", err)
+		}
+	}
+	
+	return nil
+}
@@ -0,0 +1,153 @@
+package sqlite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/coder/hnsw"
+
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+const (
+	// Edge label linking a chunk vector to its event
+	chunkOfLabel = "chunk_of"
+	
+	// QuerySimilar searches this many vectors per requested event, since a
+	// chunked event can match with several of its chunks
+	chunkSearchFactor = 4
+)
+
+// initChunkSchema adds the properties column that chunk_of edges keep their
+// offsets in. Databases created before the column existed are migrated in place.
+func (p *Provider) initChunkSchema() error {
+	rows, err := p.db.Query("PRAGMA table_info(edges)")
+	if err != nil {
+		return fmt.Errorf("failed to inspect edges table: %w", err)
+	}
+	defer rows.Close()
+	
+	hasProperties := false
+	for rows.Next() {
+		var cid, notNull, primaryKey int
+		var name, columnType string
+		var defaultValue interface{}
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultValue, &primaryKey); err != nil {
+			return fmt.Errorf("failed to scan edges column: %w", err)
+		}
+		if name == "properties" {
+			hasProperties = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to inspect edges table: %w", err)
+	}
+	rows.Close()
+	
+	if !hasProperties {
+		if _, err := p.db.Exec("ALTER TABLE edges ADD COLUMN properties TEXT"); err != nil {
+			return fmt.Errorf("failed to add edge properties column: %w", err)
+		}
+	}
+	
+	return nil
+}
+
+// AddChunkEmbeddings adds the chunk embeddings of an existing event. Each chunk is
+// stored as a vector node linked to the event by a chunk_of edge with its offsets.
+// The nodes and edges are written in one transaction, so a failure leaves no
+// orphan chunks, and the vectors are indexed only once it commits.
+func (p *Provider) AddChunkEmbeddings(ctx context.Context, eventID string, chunks []storage.ChunkEmbedding) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+	
+	var exists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM nodes WHERE id = ? AND type = 'event')", eventID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to check event existence: %w", err)
+	}
+	if !exists {
+		return fmt.Errorf("event with ID %s not found", eventID)
+	}
+	
+	nodes := make([]hnsw.Node[string], 0, len(chunks))
+	now := time.Now().UnixNano()
+	for _, chunk := range chunks {
+		vectorID := fmt.Sprintf("vec_%d_%d_%d", now, chunk.Index, len(chunk.Embedding))
+		_, err := tx.ExecContext(ctx, `INSERT INTO nodes (id, type, content) VALUES (?, ?, ?)`,
+			vectorID, "vector", serializeVector(chunk.Embedding))
+		if err != nil {
+			return fmt.Errorf("failed to store vector of chunk %d: %w", chunk.Index, err)
+		}
+		
+		encoded, err := encodeEdgeProperties(map[string]string{
+			"index": strconv.Itoa(chunk.Index),
+			"start": strconv.Itoa(chunk.Start),
+			"end":   strconv.Itoa(chunk.End),
+		})
+		if err != nil {
+			return err
+		}
+		edgeID := fmt.Sprintf("edge_%s_%s_%d", vectorID, eventID, now)
+		_, err = tx.ExecContext(ctx, `INSERT INTO edges (id, source_node_id, target_node_id, label, properties) VALUES (?, ?, ?, ?, ?)`,
+			edgeID, vectorID, eventID, chunkOfLabel, encoded)
+		if err != nil {
+			return fmt.Errorf("failed to link chunk %d: %w", chunk.Index, err)
+		}
+		
+		nodes = append(nodes, hnsw.MakeNode(vectorID, chunk.Embedding))
+	}
+	
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit chunk embeddings: %w", err)
+	}
+	
+	p.indexMu.Lock()
+	defer p.indexMu.Unlock()
+	if len(nodes) > 0 {
+		p.hnswIndex.Add(nodes...)
+	}
+	
+	return nil
+}
+
+// encodeEdgeProperties serializes edge properties, returning nil for none
+func encodeEdgeProperties(properties map[string]string) (interface{}, error) {
+	if len(properties) == 0 {
+		return nil, nil
+	}
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize edge properties: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeEdgeProperties parses serialized edge properties, ignoring malformed data
+func decodeEdgeProperties(data *string) map[string]string {
+	if data == nil || *data == "" {
+		return nil
+	}
+	var properties map[string]string
+	if err := json.Unmarshal([]byte(*data), &properties); err != nil {
+		return nil
+	}
+	return properties
+}
+
+// chunkRefFromProperties reads the offsets of a chunk_of edge
+func chunkRefFromProperties(properties map[string]string) *storage.ChunkRef {
+	index, errIndex := strconv.Atoi(properties["index"])
+	start, errStart := strconv.Atoi(properties["start"])
+	end, errEnd := strconv.Atoi(properties["end"])
+	if errIndex != nil || errStart != nil || errEnd != nil {
+		return nil
+	}
+	return &storage.ChunkRef{Index: index, Start: start, End: end}
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -116,6 +117,18 @@ func (p *Provider) initSchema() error {
 		return fmt.Errorf("failed to create edges indexes: %w", err)
 	}
 	
+	if err := p.initChunkSchema(); err != nil {
+		return err
+	}
+	
+	// Create auxiliary tables
+	if err := p.initUsageSchema(); err != nil {
+		return err
+	}
+	if err := p.initCacheSchema(); err != nil {
+		return err
+	}
+	
 	return nil
 }
 
@@ -253,6 +266,10 @@ func (p *Provider) StoreEvent(ctx context.Context, event *eventsv1.Event, embedd
 	if event.SessionId != "" {
 		eventMap["session_id"] = event.SessionId
 	}
+	if len(event.Attributes) > 0 {
+		// Stored as an object so that attribute filters can match $.attributes."key"
+		eventMap["attributes"] = event.Attributes
+	}
 	
 	// Handle event data
 	if event.Data != nil {
@@ -326,12 +343,22 @@ func (p *Provider) StoreVector(ctx context.Context, vector []float32) (string, e
 
 // CreateEdge creates an edge between two nodes
 func (p *Provider) CreateEdge(ctx context.Context, sourceID, targetID, label string) error {
+	return p.createEdge(ctx, sourceID, targetID, label, nil)
+}
+
+// createEdge creates an edge between two nodes with optional properties
+func (p *Provider) createEdge(ctx context.Context, sourceID, targetID, label string, properties map[string]string) error {
 	// Generate a unique ID for the edge
 	edgeID := fmt.Sprintf("edge_%s_%s_%d", sourceID, targetID, time.Now().UnixNano())
 	
+	encoded, err := encodeEdgeProperties(properties)
+	if err != nil {
+		return err
+	}
+	
 	// Insert the edge
-	query := `INSERT INTO edges (id, source_node_id, target_node_id, label) VALUES (?, ?, ?, ?)`
-	_, err := p.db.ExecContext(ctx, query, edgeID, sourceID, targetID, label)
+	query := `INSERT INTO edges (id, source_node_id, target_node_id, label, properties) VALUES (?, ?, ?, ?, ?)`
+	_, err = p.db.ExecContext(ctx, query, edgeID, sourceID, targetID, label, encoded)
 	if err != nil {
 		return fmt.Errorf("failed to create edge: %w", err)
 	}
@@ -339,6 +366,61 @@ func (p *Provider) CreateEdge(ctx context.Context, sourceID, targetID, label str
 	return nil
 }
 
+// GetEdges returns the outgoing edges of a node, optionally restricted to a label
+func (p *Provider) GetEdges(ctx context.Context, sourceID, label string) ([]storage.Edge, error) {
+	query := `
+		SELECT id, source_node_id, target_node_id, label, properties, CAST(strftime('%s', created_at) AS INTEGER)
+		FROM edges
+		WHERE source_node_id = ?
+	`
+	args := []interface{}{sourceID}
+	if label != "" {
+		query += " AND label = ?"
+		args = append(args, label)
+	}
+	query += " ORDER BY rowid ASC"
+	
+	return p.queryEdges(ctx, query, args...)
+}
+
+// ListEdges returns every edge with the given label, oldest first
+func (p *Provider) ListEdges(ctx context.Context, label string) ([]storage.Edge, error) {
+	query := `
+		SELECT id, source_node_id, target_node_id, label, properties, CAST(strftime('%s', created_at) AS INTEGER)
+		FROM edges
+		WHERE label = ?
+		ORDER BY rowid ASC
+	`
+	return p.queryEdges(ctx, query, label)
+}
+
+// queryEdges runs an edge query and scans the resulting edges
+func (p *Provider) queryEdges(ctx context.Context, query string, args ...interface{}) ([]storage.Edge, error) {
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query edges: %w", err)
+	}
+	defer rows.Close()
+	
+	var edges []storage.Edge
+	for rows.Next() {
+		var edge storage.Edge
+		var properties *string
+		var createdAt int64
+		if err := rows.Scan(&edge.ID, &edge.SourceID, &edge.TargetID, &edge.Label, &properties, &createdAt); err != nil {
+			return nil, fmt.Errorf("failed to scan edge: %w", err)
+		}
+		edge.Properties = decodeEdgeProperties(properties)
+		edge.CreatedAt = time.Unix(createdAt, 0)
+		edges = append(edges, edge)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	
+	return edges, nil
+}
+
 // AddEmbeddingToEvent adds an embedding to an existing event
 func (p *Provider) AddEmbeddingToEvent(ctx context.Context, eventID string, embedding []float32) error {
 	// First, verify the event exists
@@ -366,6 +448,53 @@ func (p *Provider) AddEmbeddingToEvent(ctx context.Context, eventID string, embe
 	return nil
 }
 
+// GetEmbeddings returns the embedding of each event that has one, keyed by event ID
+func (p *Provider) GetEmbeddings(ctx context.Context, eventIDs []string) (map[string][]float32, error) {
+	embeddings := make(map[string][]float32, len(eventIDs))
+	if len(eventIDs) == 0 {
+		return embeddings, nil
+	}
+	
+	placeholders := make([]string, len(eventIDs))
+	args := make([]interface{}, len(eventIDs))
+	for i, id := range eventIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	
+	// Vectors are linked to their event by an embedding_of edge
+	query := fmt.Sprintf(`
+		SELECT e.target_node_id, n.content
+		FROM edges e
+		JOIN nodes n ON n.id = e.source_node_id AND n.type = 'vector'
+		WHERE e.label = 'embedding_of' AND e.target_node_id IN (%s)
+		ORDER BY e.rowid ASC
+	`, strings.Join(placeholders, ","))
+	
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query embeddings: %w", err)
+	}
+	defer rows.Close()
+	
+	for rows.Next() {
+		var eventID string
+		var contentBlob []byte
+		if err := rows.Scan(&eventID, &contentBlob); err != nil {
+			return nil, fmt.Errorf("failed to scan embedding: %w", err)
+		}
+		// The latest embedding of an event wins
+		if embedding := deserializeVector(contentBlob); len(embedding) > 0 {
+			embeddings[eventID] = embedding
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	
+	return embeddings, nil
+}
+
 // GetEventByID retrieves a single event by its ID
 func (p *Provider) GetEventByID(ctx context.Context, eventID string) (*eventsv1.Event, error) {
 	query := `
@@ -422,6 +551,14 @@ func (p *Provider) GetEventByID(ctx context.Context, eventID string) (*eventsv1.
 	if sessionId, ok := eventMap["session_id"].(string); ok {
 		event.SessionId = sessionId
 	}
+	if attributes, ok := eventMap["attributes"].(map[string]interface{}); ok {
+		event.Attributes = make(map[string]string, len(attributes))
+		for key, value := range attributes {
+			if str, ok := value.(string); ok {
+				event.Attributes[key] = str
+			}
+		}
+	}
 	
 	// Parse time
 	if timeStr, ok := eventMap["time"].(string); ok {
@@ -518,6 +655,14 @@ func (p *Provider) BatchGetEvents(ctx context.Context, ids []string) ([]*eventsv
 		if sessionId, ok := eventMap["session_id"].(string); ok {
 			event.SessionId = sessionId
 		}
+		if attributes, ok := eventMap["attributes"].(map[string]interface{}); ok {
+			event.Attributes = make(map[string]string, len(attributes))
+			for key, value := range attributes {
+				if str, ok := value.(string); ok {
+					event.Attributes[key] = str
+				}
+			}
+		}
 		
 		// Parse time
 		if timeStr, ok := eventMap["time"].(string); ok {
@@ -611,6 +756,14 @@ func (p *Provider) GetAllEvents(ctx context.Context, offset, limit int) ([]*even
 		if sessionId, ok := eventMap["session_id"].(string); ok {
 			event.SessionId = sessionId
 		}
+		if attributes, ok := eventMap["attributes"].(map[string]interface{}); ok {
+			event.Attributes = make(map[string]string, len(attributes))
+			for key, value := range attributes {
+				if str, ok := value.(string); ok {
+					event.Attributes[key] = str
+				}
+			}
+		}
 		
 		// Parse time
 		if timeStr, ok := eventMap["time"].(string); ok {
@@ -663,6 +816,9 @@ func (p *Provider) QuerySimilar(ctx context.Context, embedding []float32, topK i
 		}
 	}
 	
+	// Chunked events have several vectors, search more vectors than events requested
+	candidates := topK * chunkSearchFactor
+	
 	// Lock for reading
 	p.indexMu.RLock()
 	defer p.indexMu.RUnlock()
@@ -686,7 +842,7 @@ func (p *Provider) QuerySimilar(ctx context.Context, embedding []float32, topK i
 		query := fmt.Sprintf(`
 			SELECT source_node_id
 			FROM edges
-			WHERE target_node_id IN (%s) AND label = 'embedding_of'
+			WHERE target_node_id IN (%s) AND label IN ('embedding_of', 'chunk_of')
 		`, strings.Join(placeholders, ","))
 		
 		rows, err := p.db.QueryContext(ctx, query, args...)
@@ -707,7 +863,7 @@ func (p *Provider) QuerySimilar(ctx context.Context, embedding []float32, topK i
 		// Search all vectors, then filter results
 		// Note: This is not optimal but HNSW doesn't support filtered search
 		// In production, consider using a specialized vector DB with filtering support
-		allNodes := p.hnswIndex.Search(embedding, topK*10) // Search more to account for filtering
+		allNodes := p.hnswIndex.Search(embedding, candidates*10) // Search more to account for filtering
 		
 		// Filter to only include vectors associated with eligible events
 		vectorIDSet := make(map[string]bool)
@@ -715,18 +871,18 @@ func (p *Provider) QuerySimilar(ctx context.Context, embedding []float32, topK i
 			vectorIDSet[id] = true
 		}
 		
-		vectorNodes = make([]hnsw.Node[string], 0, topK)
+		vectorNodes = make([]hnsw.Node[string], 0, candidates)
 		for _, node := range allNodes {
 			if vectorIDSet[node.Key] {
 				vectorNodes = append(vectorNodes, node)
-				if len(vectorNodes) >= topK {
+				if len(vectorNodes) >= candidates {
 					break
 				}
 			}
 		}
 	} else {
 		// No filter, search all vectors
-		vectorNodes = p.hnswIndex.Search(embedding, topK)
+		vectorNodes = p.hnswIndex.Search(embedding, candidates)
 	}
 	
 	// Collect vector node IDs and scores
@@ -753,9 +909,9 @@ func (p *Provider) QuerySimilar(ctx context.Context, embedding []float32, topK i
 	}
 	
 	query := fmt.Sprintf(`
-		SELECT source_node_id, target_node_id
+		SELECT source_node_id, target_node_id, label, properties
 		FROM edges
-		WHERE source_node_id IN (%s) AND label = 'embedding_of'
+		WHERE source_node_id IN (%s) AND label IN ('embedding_of', 'chunk_of')
 	`, strings.Join(placeholders, ","))
 	
 	rows, err := p.db.QueryContext(ctx, query, args...)
@@ -764,20 +920,43 @@ func (p *Provider) QuerySimilar(ctx context.Context, embedding []float32, topK i
 	}
 	defer rows.Close()
 	
-	// Build results
-	results := make([]storage.QueryResult, 0, topK)
+	// Aggregate vector hits by event, keeping the best scoring vector. For a
+	// chunked event this is its best matching chunk.
+	best := make(map[string]storage.QueryResult)
 	for rows.Next() {
-		var vectorID, eventID string
-		if err := rows.Scan(&vectorID, &eventID); err != nil {
+		var vectorID, eventID, label string
+		var properties *string
+		if err := rows.Scan(&vectorID, &eventID, &label, &properties); err != nil {
 			continue
 		}
 		
-		if score, ok := scoreMap[vectorID]; ok {
-			results = append(results, storage.QueryResult{
-				ID:    eventID,
-				Score: score,
-			})
+		score, ok := scoreMap[vectorID]
+		if !ok {
+			continue
 		}
+		if existing, seen := best[eventID]; seen && existing.Score >= score {
+			continue
+		}
+		result := storage.QueryResult{ID: eventID, Score: score}
+		if label == chunkOfLabel {
+			result.Chunk = chunkRefFromProperties(decodeEdgeProperties(properties))
+		}
+		best[eventID] = result
+	}
+	
+	// Build results, most similar first
+	results := make([]storage.QueryResult, 0, len(best))
+	for _, result := range best {
+		results = append(results, result)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > topK {
+		results = results[:topK]
 	}
 	
 	return results, nil
@@ -785,6 +964,92 @@ func (p *Provider) QuerySimilar(ctx context.Context, embedding []float32, topK i
 
 // findFilteredEventIDs finds event node IDs that match the given filter
 func (p *Provider) findFilteredEventIDs(ctx context.Context, filter *storage.Filter) (map[string]bool, error) {
+	whereConditions, args := buildFilterConditions(filter)
+	
+	// Build query
+	query := "SELECT id FROM nodes WHERE type = 'event'"
+	if len(whereConditions) > 0 {
+		query += " AND " + strings.Join(whereConditions, " AND ")
+	}
+	
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query filtered events: %w", err)
+	}
+	defer rows.Close()
+	
+	eligibleIDs := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			continue
+		}
+		eligibleIDs[id] = true
+	}
+	
+	return eligibleIDs, nil
+}
+
+// QueryEvents returns up to limit events matching the filter, most recent first
+func (p *Provider) QueryEvents(ctx context.Context, filter *storage.Filter, limit int) ([]*eventsv1.Event, error) {
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be positive")
+	}
+	
+	var whereConditions []string
+	var args []interface{}
+	if filter != nil {
+		whereConditions, args = buildFilterConditions(filter)
+	}
+	
+	// Events without a time fall back to their insertion time
+	query := "SELECT id FROM nodes WHERE type = 'event'"
+	if len(whereConditions) > 0 {
+		query += " AND " + strings.Join(whereConditions, " AND ")
+	}
+	query += " ORDER BY COALESCE(datetime(json_extract(content, '$.time')), created_at) DESC, rowid DESC LIMIT ?"
+	args = append(args, limit)
+	
+	rows, err := p.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+	
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan event id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	
+	events, err := p.BatchGetEvents(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	
+	// BatchGetEvents does not preserve order, restore the recency order
+	byID := make(map[string]*eventsv1.Event, len(events))
+	for _, event := range events {
+		byID[event.Id] = event
+	}
+	ordered := make([]*eventsv1.Event, 0, len(events))
+	for _, id := range ids {
+		if event, ok := byID[id]; ok {
+			ordered = append(ordered, event)
+		}
+	}
+	
+	return ordered, nil
+}
+
+// buildFilterConditions translates a filter into SQL conditions on event nodes
+func buildFilterConditions(filter *storage.Filter) ([]string, []interface{}) {
 	var whereConditions []string
 	var args []interface{}
 	
@@ -808,6 +1073,15 @@ func (p *Provider) findFilteredEventIDs(ctx context.Context, filter *storage.Fil
 		whereConditions = append(whereConditions, fmt.Sprintf("json_extract(content, '$.type') IN (%s)", strings.Join(placeholders, ",")))
 	}
 	
+	if len(filter.ExcludeEventTypes) > 0 {
+		placeholders := make([]string, len(filter.ExcludeEventTypes))
+		for i, eventType := range filter.ExcludeEventTypes {
+			placeholders[i] = "?"
+			args = append(args, eventType)
+		}
+		whereConditions = append(whereConditions, fmt.Sprintf("json_extract(content, '$.type') NOT IN (%s)", strings.Join(placeholders, ",")))
+	}
+	
 	if filter.TimeFrom != nil {
 		whereConditions = append(whereConditions, "datetime(json_extract(content, '$.time')) >= datetime(?)")
 		args = append(args, filter.TimeFrom.Format(time.RFC3339))
@@ -829,28 +1103,7 @@ func (p *Provider) findFilteredEventIDs(ctx context.Context, filter *storage.Fil
 		}
 	}
 	
-	// Build query
-	query := "SELECT id FROM nodes WHERE type = 'event'"
-	if len(whereConditions) > 0 {
-		query += " AND " + strings.Join(whereConditions, " AND ")
-	}
-	
-	rows, err := p.db.QueryContext(ctx, query, args...)
-	if err != nil {
-		return nil, fmt.Errorf("failed to query filtered events: %w", err)
-	}
-	defer rows.Close()
-	
-	eligibleIDs := make(map[string]bool)
-	for rows.Next() {
-		var id string
-		if err := rows.Scan(&id); err != nil {
-			continue
-		}
-		eligibleIDs[id] = true
-	}
-	
-	return eligibleIDs, nil
+	return whereConditions, args
 }
 
 // Close closes the database connection
@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 	
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
+	"github.com/soaringjerry/pcas/internal/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 func TestVectorStorage(t *testing.T) {
@@ -92,4 +95,164 @@ func TestMultipleVectorStorage(t *testing.T) {
 	for i := 1; i < len(results); i++ {
 		assert.LessOrEqual(t, results[i].Score, results[i-1].Score)
 	}
-}
\ No newline at end of file
+}
+func TestQueryEvents(t *testing.T) {
+	store, err := NewProvider(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	
+	querier, ok := store.(storage.EventQuerier)
+	require.True(t, ok, "sqlite provider should implement EventQuerier")
+	
+	ctx := context.Background()
+	base := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	
+	// Insert out of time order to verify sorting by event time
+	for _, i := range []int{2, 0, 3, 1} {
+		sessionID := "session-a"
+		if i == 3 {
+			sessionID = "session-b"
+		}
+		err := store.StoreEvent(ctx, &eventsv1.Event{
+			Id:          fmt.Sprintf("event-%d", i),
+			Type:        "pcas.user.prompt.v1",
+			Source:      "test-source",
+			Specversion: "1.0",
+			SessionId:   sessionID,
+			Time:        timestamppb.New(base.Add(time.Duration(i) * time.Minute)),
+		}, nil)
+		require.NoError(t, err)
+	}
+	
+	sessionID := "session-a"
+	events, err := querier.QueryEvents(ctx, &storage.Filter{SessionID: &sessionID}, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	assert.Equal(t, "event-2", events[0].Id)
+	assert.Equal(t, "event-1", events[1].Id)
+	
+	_, err = querier.QueryEvents(ctx, nil, 0)
+	assert.Error(t, err)
+}
+
+func TestGetEmbeddings(t *testing.T) {
+	store, err := NewProvider(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	
+	getter, ok := store.(storage.EmbeddingGetter)
+	require.True(t, ok, "sqlite provider should implement EmbeddingGetter")
+	
+	ctx := context.Background()
+	err = store.StoreEvent(ctx, &eventsv1.Event{
+		Id:          "with-embedding",
+		Type:        "user.note.v1",
+		Source:      "test-source",
+		Specversion: "1.0",
+		Attributes:  map[string]string{"importance": "0.9"},
+	}, []float32{0.1, 0.2, 0.3})
+	require.NoError(t, err)
+	err = store.StoreEvent(ctx, &eventsv1.Event{
+		Id:          "without-embedding",
+		Type:        "user.note.v1",
+		Source:      "test-source",
+		Specversion: "1.0",
+	}, nil)
+	require.NoError(t, err)
+	
+	embeddings, err := getter.GetEmbeddings(ctx, []string{"with-embedding", "without-embedding"})
+	require.NoError(t, err)
+	require.Len(t, embeddings, 1)
+	assert.Equal(t, []float32{0.1, 0.2, 0.3}, embeddings["with-embedding"])
+	
+	// Attributes are stored with the event and can be filtered on
+	event, err := store.GetEventByID(ctx, "with-embedding")
+	require.NoError(t, err)
+	assert.Equal(t, "0.9", event.Attributes["importance"])
+	
+	results, err := store.QuerySimilar(ctx, []float32{0.1, 0.2, 0.3}, 5, &storage.Filter{
+		AttributeFilters: map[string]string{"importance": "0.9"},
+	})
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "with-embedding", results[0].ID)
+}
+
+func TestChunkEmbeddings(t *testing.T) {
+	store, err := NewProvider(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	
+	chunkStore, ok := store.(storage.ChunkStorage)
+	require.True(t, ok, "sqlite provider should implement ChunkStorage")
+	
+	ctx := context.Background()
+	for _, id := range []string{"transcript", "note"} {
+		err := store.StoreEvent(ctx, &eventsv1.Event{
+			Id:          id,
+			Type:        "user.note.v1",
+			Source:      "test-source",
+			Specversion: "1.0",
+		}, nil)
+		require.NoError(t, err)
+	}
+	require.NoError(t, store.AddEmbeddingToEvent(ctx, "note", []float32{0.7, 0.7, 0}))
+	
+	err = chunkStore.AddChunkEmbeddings(ctx, "transcript", []storage.ChunkEmbedding{
+		{ChunkRef: storage.ChunkRef{Index: 0, Start: 0, End: 2000}, Embedding: []float32{0, 1, 0}},
+		{ChunkRef: storage.ChunkRef{Index: 1, Start: 1800, End: 3600}, Embedding: []float32{1, 0, 0}},
+		{ChunkRef: storage.ChunkRef{Index: 2, Start: 3400, End: 4100}, Embedding: []float32{0, 0, 1}},
+	})
+	require.NoError(t, err)
+	
+	// Chunk hits are aggregated to one result per event, with the best chunk
+	for _, filter := range []*storage.Filter{nil, {EventTypes: []string{"user.note.v1"}}} {
+		results, err := store.QuerySimilar(ctx, []float32{1, 0, 0}, 2, filter)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "transcript", results[0].ID)
+		require.NotNil(t, results[0].Chunk)
+		assert.Equal(t, storage.ChunkRef{Index: 1, Start: 1800, End: 3600}, *results[0].Chunk)
+		assert.Equal(t, "note", results[1].ID)
+		assert.Nil(t, results[1].Chunk)
+	}
+	
+	err = chunkStore.AddChunkEmbeddings(ctx, "missing", nil)
+	assert.Error(t, err)
+
+	// A batch that fails part way leaves no chunks behind
+	err = chunkStore.AddChunkEmbeddings(ctx, "note", []storage.ChunkEmbedding{
+		{ChunkRef: storage.ChunkRef{Index: 0, Start: 0, End: 10}, Embedding: []float32{0, 0, 1}},
+		{ChunkRef: storage.ChunkRef{Index: 0, Start: 0, End: 10}, Embedding: []float32{0, 0, 1}},
+	})
+	assert.Error(t, err)
+	edges, err := store.(storage.EdgeLister).ListEdges(ctx, chunkOfLabel)
+	require.NoError(t, err)
+	assert.Len(t, edges, 3)
+	results, err := store.QuerySimilar(ctx, []float32{0, 0, 1}, 2, nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, "transcript", results[0].ID)
+}
+
+func TestListEdges(t *testing.T) {
+	store, err := NewProvider(":memory:")
+	require.NoError(t, err)
+	defer store.Close()
+	
+	lister, ok := store.(storage.EdgeLister)
+	require.True(t, ok, "sqlite provider should implement EdgeLister")
+	graph := store.(storage.GraphStorage)
+	
+	ctx := context.Background()
+	require.NoError(t, graph.CreateEdge(ctx, "b", "a", "duplicate_of"))
+	require.NoError(t, graph.CreateEdge(ctx, "c", "a", "duplicate_of"))
+	require.NoError(t, graph.CreateEdge(ctx, "c", "d", "cites"))
+	
+	edges, err := lister.ListEdges(ctx, "duplicate_of")
+	require.NoError(t, err)
+	require.Len(t, edges, 2)
+	assert.Equal(t, "b", edges[0].SourceID)
+	assert.Equal(t, "c", edges[1].SourceID)
+	assert.Equal(t, "a", edges[1].TargetID)
+}
@@ -0,0 +1,92 @@
+package sqlite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/soaringjerry/pcas/internal/storage"
+)
+
+// initUsageSchema creates the table used for token usage accounting
+func (p *Provider) initUsageSchema() error {
+	createUsageTableSQL := `
+	CREATE TABLE IF NOT EXISTS usage (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id TEXT,
+		user_id TEXT,
+		source TEXT,
+		provider TEXT NOT NULL,
+		model TEXT,
+		prompt_tokens INTEGER NOT NULL DEFAULT 0,
+		completion_tokens INTEGER NOT NULL DEFAULT 0,
+		cost REAL NOT NULL DEFAULT 0,
+		created_at INTEGER NOT NULL -- Unix seconds, for cheap range queries
+	);
+	CREATE INDEX IF NOT EXISTS idx_usage_user_created ON usage(user_id, created_at);
+	CREATE INDEX IF NOT EXISTS idx_usage_source_created ON usage(source, created_at);
+	`
+	
+	if _, err := p.db.Exec(createUsageTableSQL); err != nil {
+		return fmt.Errorf("failed to create usage table: %w", err)
+	}
+	
+	return nil
+}
+
+// RecordUsage persists the usage of a single provider call
+func (p *Provider) RecordUsage(ctx context.Context, record storage.UsageRecord) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+	
+	query := `INSERT INTO usage (event_id, user_id, source, provider, model, prompt_tokens, completion_tokens, cost, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	_, err := p.db.ExecContext(ctx, query,
+		record.EventID, record.UserID, record.Source, record.Provider, record.Model,
+		record.PromptTokens, record.CompletionTokens, record.Cost, record.Time.Unix())
+	if err != nil {
+		return fmt.Errorf("failed to record usage: %w", err)
+	}
+	
+	return nil
+}
+
+// SumUsage returns the total usage matching the query
+func (p *Provider) SumUsage(ctx context.Context, query storage.UsageQuery) (storage.UsageTotals, error) {
+	whereConditions := []string{"created_at >= ?"}
+	args := []interface{}{query.Since.Unix()}
+	
+	if query.UserID != nil {
+		whereConditions = append(whereConditions, "user_id = ?")
+		args = append(args, *query.UserID)
+	}
+	
+	if query.Source != nil {
+		whereConditions = append(whereConditions, "source = ?")
+		args = append(args, *query.Source)
+	}
+	
+	if len(query.Providers) > 0 {
+		placeholders := make([]string, len(query.Providers))
+		for i, provider := range query.Providers {
+			placeholders[i] = "?"
+			args = append(args, provider)
+		}
+		whereConditions = append(whereConditions, fmt.Sprintf("provider IN (%s)", strings.Join(placeholders, ",")))
+	}
+	
+	sqlQuery := `
+		SELECT COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0), COALESCE(SUM(cost), 0)
+		FROM usage
+		WHERE ` + strings.Join(whereConditions, " AND ")
+	
+	var totals storage.UsageTotals
+	err := p.db.QueryRowContext(ctx, sqlQuery, args...).Scan(&totals.PromptTokens, &totals.CompletionTokens, &totals.Cost)
+	if err != nil {
+		return storage.UsageTotals{}, fmt.Errorf("failed to sum usage: %w", err)
+	}
+	
+	return totals, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// UsageStore stores the token usage and cost of provider calls, for budgets
+type UsageStore interface {
+	// RecordUsage persists the usage of a single provider call
+	RecordUsage(ctx context.Context, record UsageRecord) error
+	
+	// SumUsage returns the total usage matching the query
+	SumUsage(ctx context.Context, query UsageQuery) (UsageTotals, error)
+}
+
+// UsageRecord is the token usage and cost of a single provider call
+type UsageRecord struct {
+	EventID          string    // Event that triggered the call
+	UserID           string    // User the usage is attributed to
+	Source           string    // dApp that emitted the event
+	Provider         string    // Provider that served the call
+	Model            string    // Model reported by the provider
+	PromptTokens     int
+	CompletionTokens int
+	Cost             float64   // Cost in the currency of the configured pricing
+	Time             time.Time // When the call completed
+}
+
+// UsageQuery selects usage records to aggregate
+type UsageQuery struct {
+	UserID    *string   // Filter by user ID (nil means no filter)
+	Source    *string   // Filter by source (nil means no filter)
+	Providers []string  // Filter by providers (empty slice means no filter)
+	Since     time.Time // Only include usage at or after this time
+}
+
+// UsageTotals is the aggregated usage of a query
+type UsageTotals struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	Cost             float64
+}
+
+// TotalTokens returns the sum of prompt and completion tokens
+func (t UsageTotals) TotalTokens() int64 {
+	return t.PromptTokens + t.CompletionTokens
+}
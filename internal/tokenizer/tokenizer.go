@@ -0,0 +1,152 @@
+// Package tokenizer counts tokens the way the models PCAS routes to do, so that
+// context and budget limits hold for any language. Counting uses byte pair encoding
+// (BPE) with the encodings embedded in the binary, so it works offline.
+package tokenizer
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+)
+
+// Encodings supported by the BPE tokenizer
+const (
+	EncodingO200K  = "o200k_base"  // GPT-4o and newer
+	EncodingCL100K = "cl100k_base" // GPT-4, GPT-3.5 and embeddings
+	EncodingP50K   = "p50k_base"
+	EncodingR50K   = "r50k_base"
+	
+	// DefaultEncoding is used for models without a known encoding, e.g. local models.
+	// Its counts are close to those of most modern BPE vocabularies.
+	DefaultEncoding = EncodingCL100K
+)
+
+func init() {
+	// Load encodings from the embedded assets instead of downloading them
+	tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+}
+
+// Tokenizer counts the tokens of text
+type Tokenizer interface {
+	// CountTokens returns the number of tokens the text encodes to
+	CountTokens(text string) int
+	
+	// Name identifies the encoding, e.g. "cl100k_base"
+	Name() string
+}
+
+var (
+	encodingsMu sync.Mutex
+	encodings   = make(map[string]*bpeTokenizer)
+)
+
+// bpeTokenizer counts tokens with a tiktoken encoding
+type bpeTokenizer struct {
+	name     string
+	encoding *tiktoken.Tiktoken
+}
+
+// CountTokens returns the number of BPE tokens of the text
+func (t *bpeTokenizer) CountTokens(text string) int {
+	if text == "" {
+		return 0
+	}
+	return len(t.encoding.EncodeOrdinary(text))
+}
+
+// Name returns the encoding name
+func (t *bpeTokenizer) Name() string {
+	return t.name
+}
+
+// ForEncoding returns the BPE tokenizer for the named encoding. Encodings are
+// loaded once and shared, as loading takes a noticeable amount of time.
+func ForEncoding(name string) (Tokenizer, error) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	
+	if tokenizer, ok := encodings[name]; ok {
+		return tokenizer, nil
+	}
+	
+	encoding, err := tiktoken.GetEncoding(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load encoding %s: %w", name, err)
+	}
+	tokenizer := &bpeTokenizer{name: name, encoding: encoding}
+	encodings[name] = tokenizer
+	return tokenizer, nil
+}
+
+// ForModel returns the tokenizer for a model name such as "gpt-4o". The name may
+// also be an encoding name. Unknown models use DefaultEncoding; if no encoding can
+// be loaded at all, a character based estimate is returned.
+func ForModel(model string) Tokenizer {
+	name := DefaultEncoding
+	if encodingName, ok := tiktoken.MODEL_TO_ENCODING[model]; ok {
+		name = encodingName
+	} else if isEncoding(model) {
+		name = model
+	} else {
+		for prefix, encodingName := range tiktoken.MODEL_PREFIX_TO_ENCODING {
+			if strings.HasPrefix(model, prefix) {
+				name = encodingName
+				break
+			}
+		}
+	}
+	
+	tokenizer, err := ForEncoding(name)
+	if err != nil {
+		log.Printf("Falling back to token estimation for model %q: %v", model, err)
+		return Estimate()
+	}
+	return tokenizer
+}
+
+// isEncoding reports whether the name is a supported encoding
+func isEncoding(name string) bool {
+	switch name {
+	case EncodingO200K, EncodingCL100K, EncodingP50K, EncodingR50K:
+		return true
+	}
+	return false
+}
+
+// estimator approximates token counts without a vocabulary
+type estimator struct{}
+
+// Estimate returns a tokenizer that approximates counts without loading an encoding.
+// CJK characters count as one token each and other text as one token per four bytes.
+func Estimate() Tokenizer {
+	return estimator{}
+}
+
+// CountTokens estimates the number of tokens of the text
+func (estimator) CountTokens(text string) int {
+	cjk, other := 0, 0
+	for _, r := range text {
+		if isCJK(r) {
+			cjk++
+		} else {
+			other += utf8.RuneLen(r)
+		}
+	}
+	return cjk + (other+3)/4
+}
+
+// Name identifies the estimator
+func (estimator) Name() string {
+	return "estimate"
+}
+
+// isCJK reports whether the rune is a Chinese, Japanese or Korean character
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
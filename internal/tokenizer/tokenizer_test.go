@@ -0,0 +1,54 @@
+package tokenizer
+
+import "testing"
+
+func TestForModel(t *testing.T) {
+	testCases := []struct {
+		model          string
+		expectEncoding string
+	}{
+		{model: "gpt-4o", expectEncoding: EncodingO200K},
+		{model: "gpt-4o-2024-05-13", expectEncoding: EncodingO200K},
+		{model: "gpt-4", expectEncoding: EncodingCL100K},
+		{model: "p50k_base", expectEncoding: EncodingP50K},
+		{model: "llama3:8b", expectEncoding: DefaultEncoding},
+		{model: "", expectEncoding: DefaultEncoding},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.model, func(t *testing.T) {
+			if name := ForModel(tc.model).Name(); name != tc.expectEncoding {
+				t.Errorf("Expected encoding %s, got %s", tc.expectEncoding, name)
+			}
+		})
+	}
+}
+
+func TestCountTokens(t *testing.T) {
+	testCases := []struct {
+		name      string
+		tokenizer Tokenizer
+		text      string
+		expected  int
+	}{
+		{name: "empty", tokenizer: ForModel("gpt-4"), text: "", expected: 0},
+		{name: "english cl100k", tokenizer: ForModel("gpt-4"), text: "hello world", expected: 2},
+		{name: "english o200k", tokenizer: ForModel("gpt-4o"), text: "hello world", expected: 2},
+		{name: "estimate english", tokenizer: Estimate(), text: "hello world!", expected: 3},
+		{name: "estimate cjk", tokenizer: Estimate(), text: "今天天气很好", expected: 6},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if count := tc.tokenizer.CountTokens(tc.text); count != tc.expected {
+				t.Errorf("Expected %d tokens, got %d", tc.expected, count)
+			}
+		})
+	}
+
+	// CJK text takes far more tokens than the old len/4 estimate assumed
+	text := "我们的很多数据都是中文的，所以字符数除以四的估算严重偏低。"
+	if count := ForModel("gpt-4").CountTokens(text); count <= len(text)/4 {
+		t.Errorf("Expected CJK text to count more than %d tokens, got %d", len(text)/4, count)
+	}
+}
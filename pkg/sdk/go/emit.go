@@ -10,6 +10,7 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
 
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
 	eventsv1 "github.com/soaringjerry/pcas/gen/go/pcas/events/v1"
 )
 
@@ -18,6 +19,7 @@ type EmitOptions struct {
 	Source  string // Event source (defaults to "pcas-sdk")
 	Subject string // Optional event subject
 	TraceID string // Optional trace ID (auto-generated if not provided)
+	ID      string // Optional event ID (auto-generated if not provided); reuse it to retry an emit
 }
 
 // Emit sends an event to the PCAS event bus
@@ -25,8 +27,41 @@ func (c *Client) Emit(ctx context.Context, eventType string, data map[string]int
 	return c.EmitWithOptions(ctx, eventType, data, EmitOptions{})
 }
 
-// EmitWithOptions sends an event with additional options
+// EmitWithOptions sends an event with additional options. It returns once the
+// server has queued the event; the responses arrive on a subscription.
 func (c *Client) EmitWithOptions(ctx context.Context, eventType string, data map[string]interface{}, opts EmitOptions) error {
+	event, err := newEvent(eventType, data, opts)
+	if err != nil {
+		return err
+	}
+
+	// Publish the event
+	_, err = c.grpcClient.Publish(ctx, event)
+	if err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
+
+// EmitAndWait sends an event and waits for the pcas.response.v1 events
+// correlated with it, until the deadline of ctx. It returns no responses if no
+// rule routes the event type.
+func (c *Client) EmitAndWait(ctx context.Context, eventType string, data map[string]interface{}, opts EmitOptions) ([]*eventsv1.Event, error) {
+	event, err := newEvent(eventType, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.grpcClient.PublishAndWait(ctx, &busv1.PublishAndWaitRequest{Event: event})
+	if err != nil {
+		return nil, fmt.Errorf("failed to publish event: %w", err)
+	}
+	return resp.Responses, nil
+}
+
+// newEvent builds an event with the defaults of the options
+func newEvent(eventType string, data map[string]interface{}, opts EmitOptions) (*eventsv1.Event, error) {
 	// Set defaults
 	if opts.Source == "" {
 		opts.Source = "pcas-sdk"
@@ -34,10 +69,13 @@ func (c *Client) EmitWithOptions(ctx context.Context, eventType string, data map
 	if opts.TraceID == "" {
 		opts.TraceID = uuid.New().String()
 	}
+	if opts.ID == "" {
+		opts.ID = uuid.New().String()
+	}
 
 	// Create the event
 	event := &eventsv1.Event{
-		Id:          uuid.New().String(),
+		Id:          opts.ID,
 		Type:        eventType,
 		Source:      opts.Source,
 		Specversion: "1.0",
@@ -51,23 +89,17 @@ func (c *Client) EmitWithOptions(ctx context.Context, eventType string, data map
 		// Convert to structpb.Value
 		value, err := structpb.NewValue(data)
 		if err != nil {
-			return fmt.Errorf("failed to convert data to protobuf: %w", err)
+			return nil, fmt.Errorf("failed to convert data to protobuf: %w", err)
 		}
 
 		// Wrap in Any
 		anyData, err := anypb.New(value)
 		if err != nil {
-			return fmt.Errorf("failed to wrap data in Any: %w", err)
+			return nil, fmt.Errorf("failed to wrap data in Any: %w", err)
 		}
 
 		event.Data = anyData
 	}
 
-	// Publish the event
-	_, err := c.grpcClient.Publish(ctx, event)
-	if err != nil {
-		return fmt.Errorf("failed to publish event: %w", err)
-	}
-
-	return nil
-}
\ No newline at end of file
+	return event, nil
+}
@@ -0,0 +1,103 @@
+package pcas
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	busv1 "github.com/soaringjerry/pcas/gen/go/pcas/bus/v1"
+)
+
+// Error describes a failed PCAS request, as decoded from the gRPC status and
+// its details
+type Error struct {
+	Code       codes.Code
+	Reason     string // Machine-readable reason, e.g. "PROVIDER_RATE_LIMITED"
+	Message    string
+	Provider   string           // The provider that failed, if any
+	Retryable  bool             // Whether the same request may succeed later
+	RetryAfter time.Duration    // How long to wait before retrying, zero if unknown
+	Violations []FieldViolation // The invalid fields of a rejected request
+}
+
+// FieldViolation names an invalid field of a request and what is wrong with it
+type FieldViolation struct {
+	Field       string // e.g. "specversion" or "attributes"
+	Description string
+}
+
+func (e *Error) Error() string {
+	return e.Code.String() + ": " + e.Message
+}
+
+// ParseError decodes the error of a PCAS call. It returns nil for errors that
+// do not come from the server.
+func ParseError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var pcasErr *Error
+	if errors.As(err, &pcasErr) {
+		return pcasErr
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return nil
+	}
+
+	parsed := &Error{Code: st.Code(), Message: st.Message()}
+	for _, detail := range st.Details() {
+		switch d := detail.(type) {
+		case *errdetails.ErrorInfo:
+			parsed.Reason = d.Reason
+			parsed.Provider = d.Metadata["provider"]
+			parsed.Retryable, _ = strconv.ParseBool(d.Metadata["retryable"])
+		case *errdetails.RetryInfo:
+			parsed.RetryAfter = d.RetryDelay.AsDuration()
+		case *errdetails.BadRequest:
+			for _, violation := range d.FieldViolations {
+				parsed.Violations = append(parsed.Violations, FieldViolation{Field: violation.Field, Description: violation.Description})
+			}
+		}
+	}
+	return parsed
+}
+
+// StreamErrorOf decodes a StreamError received on an InteractStream, with the
+// same Reason ParseError returns for the status the stream ends with
+func StreamErrorOf(streamErr *busv1.StreamError) *Error {
+	if streamErr == nil {
+		return nil
+	}
+	reason := streamErr.ErrorReason
+	if reason == "" { // Servers that predate error_reason
+		reason = streamErr.Reason.String()
+	}
+	return &Error{
+		Code:       codes.Code(streamErr.Code),
+		Reason:     reason,
+		Message:    streamErr.Message,
+		Provider:   streamErr.Provider,
+		Retryable:  streamErr.Retryable,
+		RetryAfter: time.Duration(streamErr.RetryAfterMs) * time.Millisecond,
+	}
+}
+
+// IsRetryable reports whether a failed request may succeed when sent again later
+func IsRetryable(err error) bool {
+	parsed := ParseError(err)
+	return parsed != nil && parsed.Retryable
+}
+
+// RetryAfter returns how long to wait before retrying a failed request, or zero
+// if the server did not say
+func RetryAfter(err error) time.Duration {
+	if parsed := ParseError(err); parsed != nil {
+		return parsed.RetryAfter
+	}
+	return 0
+}